@@ -6,6 +6,8 @@ CREATE TABLE IF NOT EXISTS `users`(
                          `id` varchar(255) NOT NULL,
                          `username` varchar(255) NOT NULL UNIQUE,
                          `password` varchar(255) NOT NULL,
+                         `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                         `nsfw_mode` varchar(16) NOT NULL DEFAULT 'hide',
                          PRIMARY KEY (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
 
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"github.com/gomodule/redigo/redis"
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -11,12 +12,33 @@ import (
 	"html/template"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"reddit/pkg/audit"
+	"reddit/pkg/automod"
+	"reddit/pkg/blob"
+	"reddit/pkg/draft"
 	"reddit/pkg/handlers"
 	"reddit/pkg/idgenerator"
+	"reddit/pkg/lock"
+	"reddit/pkg/media"
+	"reddit/pkg/message"
 	"reddit/pkg/middleware"
+	"reddit/pkg/notification"
+	"reddit/pkg/outbox"
 	"reddit/pkg/post"
+	"reddit/pkg/preview"
+	"reddit/pkg/realtime"
+	"reddit/pkg/report"
+	"reddit/pkg/saved"
 	"reddit/pkg/session"
+	"reddit/pkg/spam"
 	"reddit/pkg/user"
+	"reddit/pkg/webhook"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 )
 
 func openMysql() (*sql.DB, error) {
@@ -27,6 +49,7 @@ func openMysql() (*sql.DB, error) {
 	dsn += "@tcp(mysql:3306)/golang?"
 	dsn += "&charset=utf8"
 	dsn += "&interpolateParams=true"
+	dsn += "&parseTime=true"
 
 	db, err := sql.Open("mysql", dsn)
 	if err != nil {
@@ -50,13 +73,63 @@ func openMongoDB() (*mongo.Client, error) {
 	return sess, nil
 }
 
-func openRedis() (redis.Conn, error) {
-	c, err := redis.DialURL("redis://user:@redis:6379/0")
+const (
+	redisURL = "redis://user:@redis:6379/0"
+	// redisIOTimeout - запасной срок на соединение, чтение и запись, если у команды нет своего контекста
+	redisIOTimeout = 5 * time.Second
+)
+
+// openRedisPool - каждая команда берет соединение из пула, отмена запроса закрывает только его
+func openRedisPool() *redis.Pool {
+	return &redis.Pool{
+		MaxIdle:     10,
+		IdleTimeout: 4 * time.Minute,
+		DialContext: func(ctx context.Context) (redis.Conn, error) {
+			return redis.DialURLContext(ctx, redisURL,
+				redis.DialConnectTimeout(redisIOTimeout),
+				redis.DialReadTimeout(redisIOTimeout),
+				redis.DialWriteTimeout(redisIOTimeout),
+			)
+		},
+	}
+}
+
+// dialRedisSubscription - подписке нельзя срок чтения, она ждет событий сколько угодно
+func dialRedisSubscription() (redis.Conn, error) {
+	return redis.DialURL(redisURL,
+		redis.DialConnectTimeout(redisIOTimeout),
+		redis.DialWriteTimeout(redisIOTimeout),
+	)
+}
+
+// pingRedis - без redis превью, события между репликами и планировщик не включаются
+func pingRedis(redisPool *redis.Pool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisIOTimeout)
+	defer cancel()
+	conn, err := redisPool.GetContext(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return c, nil
+	defer conn.Close()
+	_, err = redis.DoContext(conn, ctx, "PING")
+	return err
+}
 
+func openBlobStorage() (blob.Storage, error) {
+	if os.Getenv("BLOB_STORAGE") == "s3" {
+		return blob.NewS3Storage(
+			os.Getenv("S3_ENDPOINT"),
+			os.Getenv("S3_BUCKET"),
+			os.Getenv("S3_REGION"),
+			os.Getenv("S3_ACCESS_KEY"),
+			os.Getenv("S3_SECRET_KEY"),
+		), nil
+	}
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = "./media"
+	}
+	return blob.NewLocalStorage(mediaDir)
 }
 
 func main() {
@@ -74,6 +147,9 @@ func main() {
 		}
 	}(zapLogger)
 	logger := zapLogger.Sugar()
+	// ctx - корневой контекст фоновых задач, отменяется при остановке сервера
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	dbSQL, err := openMysql()
 	if err != nil {
@@ -84,23 +160,33 @@ func main() {
 	if err != nil {
 		logger.Infof("error on connection to mongoDB: %s", err.Error())
 	}
-	redisConn, err := openRedis()
-	if err != nil {
-		logger.Infof("error on connection to redis: %s", err.Error())
-	}
-	defer func(redisConn redis.Conn) {
-		err = redisConn.Close()
+	redisPool := openRedisPool()
+	defer func(redisPool *redis.Pool) {
+		err = redisPool.Close()
 		if err != nil {
 			logger.Infof("error on redis close: %s", err.Error())
 		}
-	}(redisConn)
+	}(redisPool)
+	errRedis := pingRedis(redisPool)
+	// dbTimeout - срок одной операции с базой, 0 - значение по умолчанию у каждого репозитория
+	var dbTimeout time.Duration
+	if timeout := os.Getenv("DB_TIMEOUT"); timeout != "" {
+		dbTimeout, err = time.ParseDuration(timeout)
+		if err != nil {
+			logger.Errorf("bad DB_TIMEOUT value: %s", err.Error())
+			return
+		}
+	}
 
-	dbMongoCollection := mongoSession.Database("golang").Collection("items")
+	mongoDB := mongoSession.Database("golang")
+	dbMongoCollection := mongoDB.Collection("items")
 	sessManMysql := session.SessionManagerMysql{
-		DB: dbSQL,
+		DB:      dbSQL,
+		Timeout: dbTimeout,
 	}
 	sessManRedis := session.SessionManagerRedis{
-		RedisConn: redisConn,
+		RedisPool: redisPool,
+		Timeout:   dbTimeout,
 	}
 	sessManDB := session.SessionManagerDB{
 		SessionManagerMS:  sessManMysql,
@@ -114,17 +200,267 @@ func main() {
 	clientHelper := &post.MongoClient{
 		Cl: mongoSession,
 	}
+	outboxCollection := &post.MongoCollection{
+		Coll: mongoDB.Collection("outbox"),
+	}
 	postDBRepo := post.PostDBRepo{
-		Posts: collectionHelper,
-		Sess:  clientHelper,
+		Posts:   collectionHelper,
+		Sess:    clientHelper,
+		Outbox:  outboxCollection,
+		Timeout: dbTimeout,
+	}
+	err = postDBRepo.EnsureIndexesDB()
+	if err != nil {
+		logger.Infof("error on posts indexes creation: %s", err.Error())
+	}
+	if flairsPath := os.Getenv("FLAIRS"); flairsPath != "" {
+		err = post.LoadFlairs(flairsPath)
+		if err != nil {
+			logger.Errorf("error on loading flairs: %s", err.Error())
+			return
+		}
 	}
 	userDBRepo := user.UserDBRepo{
-		DB: dbSQL,
+		DB:      dbSQL,
+		Timeout: dbTimeout,
 	}
 	IDGenerator := &idgenerator.RandomIDGenerator{}
 
 	userRepo := user.NewUserMemoryRepository(&userDBRepo, IDGenerator)
+	auditDBRepo := audit.AuditDBRepo{
+		Entries: &post.MongoCollection{
+			Coll: mongoDB.Collection("audit"),
+		},
+		Timeout: dbTimeout,
+	}
+	err = auditDBRepo.EnsureIndexesDB()
+	if err != nil {
+		logger.Infof("error on audit indexes creation: %s", err.Error())
+	}
+	auditRepo := audit.NewAuditBusinessLogic(&auditDBRepo)
+
 	postRepo := post.NewPostBusinessLogic(&postDBRepo, IDGenerator)
+	postRepo.Jobs = ctx
+	postRepo.Recorder = auditRepo
+	reportDBRepo := report.ReportDBRepo{
+		Reports: &post.MongoCollection{
+			Coll: mongoDB.Collection("reports"),
+		},
+		Timeout: dbTimeout,
+	}
+	err = reportDBRepo.EnsureIndexesDB()
+	if err != nil {
+		logger.Infof("error on reports indexes creation: %s", err.Error())
+	}
+	reportRepo := report.NewReportBusinessLogic(&reportDBRepo, postRepo, auditRepo)
+	reportRepo.Tx = &postDBRepo
+	postRepo.Queue = reportRepo
+	postRepo.Authors = userRepo
+	spamDBRepo := spam.ModelDBRepo{
+		Models: &post.MongoCollection{
+			Coll: mongoDB.Collection("spam_model"),
+		},
+		Tokens: &post.MongoCollection{
+			Coll: mongoDB.Collection("spam_tokens"),
+		},
+		Timeout: dbTimeout,
+	}
+	spamClassifier, err := spam.NewNaiveBayes(ctx, &spamDBRepo)
+	if err != nil {
+		logger.Errorf("error on loading spam model: %s", err.Error())
+		return
+	}
+	postRepo.Spam = spamClassifier
+	reportRepo.Spam = spamClassifier
+	go spamClassifier.RunRefresh(ctx, time.Minute, func(errRefresh error) {
+		logger.Errorf("error on refreshing spam model: %s", errRefresh.Error())
+	})
+	postRepo.SpamLimit = 0.9
+	if spamThreshold := os.Getenv("SPAM_THRESHOLD"); spamThreshold != "" {
+		postRepo.SpamLimit, err = strconv.ParseFloat(spamThreshold, 64)
+		if err != nil {
+			logger.Errorf("bad SPAM_THRESHOLD value: %s", err.Error())
+			return
+		}
+	}
+	if rulesPath := os.Getenv("AUTOMOD_RULES"); rulesPath != "" {
+		autoModerator, errAutoMod := automod.NewAutoModerator(rulesPath)
+		if errAutoMod != nil {
+			logger.Errorf("error on loading automoderator rules: %s", errAutoMod.Error())
+			return
+		}
+		postRepo.AutoMod = autoModerator
+		go autoModerator.Watch(ctx.Done(), 10*time.Second, func(errReload error) {
+			logger.Errorf("error on reloading automoderator rules: %s", errReload.Error())
+		})
+	}
+	if retention := os.Getenv("POST_RETENTION"); retention != "" {
+		postRepo.Retention, err = time.ParseDuration(retention)
+		if err != nil {
+			logger.Errorf("bad POST_RETENTION value: %s", err.Error())
+			return
+		}
+	}
+	if duplicateWindow := os.Getenv("DUPLICATE_WINDOW"); duplicateWindow != "" {
+		postRepo.DuplicateWindow, err = time.ParseDuration(duplicateWindow)
+		if err != nil {
+			logger.Errorf("bad DUPLICATE_WINDOW value: %s", err.Error())
+			return
+		}
+	}
+	postRepo.RejectDuplicates = os.Getenv("DUPLICATE_POLICY") != "warn"
+	if errRedis != nil {
+		logger.Infof("error on connection to redis for previews: %s", errRedis.Error())
+	} else {
+		postRepo.Previews = preview.NewFetcher(preview.NewRedisCache(redisPool))
+	}
+	postRepo.OnPreviewError = func(errPreview error) {
+		logger.Infof("error on link preview: %s", errPreview.Error())
+	}
+	if archiveAfter := os.Getenv("ARCHIVE_AFTER"); archiveAfter != "" {
+		postRepo.ArchiveAfter, err = time.ParseDuration(archiveAfter)
+		if err != nil {
+			logger.Errorf("bad ARCHIVE_AFTER value: %s", err.Error())
+			return
+		}
+	}
+	go postRepo.RunPurge(ctx, time.Hour, func(errPurge error) {
+		logger.Errorf("error on purging deleted posts: %s", errPurge.Error())
+	})
+	savedDBRepo := saved.SavedDBRepo{
+		Items: &post.MongoCollection{
+			Coll: mongoDB.Collection("saved"),
+		},
+		Timeout: dbTimeout,
+	}
+	err = savedDBRepo.EnsureIndexesDB()
+	if err != nil {
+		logger.Infof("error on saved items indexes creation: %s", err.Error())
+	}
+	savedRepo := saved.NewSavedBusinessLogic(&savedDBRepo, postRepo)
+	notificationDBRepo := notification.NotificationDBRepo{
+		Notifications: &post.MongoCollection{
+			Coll: mongoDB.Collection("notifications"),
+		},
+		TTL:     notification.DefaultTTL,
+		Timeout: dbTimeout,
+	}
+	if notificationTTL := os.Getenv("NOTIFICATION_TTL"); notificationTTL != "" {
+		notificationDBRepo.TTL, err = time.ParseDuration(notificationTTL)
+		if err != nil {
+			logger.Errorf("bad NOTIFICATION_TTL value: %s", err.Error())
+			return
+		}
+	}
+	err = notificationDBRepo.EnsureIndexesDB()
+	if err != nil {
+		logger.Infof("error on notifications indexes creation: %s", err.Error())
+	}
+	notificationRepo := notification.NewNotificationBusinessLogic(&notificationDBRepo, userRepo)
+	messageDBRepo := message.MessageDBRepo{
+		Messages: &post.MongoCollection{
+			Coll: mongoDB.Collection("messages"),
+		},
+		Blocks: &post.MongoCollection{
+			Coll: mongoDB.Collection("blocks"),
+		},
+		Timeout: dbTimeout,
+	}
+	err = messageDBRepo.EnsureIndexesDB()
+	if err != nil {
+		logger.Infof("error on messages indexes creation: %s", err.Error())
+	}
+	messageRepo := message.NewMessageBusinessLogic(&messageDBRepo, userRepo)
+	if rateLimit := os.Getenv("MESSAGE_RATE_LIMIT"); rateLimit != "" {
+		messageRepo.RateLimit, err = strconv.Atoi(rateLimit)
+		if err != nil {
+			logger.Errorf("bad MESSAGE_RATE_LIMIT value: %s", err.Error())
+			return
+		}
+	}
+	if rateWindow := os.Getenv("MESSAGE_RATE_WINDOW"); rateWindow != "" {
+		messageRepo.RateWindow, err = time.ParseDuration(rateWindow)
+		if err != nil {
+			logger.Errorf("bad MESSAGE_RATE_WINDOW value: %s", err.Error())
+			return
+		}
+	}
+	postRepo.Notifier = notificationRepo
+	realtimeHub := realtime.NewHub(nil)
+	if errRedis != nil {
+		logger.Infof("error on connection to redis for realtime events, events stay on this replica and streams will not resume: %s", errRedis.Error())
+	} else {
+		realtimeHub.Broker = realtime.NewRedisBroker(redisPool, dialRedisSubscription)
+		realtimeHub.History = realtime.NewRedisHistory(redisPool)
+	}
+	go realtimeHub.Run(ctx.Done(), func(errRealtime error) {
+		logger.Errorf("error on receiving realtime events: %s", errRealtime.Error())
+	})
+	postRepo.Events = realtimeHub
+	postRepo.OnEventError = func(errEvent error) {
+		logger.Infof("error on publishing realtime event: %s", errEvent.Error())
+	}
+	notificationRepo.Events = realtimeHub
+	postRepo.OnNotifyError = func(errNotify error) {
+		logger.Infof("error on sending notifications: %s", errNotify.Error())
+	}
+	draftDBRepo := draft.DraftDBRepo{
+		Drafts: &post.MongoCollection{
+			Coll: mongoDB.Collection("drafts"),
+		},
+		Timeout: dbTimeout,
+	}
+	err = draftDBRepo.EnsureIndexesDB()
+	if err != nil {
+		logger.Infof("error on drafts indexes creation: %s", err.Error())
+	}
+	draftRepo := draft.NewDraftBusinessLogic(&draftDBRepo, postRepo)
+	if errRedis != nil {
+		logger.Infof("error on connection to redis for scheduler: %s", errRedis.Error())
+	} else {
+		hostname, _ := os.Hostname()
+		schedulerLock := lock.NewRedisLock(redisPool, "lock:draft_scheduler", hostname+"-"+strconv.Itoa(os.Getpid()), 30*time.Second)
+		go draftRepo.RunScheduler(ctx, 10*time.Second, schedulerLock, func(errSchedule error) {
+			logger.Errorf("error on publishing scheduled posts: %s", errSchedule.Error())
+		})
+	}
+	webhookDBRepo := webhook.WebhookDBRepo{
+		Subscriptions: &post.MongoCollection{
+			Coll: mongoDB.Collection("webhooks"),
+		},
+		Deliveries: &post.MongoCollection{
+			Coll: mongoDB.Collection("webhook_deliveries"),
+		},
+		LogTTL:  webhook.DefaultLogTTL,
+		Timeout: dbTimeout,
+	}
+	err = webhookDBRepo.EnsureIndexesDB()
+	if err != nil {
+		logger.Infof("error on webhook indexes creation: %s", err.Error())
+	}
+	webhookRepo := webhook.NewWebhookBusinessLogic(&webhookDBRepo)
+	go webhookRepo.RunSender(ctx, 10*time.Second, func(errWebhook error) {
+		logger.Errorf("error on sending webhooks: %s", errWebhook.Error())
+	})
+	outboxDBRepo := outbox.OutboxDBRepo{
+		Events:  outboxCollection,
+		TTL:     outbox.DefaultTTL,
+		Timeout: dbTimeout,
+	}
+	err = outboxDBRepo.EnsureIndexesDB()
+	if err != nil {
+		logger.Infof("error on outbox indexes creation: %s", err.Error())
+	}
+	outboxRelay := outbox.NewRelay(&outboxDBRepo, webhookRepo)
+	go outboxRelay.Run(ctx, time.Second, func(errRelay error) {
+		logger.Errorf("error on relaying outbox events: %s", errRelay.Error())
+	})
+	moderators := user.NewModerators(strings.Split(os.Getenv("MODERATORS"), ","))
+	blobStorage, err := openBlobStorage()
+	if err != nil {
+		logger.Errorf("error on blob storage initialization: %s", err.Error())
+		return
+	}
 
 	userHandler := handlers.UserHandler{
 		UserRepo:       userRepo,
@@ -133,8 +469,58 @@ func main() {
 	}
 
 	postHandler := handlers.PostHandler{
-		PostRepo: postRepo,
-		Logger:   logger,
+		PostRepo:   postRepo,
+		UserRepo:   userRepo,
+		Uploader:   media.NewUploader(blobStorage),
+		Moderators: moderators,
+		SavedRepo:  savedRepo,
+		Logger:     logger,
+		PublicURL:  os.Getenv("PUBLIC_URL"),
+	}
+
+	draftHandler := handlers.DraftHandler{
+		DraftRepo: draftRepo,
+		Logger:    logger,
+	}
+
+	savedHandler := handlers.SavedHandler{
+		SavedRepo: savedRepo,
+		Logger:    logger,
+	}
+
+	notificationHandler := handlers.NotificationHandler{
+		NotificationRepo: notificationRepo,
+		Logger:           logger,
+	}
+
+	realtimeHandler := handlers.RealtimeHandler{
+		Hub:    realtimeHub,
+		Logger: logger,
+	}
+
+	messageHandler := handlers.MessageHandler{
+		MessageRepo: messageRepo,
+		Logger:      logger,
+	}
+
+	reportHandler := handlers.ReportHandler{
+		ReportRepo: reportRepo,
+		Logger:     logger,
+	}
+
+	mediaHandler := handlers.MediaHandler{
+		Storage: blobStorage,
+		Logger:  logger,
+	}
+
+	auditHandler := handlers.AuditHandler{
+		AuditRepo: auditRepo,
+		Logger:    logger,
+	}
+
+	webhookHandler := handlers.WebhookHandler{
+		WebhookRepo: webhookRepo,
+		Logger:      logger,
 	}
 
 	router := mux.NewRouter()
@@ -143,10 +529,17 @@ func main() {
 	staticDir := "./06_databases/99_hw/redditclone/static"
 	staticRouter.PathPrefix("/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir(staticDir))))
 
-	router.HandleFunc("/api/posts/", postHandler.List).Methods(http.MethodGet)
-	router.HandleFunc("/api/posts/{CATEGORY_NAME}", postHandler.ListByCategory).Methods(http.MethodGet)
-	router.HandleFunc("/api/post/{POST_ID}", postHandler.GetPostInfo).Methods(http.MethodGet)
-	router.HandleFunc("/api/user/{USER_LOGIN}", postHandler.ListByUserLogin).Methods(http.MethodGet)
+	router.HandleFunc(handlers.MediaPrefix+"{KEY}", mediaHandler.Serve).Methods(http.MethodGet, http.MethodHead)
+
+	router.Handle("/api/posts/", middleware.OptionalAuth(logger, sessionManager, http.HandlerFunc(postHandler.List))).Methods(http.MethodGet)
+	// ленты раньше общих маршрутов, иначе music.rss станет названием категории
+	router.HandleFunc("/api/posts/{CATEGORY_NAME}.rss", postHandler.CategoryRSS).Methods(http.MethodGet, http.MethodHead)
+	router.HandleFunc("/api/posts/{CATEGORY_NAME}.atom", postHandler.CategoryAtom).Methods(http.MethodGet, http.MethodHead)
+	router.HandleFunc("/api/user/{USER_LOGIN}.atom", postHandler.UserAtom).Methods(http.MethodGet, http.MethodHead)
+	router.Handle("/api/posts/{CATEGORY_NAME}", middleware.OptionalAuth(logger, sessionManager, http.HandlerFunc(postHandler.ListByCategory))).Methods(http.MethodGet)
+	router.Handle("/api/post/{POST_ID}", middleware.OptionalAuth(logger, sessionManager, http.HandlerFunc(postHandler.GetPostInfo))).Methods(http.MethodGet)
+	router.Handle("/api/user/{USER_LOGIN}", middleware.OptionalAuth(logger, sessionManager, http.HandlerFunc(postHandler.ListByUserLogin))).Methods(http.MethodGet)
+	router.HandleFunc("/api/flairs/{CATEGORY_NAME}", postHandler.ListFlairs).Methods(http.MethodGet)
 
 	router.HandleFunc("/api/register", userHandler.Register).Methods(http.MethodPost)
 	router.HandleFunc("/api/login", userHandler.Login).Methods(http.MethodPost)
@@ -154,22 +547,119 @@ func main() {
 	// нужна авторизация
 
 	rAuth := mux.NewRouter()
+	router.Handle("/api/post/{POST_ID}/{COMMENT_ID}/report", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodPost)
+	router.Handle("/api/post/{POST_ID}/report", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodPost)
+	router.Handle("/api/post/{POST_ID}/restore", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodPost)
+	router.Handle("/api/post/{POST_ID}/marks", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodPost)
+	router.Handle("/api/post/{POST_ID}/poll", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodPost)
+	router.Handle("/api/post/{POST_ID}/crosspost", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodPost)
+	router.Handle("/api/user/me/preferences", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodGet, http.MethodPut)
+	router.Handle("/api/user/me/saved", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodGet)
+	router.HandleFunc("/api/posts/{CATEGORY_NAME}/stream", realtimeHandler.CategoryStream).Methods(http.MethodGet)
+	router.HandleFunc("/api/post/{POST_ID}/stream", realtimeHandler.PostStream).Methods(http.MethodGet)
+	router.Handle("/api/ws", middleware.QueryToken(middleware.OptionalAuth(logger, sessionManager, http.HandlerFunc(realtimeHandler.Connect)))).Methods(http.MethodGet)
+	router.Handle("/api/notifications", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodGet)
+	router.Handle("/api/notifications/read", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodPost)
+	router.Handle("/api/notifications/{NOTIFICATION_ID}/read", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodPost)
+	router.Handle("/api/messages", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodPost)
+	router.Handle("/api/messages/inbox", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodGet)
+	router.Handle("/api/messages/outbox", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodGet)
+	router.Handle("/api/messages/threads", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodGet)
+	router.Handle("/api/messages/threads/{USER_LOGIN}", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodGet)
+	router.Handle("/api/messages/{MESSAGE_ID}/read", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodPost)
+	router.Handle("/api/messages/{MESSAGE_ID}", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodDelete)
+	router.Handle("/api/user/me/blocks", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodGet)
+	router.Handle("/api/user/{USER_LOGIN}/block", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodPost, http.MethodDelete)
+	router.Handle("/api/drafts", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodGet, http.MethodPost)
+	router.Handle("/api/drafts/{DRAFT_ID}", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodGet, http.MethodPut, http.MethodDelete)
+	router.Handle("/api/drafts/{DRAFT_ID}/publish", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodPost)
+	router.Handle("/api/post/{POST_ID}/save", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodPost, http.MethodDelete)
+	router.Handle("/api/post/{POST_ID}/hide", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodPost, http.MethodDelete)
+	router.Handle("/api/post/{POST_ID}/{COMMENT_ID}/save", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodPost, http.MethodDelete)
+	router.Handle("/api/post/{POST_ID}/{COMMENT_ID}/hide", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodPost, http.MethodDelete)
 	router.Handle("/api/post/{POST_ID}/{COMMENT_ID}", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodDelete)
 	router.Handle("/api/post/{POST_ID}/upvote", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodGet)
 	router.Handle("/api/post/{POST_ID}/downvote", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodGet)
 	router.Handle("/api/post/{POST_ID}/unvote", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodGet)
 	router.Handle("/api/post/{POST_ID}", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodDelete)
 	router.Handle("/api/posts", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodPost)
+	router.Handle("/api/posts/image", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodPost)
 	router.Handle("/api/post/{POST_ID}", middleware.Auth(logger, sessionManager, rAuth)).Methods(http.MethodPost)
 
+	rAuth.HandleFunc("/api/post/{POST_ID}/{COMMENT_ID}/report", reportHandler.ReportComment).Methods(http.MethodPost)
+	rAuth.HandleFunc("/api/post/{POST_ID}/report", reportHandler.ReportPost).Methods(http.MethodPost)
+	rAuth.HandleFunc("/api/post/{POST_ID}/restore", postHandler.RestorePost).Methods(http.MethodPost)
+	rAuth.HandleFunc("/api/post/{POST_ID}/marks", postHandler.SetMarks).Methods(http.MethodPost)
+	rAuth.HandleFunc("/api/post/{POST_ID}/poll", postHandler.VotePoll).Methods(http.MethodPost)
+	rAuth.HandleFunc("/api/post/{POST_ID}/crosspost", postHandler.Crosspost).Methods(http.MethodPost)
+	rAuth.HandleFunc("/api/user/me/preferences", userHandler.GetPreferences).Methods(http.MethodGet)
+	rAuth.HandleFunc("/api/user/me/preferences", userHandler.SetPreferences).Methods(http.MethodPut)
+	rAuth.HandleFunc("/api/user/me/saved", savedHandler.List).Methods(http.MethodGet)
+	rAuth.HandleFunc("/api/notifications", notificationHandler.List).Methods(http.MethodGet)
+	rAuth.HandleFunc("/api/notifications/read", notificationHandler.MarkAllRead).Methods(http.MethodPost)
+	rAuth.HandleFunc("/api/notifications/{NOTIFICATION_ID}/read", notificationHandler.MarkRead).Methods(http.MethodPost)
+	rAuth.HandleFunc("/api/messages", messageHandler.Send).Methods(http.MethodPost)
+	rAuth.HandleFunc("/api/messages/inbox", messageHandler.Inbox).Methods(http.MethodGet)
+	rAuth.HandleFunc("/api/messages/outbox", messageHandler.Outbox).Methods(http.MethodGet)
+	rAuth.HandleFunc("/api/messages/threads", messageHandler.Threads).Methods(http.MethodGet)
+	rAuth.HandleFunc("/api/messages/threads/{USER_LOGIN}", messageHandler.Thread).Methods(http.MethodGet)
+	rAuth.HandleFunc("/api/messages/{MESSAGE_ID}/read", messageHandler.MarkRead).Methods(http.MethodPost)
+	rAuth.HandleFunc("/api/messages/{MESSAGE_ID}", messageHandler.Delete).Methods(http.MethodDelete)
+	rAuth.HandleFunc("/api/user/me/blocks", messageHandler.Blocks).Methods(http.MethodGet)
+	rAuth.HandleFunc("/api/user/{USER_LOGIN}/block", messageHandler.Block).Methods(http.MethodPost)
+	rAuth.HandleFunc("/api/user/{USER_LOGIN}/block", messageHandler.Unblock).Methods(http.MethodDelete)
+	rAuth.HandleFunc("/api/drafts", draftHandler.List).Methods(http.MethodGet)
+	rAuth.HandleFunc("/api/drafts", draftHandler.Create).Methods(http.MethodPost)
+	rAuth.HandleFunc("/api/drafts/{DRAFT_ID}", draftHandler.Get).Methods(http.MethodGet)
+	rAuth.HandleFunc("/api/drafts/{DRAFT_ID}", draftHandler.Update).Methods(http.MethodPut)
+	rAuth.HandleFunc("/api/drafts/{DRAFT_ID}", draftHandler.Delete).Methods(http.MethodDelete)
+	rAuth.HandleFunc("/api/drafts/{DRAFT_ID}/publish", draftHandler.Publish).Methods(http.MethodPost)
+	rAuth.HandleFunc("/api/post/{POST_ID}/save", savedHandler.Save).Methods(http.MethodPost)
+	rAuth.HandleFunc("/api/post/{POST_ID}/save", savedHandler.Unsave).Methods(http.MethodDelete)
+	rAuth.HandleFunc("/api/post/{POST_ID}/hide", savedHandler.Hide).Methods(http.MethodPost)
+	rAuth.HandleFunc("/api/post/{POST_ID}/hide", savedHandler.Unhide).Methods(http.MethodDelete)
+	rAuth.HandleFunc("/api/post/{POST_ID}/{COMMENT_ID}/save", savedHandler.Save).Methods(http.MethodPost)
+	rAuth.HandleFunc("/api/post/{POST_ID}/{COMMENT_ID}/save", savedHandler.Unsave).Methods(http.MethodDelete)
+	rAuth.HandleFunc("/api/post/{POST_ID}/{COMMENT_ID}/hide", savedHandler.Hide).Methods(http.MethodPost)
+	rAuth.HandleFunc("/api/post/{POST_ID}/{COMMENT_ID}/hide", savedHandler.Unhide).Methods(http.MethodDelete)
 	rAuth.HandleFunc("/api/post/{POST_ID}/{COMMENT_ID}", postHandler.DeleteComment).Methods(http.MethodDelete)
 	rAuth.HandleFunc("/api/post/{POST_ID}/upvote", postHandler.MakeVote).Methods(http.MethodGet)
 	rAuth.HandleFunc("/api/post/{POST_ID}/downvote", postHandler.MakeVote).Methods(http.MethodGet)
 	rAuth.HandleFunc("/api/post/{POST_ID}/unvote", postHandler.MakeVote).Methods(http.MethodGet)
 	rAuth.HandleFunc("/api/post/{POST_ID}", postHandler.DeletePost).Methods(http.MethodDelete)
 	rAuth.HandleFunc("/api/posts", postHandler.NewPost).Methods(http.MethodPost)
+	rAuth.HandleFunc("/api/posts/image", postHandler.NewImagePost).Methods(http.MethodPost)
 	rAuth.HandleFunc("/api/post/{POST_ID}", postHandler.NewComment).Methods(http.MethodPost)
 
+	// нужны права модератора
+
+	rModer := mux.NewRouter()
+	router.Handle("/api/moderation/queue", middleware.Auth(logger, sessionManager, middleware.Moderator(logger, moderators, rModer))).Methods(http.MethodGet)
+	router.Handle("/api/moderation/queue/{ITEM_ID}/approve", middleware.Auth(logger, sessionManager, middleware.Moderator(logger, moderators, rModer))).Methods(http.MethodPost)
+	router.Handle("/api/moderation/queue/{ITEM_ID}/remove", middleware.Auth(logger, sessionManager, middleware.Moderator(logger, moderators, rModer))).Methods(http.MethodPost)
+	router.Handle("/api/moderation/log", middleware.Auth(logger, sessionManager, middleware.Moderator(logger, moderators, rModer))).Methods(http.MethodGet)
+	router.Handle("/api/moderation/post/{POST_ID}/lock", middleware.Auth(logger, sessionManager, middleware.Moderator(logger, moderators, rModer))).Methods(http.MethodPost)
+	router.Handle("/api/moderation/post/{POST_ID}/unlock", middleware.Auth(logger, sessionManager, middleware.Moderator(logger, moderators, rModer))).Methods(http.MethodPost)
+
+	router.Handle("/api/moderation/webhooks", middleware.Auth(logger, sessionManager, middleware.Moderator(logger, moderators, rModer))).Methods(http.MethodGet, http.MethodPost)
+	router.Handle("/api/moderation/webhooks/dead", middleware.Auth(logger, sessionManager, middleware.Moderator(logger, moderators, rModer))).Methods(http.MethodGet)
+	router.Handle("/api/moderation/webhooks/deliveries/{DELIVERY_ID}/retry", middleware.Auth(logger, sessionManager, middleware.Moderator(logger, moderators, rModer))).Methods(http.MethodPost)
+	router.Handle("/api/moderation/webhooks/{WEBHOOK_ID}", middleware.Auth(logger, sessionManager, middleware.Moderator(logger, moderators, rModer))).Methods(http.MethodDelete)
+	router.Handle("/api/moderation/webhooks/{WEBHOOK_ID}/deliveries", middleware.Auth(logger, sessionManager, middleware.Moderator(logger, moderators, rModer))).Methods(http.MethodGet)
+
+	rModer.HandleFunc("/api/moderation/queue", reportHandler.Queue).Methods(http.MethodGet)
+	rModer.HandleFunc("/api/moderation/queue/{ITEM_ID}/approve", reportHandler.Approve).Methods(http.MethodPost)
+	rModer.HandleFunc("/api/moderation/queue/{ITEM_ID}/remove", reportHandler.Remove).Methods(http.MethodPost)
+	rModer.HandleFunc("/api/moderation/log", auditHandler.List).Methods(http.MethodGet)
+	rModer.HandleFunc("/api/moderation/post/{POST_ID}/lock", postHandler.Lock).Methods(http.MethodPost)
+	rModer.HandleFunc("/api/moderation/post/{POST_ID}/unlock", postHandler.Unlock).Methods(http.MethodPost)
+	rModer.HandleFunc("/api/moderation/webhooks", webhookHandler.List).Methods(http.MethodGet)
+	rModer.HandleFunc("/api/moderation/webhooks", webhookHandler.Subscribe).Methods(http.MethodPost)
+	rModer.HandleFunc("/api/moderation/webhooks/dead", webhookHandler.DeadLetters).Methods(http.MethodGet)
+	rModer.HandleFunc("/api/moderation/webhooks/deliveries/{DELIVERY_ID}/retry", webhookHandler.Redeliver).Methods(http.MethodPost)
+	rModer.HandleFunc("/api/moderation/webhooks/{WEBHOOK_ID}", webhookHandler.Unsubscribe).Methods(http.MethodDelete)
+	rModer.HandleFunc("/api/moderation/webhooks/{WEBHOOK_ID}/deliveries", webhookHandler.Deliveries).Methods(http.MethodGet)
+
 	accessLogRouter := middleware.AccessLog(logger, router)
 	errorLogRouter := middleware.ErrorLog(logger, accessLogRouter)
 	mux := middleware.Panic(logger, errorLogRouter)
@@ -187,8 +677,20 @@ func main() {
 		"type", "START",
 		"addr", addr,
 	)
-	err = http.ListenAndServe(addr, mux)
-	if err != nil {
+	server := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if errShutdown := server.Shutdown(shutdownCtx); errShutdown != nil {
+			logger.Infof("error on server shutdown: %s", errShutdown.Error())
+		}
+	}()
+	err = server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		logger.Infof("errror in server start")
 		return
 	}
@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"reddit/pkg/user"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 200
+)
+
+type AuditRepo interface {
+	RecordAction(ctx context.Context, actor *user.User, action, postID, commentID, category, reason string) error
+	GetEntries(ctx context.Context, filter *Filter) ([]*Entry, error)
+}
+
+type Entry struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	ActorID   string             `json:"actorId" bson:"actorId"`
+	Actor     string             `json:"actor" bson:"actor"`
+	Action    string             `json:"action" bson:"action"`
+	PostID    string             `json:"postId" bson:"postId"`
+	CommentID string             `json:"commentId,omitempty" bson:"commentId,omitempty"`
+	Category  string             `json:"category" bson:"category"`
+	Reason    string             `json:"reason,omitempty" bson:"reason,omitempty"`
+	Created   time.Time          `json:"created" bson:"created"`
+}
+
+type Filter struct {
+	Category string
+	Actor    string
+	Action   string
+	Page     int
+	Limit    int
+}
+
+func (f *Filter) normalize() {
+	if f.Page < 1 {
+		f.Page = 1
+	}
+	if f.Limit < 1 {
+		f.Limit = defaultLimit
+	}
+	if f.Limit > maxLimit {
+		f.Limit = maxLimit
+	}
+}
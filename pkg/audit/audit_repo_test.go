@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"reddit/pkg/post"
+	"reddit/pkg/user"
+)
+
+func TestRecordAction(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := post.NewMockCollectionHelper(ctrl)
+	testRepo := NewAuditBusinessLogic(&AuditDBRepo{Entries: testCollection})
+	moderator := &user.User{ID: "moderatorID", Username: "moderator"}
+
+	// ошибка записи в монго
+	testCollection.EXPECT().InsertOne(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("error"))
+	err := testRepo.RecordAction(context.Background(), moderator, post.ActionRemovePost, "postID", "", "music", "spam")
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+
+	// запись добавлена
+	testCollection.EXPECT().InsertOne(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, document interface{}) (interface{}, error) {
+		entry, ok := document.(*Entry)
+		if !ok {
+			t.Fatalf("unexpected document type %T", document)
+		}
+		if entry.Actor != "moderator" || entry.ActorID != "moderatorID" || entry.Action != post.ActionRemovePost ||
+			entry.PostID != "postID" || entry.Category != "music" || entry.Reason != "spam" || entry.Created.IsZero() {
+			t.Errorf("wrong entry: %v", entry)
+		}
+		return "any", nil
+	})
+	err = testRepo.RecordAction(context.Background(), moderator, post.ActionRemovePost, "postID", "", "music", "spam")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+}
+
+func TestGetEntries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := post.NewMockCollectionHelper(ctrl)
+	testRepo := NewAuditBusinessLogic(&AuditDBRepo{Entries: testCollection})
+
+	// какая то ошибка в монго
+	testCollection.EXPECT().Find(gomock.Any(), bson.M{}, gomock.Any()).Return(nil, fmt.Errorf("error"))
+	_, err := testRepo.GetEntries(context.Background(), &Filter{})
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+
+	// фильтр по категории и модератору, пагинация нормализуется
+	cursor, err := mongo.NewCursorFromDocuments([]interface{}{&Entry{Action: post.ActionApprove}}, nil, nil)
+	if err != nil {
+		t.Fatalf("error on cursor creation")
+		return
+	}
+	filter := &Filter{Category: "music", Actor: "moderator", Page: -1, Limit: 1000}
+	testCollection.EXPECT().Find(gomock.Any(), bson.M{"category": "music", "actor": "moderator"}, gomock.Any()).Return(cursor, nil)
+	entries, err := testRepo.GetEntries(context.Background(), filter)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if len(entries) != 1 {
+		t.Errorf("wrong number of entries: expected %d, got %d", 1, len(entries))
+		return
+	}
+	if filter.Page != 1 || filter.Limit != maxLimit {
+		t.Errorf("pagination was not normalized: page %d, limit %d", filter.Page, filter.Limit)
+		return
+	}
+}
+
+func TestRecordActionContext(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := post.NewMockCollectionHelper(ctrl)
+	testRepo := NewAuditBusinessLogic(&AuditDBRepo{Entries: testCollection, Timeout: 10 * time.Millisecond})
+	moderator := &user.User{ID: "moderatorID", Username: "moderator"}
+	// монга отвечает только по отмене контекста
+	testCollection.EXPECT().InsertOne(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, _ interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}).Times(2)
+
+	// клиент отключился
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := testRepo.RecordAction(ctx, moderator, post.ActionRemovePost, "postID", "", "music", "spam")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+		return
+	}
+
+	// база не ответила за Timeout
+	err = testRepo.RecordAction(context.Background(), moderator, post.ActionRemovePost, "postID", "", "music", "spam")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		return
+	}
+}
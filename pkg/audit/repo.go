@@ -0,0 +1,44 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"reddit/pkg/user"
+)
+
+type AuditDBRepository interface {
+	AddEntryDB(ctx context.Context, entry *Entry) error
+	GetEntriesDB(ctx context.Context, filter *Filter) ([]*Entry, error)
+}
+
+type AuditBusinessLogic struct {
+	AuditDBRepo AuditDBRepository
+}
+
+func NewAuditBusinessLogic(repo AuditDBRepository) *AuditBusinessLogic {
+	return &AuditBusinessLogic{
+		AuditDBRepo: repo,
+	}
+}
+
+func (a *AuditBusinessLogic) RecordAction(ctx context.Context, actor *user.User, action, postID, commentID, category, reason string) error {
+	entry := &Entry{
+		Action:    action,
+		PostID:    postID,
+		CommentID: commentID,
+		Category:  category,
+		Reason:    reason,
+		Created:   time.Now().UTC(),
+	}
+	if actor != nil {
+		entry.ActorID = actor.ID
+		entry.Actor = actor.Username
+	}
+	return a.AuditDBRepo.AddEntryDB(ctx, entry)
+}
+
+func (a *AuditBusinessLogic) GetEntries(ctx context.Context, filter *Filter) ([]*Entry, error) {
+	filter.normalize()
+	return a.AuditDBRepo.GetEntriesDB(ctx, filter)
+}
@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reddit/pkg/post"
+)
+
+type AuditDBRepo struct {
+	Entries post.CollectionHelper
+	// Timeout - срок одной операции, 0 - post.DefaultTimeout
+	Timeout time.Duration
+}
+
+func (a *AuditDBRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return post.WithTimeout(ctx, a.Timeout)
+}
+
+func (a *AuditDBRepo) EnsureIndexesDB() error {
+	return a.Entries.CreateIndexes(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "created", Value: -1}}},
+		{Keys: bson.D{{Key: "category", Value: 1}, {Key: "created", Value: -1}}},
+		{Keys: bson.D{{Key: "actor", Value: 1}, {Key: "created", Value: -1}}},
+	})
+}
+
+func (a *AuditDBRepo) AddEntryDB(ctx context.Context, entry *Entry) error {
+	ctx, cancel := a.withTimeout(ctx)
+	defer cancel()
+	entry.ID = primitive.NewObjectID()
+	_, err := a.Entries.InsertOne(ctx, entry)
+	return err
+}
+
+func (a *AuditDBRepo) GetEntriesDB(ctx context.Context, filter *Filter) ([]*Entry, error) {
+	ctx, cancel := a.withTimeout(ctx)
+	defer cancel()
+	entries := make([]*Entry, 0)
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created", Value: -1}}).
+		SetSkip(int64((filter.Page - 1) * filter.Limit)).
+		SetLimit(int64(filter.Limit))
+	result, err := a.Entries.Find(ctx, buildFilter(filter), opts)
+	if err != nil {
+		return nil, err
+	}
+	err = result.All(ctx, &entries)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func buildFilter(filter *Filter) bson.M {
+	mongoFilter := bson.M{}
+	if filter.Category != "" {
+		mongoFilter["category"] = filter.Category
+	}
+	if filter.Actor != "" {
+		mongoFilter["actor"] = filter.Actor
+	}
+	if filter.Action != "" {
+		mongoFilter["action"] = filter.Action
+	}
+	return mongoFilter
+}
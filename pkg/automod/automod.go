@@ -0,0 +1,224 @@
+package automod
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	KindPost    = "post"
+	KindComment = "comment"
+
+	ActionReject = "reject"
+	ActionHold   = "hold"
+	ActionFlair  = "flair"
+	ActionReport = "report"
+
+	AllCategories = "*"
+)
+
+var ErrBadRule = errors.New("bad automoderator rule")
+
+type AuthorStats interface {
+	AccountAge() (time.Duration, error)
+	Karma() (int, error)
+}
+
+type Content struct {
+	Kind     string
+	Category string
+	Title    string
+	Body     string
+	URL      string
+	Author   AuthorStats
+}
+
+type Decision struct {
+	Reject  bool
+	Message string
+	Hold    bool
+	Report  bool
+	Flair   string
+	Rules   []string
+}
+
+func (d *Decision) Reason() string {
+	return "automod: " + strings.Join(d.Rules, ", ")
+}
+
+type Conditions struct {
+	TitleRegex         string   `json:"titleRegex,omitempty" yaml:"titleRegex,omitempty"`
+	BodyRegex          string   `json:"bodyRegex,omitempty" yaml:"bodyRegex,omitempty"`
+	Domains            []string `json:"domains,omitempty" yaml:"domains,omitempty"`
+	AccountAgeLessThan string   `json:"accountAgeLessThan,omitempty" yaml:"accountAgeLessThan,omitempty"`
+	KarmaBelow         *int     `json:"karmaBelow,omitempty" yaml:"karmaBelow,omitempty"`
+}
+
+type Action struct {
+	Type    string `json:"type" yaml:"type"`
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+	Flair   string `json:"flair,omitempty" yaml:"flair,omitempty"`
+}
+
+type Rule struct {
+	Name       string     `json:"name" yaml:"name"`
+	Kinds      []string   `json:"kinds,omitempty" yaml:"kinds,omitempty"`
+	Conditions Conditions `json:"conditions" yaml:"conditions"`
+	Action     Action     `json:"action" yaml:"action"`
+
+	titleRe    *regexp.Regexp
+	bodyRe     *regexp.Regexp
+	accountAge time.Duration
+}
+
+type RuleSet struct {
+	Categories map[string][]*Rule `json:"categories" yaml:"categories"`
+}
+
+func (rs *RuleSet) compile() error {
+	for category, rules := range rs.Categories {
+		for _, rule := range rules {
+			err := rule.compile()
+			if err != nil {
+				return fmt.Errorf("%w: category %s, rule %q: %s", ErrBadRule, category, rule.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Rule) compile() error {
+	var err error
+	cond := r.Conditions
+	if cond.TitleRegex == "" && cond.BodyRegex == "" && len(cond.Domains) == 0 &&
+		cond.AccountAgeLessThan == "" && cond.KarmaBelow == nil {
+		return errors.New("rule has no conditions")
+	}
+	if cond.TitleRegex != "" {
+		r.titleRe, err = regexp.Compile(cond.TitleRegex)
+		if err != nil {
+			return err
+		}
+	}
+	if cond.BodyRegex != "" {
+		r.bodyRe, err = regexp.Compile(cond.BodyRegex)
+		if err != nil {
+			return err
+		}
+	}
+	if cond.AccountAgeLessThan != "" {
+		r.accountAge, err = time.ParseDuration(cond.AccountAgeLessThan)
+		if err != nil {
+			return err
+		}
+	}
+	for _, kind := range r.Kinds {
+		if kind != KindPost && kind != KindComment {
+			return fmt.Errorf("unknown kind %q", kind)
+		}
+	}
+	switch r.Action.Type {
+	case ActionReject, ActionHold, ActionReport:
+	case ActionFlair:
+		if r.Action.Flair == "" {
+			return errors.New("flair action without flair")
+		}
+	default:
+		return fmt.Errorf("unknown action %q", r.Action.Type)
+	}
+	return nil
+}
+
+func (rs *RuleSet) Evaluate(content *Content) (*Decision, error) {
+	decision := &Decision{}
+	rules := make([]*Rule, 0, len(rs.Categories[AllCategories])+len(rs.Categories[content.Category]))
+	rules = append(rules, rs.Categories[AllCategories]...)
+	rules = append(rules, rs.Categories[content.Category]...)
+	for _, rule := range rules {
+		matched, err := rule.matches(content)
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			continue
+		}
+		decision.Rules = append(decision.Rules, rule.Name)
+		switch rule.Action.Type {
+		case ActionReject:
+			decision.Reject = true
+			decision.Message = rule.Action.Message
+			return decision, nil
+		case ActionHold:
+			decision.Hold = true
+		case ActionReport:
+			decision.Report = true
+		case ActionFlair:
+			if decision.Flair == "" && content.Kind == KindPost {
+				decision.Flair = rule.Action.Flair
+			}
+		}
+	}
+	return decision, nil
+}
+
+func (r *Rule) matches(content *Content) (bool, error) {
+	if len(r.Kinds) != 0 && !contains(r.Kinds, content.Kind) {
+		return false, nil
+	}
+	if r.titleRe != nil && (content.Kind != KindPost || !r.titleRe.MatchString(content.Title)) {
+		return false, nil
+	}
+	if r.bodyRe != nil && !r.bodyRe.MatchString(content.Body) {
+		return false, nil
+	}
+	if len(r.Conditions.Domains) != 0 && !matchesDomain(content.URL, r.Conditions.Domains) {
+		return false, nil
+	}
+	if r.Conditions.AccountAgeLessThan != "" {
+		age, err := content.Author.AccountAge()
+		if err != nil {
+			return false, err
+		}
+		if age >= r.accountAge {
+			return false, nil
+		}
+	}
+	if r.Conditions.KarmaBelow != nil {
+		karma, err := content.Author.Karma()
+		if err != nil {
+			return false, err
+		}
+		if karma >= *r.Conditions.KarmaBelow {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func matchesDomain(rawURL string, domains []string) bool {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || parsedURL.Hostname() == "" {
+		return false
+	}
+	host := strings.ToLower(parsedURL.Hostname())
+	for _, domain := range domains {
+		domain = strings.ToLower(domain)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, current := range values {
+		if current == value {
+			return true
+		}
+	}
+	return false
+}
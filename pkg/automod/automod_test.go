@@ -0,0 +1,174 @@
+package automod
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testAuthor struct {
+	age   time.Duration
+	karma int
+	err   error
+}
+
+func (a *testAuthor) AccountAge() (time.Duration, error) {
+	return a.age, a.err
+}
+
+func (a *testAuthor) Karma() (int, error) {
+	return a.karma, a.err
+}
+
+const testRules = `
+categories:
+  "*":
+    - name: no-shorteners
+      kinds: [post]
+      conditions:
+        domains: [bit.ly]
+      action:
+        type: reject
+        message: link shorteners are not allowed
+    - name: newbie
+      conditions:
+        accountAgeLessThan: 24h
+        karmaBelow: 10
+      action:
+        type: hold
+  programming:
+    - name: question-flair
+      conditions:
+        titleRegex: "(?i)^how (do|to)"
+      action:
+        type: flair
+        flair: question
+    - name: crypto
+      conditions:
+        bodyRegex: "(?i)crypto|nft"
+      action:
+        type: report
+`
+
+func TestEvaluate(t *testing.T) {
+	rules, err := ParseRules([]byte(testRules), "yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	oldAuthor := &testAuthor{age: 30 * 24 * time.Hour, karma: 100}
+	cases := []struct {
+		name     string
+		content  *Content
+		expected Decision
+	}{
+		{
+			name:     "ссылка на сокращатель из поддомена",
+			content:  &Content{Kind: KindPost, Category: "music", URL: "https://www.bit.ly/abc", Author: oldAuthor},
+			expected: Decision{Reject: true, Message: "link shorteners are not allowed", Rules: []string{"no-shorteners"}},
+		},
+		{
+			name:     "правило для постов не применяется к комментам",
+			content:  &Content{Kind: KindComment, Category: "music", URL: "https://bit.ly/abc", Author: oldAuthor},
+			expected: Decision{},
+		},
+		{
+			name:     "новый аккаунт с маленькой кармой",
+			content:  &Content{Kind: KindComment, Category: "music", Body: "hi", Author: &testAuthor{age: time.Hour, karma: 1}},
+			expected: Decision{Hold: true, Rules: []string{"newbie"}},
+		},
+		{
+			name:     "новый аккаунт с большой кармой",
+			content:  &Content{Kind: KindComment, Category: "music", Body: "hi", Author: &testAuthor{age: time.Hour, karma: 50}},
+			expected: Decision{},
+		},
+		{
+			name:     "флер и жалоба в категории",
+			content:  &Content{Kind: KindPost, Category: "programming", Title: "How to buy NFT", Body: "nft", Author: oldAuthor},
+			expected: Decision{Flair: "question", Report: true, Rules: []string{"question-flair", "crypto"}},
+		},
+		{
+			name:     "правила категории не применяются к другим категориям",
+			content:  &Content{Kind: KindPost, Category: "music", Title: "How to buy NFT", Body: "nft", Author: oldAuthor},
+			expected: Decision{},
+		},
+	}
+	for _, tc := range cases {
+		decision, err := rules.Evaluate(tc.content)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.name, err)
+			continue
+		}
+		if decision.Reject != tc.expected.Reject || decision.Message != tc.expected.Message ||
+			decision.Hold != tc.expected.Hold || decision.Report != tc.expected.Report ||
+			decision.Flair != tc.expected.Flair || len(decision.Rules) != len(tc.expected.Rules) {
+			t.Errorf("%s: wrong decision: expected %+v, got %+v", tc.name, tc.expected, *decision)
+		}
+	}
+
+	// ошибка при получении кармы
+	_, err = rules.Evaluate(&Content{Kind: KindPost, Category: "music", Author: &testAuthor{err: errors.New("db_error")}})
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	badRules := []string{
+		`{"categories": {"*": [{"name": "empty", "action": {"type": "reject"}}]}}`,
+		`{"categories": {"*": [{"name": "regex", "conditions": {"titleRegex": "("}, "action": {"type": "reject"}}]}}`,
+		`{"categories": {"*": [{"name": "action", "conditions": {"titleRegex": "a"}, "action": {"type": "ban"}}]}}`,
+		`{"categories": {"*": [{"name": "flair", "conditions": {"titleRegex": "a"}, "action": {"type": "flair"}}]}}`,
+		`{"categories": {"*": [{"name": "age", "conditions": {"accountAgeLessThan": "day"}, "action": {"type": "hold"}}]}}`,
+	}
+	for _, rules := range badRules {
+		_, err := ParseRules([]byte(rules), "json")
+		if !errors.Is(err, ErrBadRule) {
+			t.Errorf("expected error %s for %s, got %v", ErrBadRule, rules, err)
+		}
+	}
+	_, err := ParseRules([]byte(`{"categories": {"*": [{"name": "ok", "conditions": {"titleRegex": "a"}, "action": {"type": "hold"}}]}}`), "json")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	err := os.WriteFile(path, []byte(testRules), 0o600)
+	if err != nil {
+		t.Fatalf("can not write rules: %s", err)
+	}
+	am, err := NewAutoModerator(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	content := &Content{Kind: KindPost, Category: "music", Title: "spam", Author: &testAuthor{age: time.Hour * 1000, karma: 100}}
+	decision, err := am.Evaluate(content)
+	if err != nil || decision.Reject {
+		t.Fatalf("unexpected decision %+v, error %v", decision, err)
+	}
+
+	// битые правила не заменяют рабочие
+	err = os.WriteFile(path, []byte("categories: ["), 0o600)
+	if err != nil {
+		t.Fatalf("can not write rules: %s", err)
+	}
+	if am.Reload() == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	err = os.WriteFile(path, []byte(`{"categories": {"music": [{"name": "spam", "conditions": {"titleRegex": "spam"}, "action": {"type": "reject", "message": "no"}}]}}`), 0o600)
+	if err != nil {
+		t.Fatalf("can not write rules: %s", err)
+	}
+	err = am.Reload()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	decision, err = am.Evaluate(content)
+	if err != nil || !decision.Reject {
+		t.Errorf("rules were not reloaded: decision %+v, error %v", decision, err)
+	}
+}
@@ -0,0 +1,123 @@
+package automod
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type AutoModerator struct {
+	mu      *sync.RWMutex
+	path    string
+	modTime time.Time
+	rules   *RuleSet
+}
+
+func NewAutoModerator(path string) (*AutoModerator, error) {
+	am := &AutoModerator{
+		mu:    &sync.RWMutex{},
+		path:  path,
+		rules: &RuleSet{},
+	}
+	err := am.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return am, nil
+}
+
+func NewAutoModeratorFromRules(rules *RuleSet) (*AutoModerator, error) {
+	err := rules.compile()
+	if err != nil {
+		return nil, err
+	}
+	return &AutoModerator{
+		mu:    &sync.RWMutex{},
+		rules: rules,
+	}, nil
+}
+
+func ParseRules(data []byte, format string) (*RuleSet, error) {
+	rules := &RuleSet{}
+	var err error
+	if format == "json" {
+		err = json.Unmarshal(data, rules)
+	} else {
+		err = yaml.Unmarshal(data, rules)
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = rules.compile()
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (am *AutoModerator) Reload() error {
+	info, err := os.Stat(am.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(am.path)
+	if err != nil {
+		return err
+	}
+	format := "yaml"
+	if strings.EqualFold(filepath.Ext(am.path), ".json") {
+		format = "json"
+	}
+	rules, err := ParseRules(data, format)
+	if err != nil {
+		return err
+	}
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.rules = rules
+	am.modTime = info.ModTime()
+	return nil
+}
+
+// Watch перечитывает файл с правилами при изменении, при ошибке остаются старые правила
+func (am *AutoModerator) Watch(stop <-chan struct{}, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			info, err := os.Stat(am.path)
+			if err != nil {
+				onError(err)
+				continue
+			}
+			am.mu.RLock()
+			changed := !info.ModTime().Equal(am.modTime)
+			am.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			err = am.Reload()
+			if err != nil {
+				am.mu.Lock()
+				am.modTime = info.ModTime()
+				am.mu.Unlock()
+				onError(err)
+			}
+		}
+	}
+}
+
+func (am *AutoModerator) Evaluate(content *Content) (*Decision, error) {
+	am.mu.RLock()
+	rules := am.rules
+	am.mu.RUnlock()
+	return rules.Evaluate(content)
+}
@@ -0,0 +1,31 @@
+package blob
+
+import (
+	"errors"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("no blob found")
+	ErrBadKey   = errors.New("bad blob key")
+)
+
+var keyPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+type Storage interface {
+	Put(key string, data []byte, contentType string) error
+	Get(key string) (*Object, error)
+	Delete(key string) error
+}
+
+type Object struct {
+	Data        []byte
+	ContentType string
+	ModTime     time.Time
+}
+
+// ValidKey не пускает ключи с путями, чтобы нельзя было выйти за пределы хранилища
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key) && key != "." && key != ".."
+}
@@ -0,0 +1,120 @@
+package blob
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func checkStorage(t *testing.T, storage Storage) {
+	t.Helper()
+
+	// ключи с путями не принимаются
+	err := storage.Put("../passwd", []byte("data"), "text/plain")
+	if !errors.Is(err, ErrBadKey) {
+		t.Errorf("wrong error: expected %s, got %v", ErrBadKey, err)
+		return
+	}
+
+	// такого файла нет
+	_, err = storage.Get("absent.png")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("wrong error: expected %s, got %v", ErrNotFound, err)
+		return
+	}
+
+	// файл сохранен и прочитан
+	err = storage.Put("image.png", []byte("png data"), "image/png")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	object, err := storage.Get("image.png")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if string(object.Data) != "png data" || object.ContentType != "image/png" {
+		t.Errorf("wrong object: %s, %s", object.Data, object.ContentType)
+		return
+	}
+
+	// файл удален
+	err = storage.Delete("image.png")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	_, err = storage.Get("image.png")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("wrong error: expected %s, got %v", ErrNotFound, err)
+		return
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkStorage(t, storage)
+}
+
+// fakeS3 - минимальная замена MinIO, проверяющая подпись запросов
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	signTime, err := time.Parse(amzDateFormat, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		http.Error(w, "no date", http.StatusForbidden)
+		return
+	}
+	if !strings.HasSuffix(r.Header.Get("Authorization"), "Signature="+signature(r, "secret", "us-east-1", signTime)) {
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = data
+		f.types[r.URL.Path] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", f.types[r.URL.Path])
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		_, _ = io.Copy(w, bytes.NewReader(data))
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3Storage(t *testing.T) {
+	server := httptest.NewServer(&fakeS3{objects: make(map[string][]byte), types: make(map[string]string)})
+	defer server.Close()
+
+	checkStorage(t, NewS3Storage(server.URL, "media", "us-east-1", "access", "secret"))
+
+	// с неверным секретом запросы отклоняются
+	err := NewS3Storage(server.URL, "media", "us-east-1", "access", "wrong").Put("image.png", []byte("data"), "image/png")
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected signature error, got %v", err)
+		return
+	}
+}
@@ -0,0 +1,75 @@
+package blob
+
+import (
+	"errors"
+	"io/fs"
+	"mime"
+	"os"
+	"path/filepath"
+)
+
+type LocalStorage struct {
+	Dir string
+}
+
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalStorage{Dir: dir}, nil
+}
+
+func (l *LocalStorage) Put(key string, data []byte, _ string) error {
+	if !ValidKey(key) {
+		return ErrBadKey
+	}
+	// пишем во временный файл и переименовываем, чтобы читатели не видели недописанный файл
+	tmp, err := os.CreateTemp(l.Dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(l.Dir, key))
+}
+
+func (l *LocalStorage) Get(key string) (*Object, error) {
+	if !ValidKey(key) {
+		return nil, ErrBadKey
+	}
+	path := filepath.Join(l.Dir, key)
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{
+		Data:        data,
+		ContentType: mime.TypeByExtension(filepath.Ext(key)),
+		ModTime:     info.ModTime(),
+	}, nil
+}
+
+func (l *LocalStorage) Delete(key string) error {
+	if !ValidKey(key) {
+		return ErrBadKey
+	}
+	err := os.Remove(filepath.Join(l.Dir, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
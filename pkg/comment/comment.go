@@ -6,10 +6,12 @@ import (
 )
 
 type Comment struct {
-	Created string     `json:"created"`
-	Author  *user.User `json:"author"`
-	Body    string     `json:"body"`
-	ID      string     `json:"id"`
+	Created   string     `json:"created"`
+	Author    *user.User `json:"author"`
+	Body      string     `json:"body"`
+	BodyHTML  string     `json:"bodyHTML,omitempty" bson:"bodyHTML,omitempty"`
+	ID        string     `json:"id"`
+	SpamScore float64    `json:"-" bson:"spamScore,omitempty"`
 }
 
 func (c *CommentForm) Validate() []string {
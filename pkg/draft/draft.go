@@ -0,0 +1,112 @@
+package draft
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"reddit/pkg/post"
+	"reddit/pkg/user"
+)
+
+const (
+	StatusDraft      = "draft"
+	StatusScheduled  = "scheduled"
+	StatusPublishing = "publishing"
+)
+
+var (
+	ErrNoDraft      = errors.New("no draft found")
+	ErrInvalid      = errors.New("draft is not ready for publishing")
+	ErrPastSchedule = errors.New("publish time must be in the future")
+)
+
+type DraftRepo interface {
+	Create(ctx context.Context, author *user.User, form *Form) (*Draft, error)
+	Get(ctx context.Context, author *user.User, draftID string) (*Draft, error)
+	List(ctx context.Context, author *user.User) ([]*Draft, error)
+	Update(ctx context.Context, author *user.User, draftID string, form *Form) (*Draft, error)
+	Delete(ctx context.Context, author *user.User, draftID string) error
+	Publish(ctx context.Context, author *user.User, draftID string) (*post.Post, error)
+}
+
+type PostAdder interface {
+	AddPost(ctx context.Context, newPost *post.Post, author *user.User) (*post.Post, error)
+}
+
+// Locker - выбор лидера среди реплик, публикует только тот, кто держит блокировку
+type Locker interface {
+	Acquire(ctx context.Context) (bool, error)
+	Release(ctx context.Context) error
+}
+
+// Draft - черновик живет отдельно от постов, поэтому в ленты и поиск не попадает
+type Draft struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	AuthorID  string             `json:"-" bson:"authorId"`
+	Author    *user.User         `json:"author" bson:"author"`
+	Post      *post.Post         `json:"post" bson:"post"`
+	PublishAt *time.Time         `json:"publishAt,omitempty" bson:"publishAt,omitempty"`
+	Status    string             `json:"status" bson:"status"`
+	// ClaimedAt - когда черновик забрали на публикацию, по нему видно брошенную упавшей репликой публикацию
+	ClaimedAt *time.Time `json:"-" bson:"claimedAt,omitempty"`
+	Error     string     `json:"error,omitempty" bson:"error,omitempty"`
+	Updated   time.Time  `json:"updated" bson:"updated"`
+}
+
+type Form struct {
+	Post      *post.Post `json:"post"`
+	PublishAt *time.Time `json:"publishAt"`
+}
+
+type ValidationError struct {
+	Messages []string
+}
+
+func (e *ValidationError) Error() string {
+	return ErrInvalid.Error() + ": " + strings.Join(e.Messages, ", ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalid
+}
+
+// content - только то, что задает автор; служебные поля заполнит AddPost при публикации
+func content(p *post.Post) *post.Post {
+	if p == nil {
+		return &post.Post{}
+	}
+	draftContent := &post.Post{
+		Type:     p.Type,
+		Title:    p.Title,
+		URL:      p.URL,
+		Category: p.Category,
+		Text:     p.Text,
+		Flair:    p.Flair,
+		Tags:     p.Tags,
+		NSFW:     p.NSFW,
+		Spoiler:  p.Spoiler,
+	}
+	if p.Poll != nil {
+		draftContent.Poll = &post.Poll{ClosesAt: p.Poll.ClosesAt}
+		for _, option := range p.Poll.Options {
+			if option != nil {
+				draftContent.Poll.Options = append(draftContent.Poll.Options, &post.PollOption{Text: option.Text})
+			}
+		}
+	}
+	return draftContent
+}
+
+func validate(p *post.Post) error {
+	if p.Type == "image" {
+		return &ValidationError{Messages: []string{"image posts must be uploaded to /api/posts/image"}}
+	}
+	if validationErrors := p.Validate(); len(validationErrors) != 0 {
+		return &ValidationError{Messages: validationErrors}
+	}
+	return nil
+}
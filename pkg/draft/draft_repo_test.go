@@ -0,0 +1,251 @@
+package draft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"reddit/pkg/post"
+	"reddit/pkg/user"
+)
+
+func TestCreateAndUpdate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := post.NewMockCollectionHelper(ctrl)
+	testRepo := NewDraftBusinessLogic(&DraftDBRepo{Drafts: testCollection}, NewMockPostAdder(ctrl))
+	author := &user.User{ID: "author_id", Username: "author"}
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+
+	// черновик без даты сохраняется без проверок, служебные поля отбрасываются
+	testCollection.EXPECT().InsertOne(gomock.Any(), gomock.Any()).Return("any", nil)
+	newDraft, err := testRepo.Create(context.Background(), author, &Form{Post: &post.Post{Title: "недописанный", Score: 100, Views: 5}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if newDraft.Status != StatusDraft || newDraft.AuthorID != "author_id" || newDraft.Post.Score != 0 || newDraft.Post.Views != 0 {
+		t.Errorf("wrong draft: %+v, post %+v", newDraft, newDraft.Post)
+	}
+
+	// запланировать можно только валидный пост
+	_, err = testRepo.Create(context.Background(), author, &Form{Post: &post.Post{Title: "недописанный"}, PublishAt: &future})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Messages) == 0 {
+		t.Errorf("wrong error: expected validation error, got %v", err)
+	}
+
+	// и только на будущее
+	_, err = testRepo.Create(context.Background(), author, &Form{Post: &post.Post{Type: "text", Title: "t", Category: "music", Text: "x"}, PublishAt: &past})
+	if !errors.Is(err, ErrPastSchedule) {
+		t.Errorf("wrong error: expected %s, got %v", ErrPastSchedule, err)
+	}
+
+	// чужой черновик не виден
+	draftID := primitive.NewObjectID()
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": draftID, "authorId": "another_id"}).Return(mongo.NewSingleResultFromDocument(bson.D{}, mongo.ErrNoDocuments, nil))
+	_, err = testRepo.Update(context.Background(), &user.User{ID: "another_id"}, draftID.Hex(), &Form{})
+	if !errors.Is(err, ErrNoDraft) {
+		t.Errorf("wrong error: expected %s, got %v", ErrNoDraft, err)
+	}
+
+	// черновик уже публикуется, а брошенную публикацию автор может поправить
+	stored := &Draft{ID: draftID, AuthorID: "author_id", Author: author, Post: &post.Post{}, Status: StatusDraft}
+	updateFilter := func(filter interface{}) bool {
+		alternatives, ok := filter.(bson.M)["$or"].(bson.A)
+		if !ok || len(alternatives) != 2 || filter.(bson.M)["_id"] != draftID || filter.(bson.M)["authorId"] != "author_id" {
+			return false
+		}
+		stale := alternatives[1].(bson.M)["claimedAt"].(bson.M)["$not"].(bson.M)["$gte"].(time.Time)
+		return time.Since(stale) >= claimLease && time.Since(stale) < claimLease+time.Minute
+	}
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": draftID, "authorId": "author_id"}).Return(mongo.NewSingleResultFromDocument(stored, nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, filter, update interface{}, _ ...interface{}) (*mongo.UpdateResult, error) {
+		if !updateFilter(filter) || update.(bson.M)["$unset"].(bson.M)["claimedAt"] != "" {
+			t.Errorf("wrong update: %v, %v", filter, update)
+		}
+		return &mongo.UpdateResult{MatchedCount: 0}, nil
+	})
+	_, err = testRepo.Update(context.Background(), author, draftID.Hex(), &Form{Post: &post.Post{Type: "text", Title: "t", Category: "music", Text: "x"}, PublishAt: &future})
+	if !errors.Is(err, ErrNoDraft) {
+		t.Errorf("wrong error: expected %s, got %v", ErrNoDraft, err)
+	}
+
+	// черновик запланирован
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": draftID, "authorId": "author_id"}).Return(mongo.NewSingleResultFromDocument(stored, nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), gomock.Any(), gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	updatedDraft, err := testRepo.Update(context.Background(), author, draftID.Hex(), &Form{Post: &post.Post{Type: "text", Title: "t", Category: "music", Text: "x"}, PublishAt: &future})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if updatedDraft.Status != StatusScheduled || !updatedDraft.PublishAt.Equal(future) {
+		t.Errorf("wrong draft: %+v", updatedDraft)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := post.NewMockCollectionHelper(ctrl)
+	testPosts := NewMockPostAdder(ctrl)
+	testRepo := NewDraftBusinessLogic(&DraftDBRepo{Drafts: testCollection}, testPosts)
+	author := &user.User{ID: "author_id", Username: "author"}
+	draftID := primitive.NewObjectID()
+	updated := time.Now().UTC().Truncate(time.Millisecond)
+	newDraft := func(draftPost *post.Post) *Draft {
+		return &Draft{ID: draftID, AuthorID: "author_id", Author: author, Post: draftPost, Status: StatusDraft, Updated: updated}
+	}
+	findDraft := func(stored *Draft) {
+		testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": draftID, "authorId": "author_id"}).Return(mongo.NewSingleResultFromDocument(stored, nil, nil))
+	}
+	claimFilter := bson.M{"_id": draftID, "status": StatusDraft, "updated": updated, "claimedAt": bson.M{"$exists": false}}
+	validPost := &post.Post{Type: "text", Title: "t", Category: "music", Text: "x"}
+
+	// черновик уже забрал планировщик
+	findDraft(newDraft(validPost))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), claimFilter, gomock.Any()).DoAndReturn(func(_ context.Context, _, update interface{}, _ ...interface{}) (*mongo.UpdateResult, error) {
+		set := update.(bson.M)["$set"].(bson.M)
+		if set["status"] != StatusPublishing || time.Since(set["claimedAt"].(time.Time)) > time.Minute {
+			t.Errorf("wrong claim: %v", update)
+		}
+		return &mongo.UpdateResult{MatchedCount: 0}, nil
+	})
+	_, err := testRepo.Publish(context.Background(), author, draftID.Hex())
+	if !errors.Is(err, ErrNoDraft) {
+		t.Errorf("wrong error: expected %s, got %v", ErrNoDraft, err)
+	}
+
+	// черновик публикуется прямо сейчас - второй раз его не забрать
+	claimedAt := time.Now().UTC().Truncate(time.Millisecond)
+	publishing := newDraft(validPost)
+	publishing.Status = StatusPublishing
+	publishing.ClaimedAt = &claimedAt
+	findDraft(publishing)
+	_, err = testRepo.Publish(context.Background(), author, draftID.Hex())
+	if !errors.Is(err, ErrNoDraft) {
+		t.Errorf("wrong error: expected %s, got %v", ErrNoDraft, err)
+	}
+
+	// публикацию бросила упавшая реплика - черновик забирается заново
+	staleAt := claimedAt.Add(-claimLease - time.Minute)
+	publishing.ClaimedAt = &staleAt
+	findDraft(publishing)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": draftID, "status": StatusPublishing, "updated": updated, "claimedAt": staleAt}, gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	testPosts.EXPECT().AddPost(gomock.Any(), gomock.Any(), author).Return(&post.Post{Title: "t"}, nil)
+	testCollection.EXPECT().DeleteOne(gomock.Any(), bson.M{"_id": draftID, "authorId": "author_id"}).Return(int64(1), nil)
+	_, err = testRepo.Publish(context.Background(), author, draftID.Hex())
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	// недописанный черновик возвращается автору с ошибкой
+	findDraft(newDraft(&post.Post{Title: "t"}))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), claimFilter, gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": draftID}, gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	_, err = testRepo.Publish(context.Background(), author, draftID.Hex())
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("wrong error: expected %s, got %v", ErrInvalid, err)
+	}
+
+	// автомодератор отклонил
+	findDraft(newDraft(validPost))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), claimFilter, gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	testPosts.EXPECT().AddPost(gomock.Any(), gomock.Any(), author).Return(nil, &post.RejectedError{Message: "no"})
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": draftID}, gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ interface{}, update interface{}, _ ...interface{}) (*mongo.UpdateResult, error) {
+			set := update.(bson.M)["$set"].(bson.M)
+			if set["status"] != StatusDraft || set["error"] != "rejected by automoderator: no" {
+				t.Errorf("wrong release: %v", update)
+			}
+			return &mongo.UpdateResult{MatchedCount: 1}, nil
+		})
+	_, err = testRepo.Publish(context.Background(), author, draftID.Hex())
+	if !errors.Is(err, post.ErrRejected) {
+		t.Errorf("wrong error: expected %s, got %v", post.ErrRejected, err)
+	}
+
+	// опубликован и удален из черновиков
+	findDraft(newDraft(validPost))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), claimFilter, gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	testPosts.EXPECT().AddPost(gomock.Any(), gomock.Any(), author).DoAndReturn(func(_ context.Context, newPost *post.Post, _ *user.User) (*post.Post, error) {
+		newPost.ID = primitive.NewObjectID()
+		return newPost, nil
+	})
+	testCollection.EXPECT().DeleteOne(gomock.Any(), bson.M{"_id": draftID, "authorId": "author_id"}).Return(int64(1), nil)
+	publishedPost, err := testRepo.Publish(context.Background(), author, draftID.Hex())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if publishedPost.Title != "t" || publishedPost.ID.IsZero() {
+		t.Errorf("wrong post: %+v", publishedPost)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := post.NewMockCollectionHelper(ctrl)
+	testPosts := NewMockPostAdder(ctrl)
+	testLocker := NewMockLocker(ctrl)
+	testRepo := NewDraftBusinessLogic(&DraftDBRepo{Drafts: testCollection}, testPosts)
+	author := &user.User{ID: "author_id", Username: "author"}
+
+	// не лидер - ничего не публикует
+	testLocker.EXPECT().Acquire(gomock.Any()).Return(false, nil)
+	if err := testRepo.tick(context.Background(), testLocker); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	// ошибка блокировки
+	testLocker.EXPECT().Acquire(gomock.Any()).Return(false, fmt.Errorf("error"))
+	if err := testRepo.tick(context.Background(), testLocker); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	// лидер публикует пришедшие черновики: один опубликован, второй забрал автор
+	updated := time.Now().UTC().Truncate(time.Millisecond)
+	first := &Draft{ID: primitive.NewObjectID(), AuthorID: "author_id", Author: author, Status: StatusScheduled, Updated: updated,
+		Post: &post.Post{Type: "text", Title: "t", Category: "music", Text: "x"}}
+	second := &Draft{ID: primitive.NewObjectID(), AuthorID: "author_id", Author: author, Status: StatusScheduled, Updated: updated,
+		Post: &post.Post{Type: "text", Title: "t2", Category: "music", Text: "x"}}
+	cursor, err := mongo.NewCursorFromDocuments([]interface{}{first, second}, nil, nil)
+	if err != nil {
+		t.Fatalf("error on cursor creation")
+	}
+	testLocker.EXPECT().Acquire(gomock.Any()).Return(true, nil)
+	testCollection.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, filter interface{}, _ ...interface{}) (*mongo.Cursor, error) {
+			alternatives, ok := filter.(bson.M)["$or"].(bson.A)
+			if !ok || len(alternatives) != 2 || alternatives[0].(bson.M)["status"] != StatusScheduled || alternatives[1].(bson.M)["status"] != StatusPublishing {
+				t.Errorf("wrong due filter: %v", filter)
+			}
+			return cursor, nil
+		})
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": first.ID, "status": StatusScheduled, "updated": updated, "claimedAt": bson.M{"$exists": false}}, gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	testPosts.EXPECT().AddPost(gomock.Any(), gomock.Any(), gomock.Any()).Return(&post.Post{Title: "t"}, nil)
+	testCollection.EXPECT().DeleteOne(gomock.Any(), bson.M{"_id": first.ID, "authorId": "author_id"}).Return(int64(1), nil)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": second.ID, "status": StatusScheduled, "updated": updated, "claimedAt": bson.M{"$exists": false}}, gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 0}, nil)
+	if err = testRepo.tick(context.Background(), testLocker); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	// остановка отпускает блокировку
+	ctx, cancel := context.WithCancel(context.Background())
+	testLocker.EXPECT().Release(gomock.Any()).DoAndReturn(func(releaseCtx context.Context) error {
+		if releaseCtx.Err() != nil {
+			t.Errorf("release got cancelled context")
+		}
+		return nil
+	})
+	cancel()
+	testRepo.RunScheduler(ctx, time.Hour, testLocker, nil)
+}
@@ -0,0 +1,212 @@
+package draft
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"reddit/pkg/post"
+	"reddit/pkg/user"
+)
+
+const (
+	dueBatch = 100
+	// claimLease - публикация заведомо укладывается в это время, забранный раньше черновик считается брошенным
+	claimLease = 10 * time.Minute
+)
+
+type DraftDBRepository interface {
+	AddDraftDB(ctx context.Context, draft *Draft) error
+	GetDraftDB(ctx context.Context, draftID, authorID string) (*Draft, error)
+	GetDraftsDB(ctx context.Context, authorID string) ([]*Draft, error)
+	UpdateDraftDB(ctx context.Context, draft *Draft, staleBefore time.Time) (bool, error)
+	DeleteDraftDB(ctx context.Context, draftID, authorID string) (bool, error)
+	ClaimDraftDB(ctx context.Context, draft *Draft, claimedAt time.Time) (bool, error)
+	ReleaseDraftDB(ctx context.Context, draftID, errText string) error
+	GetDueDraftsDB(ctx context.Context, now, staleBefore time.Time, limit int) ([]*Draft, error)
+}
+
+type DraftBusinessLogic struct {
+	DraftDBRepo DraftDBRepository
+	PostRepo    PostAdder
+}
+
+func NewDraftBusinessLogic(repo DraftDBRepository, postRepo PostAdder) *DraftBusinessLogic {
+	return &DraftBusinessLogic{
+		DraftDBRepo: repo,
+		PostRepo:    postRepo,
+	}
+}
+
+// fill - черновик без даты публикации можно сохранить каким угодно,
+// запланированный обязан сразу проходить валидацию поста
+func fill(draft *Draft, form *Form, now time.Time) error {
+	draft.Post = content(form.Post)
+	draft.PublishAt = form.PublishAt
+	draft.Status = StatusDraft
+	draft.ClaimedAt = nil
+	draft.Error = ""
+	// в монго время хранится с точностью до миллисекунд, по нему черновик забирается на публикацию
+	draft.Updated = now.UTC().Truncate(time.Millisecond)
+	if draft.PublishAt == nil {
+		return nil
+	}
+	if !draft.PublishAt.After(now) {
+		return ErrPastSchedule
+	}
+	if err := validate(draft.Post); err != nil {
+		return err
+	}
+	draft.Status = StatusScheduled
+	return nil
+}
+
+func (d *DraftBusinessLogic) Create(ctx context.Context, author *user.User, form *Form) (*Draft, error) {
+	draft := &Draft{
+		AuthorID: author.ID,
+		Author:   author,
+	}
+	if err := fill(draft, form, time.Now()); err != nil {
+		return nil, err
+	}
+	err := d.DraftDBRepo.AddDraftDB(ctx, draft)
+	if err != nil {
+		return nil, err
+	}
+	return draft, nil
+}
+
+func (d *DraftBusinessLogic) Get(ctx context.Context, author *user.User, draftID string) (*Draft, error) {
+	return d.DraftDBRepo.GetDraftDB(ctx, draftID, author.ID)
+}
+
+func (d *DraftBusinessLogic) List(ctx context.Context, author *user.User) ([]*Draft, error) {
+	return d.DraftDBRepo.GetDraftsDB(ctx, author.ID)
+}
+
+func (d *DraftBusinessLogic) Update(ctx context.Context, author *user.User, draftID string, form *Form) (*Draft, error) {
+	draft, err := d.DraftDBRepo.GetDraftDB(ctx, draftID, author.ID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err = fill(draft, form, now); err != nil {
+		return nil, err
+	}
+	updated, err := d.DraftDBRepo.UpdateDraftDB(ctx, draft, now.Add(-claimLease))
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		// пока правили, черновик уже публикуется или опубликован
+		return nil, ErrNoDraft
+	}
+	return draft, nil
+}
+
+func (d *DraftBusinessLogic) Delete(ctx context.Context, author *user.User, draftID string) error {
+	deleted, err := d.DraftDBRepo.DeleteDraftDB(ctx, draftID, author.ID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNoDraft
+	}
+	return nil
+}
+
+func (d *DraftBusinessLogic) Publish(ctx context.Context, author *user.User, draftID string) (*post.Post, error) {
+	draft, err := d.DraftDBRepo.GetDraftDB(ctx, draftID, author.ID)
+	if err != nil {
+		return nil, err
+	}
+	return d.publish(ctx, draft)
+}
+
+// publish сначала забирает черновик себе, так что один черновик не публикуется дважды,
+// даже если автор жмет кнопку одновременно с планировщиком, и не публикуется,
+// если его успели поправить после чтения. Черновик, забранный дольше claimLease назад, забирается заново
+func (d *DraftBusinessLogic) publish(ctx context.Context, draft *Draft) (*post.Post, error) {
+	draftID := draft.ID.Hex()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	if draft.Status == StatusPublishing && draft.ClaimedAt != nil && draft.ClaimedAt.After(now.Add(-claimLease)) {
+		return nil, ErrNoDraft
+	}
+	claimed, err := d.DraftDBRepo.ClaimDraftDB(ctx, draft, now)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrNoDraft
+	}
+	newPost := content(draft.Post)
+	err = validate(newPost)
+	if err == nil {
+		newPost, err = d.PostRepo.AddPost(ctx, newPost, draft.Author)
+	}
+	// забранный черновик надо отпустить или удалить, даже если запрос уже отменен
+	cleanupCtx := context.WithoutCancel(ctx)
+	if err != nil {
+		if errRelease := d.DraftDBRepo.ReleaseDraftDB(cleanupCtx, draftID, err.Error()); errRelease != nil {
+			return nil, errors.Join(err, errRelease)
+		}
+		return nil, err
+	}
+	_, err = d.DraftDBRepo.DeleteDraftDB(cleanupCtx, draftID, draft.AuthorID)
+	if err != nil {
+		return nil, err
+	}
+	return newPost, nil
+}
+
+// PublishDue публикует все, чему пришло время; неудачные черновики
+// возвращаются автору с текстом ошибки и дальше не мешают
+func (d *DraftBusinessLogic) PublishDue(ctx context.Context, now time.Time) (int, error) {
+	drafts, err := d.DraftDBRepo.GetDueDraftsDB(ctx, now, now.Add(-claimLease), dueBatch)
+	if err != nil {
+		return 0, err
+	}
+	published := 0
+	var errs []error
+	for _, draft := range drafts {
+		_, err = d.publish(ctx, draft)
+		if errors.Is(err, ErrNoDraft) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		published++
+	}
+	return published, errors.Join(errs...)
+}
+
+// RunScheduler работает, пока не отменят ctx
+func (d *DraftBusinessLogic) RunScheduler(ctx context.Context, interval time.Duration, locker Locker, onError func(err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// ctx уже отменен, а блокировку надо снять, чтобы другая реплика не ждала TTL
+			if err := locker.Release(context.WithoutCancel(ctx)); err != nil && onError != nil {
+				onError(err)
+			}
+			return
+		case <-ticker.C:
+			if err := d.tick(ctx, locker); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+func (d *DraftBusinessLogic) tick(ctx context.Context, locker Locker) error {
+	leader, err := locker.Acquire(ctx)
+	if err != nil || !leader {
+		return err
+	}
+	_, err = d.PublishDue(ctx, time.Now())
+	return err
+}
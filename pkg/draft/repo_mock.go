@@ -0,0 +1,216 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: draft.go
+
+// Package draft is a generated GoMock package.
+package draft
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	post "reddit/pkg/post"
+	user "reddit/pkg/user"
+)
+
+// MockDraftRepo is a mock of DraftRepo interface.
+type MockDraftRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockDraftRepoMockRecorder
+}
+
+// MockDraftRepoMockRecorder is the mock recorder for MockDraftRepo.
+type MockDraftRepoMockRecorder struct {
+	mock *MockDraftRepo
+}
+
+// NewMockDraftRepo creates a new mock instance.
+func NewMockDraftRepo(ctrl *gomock.Controller) *MockDraftRepo {
+	mock := &MockDraftRepo{ctrl: ctrl}
+	mock.recorder = &MockDraftRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDraftRepo) EXPECT() *MockDraftRepoMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockDraftRepo) Create(ctx context.Context, author *user.User, form *Form) (*Draft, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, author, form)
+	ret0, _ := ret[0].(*Draft)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockDraftRepoMockRecorder) Create(ctx, author, form interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockDraftRepo)(nil).Create), ctx, author, form)
+}
+
+// Delete mocks base method.
+func (m *MockDraftRepo) Delete(ctx context.Context, author *user.User, draftID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, author, draftID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockDraftRepoMockRecorder) Delete(ctx, author, draftID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockDraftRepo)(nil).Delete), ctx, author, draftID)
+}
+
+// Get mocks base method.
+func (m *MockDraftRepo) Get(ctx context.Context, author *user.User, draftID string) (*Draft, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, author, draftID)
+	ret0, _ := ret[0].(*Draft)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockDraftRepoMockRecorder) Get(ctx, author, draftID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockDraftRepo)(nil).Get), ctx, author, draftID)
+}
+
+// List mocks base method.
+func (m *MockDraftRepo) List(ctx context.Context, author *user.User) ([]*Draft, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, author)
+	ret0, _ := ret[0].([]*Draft)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockDraftRepoMockRecorder) List(ctx, author interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockDraftRepo)(nil).List), ctx, author)
+}
+
+// Publish mocks base method.
+func (m *MockDraftRepo) Publish(ctx context.Context, author *user.User, draftID string) (*post.Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Publish", ctx, author, draftID)
+	ret0, _ := ret[0].(*post.Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Publish indicates an expected call of Publish.
+func (mr *MockDraftRepoMockRecorder) Publish(ctx, author, draftID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockDraftRepo)(nil).Publish), ctx, author, draftID)
+}
+
+// Update mocks base method.
+func (m *MockDraftRepo) Update(ctx context.Context, author *user.User, draftID string, form *Form) (*Draft, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", ctx, author, draftID, form)
+	ret0, _ := ret[0].(*Draft)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockDraftRepoMockRecorder) Update(ctx, author, draftID, form interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockDraftRepo)(nil).Update), ctx, author, draftID, form)
+}
+
+// MockPostAdder is a mock of PostAdder interface.
+type MockPostAdder struct {
+	ctrl     *gomock.Controller
+	recorder *MockPostAdderMockRecorder
+}
+
+// MockPostAdderMockRecorder is the mock recorder for MockPostAdder.
+type MockPostAdderMockRecorder struct {
+	mock *MockPostAdder
+}
+
+// NewMockPostAdder creates a new mock instance.
+func NewMockPostAdder(ctrl *gomock.Controller) *MockPostAdder {
+	mock := &MockPostAdder{ctrl: ctrl}
+	mock.recorder = &MockPostAdderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPostAdder) EXPECT() *MockPostAdderMockRecorder {
+	return m.recorder
+}
+
+// AddPost mocks base method.
+func (m *MockPostAdder) AddPost(ctx context.Context, newPost *post.Post, author *user.User) (*post.Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddPost", ctx, newPost, author)
+	ret0, _ := ret[0].(*post.Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddPost indicates an expected call of AddPost.
+func (mr *MockPostAdderMockRecorder) AddPost(ctx, newPost, author interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPost", reflect.TypeOf((*MockPostAdder)(nil).AddPost), ctx, newPost, author)
+}
+
+// MockLocker is a mock of Locker interface.
+type MockLocker struct {
+	ctrl     *gomock.Controller
+	recorder *MockLockerMockRecorder
+}
+
+// MockLockerMockRecorder is the mock recorder for MockLocker.
+type MockLockerMockRecorder struct {
+	mock *MockLocker
+}
+
+// NewMockLocker creates a new mock instance.
+func NewMockLocker(ctrl *gomock.Controller) *MockLocker {
+	mock := &MockLocker{ctrl: ctrl}
+	mock.recorder = &MockLockerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLocker) EXPECT() *MockLockerMockRecorder {
+	return m.recorder
+}
+
+// Acquire mocks base method.
+func (m *MockLocker) Acquire(ctx context.Context) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Acquire", ctx)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Acquire indicates an expected call of Acquire.
+func (mr *MockLockerMockRecorder) Acquire(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Acquire", reflect.TypeOf((*MockLocker)(nil).Acquire), ctx)
+}
+
+// Release mocks base method.
+func (m *MockLocker) Release(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Release", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Release indicates an expected call of Release.
+func (mr *MockLockerMockRecorder) Release(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Release", reflect.TypeOf((*MockLocker)(nil).Release), ctx)
+}
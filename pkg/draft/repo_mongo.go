@@ -0,0 +1,182 @@
+package draft
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reddit/pkg/post"
+)
+
+type DraftDBRepo struct {
+	Drafts post.CollectionHelper
+	// Timeout - срок одной операции, 0 - post.DefaultTimeout
+	Timeout time.Duration
+}
+
+func (d *DraftDBRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return post.WithTimeout(ctx, d.Timeout)
+}
+
+func (d *DraftDBRepo) EnsureIndexesDB() error {
+	return d.Drafts.CreateIndexes(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "authorId", Value: 1}, {Key: "updated", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "publishAt", Value: 1}}},
+	})
+}
+
+func getMongoID(draftID string) (primitive.ObjectID, error) {
+	draftIDMongo, err := primitive.ObjectIDFromHex(draftID)
+	if err != nil {
+		return primitive.NilObjectID, ErrNoDraft
+	}
+	return draftIDMongo, nil
+}
+
+func (d *DraftDBRepo) AddDraftDB(ctx context.Context, draft *Draft) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+	draft.ID = primitive.NewObjectID()
+	_, err := d.Drafts.InsertOne(ctx, draft)
+	return err
+}
+
+func (d *DraftDBRepo) GetDraftDB(ctx context.Context, draftID, authorID string) (*Draft, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+	draftIDMongo, err := getMongoID(draftID)
+	if err != nil {
+		return nil, err
+	}
+	draft := &Draft{}
+	err = d.Drafts.FindOne(ctx, bson.M{"_id": draftIDMongo, "authorId": authorID}).Decode(draft)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNoDraft
+	}
+	if err != nil {
+		return nil, err
+	}
+	return draft, nil
+}
+
+func (d *DraftDBRepo) GetDraftsDB(ctx context.Context, authorID string) ([]*Draft, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+	drafts := make([]*Draft, 0)
+	opts := options.Find().SetSort(bson.D{{Key: "updated", Value: -1}})
+	result, err := d.Drafts.Find(ctx, bson.M{"authorId": authorID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = result.All(ctx, &drafts)
+	if err != nil {
+		return nil, err
+	}
+	return drafts, nil
+}
+
+// UpdateDraftDB не трогает черновик, который уже публикуется, если только его не забрали раньше staleBefore
+func (d *DraftDBRepo) UpdateDraftDB(ctx context.Context, draft *Draft, staleBefore time.Time) (bool, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+	filter := bson.M{"_id": draft.ID, "authorId": draft.AuthorID, "$or": bson.A{
+		bson.M{"status": bson.M{"$ne": StatusPublishing}},
+		staleClaim(staleBefore),
+	}}
+	update := bson.M{
+		"$set": bson.M{
+			"post":      draft.Post,
+			"publishAt": draft.PublishAt,
+			"status":    draft.Status,
+			"error":     draft.Error,
+			"updated":   draft.Updated,
+		},
+		"$unset": bson.M{"claimedAt": ""},
+	}
+	result, err := d.Drafts.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+func (d *DraftDBRepo) DeleteDraftDB(ctx context.Context, draftID, authorID string) (bool, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+	draftIDMongo, err := getMongoID(draftID)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := d.Drafts.DeleteOne(ctx, bson.M{"_id": draftIDMongo, "authorId": authorID})
+	if err != nil {
+		return false, err
+	}
+	return deleted == 1, nil
+}
+
+// staleClaim - публикация брошена: забрана раньше staleBefore или вовсе без времени, как до появления claimedAt
+func staleClaim(staleBefore time.Time) bson.M {
+	return bson.M{"status": StatusPublishing, "claimedAt": bson.M{"$not": bson.M{"$gte": staleBefore}}}
+}
+
+// ClaimDraftDB забирает черновик, только если он не менялся с чтения, в том числе не забран заново другой репликой
+func (d *DraftDBRepo) ClaimDraftDB(ctx context.Context, draft *Draft, claimedAt time.Time) (bool, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+	filter := bson.M{"_id": draft.ID, "status": draft.Status, "updated": draft.Updated, "claimedAt": bson.M{"$exists": false}}
+	if draft.ClaimedAt != nil {
+		filter["claimedAt"] = *draft.ClaimedAt
+	}
+	update := bson.M{
+		"$set": bson.M{"status": StatusPublishing, "claimedAt": claimedAt},
+	}
+	result, err := d.Drafts.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// ReleaseDraftDB возвращает неопубликованный черновик автору с причиной
+func (d *DraftDBRepo) ReleaseDraftDB(ctx context.Context, draftID, errText string) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+	draftIDMongo, err := getMongoID(draftID)
+	if err != nil {
+		return err
+	}
+	update := bson.M{
+		"$set":   bson.M{"status": StatusDraft, "error": errText, "updated": time.Now().UTC().Truncate(time.Millisecond)},
+		"$unset": bson.M{"publishAt": "", "claimedAt": ""},
+	}
+	_, err = d.Drafts.UpdateOne(ctx, bson.M{"_id": draftIDMongo}, update)
+	return err
+}
+
+// GetDueDraftsDB - запланированные на now и раньше, а также брошенные посреди публикации
+func (d *DraftDBRepo) GetDueDraftsDB(ctx context.Context, now, staleBefore time.Time, limit int) ([]*Draft, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+	drafts := make([]*Draft, 0)
+	opts := options.Find().
+		SetSort(bson.D{{Key: "publishAt", Value: 1}}).
+		SetLimit(int64(limit))
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": StatusScheduled, "publishAt": bson.M{"$lte": now}},
+		staleClaim(staleBefore),
+	}}
+	result, err := d.Drafts.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = result.All(ctx, &drafts)
+	if err != nil {
+		return nil, err
+	}
+	return drafts, nil
+}
@@ -0,0 +1,244 @@
+package feed
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"html"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"reddit/pkg/post"
+)
+
+const (
+	// MaxItems - в ленту попадают только самые свежие посты
+	MaxItems = 50
+	// createdLayout - формат, в котором посты хранят Created
+	createdLayout = "2006-01-02T15:04:05.999Z"
+	atomNS        = "http://www.w3.org/2005/Atom"
+	dcNS          = "http://purl.org/dc/elements/1.1/"
+	// guidPrefix - id записи зависит только от id поста, а не от адреса сайта
+	guidPrefix = "urn:reddit:post:"
+)
+
+// Feed - лента постов, одна и та же для rss и atom
+type Feed struct {
+	Title   string
+	ID      string
+	BaseURL string
+	Path    string
+	SelfURL string
+	Posts   []*post.Post
+}
+
+// New оставляет MaxItems самых свежих постов, новые сверху
+func New(title, id, baseURL, path, selfURL string, posts []*post.Post) *Feed {
+	sorted := make([]*post.Post, len(posts))
+	copy(sorted, posts)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return Created(sorted[i]).After(Created(sorted[j]))
+	})
+	if len(sorted) > MaxItems {
+		sorted = sorted[:MaxItems]
+	}
+	return &Feed{
+		Title:   title,
+		ID:      id,
+		BaseURL: baseURL,
+		Path:    path,
+		SelfURL: selfURL,
+		Posts:   sorted,
+	}
+}
+
+// Created - время создания поста, а если Created не разобрать - время из его ObjectID
+func Created(p *post.Post) time.Time {
+	created, err := time.Parse(createdLayout, p.Created)
+	if err != nil {
+		return p.ID.Timestamp().UTC()
+	}
+	return created.UTC()
+}
+
+// Updated - время самого свежего поста, ноль у пустой ленты
+func (f *Feed) Updated() time.Time {
+	if len(f.Posts) == 0 {
+		return time.Time{}
+	}
+	return Created(f.Posts[0])
+}
+
+// ETag считается по готовому документу, так что меняется вместе с любым полем ленты
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func (f *Feed) link(path string) string {
+	return f.BaseURL + path
+}
+
+func (f *Feed) postLink(p *post.Post) string {
+	return f.link("/a/" + url.PathEscape(p.Category) + "/" + p.ID.Hex())
+}
+
+// content - html записи: текст поста, ссылка или картинка
+func (f *Feed) content(p *post.Post) string {
+	body := p.TextHTML
+	if p.URL != "" {
+		escaped := html.EscapeString(p.URL)
+		body += `<p><a href="` + escaped + `" rel="nofollow noopener">` + escaped + `</a></p>`
+	}
+	if p.Image != nil {
+		imageURL := p.Image.URL
+		// загруженные картинки лежат у нас же, читалке нужен полный адрес
+		if strings.HasPrefix(imageURL, "/") {
+			imageURL = f.link(imageURL)
+		}
+		body += `<p><img src="` + html.EscapeString(imageURL) + `" alt=""></p>`
+	}
+	return body
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DCNS    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Self          atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Creator     string  `xml:"dc:creator,omitempty"`
+	Category    string  `xml:"category"`
+	Description string  `xml:"description"`
+}
+
+func (f *Feed) RSS() ([]byte, error) {
+	channel := rssChannel{
+		Title:       f.Title,
+		Link:        f.link(f.Path),
+		Description: f.Title,
+		Self:        atomLink{Href: f.SelfURL, Rel: "self", Type: "application/rss+xml"},
+		Items:       make([]rssItem, 0, len(f.Posts)),
+	}
+	if updated := f.Updated(); !updated.IsZero() {
+		channel.LastBuildDate = updated.Format(time.RFC1123Z)
+	}
+	for _, p := range f.Posts {
+		item := rssItem{
+			Title:       p.Title,
+			Link:        f.postLink(p),
+			GUID:        rssGUID{IsPermaLink: "false", Value: guidPrefix + p.ID.Hex()},
+			PubDate:     Created(p).Format(time.RFC1123Z),
+			Category:    p.Category,
+			Description: f.content(p),
+		}
+		if p.Author != nil {
+			item.Creator = p.Author.Username
+		}
+		channel.Items = append(channel.Items, item)
+	}
+	return marshal(&rss{Version: "2.0", AtomNS: atomNS, DCNS: dcNS, Channel: channel})
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	NS      string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title     string       `xml:"title"`
+	ID        string       `xml:"id"`
+	Link      atomLink     `xml:"link"`
+	Published string       `xml:"published"`
+	Updated   string       `xml:"updated"`
+	Author    *atomPerson  `xml:"author,omitempty"`
+	Category  atomCategory `xml:"category"`
+	Content   atomText     `xml:"content"`
+}
+
+func (f *Feed) Atom() ([]byte, error) {
+	feed := &atomFeed{
+		NS:      atomNS,
+		Title:   f.Title,
+		ID:      f.ID,
+		Updated: f.Updated().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.SelfURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.link(f.Path), Rel: "alternate", Type: "text/html"},
+		},
+		Entries: make([]atomEntry, 0, len(f.Posts)),
+	}
+	for _, p := range f.Posts {
+		created := Created(p).Format(time.RFC3339)
+		entry := atomEntry{
+			Title:     p.Title,
+			ID:        guidPrefix + p.ID.Hex(),
+			Link:      atomLink{Href: f.postLink(p), Rel: "alternate", Type: "text/html"},
+			Published: created,
+			Updated:   created,
+			Category:  atomCategory{Term: p.Category},
+			Content:   atomText{Type: "html", Value: f.content(p)},
+		}
+		if p.Author != nil {
+			entry.Author = &atomPerson{Name: p.Author.Username, URI: f.link("/u/" + url.PathEscape(p.Author.Username))}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return marshal(feed)
+}
+
+func marshal(document interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
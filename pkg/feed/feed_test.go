@@ -0,0 +1,117 @@
+package feed
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"reddit/pkg/post"
+	"reddit/pkg/user"
+)
+
+func testPosts(t *testing.T) []*post.Post {
+	older, err := primitive.ObjectIDFromHex("654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Fatalf("error in id")
+	}
+	newer, err := primitive.ObjectIDFromHex("654f63e3a2414a2a554b6424")
+	if err != nil {
+		t.Fatalf("error in id")
+	}
+	return []*post.Post{
+		{
+			ID:       older,
+			Type:     "link",
+			Title:    "old & link",
+			URL:      "https://example.com/?a=1&b=2",
+			Category: "music",
+			Author:   &user.User{Username: "hhhhhhhh"},
+			Created:  "2023-11-11T14:22:11.695Z",
+		},
+		{
+			ID:       newer,
+			Type:     "text",
+			Title:    "new",
+			Category: "music",
+			Author:   &user.User{Username: "jjjjjjjj"},
+			Text:     "hi",
+			TextHTML: "<p>hi</p>",
+			Created:  "2023-11-12T10:00:00Z",
+			Image:    &post.Image{URL: "/static/images/1.png"},
+		},
+	}
+}
+
+func TestFeed(t *testing.T) {
+	postsFeed := New("music", "urn:reddit:category:music", "https://example.org", "/a/music", "https://example.org/api/posts/music.rss", testPosts(t))
+
+	// новые посты сверху, время последнего поста - время ленты
+	if postsFeed.Posts[0].Title != "new" {
+		t.Errorf("wrong order: %s first", postsFeed.Posts[0].Title)
+		return
+	}
+	if expected := time.Date(2023, 11, 12, 10, 0, 0, 0, time.UTC); !postsFeed.Updated().Equal(expected) {
+		t.Errorf("wrong updated: %s", postsFeed.Updated())
+		return
+	}
+
+	rss, err := postsFeed.RSS()
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	for _, expected := range []string{
+		`<guid isPermaLink="false">urn:reddit:post:654f63e3a2414a2a554b6424</guid>`,
+		`<link>https://example.org/a/music/654f63e3a2414a2a554b6423</link>`,
+		`<title>old &amp; link</title>`,
+		`<pubDate>Sat, 11 Nov 2023 14:22:11 +0000</pubDate>`,
+		`<lastBuildDate>Sun, 12 Nov 2023 10:00:00 +0000</lastBuildDate>`,
+		`<dc:creator>hhhhhhhh</dc:creator>`,
+		`&lt;img src=&#34;https://example.org/static/images/1.png&#34;`,
+		`https://example.com/?a=1&amp;amp;b=2`,
+	} {
+		if !strings.Contains(string(rss), expected) {
+			t.Errorf("rss does not contain %s:\n%s", expected, rss)
+			return
+		}
+	}
+
+	atom, err := postsFeed.Atom()
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	for _, expected := range []string{
+		`<feed xmlns="http://www.w3.org/2005/Atom">`,
+		`<id>urn:reddit:category:music</id>`,
+		`<updated>2023-11-12T10:00:00Z</updated>`,
+		`<id>urn:reddit:post:654f63e3a2414a2a554b6423</id>`,
+		`<published>2023-11-11T14:22:11Z</published>`,
+		`<link href="https://example.org/api/posts/music.rss" rel="self" type="application/atom+xml"></link>`,
+		`<uri>https://example.org/u/jjjjjjjj</uri>`,
+		`<content type="html">&lt;p&gt;hi&lt;/p&gt;`,
+	} {
+		if !strings.Contains(string(atom), expected) {
+			t.Errorf("atom does not contain %s:\n%s", expected, atom)
+			return
+		}
+	}
+
+	// тот же документ - тот же ETag
+	if ETag(atom) != ETag(append([]byte{}, atom...)) || ETag(atom) == ETag(rss) {
+		t.Errorf("etag must depend only on body")
+		return
+	}
+}
+
+func TestCreated(t *testing.T) {
+	posts := testPosts(t)
+	// Created не разбирается - берем время из ObjectID
+	posts[0].Created = "bad"
+	if !Created(posts[0]).Equal(posts[0].ID.Timestamp()) {
+		t.Errorf("wrong fallback: %s", Created(posts[0]))
+		return
+	}
+}
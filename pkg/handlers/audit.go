@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"reddit/pkg/audit"
+	"reddit/pkg/response"
+)
+
+type AuditHandler struct {
+	AuditRepo audit.AuditRepo
+	Logger    *zap.SugaredLogger
+}
+
+func (ah *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := &audit.Filter{
+		Category: query.Get("category"),
+		Actor:    query.Get("actor"),
+		Action:   query.Get("action"),
+	}
+	var err error
+	if page := query.Get("page"); page != "" {
+		filter.Page, err = strconv.Atoi(page)
+		if err != nil {
+			response.WriteResponse(ah.Logger, w, []byte(`{"message": "page must be a number"}`), http.StatusBadRequest)
+			return
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			response.WriteResponse(ah.Logger, w, []byte(`{"message": "limit must be a number"}`), http.StatusBadRequest)
+			return
+		}
+	}
+	entries, err := ah.AuditRepo.GetEntries(r.Context(), filter)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "can not get audit log: %s"}`, err)
+		response.WriteResponse(ah.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	entriesJSON, err := json.Marshal(entries)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in coding audit log: %s"}`, err)
+		response.WriteResponse(ah.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	response.WriteResponse(ah.Logger, w, entriesJSON, http.StatusOK)
+}
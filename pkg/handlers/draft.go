@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"reddit/pkg/draft"
+	"reddit/pkg/middleware"
+	"reddit/pkg/post"
+	"reddit/pkg/response"
+	"reddit/pkg/user"
+)
+
+type DraftHandler struct {
+	DraftRepo draft.DraftRepo
+	Logger    *zap.SugaredLogger
+}
+
+func (dh *DraftHandler) List(w http.ResponseWriter, r *http.Request) {
+	author, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(dh.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	drafts, err := dh.DraftRepo.List(r.Context(), author)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "can not get drafts: %s"}`, err)
+		response.WriteResponse(dh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	dh.writeJSON(w, drafts, http.StatusOK)
+}
+
+func (dh *DraftHandler) Get(w http.ResponseWriter, r *http.Request) {
+	author, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(dh.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	foundDraft, err := dh.DraftRepo.Get(r.Context(), author, mux.Vars(r)["DRAFT_ID"])
+	if err != nil {
+		dh.writeError(w, err)
+		return
+	}
+	dh.writeJSON(w, foundDraft, http.StatusOK)
+}
+
+func (dh *DraftHandler) Create(w http.ResponseWriter, r *http.Request) {
+	author, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(dh.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	form, ok := dh.readForm(w, r)
+	if !ok {
+		return
+	}
+	newDraft, err := dh.DraftRepo.Create(r.Context(), author, form)
+	if err != nil {
+		dh.writeError(w, err)
+		return
+	}
+	dh.writeJSON(w, newDraft, http.StatusCreated)
+}
+
+func (dh *DraftHandler) Update(w http.ResponseWriter, r *http.Request) {
+	author, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(dh.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	form, ok := dh.readForm(w, r)
+	if !ok {
+		return
+	}
+	updatedDraft, err := dh.DraftRepo.Update(r.Context(), author, mux.Vars(r)["DRAFT_ID"], form)
+	if err != nil {
+		dh.writeError(w, err)
+		return
+	}
+	dh.writeJSON(w, updatedDraft, http.StatusOK)
+}
+
+func (dh *DraftHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	author, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(dh.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	err := dh.DraftRepo.Delete(r.Context(), author, mux.Vars(r)["DRAFT_ID"])
+	if err != nil {
+		dh.writeError(w, err)
+		return
+	}
+	response.WriteResponse(dh.Logger, w, []byte(`{"message": "success"}`), http.StatusOK)
+}
+
+func (dh *DraftHandler) Publish(w http.ResponseWriter, r *http.Request) {
+	author, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(dh.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	publishedPost, err := dh.DraftRepo.Publish(r.Context(), author, mux.Vars(r)["DRAFT_ID"])
+	if err != nil {
+		dh.writeError(w, err)
+		return
+	}
+	post.HidePollResults(author.ID, publishedPost)
+	dh.writeJSON(w, publishedPost, http.StatusCreated)
+}
+
+func (dh *DraftHandler) readForm(w http.ResponseWriter, r *http.Request) (*draft.Form, bool) {
+	rBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in reading request body: %s"}`, err)
+		response.WriteResponse(dh.Logger, w, []byte(errText), http.StatusBadRequest)
+		return nil, false
+	}
+	form := &draft.Form{}
+	err = json.Unmarshal(rBody, form)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in json decoding of draft form: %s"}`, err)
+		response.WriteResponse(dh.Logger, w, []byte(errText), http.StatusBadRequest)
+		return nil, false
+	}
+	return form, true
+}
+
+func (dh *DraftHandler) writeError(w http.ResponseWriter, err error) {
+	var validationErr *draft.ValidationError
+	var rejectedErr *post.RejectedError
+	var duplicateErr *post.DuplicateError
+	switch {
+	case errors.Is(err, draft.ErrNoDraft):
+		response.WriteResponse(dh.Logger, w, []byte(`{"message": "there is no such draft"}`), http.StatusNotFound)
+	case errors.As(err, &validationErr):
+		dh.writeJSON(w, validationErr.Messages, http.StatusUnprocessableEntity)
+	case errors.Is(err, draft.ErrPastSchedule):
+		errText := fmt.Sprintf(`{"message": "%s"}`, err)
+		response.WriteResponse(dh.Logger, w, []byte(errText), http.StatusUnprocessableEntity)
+	case errors.As(err, &rejectedErr):
+		errText := fmt.Sprintf(`{"message": "%s"}`, rejectedErr.Message)
+		response.WriteResponse(dh.Logger, w, []byte(errText), http.StatusUnprocessableEntity)
+	case errors.As(err, &duplicateErr):
+		errText := fmt.Sprintf(`{"message": "%s", "duplicateOf": "%s"}`, post.ErrDuplicate, duplicateErr.Original.ID.Hex())
+		response.WriteResponse(dh.Logger, w, []byte(errText), http.StatusConflict)
+	default:
+		errText := fmt.Sprintf(`{"message": "error in draft processing: %s"}`, err)
+		response.WriteResponse(dh.Logger, w, []byte(errText), http.StatusInternalServerError)
+	}
+}
+
+func (dh *DraftHandler) writeJSON(w http.ResponseWriter, value interface{}, status int) {
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in coding drafts: %s"}`, err)
+		response.WriteResponse(dh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	response.WriteResponse(dh.Logger, w, valueJSON, status)
+}
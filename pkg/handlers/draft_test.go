@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"reddit/pkg/draft"
+	"reddit/pkg/middleware"
+	"reddit/pkg/post"
+	"reddit/pkg/user"
+)
+
+func TestDraftHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := draft.NewMockDraftRepo(ctrl)
+	testHandler := &DraftHandler{
+		Logger:    zap.NewNop().Sugar(),
+		DraftRepo: testRepo,
+	}
+	author := &user.User{
+		ID:       "GgGHsZysctdVTaCvTZWhgzLReBThTXHc",
+		Username: "jjjjjjjj",
+	}
+	send := func(handler http.HandlerFunc, method, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "/api/drafts/draft_id", strings.NewReader(body))
+		request = mux.SetURLVars(request, map[string]string{"DRAFT_ID": "draft_id"})
+		ctx := context.WithValue(request.Context(), middleware.MyUserKey, author)
+		respWriter := httptest.NewRecorder()
+		handler(respWriter, request.WithContext(ctx))
+		return respWriter
+	}
+
+	// кривой json
+	if respWriter := send(testHandler.Create, http.MethodPost, `{"post": `); respWriter.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got status %d", http.StatusBadRequest, respWriter.Code)
+		return
+	}
+
+	cases := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		body    string
+		expect  func()
+		status  int
+		resp    string
+	}{
+		{"черновик создан", testHandler.Create, http.MethodPost, `{"post": {"title": "t"}}`, func() {
+			testRepo.EXPECT().Create(gomock.Any(), author, &draft.Form{Post: &post.Post{Title: "t"}}).Return(&draft.Draft{Status: draft.StatusDraft}, nil)
+		}, http.StatusCreated, ""},
+		{"запланировать невалидный пост", testHandler.Update, http.MethodPut, `{}`, func() {
+			testRepo.EXPECT().Update(gomock.Any(), author, "draft_id", &draft.Form{}).Return(nil, &draft.ValidationError{Messages: []string{"title: non zero value required"}})
+		}, http.StatusUnprocessableEntity, `["title: non zero value required"]`},
+		{"время в прошлом", testHandler.Update, http.MethodPut, `{}`, func() {
+			testRepo.EXPECT().Update(gomock.Any(), author, "draft_id", &draft.Form{}).Return(nil, draft.ErrPastSchedule)
+		}, http.StatusUnprocessableEntity, ""},
+		{"чужой черновик", testHandler.Get, http.MethodGet, "", func() {
+			testRepo.EXPECT().Get(gomock.Any(), author, "draft_id").Return(nil, draft.ErrNoDraft)
+		}, http.StatusNotFound, ""},
+		{"какая то ошибка сервера", testHandler.List, http.MethodGet, "", func() {
+			testRepo.EXPECT().List(gomock.Any(), author).Return(nil, fmt.Errorf("error"))
+		}, http.StatusInternalServerError, ""},
+		{"черновик удален", testHandler.Delete, http.MethodDelete, "", func() {
+			testRepo.EXPECT().Delete(gomock.Any(), author, "draft_id").Return(nil)
+		}, http.StatusOK, `{"message": "success"}`},
+		{"публикацию отклонил автомодератор", testHandler.Publish, http.MethodPost, "", func() {
+			testRepo.EXPECT().Publish(gomock.Any(), author, "draft_id").Return(nil, &post.RejectedError{Message: "no"})
+		}, http.StatusUnprocessableEntity, `{"message": "no"}`},
+		{"такая ссылка уже есть", testHandler.Publish, http.MethodPost, "", func() {
+			testRepo.EXPECT().Publish(gomock.Any(), author, "draft_id").Return(nil, &post.DuplicateError{Original: &post.Post{ID: primitive.NilObjectID}})
+		}, http.StatusConflict, ""},
+		{"опубликован", testHandler.Publish, http.MethodPost, "", func() {
+			testRepo.EXPECT().Publish(gomock.Any(), author, "draft_id").Return(&post.Post{Title: "t"}, nil)
+		}, http.StatusCreated, ""},
+	}
+	for _, testCase := range cases {
+		testCase.expect()
+		respWriter := send(testCase.handler, testCase.method, testCase.body)
+		if respWriter.Code != testCase.status {
+			t.Errorf("%s: expected status %d, got status %d", testCase.name, testCase.status, respWriter.Code)
+			return
+		}
+		if testCase.resp != "" && respWriter.Body.String() != testCase.resp {
+			t.Errorf("%s: expected body %s, got %s", testCase.name, testCase.resp, respWriter.Body.String())
+			return
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"reddit/pkg/feed"
+	"reddit/pkg/post"
+	"reddit/pkg/response"
+	"reddit/pkg/user"
+)
+
+const (
+	feedRSS  = "rss"
+	feedAtom = "atom"
+)
+
+// anonymousFilter - читалки ходят анонимно, поэтому в ленты попадает то же, что видит аноним
+func anonymousFilter() *post.ListFilter {
+	return &post.ListFilter{HideNSFW: true}
+}
+
+// baseURL - адрес сайта для ссылок из ленты: PublicURL, если задан, иначе из запроса
+func (ph *PostHandler) baseURL(r *http.Request) string {
+	if ph.PublicURL != "" {
+		return strings.TrimSuffix(ph.PublicURL, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func (ph *PostHandler) CategoryRSS(w http.ResponseWriter, r *http.Request) {
+	ph.categoryFeed(w, r, feedRSS)
+}
+
+func (ph *PostHandler) CategoryAtom(w http.ResponseWriter, r *http.Request) {
+	ph.categoryFeed(w, r, feedAtom)
+}
+
+func (ph *PostHandler) categoryFeed(w http.ResponseWriter, r *http.Request, format string) {
+	category := mux.Vars(r)["CATEGORY_NAME"]
+	posts, err := ph.PostRepo.GetPostByCategory(r.Context(), category, anonymousFilter())
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "can not get posts: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	base := ph.baseURL(r)
+	path := "/a/" + url.PathEscape(category)
+	postsFeed := feed.New(category, "urn:reddit:category:"+category, base, path, base+r.URL.RequestURI(), posts)
+	ph.writeFeed(w, r, postsFeed, format)
+}
+
+func (ph *PostHandler) UserAtom(w http.ResponseWriter, r *http.Request) {
+	userLogin := mux.Vars(r)["USER_LOGIN"]
+	posts, err := ph.PostRepo.GetPostsByUserID(r.Context(), userLogin, anonymousFilter())
+	if errors.Is(err, user.ErrNoUser) {
+		errText := fmt.Sprintf(`{"message": "there is no user with username %s"}`, userLogin)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "can not get posts: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	base := ph.baseURL(r)
+	path := "/u/" + url.PathEscape(userLogin)
+	postsFeed := feed.New("Posts by "+userLogin, "urn:reddit:user:"+userLogin, base, path, base+r.URL.RequestURI(), posts)
+	ph.writeFeed(w, r, postsFeed, feedAtom)
+}
+
+// writeFeed отвечает 304 на If-None-Match с тем же ETag или If-Modified-Since не раньше последнего поста
+func (ph *PostHandler) writeFeed(w http.ResponseWriter, r *http.Request, postsFeed *feed.Feed, format string) {
+	var body []byte
+	var err error
+	contentType := "application/atom+xml; charset=utf-8"
+	if format == feedRSS {
+		contentType = "application/rss+xml; charset=utf-8"
+		body, err = postsFeed.RSS()
+	} else {
+		body, err = postsFeed.Atom()
+	}
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in coding feed: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", feed.ETag(body))
+	if ph.PublicURL != "" {
+		w.Header().Set("Cache-Control", "public, max-age=60")
+	} else {
+		// ссылки собраны из заголовков запроса, общему кэшу такую ленту отдавать другим клиентам нельзя
+		w.Header().Set("Cache-Control", "private, max-age=60")
+		w.Header().Set("Vary", "Host, X-Forwarded-Proto")
+	}
+	// ServeContent сам разбирает If-None-Match и If-Modified-Since
+	http.ServeContent(w, r, "", postsFeed.Updated(), bytes.NewReader(body))
+}
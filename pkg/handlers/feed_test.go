@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"reddit/pkg/post"
+	"reddit/pkg/user"
+)
+
+func TestPostHandlerFeeds(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := post.NewMockPostRepo(ctrl)
+	testHandler := &PostHandler{
+		Logger:    zap.NewNop().Sugar(),
+		PostRepo:  testRepo,
+		PublicURL: "https://example.org/",
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/api/posts/{CATEGORY_NAME}.rss", testHandler.CategoryRSS).Methods(http.MethodGet, http.MethodHead)
+	router.HandleFunc("/api/posts/{CATEGORY_NAME}.atom", testHandler.CategoryAtom).Methods(http.MethodGet, http.MethodHead)
+	router.HandleFunc("/api/user/{USER_LOGIN}.atom", testHandler.UserAtom).Methods(http.MethodGet, http.MethodHead)
+	router.HandleFunc("/api/posts/{CATEGORY_NAME}", testHandler.ListByCategory).Methods(http.MethodGet)
+
+	objID, err := primitive.ObjectIDFromHex("654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Fatalf("error in id")
+	}
+	posts := []*post.Post{
+		{
+			ID:       objID,
+			Type:     "text",
+			Title:    "fef",
+			Category: "music",
+			Author:   &user.User{Username: "hhhhhhhh"},
+			Created:  "2023-11-11T14:22:11.695Z",
+		},
+	}
+
+	// лента категории строится из того же, что отдает ListByCategory
+	testRepo.EXPECT().GetPostByCategory(gomock.Any(), "music", &post.ListFilter{HideNSFW: true}).Return(posts, nil)
+	respWriter := httptest.NewRecorder()
+	router.ServeHTTP(respWriter, httptest.NewRequest(http.MethodGet, "/api/posts/music.rss", nil))
+	if respWriter.Code != http.StatusOK {
+		t.Errorf("expected status %d, got status %d", http.StatusOK, respWriter.Code)
+		return
+	}
+	if contentType := respWriter.Header().Get("Content-Type"); contentType != "application/rss+xml; charset=utf-8" {
+		t.Errorf("wrong content type: %s", contentType)
+		return
+	}
+	if lastModified := respWriter.Header().Get("Last-Modified"); lastModified != "Sat, 11 Nov 2023 14:22:11 GMT" {
+		t.Errorf("wrong last modified: %s", lastModified)
+		return
+	}
+	if !strings.Contains(respWriter.Body.String(), "<link>https://example.org/a/music/654f63e3a2414a2a554b6423</link>") {
+		t.Errorf("wrong feed: %s", respWriter.Body.String())
+		return
+	}
+	if cacheControl := respWriter.Header().Get("Cache-Control"); cacheControl != "public, max-age=60" {
+		t.Errorf("wrong cache control: %s", cacheControl)
+		return
+	}
+	etag := respWriter.Header().Get("ETag")
+
+	cases := []struct {
+		name    string
+		url     string
+		header  string
+		value   string
+		user    bool
+		retErr  error
+		status  int
+		snippet string
+	}{
+		{"тот же etag", "/api/posts/music.rss", "If-None-Match", etag, false, nil, http.StatusNotModified, ""},
+		{"etag изменился", "/api/posts/music.rss", "If-None-Match", `"old"`, false, nil, http.StatusOK, "<rss"},
+		{"новых постов не было", "/api/posts/music.atom", "If-Modified-Since", "Sat, 11 Nov 2023 14:22:11 GMT", false, nil, http.StatusNotModified, ""},
+		{"появились новые посты", "/api/posts/music.atom", "If-Modified-Since", "Sat, 11 Nov 2023 14:00:00 GMT", false, nil, http.StatusOK, "<feed"},
+		{"ошибка базы", "/api/posts/music.atom", "", "", false, fmt.Errorf("db_error"), http.StatusInternalServerError, ""},
+		{"лента пользователя", "/api/user/hhhhhhhh.atom", "", "", true, nil, http.StatusOK, "<id>urn:reddit:user:hhhhhhhh</id>"},
+		{"нет пользователя", "/api/user/hhhhhhhh.atom", "", "", true, user.ErrNoUser, http.StatusNotFound, ""},
+	}
+	for _, testCase := range cases {
+		if testCase.user {
+			testRepo.EXPECT().GetPostsByUserID(gomock.Any(), "hhhhhhhh", &post.ListFilter{HideNSFW: true}).Return(posts, testCase.retErr)
+		} else {
+			testRepo.EXPECT().GetPostByCategory(gomock.Any(), "music", &post.ListFilter{HideNSFW: true}).Return(posts, testCase.retErr)
+		}
+		request := httptest.NewRequest(http.MethodGet, testCase.url, nil)
+		if testCase.header != "" {
+			request.Header.Set(testCase.header, testCase.value)
+		}
+		respWriter = httptest.NewRecorder()
+		router.ServeHTTP(respWriter, request)
+		if respWriter.Code != testCase.status {
+			t.Errorf("[%s] expected status %d, got status %d", testCase.name, testCase.status, respWriter.Code)
+			return
+		}
+		if !strings.Contains(respWriter.Body.String(), testCase.snippet) {
+			t.Errorf("[%s] wrong body: %s", testCase.name, respWriter.Body.String())
+			return
+		}
+	}
+}
+
+func TestPostHandlerFeedWithoutPublicURL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := post.NewMockPostRepo(ctrl)
+	testHandler := &PostHandler{
+		Logger:   zap.NewNop().Sugar(),
+		PostRepo: testRepo,
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/api/posts/{CATEGORY_NAME}.atom", testHandler.CategoryAtom).Methods(http.MethodGet)
+
+	// ссылки берутся из Host и X-Forwarded-Proto, поэтому лента не должна попасть в общий кэш
+	testRepo.EXPECT().GetPostByCategory(gomock.Any(), "music", &post.ListFilter{HideNSFW: true}).Return([]*post.Post{}, nil)
+	request := httptest.NewRequest(http.MethodGet, "/api/posts/music.atom", nil)
+	request.Host = "evil.example"
+	request.Header.Set("X-Forwarded-Proto", "https")
+	respWriter := httptest.NewRecorder()
+	router.ServeHTTP(respWriter, request)
+	if respWriter.Code != http.StatusOK {
+		t.Errorf("expected status %d, got status %d", http.StatusOK, respWriter.Code)
+		return
+	}
+	if !strings.Contains(respWriter.Body.String(), "https://evil.example/a/music") {
+		t.Errorf("wrong feed: %s", respWriter.Body.String())
+		return
+	}
+	if cacheControl := respWriter.Header().Get("Cache-Control"); cacheControl != "private, max-age=60" {
+		t.Errorf("wrong cache control: %s", cacheControl)
+		return
+	}
+	if vary := respWriter.Header().Get("Vary"); vary != "Host, X-Forwarded-Proto" {
+		t.Errorf("wrong vary: %s", vary)
+		return
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"reddit/pkg/response"
+
+	"reddit/pkg/blob"
+)
+
+const MediaPrefix = "/media/"
+
+type MediaHandler struct {
+	Storage blob.Storage
+	Logger  *zap.SugaredLogger
+}
+
+func (mh *MediaHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	key := vars["KEY"]
+	object, err := mh.Storage.Get(key)
+	if errors.Is(err, blob.ErrNotFound) || errors.Is(err, blob.ErrBadKey) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in reading file: %s"}`, err)
+		response.WriteResponse(mh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	// имя файла - хеш содержимого, поэтому его можно кешировать навсегда
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", `"`+key+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if object.ContentType != "" {
+		w.Header().Set("Content-Type", object.ContentType)
+	}
+	http.ServeContent(w, r, key, object.ModTime, bytes.NewReader(object.Data))
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"reddit/pkg/blob"
+	"reddit/pkg/media"
+	"reddit/pkg/middleware"
+	"reddit/pkg/post"
+	"reddit/pkg/user"
+)
+
+func imagePostRequest(t *testing.T, fields map[string]string, file []byte) *http.Request {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			t.Fatalf("can not write field: %s", err)
+		}
+	}
+	if file != nil {
+		part, err := writer.CreateFormFile("file", "picture.png")
+		if err != nil {
+			t.Fatalf("can not create file part: %s", err)
+		}
+		_, _ = part.Write(file)
+	}
+	_ = writer.Close()
+	request := httptest.NewRequest(http.MethodPost, "/api/posts/image", body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	return request
+}
+
+func TestPostHandlerNewImagePost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storage, err := blob.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testRepo := post.NewMockPostRepo(ctrl)
+	testHandler := &PostHandler{
+		Logger:   zap.NewNop().Sugar(),
+		PostRepo: testRepo,
+		Uploader: media.NewUploader(storage),
+	}
+	mediaHandler := &MediaHandler{
+		Logger:  zap.NewNop().Sugar(),
+		Storage: storage,
+	}
+	currentUser := &user.User{
+		ID:       "GgGHsZysctdVTaCvTZWhgzLReBThTXHc",
+		Username: "jjjjjjjj",
+	}
+	picture := &bytes.Buffer{}
+	if err = png.Encode(picture, image.NewRGBA(image.Rect(0, 0, 40, 20))); err != nil {
+		t.Fatalf("can not encode png: %s", err)
+	}
+	fields := map[string]string{"title": "cat", "category": "funny"}
+
+	// вместо картинки прислали html
+	request := imagePostRequest(t, fields, []byte("<html></html>"))
+	respWriter := httptest.NewRecorder()
+	testHandler.NewImagePost(respWriter, request.WithContext(context.WithValue(request.Context(), middleware.MyUserKey, currentUser)))
+	if resp := respWriter.Result(); resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("expected status %d, got status %d", http.StatusUnsupportedMediaType, resp.StatusCode)
+		return
+	}
+
+	// нет заголовка - не проходит валидацию
+	request = imagePostRequest(t, map[string]string{"category": "funny"}, picture.Bytes())
+	respWriter = httptest.NewRecorder()
+	testHandler.NewImagePost(respWriter, request.WithContext(context.WithValue(request.Context(), middleware.MyUserKey, currentUser)))
+	if resp := respWriter.Result(); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d, got status %d", http.StatusUnprocessableEntity, resp.StatusCode)
+		return
+	}
+
+	// пост с картинкой создан
+	var created *post.Post
+	testRepo.EXPECT().AddPost(gomock.Any(), gomock.Any(), currentUser).DoAndReturn(func(_ context.Context, newPost *post.Post, _ *user.User) (*post.Post, error) {
+		created = newPost
+		return newPost, nil
+	})
+	request = imagePostRequest(t, fields, picture.Bytes())
+	respWriter = httptest.NewRecorder()
+	testHandler.NewImagePost(respWriter, request.WithContext(context.WithValue(request.Context(), middleware.MyUserKey, currentUser)))
+	if resp := respWriter.Result(); resp.StatusCode != http.StatusCreated {
+		t.Errorf("expected status %d, got status %d", http.StatusCreated, resp.StatusCode)
+		return
+	}
+	if created.Type != "image" || created.Image.Width != 40 || !strings.HasPrefix(created.Image.URL, MediaPrefix) {
+		t.Errorf("wrong image post: %+v", created.Image)
+		return
+	}
+
+	// картинка отдается с заголовками кеширования
+	key := strings.TrimPrefix(created.Image.URL, MediaPrefix)
+	request = mux.SetURLVars(httptest.NewRequest(http.MethodGet, created.Image.URL, nil), map[string]string{"KEY": key})
+	respWriter = httptest.NewRecorder()
+	mediaHandler.Serve(respWriter, request)
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/png" || !strings.Contains(resp.Header.Get("Cache-Control"), "max-age") {
+		t.Errorf("wrong media response: %d %v", resp.StatusCode, resp.Header)
+		return
+	}
+
+	// повторный запрос с ETag не отдает тело
+	request.Header.Set("If-None-Match", resp.Header.Get("ETag"))
+	respWriter = httptest.NewRecorder()
+	mediaHandler.Serve(respWriter, request)
+	if resp = respWriter.Result(); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("expected status %d, got status %d", http.StatusNotModified, resp.StatusCode)
+		return
+	}
+
+	// файла нет
+	request = mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/media/absent.png", nil), map[string]string{"KEY": "absent.png"})
+	respWriter = httptest.NewRecorder()
+	mediaHandler.Serve(respWriter, request)
+	if resp = respWriter.Result(); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status %d, got status %d", http.StatusNotFound, resp.StatusCode)
+		return
+	}
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"reddit/pkg/message"
+	"reddit/pkg/middleware"
+	"reddit/pkg/response"
+	"reddit/pkg/user"
+)
+
+type MessageHandler struct {
+	MessageRepo message.MessageRepo
+	Logger      *zap.SugaredLogger
+}
+
+func (mh *MessageHandler) Send(w http.ResponseWriter, r *http.Request) {
+	sender, ok := mh.currentUser(w, r)
+	if !ok {
+		return
+	}
+	rBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in reading request body: %s"}`, err)
+		response.WriteResponse(mh.Logger, w, []byte(errText), http.StatusBadRequest)
+		return
+	}
+	form := &message.MessageForm{}
+	err = json.Unmarshal(rBody, form)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in json decoding of message form: %s"}`, err)
+		response.WriteResponse(mh.Logger, w, []byte(errText), http.StatusBadRequest)
+		return
+	}
+	if validationErrors := form.Validate(); len(validationErrors) != 0 {
+		mh.writeJSON(w, validationErrors, http.StatusUnprocessableEntity)
+		return
+	}
+	newMessage, err := mh.MessageRepo.Send(r.Context(), sender, form.To, form.Body)
+	if err != nil {
+		mh.writeError(w, err)
+		return
+	}
+	mh.writeJSON(w, newMessage, http.StatusCreated)
+}
+
+func (mh *MessageHandler) Inbox(w http.ResponseWriter, r *http.Request) {
+	mh.list(w, r, mh.MessageRepo.Inbox)
+}
+
+func (mh *MessageHandler) Outbox(w http.ResponseWriter, r *http.Request) {
+	mh.list(w, r, mh.MessageRepo.Outbox)
+}
+
+func (mh *MessageHandler) Thread(w http.ResponseWriter, r *http.Request) {
+	peerUsername := mux.Vars(r)["USER_LOGIN"]
+	mh.list(w, r, func(ctx context.Context, userID string, filter *message.Filter) ([]*message.Message, error) {
+		return mh.MessageRepo.Thread(ctx, userID, peerUsername, filter)
+	})
+}
+
+func (mh *MessageHandler) list(w http.ResponseWriter, r *http.Request, getMessages func(ctx context.Context, userID string, filter *message.Filter) ([]*message.Message, error)) {
+	currentUser, ok := mh.currentUser(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	filter := &message.Filter{}
+	var err error
+	if page := query.Get("page"); page != "" {
+		filter.Page, err = strconv.Atoi(page)
+		if err != nil {
+			response.WriteResponse(mh.Logger, w, []byte(`{"message": "page must be a number"}`), http.StatusBadRequest)
+			return
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			response.WriteResponse(mh.Logger, w, []byte(`{"message": "limit must be a number"}`), http.StatusBadRequest)
+			return
+		}
+	}
+	messages, err := getMessages(r.Context(), currentUser.ID, filter)
+	if err != nil {
+		mh.writeError(w, err)
+		return
+	}
+	mh.writeJSON(w, messages, http.StatusOK)
+}
+
+func (mh *MessageHandler) Threads(w http.ResponseWriter, r *http.Request) {
+	currentUser, ok := mh.currentUser(w, r)
+	if !ok {
+		return
+	}
+	threads, err := mh.MessageRepo.Threads(r.Context(), currentUser.ID)
+	if err != nil {
+		mh.writeError(w, err)
+		return
+	}
+	mh.writeJSON(w, threads, http.StatusOK)
+}
+
+func (mh *MessageHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	currentUser, ok := mh.currentUser(w, r)
+	if !ok {
+		return
+	}
+	err := mh.MessageRepo.MarkRead(r.Context(), currentUser.ID, mux.Vars(r)["MESSAGE_ID"])
+	if err != nil {
+		mh.writeError(w, err)
+		return
+	}
+	response.WriteResponse(mh.Logger, w, []byte(`{"message": "success"}`), http.StatusOK)
+}
+
+func (mh *MessageHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	currentUser, ok := mh.currentUser(w, r)
+	if !ok {
+		return
+	}
+	err := mh.MessageRepo.Delete(r.Context(), currentUser.ID, mux.Vars(r)["MESSAGE_ID"])
+	if err != nil {
+		mh.writeError(w, err)
+		return
+	}
+	response.WriteResponse(mh.Logger, w, []byte(`{"message": "success"}`), http.StatusOK)
+}
+
+func (mh *MessageHandler) Block(w http.ResponseWriter, r *http.Request) {
+	currentUser, ok := mh.currentUser(w, r)
+	if !ok {
+		return
+	}
+	err := mh.MessageRepo.Block(r.Context(), currentUser, mux.Vars(r)["USER_LOGIN"])
+	if err != nil {
+		mh.writeError(w, err)
+		return
+	}
+	response.WriteResponse(mh.Logger, w, []byte(`{"message": "success"}`), http.StatusOK)
+}
+
+func (mh *MessageHandler) Unblock(w http.ResponseWriter, r *http.Request) {
+	currentUser, ok := mh.currentUser(w, r)
+	if !ok {
+		return
+	}
+	err := mh.MessageRepo.Unblock(r.Context(), currentUser.ID, mux.Vars(r)["USER_LOGIN"])
+	if err != nil {
+		mh.writeError(w, err)
+		return
+	}
+	response.WriteResponse(mh.Logger, w, []byte(`{"message": "success"}`), http.StatusOK)
+}
+
+func (mh *MessageHandler) Blocks(w http.ResponseWriter, r *http.Request) {
+	currentUser, ok := mh.currentUser(w, r)
+	if !ok {
+		return
+	}
+	blocks, err := mh.MessageRepo.Blocks(r.Context(), currentUser.ID)
+	if err != nil {
+		mh.writeError(w, err)
+		return
+	}
+	mh.writeJSON(w, blocks, http.StatusOK)
+}
+
+func (mh *MessageHandler) currentUser(w http.ResponseWriter, r *http.Request) (*user.User, bool) {
+	currentUser, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(mh.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return nil, false
+	}
+	return currentUser, true
+}
+
+func (mh *MessageHandler) writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, user.ErrNoUser):
+		response.WriteResponse(mh.Logger, w, []byte(`{"message": "there is no such user"}`), http.StatusNotFound)
+	case errors.Is(err, message.ErrNoMessage):
+		response.WriteResponse(mh.Logger, w, []byte(`{"message": "there is no such message"}`), http.StatusNotFound)
+	case errors.Is(err, message.ErrNoBlock):
+		response.WriteResponse(mh.Logger, w, []byte(`{"message": "user is not blocked"}`), http.StatusNotFound)
+	case errors.Is(err, message.ErrSelfMessage):
+		errText := fmt.Sprintf(`{"message": "%s"}`, err)
+		response.WriteResponse(mh.Logger, w, []byte(errText), http.StatusUnprocessableEntity)
+	case errors.Is(err, message.ErrBlocked):
+		errText := fmt.Sprintf(`{"message": "%s"}`, err)
+		response.WriteResponse(mh.Logger, w, []byte(errText), http.StatusForbidden)
+	case errors.Is(err, message.ErrRateLimited):
+		errText := fmt.Sprintf(`{"message": "%s"}`, err)
+		response.WriteResponse(mh.Logger, w, []byte(errText), http.StatusTooManyRequests)
+	default:
+		errText := fmt.Sprintf(`{"message": "error in message processing: %s"}`, err)
+		response.WriteResponse(mh.Logger, w, []byte(errText), http.StatusInternalServerError)
+	}
+}
+
+func (mh *MessageHandler) writeJSON(w http.ResponseWriter, value interface{}, status int) {
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in coding messages: %s"}`, err)
+		response.WriteResponse(mh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	response.WriteResponse(mh.Logger, w, valueJSON, status)
+}
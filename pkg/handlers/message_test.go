@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"reddit/pkg/message"
+	"reddit/pkg/middleware"
+	"reddit/pkg/user"
+)
+
+func TestMessageHandlerSend(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := message.NewMockMessageRepo(ctrl)
+	testHandler := &MessageHandler{
+		MessageRepo: testRepo,
+		Logger:      zap.NewNop().Sugar(),
+	}
+	sender := &user.User{ID: "sender_id", Username: "sender"}
+
+	cases := []struct {
+		name          string
+		body          string
+		returnMessage *message.Message
+		returnErr     error
+		status        int
+	}{
+		{"кривой json", `{"to": `, nil, nil, http.StatusBadRequest},
+		{"пустое сообщение", `{"to": "recipient", "body": ""}`, nil, nil, http.StatusUnprocessableEntity},
+		{"кривое имя", `{"to": "<b>", "body": "привет"}`, nil, nil, http.StatusUnprocessableEntity},
+		{"нет пользователя", `{"to": "recipient", "body": "привет"}`, nil, user.ErrNoUser, http.StatusNotFound},
+		{"себе", `{"to": "recipient", "body": "привет"}`, nil, message.ErrSelfMessage, http.StatusUnprocessableEntity},
+		{"заблокирован", `{"to": "recipient", "body": "привет"}`, nil, message.ErrBlocked, http.StatusForbidden},
+		{"лимит", `{"to": "recipient", "body": "привет"}`, nil, message.ErrRateLimited, http.StatusTooManyRequests},
+		{"какая то ошибка сервера", `{"to": "recipient", "body": "привет"}`, nil, fmt.Errorf("error"), http.StatusInternalServerError},
+		{"отправлено", `{"to": "recipient", "body": "привет"}`, &message.Message{Body: "привет"}, nil, http.StatusCreated},
+	}
+	for _, testCase := range cases {
+		if testCase.returnMessage != nil || testCase.returnErr != nil {
+			testRepo.EXPECT().Send(gomock.Any(), sender, "recipient", "привет").Return(testCase.returnMessage, testCase.returnErr)
+		}
+		request := httptest.NewRequest(http.MethodPost, "/api/messages", strings.NewReader(testCase.body))
+		ctx := context.WithValue(request.Context(), middleware.MyUserKey, sender)
+		respWriter := httptest.NewRecorder()
+		testHandler.Send(respWriter, request.WithContext(ctx))
+		if respWriter.Code != testCase.status {
+			t.Errorf("%s: expected status %d, got status %d", testCase.name, testCase.status, respWriter.Code)
+			return
+		}
+	}
+}
+
+func TestMessageHandlerLists(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := message.NewMockMessageRepo(ctrl)
+	testHandler := &MessageHandler{
+		MessageRepo: testRepo,
+		Logger:      zap.NewNop().Sugar(),
+	}
+	currentUser := &user.User{ID: "user_id", Username: "jjjjjjjj"}
+	newRequest := func(target string, vars map[string]string) *http.Request {
+		request := httptest.NewRequest(http.MethodGet, target, nil)
+		request = mux.SetURLVars(request, vars)
+		return request.WithContext(context.WithValue(request.Context(), middleware.MyUserKey, currentUser))
+	}
+
+	// кривая страница
+	respWriter := httptest.NewRecorder()
+	testHandler.Inbox(respWriter, newRequest("/api/messages/inbox?page=x", nil))
+	if respWriter.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got status %d", http.StatusBadRequest, respWriter.Code)
+		return
+	}
+
+	testRepo.EXPECT().Inbox(gomock.Any(), "user_id", &message.Filter{Page: 2, Limit: 5}).Return([]*message.Message{{Body: "входящее"}}, nil)
+	respWriter = httptest.NewRecorder()
+	testHandler.Inbox(respWriter, newRequest("/api/messages/inbox?page=2&limit=5", nil))
+	if respWriter.Code != http.StatusOK || !strings.Contains(respWriter.Body.String(), "входящее") {
+		t.Errorf("unexpected response: %d %s", respWriter.Code, respWriter.Body.String())
+		return
+	}
+
+	testRepo.EXPECT().Outbox(gomock.Any(), "user_id", &message.Filter{}).Return(nil, fmt.Errorf("error"))
+	respWriter = httptest.NewRecorder()
+	testHandler.Outbox(respWriter, newRequest("/api/messages/outbox", nil))
+	if respWriter.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got status %d", http.StatusInternalServerError, respWriter.Code)
+		return
+	}
+
+	testRepo.EXPECT().Thread(gomock.Any(), "user_id", "alice", &message.Filter{}).Return(nil, user.ErrNoUser)
+	respWriter = httptest.NewRecorder()
+	testHandler.Thread(respWriter, newRequest("/api/messages/threads/alice", map[string]string{"USER_LOGIN": "alice"}))
+	if respWriter.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got status %d", http.StatusNotFound, respWriter.Code)
+		return
+	}
+
+	testRepo.EXPECT().Threads(gomock.Any(), "user_id").Return([]*message.Thread{{With: &user.User{Username: "alice"}, Unread: 3}}, nil)
+	respWriter = httptest.NewRecorder()
+	testHandler.Threads(respWriter, newRequest("/api/messages/threads", nil))
+	if respWriter.Code != http.StatusOK || !strings.Contains(respWriter.Body.String(), `"unread":3`) {
+		t.Errorf("unexpected response: %d %s", respWriter.Code, respWriter.Body.String())
+		return
+	}
+}
+
+func TestMessageHandlerChange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := message.NewMockMessageRepo(ctrl)
+	testHandler := &MessageHandler{
+		MessageRepo: testRepo,
+		Logger:      zap.NewNop().Sugar(),
+	}
+	currentUser := &user.User{ID: "user_id", Username: "jjjjjjjj"}
+
+	cases := []struct {
+		name    string
+		handler http.HandlerFunc
+		expect  func(err error)
+		vars    map[string]string
+		err     error
+		status  int
+	}{
+		{"прочитать чужое", testHandler.MarkRead, func(err error) {
+			testRepo.EXPECT().MarkRead(gomock.Any(), "user_id", "message_id").Return(err)
+		}, map[string]string{"MESSAGE_ID": "message_id"}, message.ErrNoMessage, http.StatusNotFound},
+		{"удалить у себя", testHandler.Delete, func(err error) {
+			testRepo.EXPECT().Delete(gomock.Any(), "user_id", "message_id").Return(err)
+		}, map[string]string{"MESSAGE_ID": "message_id"}, nil, http.StatusOK},
+		{"заблокировать", testHandler.Block, func(err error) {
+			testRepo.EXPECT().Block(gomock.Any(), currentUser, "alice").Return(err)
+		}, map[string]string{"USER_LOGIN": "alice"}, nil, http.StatusOK},
+		{"разблокировать незаблокированного", testHandler.Unblock, func(err error) {
+			testRepo.EXPECT().Unblock(gomock.Any(), "user_id", "alice").Return(err)
+		}, map[string]string{"USER_LOGIN": "alice"}, message.ErrNoBlock, http.StatusNotFound},
+	}
+	for _, testCase := range cases {
+		testCase.expect(testCase.err)
+		request := httptest.NewRequest(http.MethodPost, "/api/messages", nil)
+		request = mux.SetURLVars(request, testCase.vars)
+		ctx := context.WithValue(request.Context(), middleware.MyUserKey, currentUser)
+		respWriter := httptest.NewRecorder()
+		testCase.handler(respWriter, request.WithContext(ctx))
+		if respWriter.Code != testCase.status {
+			t.Errorf("%s: expected status %d, got status %d", testCase.name, testCase.status, respWriter.Code)
+			return
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"reddit/pkg/middleware"
+	"reddit/pkg/notification"
+	"reddit/pkg/response"
+	"reddit/pkg/user"
+)
+
+type NotificationHandler struct {
+	NotificationRepo notification.NotificationRepo
+	Logger           *zap.SugaredLogger
+}
+
+func (nh *NotificationHandler) List(w http.ResponseWriter, r *http.Request) {
+	currentUser, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(nh.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	query := r.URL.Query()
+	filter := &notification.Filter{}
+	var err error
+	if page := query.Get("page"); page != "" {
+		filter.Page, err = strconv.Atoi(page)
+		if err != nil {
+			response.WriteResponse(nh.Logger, w, []byte(`{"message": "page must be a number"}`), http.StatusBadRequest)
+			return
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			response.WriteResponse(nh.Logger, w, []byte(`{"message": "limit must be a number"}`), http.StatusBadRequest)
+			return
+		}
+	}
+	if unread := query.Get("unread"); unread != "" {
+		filter.UnreadOnly, err = strconv.ParseBool(unread)
+		if err != nil {
+			response.WriteResponse(nh.Logger, w, []byte(`{"message": "unread must be true or false"}`), http.StatusBadRequest)
+			return
+		}
+	}
+	page, err := nh.NotificationRepo.GetNotifications(r.Context(), currentUser.ID, filter)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "can not get notifications: %s"}`, err)
+		response.WriteResponse(nh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	pageJSON, err := json.Marshal(page)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in coding notifications: %s"}`, err)
+		response.WriteResponse(nh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	response.WriteResponse(nh.Logger, w, pageJSON, http.StatusOK)
+}
+
+func (nh *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	notificationID := mux.Vars(r)["NOTIFICATION_ID"]
+	currentUser, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(nh.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	err := nh.NotificationRepo.MarkRead(r.Context(), currentUser.ID, notificationID)
+	if errors.Is(err, notification.ErrNoNotification) {
+		errText := fmt.Sprintf(`{"message": "there is no notification with id %s"}`, notificationID)
+		response.WriteResponse(nh.Logger, w, []byte(errText), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in marking notification as read: %s"}`, err)
+		response.WriteResponse(nh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	response.WriteResponse(nh.Logger, w, []byte(`{"message": "success"}`), http.StatusOK)
+}
+
+func (nh *NotificationHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	currentUser, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(nh.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	marked, err := nh.NotificationRepo.MarkAllRead(r.Context(), currentUser.ID)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in marking notifications as read: %s"}`, err)
+		response.WriteResponse(nh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	response.WriteResponse(nh.Logger, w, []byte(fmt.Sprintf(`{"marked": %d}`, marked)), http.StatusOK)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"reddit/pkg/middleware"
+	"reddit/pkg/notification"
+	"reddit/pkg/user"
+)
+
+func TestNotificationHandlerList(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := notification.NewMockNotificationRepo(ctrl)
+	testHandler := &NotificationHandler{
+		NotificationRepo: testRepo,
+		Logger:           zap.NewNop().Sugar(),
+	}
+	currentUser := &user.User{ID: "user_id", Username: "jjjjjjjj"}
+
+	cases := []struct {
+		name      string
+		query     string
+		filter    *notification.Filter
+		returnErr error
+		status    int
+	}{
+		{"кривая страница", "?page=x", nil, nil, http.StatusBadRequest},
+		{"кривой лимит", "?limit=x", nil, nil, http.StatusBadRequest},
+		{"кривой unread", "?unread=maybe", nil, nil, http.StatusBadRequest},
+		{"ошибка базы", "", &notification.Filter{}, fmt.Errorf("error"), http.StatusInternalServerError},
+		{"непрочитанные", "?page=2&limit=10&unread=true", &notification.Filter{Page: 2, Limit: 10, UnreadOnly: true}, nil, http.StatusOK},
+	}
+	for _, testCase := range cases {
+		if testCase.filter != nil {
+			page := &notification.Page{Notifications: []*notification.Notification{{Kind: notification.KindMention}}, Unread: 1}
+			if testCase.returnErr != nil {
+				page = nil
+			}
+			testRepo.EXPECT().GetNotifications(gomock.Any(), "user_id", testCase.filter).Return(page, testCase.returnErr)
+		}
+		request := httptest.NewRequest(http.MethodGet, "/api/notifications"+testCase.query, nil)
+		ctx := context.WithValue(request.Context(), middleware.MyUserKey, currentUser)
+		respWriter := httptest.NewRecorder()
+		testHandler.List(respWriter, request.WithContext(ctx))
+		if respWriter.Code != testCase.status {
+			t.Errorf("%s: expected status %d, got status %d", testCase.name, testCase.status, respWriter.Code)
+			return
+		}
+		if testCase.status == http.StatusOK && !strings.Contains(respWriter.Body.String(), `"unread":1`) {
+			t.Errorf("%s: wrong body: %s", testCase.name, respWriter.Body.String())
+			return
+		}
+	}
+}
+
+func TestNotificationHandlerMarkRead(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := notification.NewMockNotificationRepo(ctrl)
+	testHandler := &NotificationHandler{
+		NotificationRepo: testRepo,
+		Logger:           zap.NewNop().Sugar(),
+	}
+	currentUser := &user.User{ID: "user_id", Username: "jjjjjjjj"}
+
+	cases := []struct {
+		name      string
+		returnErr error
+		status    int
+	}{
+		{"нет уведомления", notification.ErrNoNotification, http.StatusNotFound},
+		{"ошибка базы", fmt.Errorf("error"), http.StatusInternalServerError},
+		{"прочитано", nil, http.StatusOK},
+	}
+	for _, testCase := range cases {
+		testRepo.EXPECT().MarkRead(gomock.Any(), "user_id", "notification_id").Return(testCase.returnErr)
+		request := httptest.NewRequest(http.MethodPost, "/api/notifications/notification_id/read", nil)
+		request = mux.SetURLVars(request, map[string]string{"NOTIFICATION_ID": "notification_id"})
+		ctx := context.WithValue(request.Context(), middleware.MyUserKey, currentUser)
+		respWriter := httptest.NewRecorder()
+		testHandler.MarkRead(respWriter, request.WithContext(ctx))
+		if respWriter.Code != testCase.status {
+			t.Errorf("%s: expected status %d, got status %d", testCase.name, testCase.status, respWriter.Code)
+			return
+		}
+	}
+
+	// все сразу
+	testRepo.EXPECT().MarkAllRead(gomock.Any(), "user_id").Return(int64(4), nil)
+	request := httptest.NewRequest(http.MethodPost, "/api/notifications/read", nil)
+	ctx := context.WithValue(request.Context(), middleware.MyUserKey, currentUser)
+	respWriter := httptest.NewRecorder()
+	testHandler.MarkAllRead(respWriter, request.WithContext(ctx))
+	if respWriter.Code != http.StatusOK || respWriter.Body.String() != `{"marked": 4}` {
+		t.Errorf("unexpected response: %d %s", respWriter.Code, respWriter.Body.String())
+		return
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"go.uber.org/zap"
@@ -13,23 +14,155 @@ import (
 
 	"github.com/gorilla/mux"
 	"reddit/pkg/comment"
+	"reddit/pkg/media"
 	"reddit/pkg/middleware"
 	"reddit/pkg/post"
+	"reddit/pkg/report"
+	"reddit/pkg/saved"
 	"reddit/pkg/user"
 )
 
+const (
+	multipartMemory   = 1 << 20
+	multipartOverhead = 1 << 20
+	// maxPostBody - с запасом больше самого длинного допустимого текста поста
+	maxPostBody = 1 << 20
+)
+
 type PostHandler struct {
-	PostRepo post.PostRepo
-	Logger   *zap.SugaredLogger
+	PostRepo   post.PostRepo
+	UserRepo   user.UserRepo
+	Uploader   *media.Uploader
+	Moderators *user.Moderators
+	SavedRepo  saved.SavedRepo
+	Logger     *zap.SugaredLogger
+	// PublicURL - адрес сайта для ссылок в rss и atom, по умолчанию берется из запроса
+	PublicURL string
+}
+
+// postETag - версия поста, с ней клиент присылает правку в If-Match
+func postETag(target *post.Post) string {
+	return strconv.Quote(strconv.Itoa(target.Version))
+}
+
+// ifMatchVersion - без If-Match или с * правка идет поверх любой версии, ok == false - в заголовке не наш ETag
+func ifMatchVersion(r *http.Request) (int, bool) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return post.AnyVersion, true
+	}
+	unquoted, err := strconv.Unquote(ifMatch)
+	if err != nil || !strings.HasPrefix(ifMatch, `"`) {
+		return 0, false
+	}
+	version, err := strconv.Atoi(unquoted)
+	if err != nil || version < 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+func (ph *PostHandler) writePreconditionFailed(w http.ResponseWriter) {
+	response.WriteResponse(ph.Logger, w, []byte(`{"message": "post was changed, reload it and try again"}`), http.StatusPreconditionFailed)
+}
+
+func viewerID(r *http.Request) string {
+	viewer, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		return ""
+	}
+	return viewer.ID
+}
+
+func (ph *PostHandler) listFilter(r *http.Request) *post.ListFilter {
+	query := r.URL.Query()
+	filter := &post.ListFilter{
+		Flair:    query.Get("flair"),
+		Tag:      query.Get("tag"),
+		HideNSFW: true,
+	}
+	viewer, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok || ph.UserRepo == nil {
+		return filter
+	}
+	preferences, err := ph.UserRepo.GetPreferences(r.Context(), viewer.ID)
+	if err != nil {
+		ph.Logger.Infof("can not get preferences of user %s: %s", viewer.ID, err)
+		return filter
+	}
+	filter.HideNSFW = preferences.NSFW == user.NSFWHide
+	filter.BlurNSFW = preferences.NSFW == user.NSFWBlur
+	return filter
+}
+
+// hidePosts убирает из ленты посты, скрытые зрителем. Скрытые ищутся только среди постов выдачи,
+// а не выгружаются целиком, иначе каждый запрос ленты рос бы с каждым скрытием
+func (ph *PostHandler) hidePosts(r *http.Request, posts []*post.Post) []*post.Post {
+	viewer, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok || ph.SavedRepo == nil || len(posts) == 0 {
+		return posts
+	}
+	postIDs := make([]string, 0, len(posts))
+	for _, currentPost := range posts {
+		postIDs = append(postIDs, currentPost.ID.Hex())
+	}
+	hiddenIDs, err := ph.SavedRepo.HiddenPostIDs(r.Context(), viewer.ID, postIDs)
+	if err != nil {
+		ph.Logger.Infof("can not get hidden posts of user %s: %s", viewer.ID, err)
+		return posts
+	}
+	if len(hiddenIDs) == 0 {
+		return posts
+	}
+	hidden := make(map[string]struct{}, len(hiddenIDs))
+	for _, postID := range hiddenIDs {
+		hidden[postID] = struct{}{}
+	}
+	visiblePosts := make([]*post.Post, 0, len(posts))
+	for _, currentPost := range posts {
+		if _, ok := hidden[currentPost.ID.Hex()]; !ok {
+			visiblePosts = append(visiblePosts, currentPost)
+		}
+	}
+	return visiblePosts
 }
 
-func (ph *PostHandler) List(w http.ResponseWriter, _ *http.Request) {
-	posts, err := ph.PostRepo.GetAll()
+// hideComments убирает из поста комментарии, скрытые зрителем
+func (ph *PostHandler) hideComments(r *http.Request, viewedPost *post.Post) {
+	viewer, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok || ph.SavedRepo == nil || len(viewedPost.Comments) == 0 {
+		return
+	}
+	hiddenIDs, err := ph.SavedRepo.HiddenCommentIDs(r.Context(), viewer.ID, viewedPost.ID.Hex())
+	if err != nil {
+		ph.Logger.Infof("can not get hidden comments of user %s: %s", viewer.ID, err)
+		return
+	}
+	if len(hiddenIDs) == 0 {
+		return
+	}
+	hidden := make(map[string]struct{}, len(hiddenIDs))
+	for _, commentID := range hiddenIDs {
+		hidden[commentID] = struct{}{}
+	}
+	visibleComments := make([]*comment.Comment, 0, len(viewedPost.Comments))
+	for _, currentComment := range viewedPost.Comments {
+		if _, ok := hidden[currentComment.ID]; !ok {
+			visibleComments = append(visibleComments, currentComment)
+		}
+	}
+	viewedPost.Comments = visibleComments
+}
+
+func (ph *PostHandler) List(w http.ResponseWriter, r *http.Request) {
+	posts, err := ph.PostRepo.GetAll(r.Context(), ph.listFilter(r))
 	if err != nil {
 		errText := fmt.Sprintf(`{"message": "can not get posts: %s"}`, err)
 		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
 		return
 	}
+	posts = ph.hidePosts(r, posts)
+	post.HidePollResults(viewerID(r), posts...)
 	postsJSON, err := json.Marshal(posts)
 	if err != nil {
 		errText := fmt.Sprintf(`{"message": "error in coding posts: %s"}`, err)
@@ -47,10 +180,15 @@ func (ph *PostHandler) NewPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	postFromForm := &post.Post{}
-	rBody, err := io.ReadAll(r.Body)
+	rBody, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPostBody))
 	if err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
 		errText := fmt.Sprintf(`{"message": "error in reading request body: %s"}`, err)
-		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusBadRequest)
+		response.WriteResponse(ph.Logger, w, []byte(errText), status)
 		return
 	}
 	err = json.Unmarshal(rBody, postFromForm)
@@ -60,7 +198,70 @@ func (ph *PostHandler) NewPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	ph.Logger.Infof("postForm %v", postFromForm)
+	if postFromForm.Type == "image" {
+		response.WriteResponse(ph.Logger, w, []byte(`{"message": "image posts must be uploaded to /api/posts/image"}`), http.StatusUnprocessableEntity)
+		return
+	}
+	postFromForm.Image = nil
+	postFromForm.CrosspostOf = ""
+
+	if validationErrors := postFromForm.Validate(); len(validationErrors) != 0 {
+		var errorsJSON []byte
+		errorsJSON, err = json.Marshal(validationErrors)
+		if err != nil {
+			errText := fmt.Sprintf(`{"message": "error in json coding of validation errors of post: %s"}`, err)
+			response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
+			return
+		}
+		response.WriteResponse(ph.Logger, w, errorsJSON, http.StatusUnprocessableEntity)
+		return
+	}
+
+	ph.addPost(w, r, postFromForm, author)
+}
 
+func (ph *PostHandler) NewImagePost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	author, ok := ctx.Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(ph.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, ph.Uploader.MaxSize+multipartOverhead)
+	err := r.ParseMultipartForm(multipartMemory)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in reading multipart form: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusRequestEntityTooLarge)
+		return
+	}
+	defer func() {
+		_ = r.MultipartForm.RemoveAll()
+	}()
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "there is no file in form: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in reading file: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusBadRequest)
+		return
+	}
+	postFromForm := &post.Post{
+		Type:     "image",
+		Title:    r.FormValue("title"),
+		Category: r.FormValue("category"),
+		Flair:    r.FormValue("flair"),
+		NSFW:     r.FormValue("nsfw") == "true",
+		Spoiler:  r.FormValue("spoiler") == "true",
+		Image:    &post.Image{},
+	}
+	if tags := r.FormValue("tags"); tags != "" {
+		postFromForm.Tags = strings.Split(tags, ",")
+	}
 	if validationErrors := postFromForm.Validate(); len(validationErrors) != 0 {
 		var errorsJSON []byte
 		errorsJSON, err = json.Marshal(validationErrors)
@@ -72,13 +273,56 @@ func (ph *PostHandler) NewPost(w http.ResponseWriter, r *http.Request) {
 		response.WriteResponse(ph.Logger, w, errorsJSON, http.StatusUnprocessableEntity)
 		return
 	}
+	upload, err := ph.Uploader.Upload(data)
+	if errors.Is(err, media.ErrTooLarge) {
+		errText := fmt.Sprintf(`{"message": "%s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if errors.Is(err, media.ErrUnsupportedType) {
+		errText := fmt.Sprintf(`{"message": "%s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusUnsupportedMediaType)
+		return
+	}
+	if errors.Is(err, media.ErrBadImage) {
+		errText := fmt.Sprintf(`{"message": "%s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in saving image: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	postFromForm.Image = &post.Image{
+		URL:          MediaPrefix + upload.Key,
+		ThumbnailURL: MediaPrefix + upload.ThumbnailKey,
+		Width:        upload.Width,
+		Height:       upload.Height,
+	}
+	ph.addPost(w, r, postFromForm, author)
+}
 
-	addedPost, err := ph.PostRepo.AddPost(postFromForm, author)
+func (ph *PostHandler) addPost(w http.ResponseWriter, r *http.Request, postFromForm *post.Post, author *user.User) {
+	addedPost, err := ph.PostRepo.AddPost(r.Context(), postFromForm, author)
+	var rejectedErr *post.RejectedError
+	if errors.As(err, &rejectedErr) {
+		errText := fmt.Sprintf(`{"message": "%s"}`, rejectedErr.Message)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusUnprocessableEntity)
+		return
+	}
+	var duplicateErr *post.DuplicateError
+	if errors.As(err, &duplicateErr) {
+		errText := fmt.Sprintf(`{"message": "%s", "duplicateOf": "%s"}`, post.ErrDuplicate, duplicateErr.Original.ID.Hex())
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		errText := fmt.Sprintf(`{"message": "error in adding post: %s"}`, err)
 		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
 		return
 	}
+	post.HidePollResults(author.ID, addedPost)
 	newPostJSON, err := json.Marshal(addedPost)
 	if err != nil {
 		errText := fmt.Sprintf(`{"message": "error in coding posts: %s"}`, err)
@@ -93,12 +337,14 @@ func (ph *PostHandler) NewPost(w http.ResponseWriter, r *http.Request) {
 func (ph *PostHandler) ListByCategory(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	category := vars["CATEGORY_NAME"]
-	posts, err := ph.PostRepo.GetPostByCategory(category)
+	posts, err := ph.PostRepo.GetPostByCategory(r.Context(), category, ph.listFilter(r))
 	if err != nil {
 		errText := fmt.Sprintf(`{"message": "can not get posts: %s"}`, err)
 		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
 		return
 	}
+	posts = ph.hidePosts(r, posts)
+	post.HidePollResults(viewerID(r), posts...)
 	postsJSON, err := json.Marshal(posts)
 	if err != nil {
 		errText := fmt.Sprintf(`{"message": "error in coding posts: %s"}`, err)
@@ -108,10 +354,23 @@ func (ph *PostHandler) ListByCategory(w http.ResponseWriter, r *http.Request) {
 	response.WriteResponse(ph.Logger, w, postsJSON, http.StatusOK)
 }
 
+func (ph *PostHandler) ListFlairs(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	category := vars["CATEGORY_NAME"]
+	flairsJSON, err := json.Marshal(post.FlairsOf(category))
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in coding flairs: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	response.WriteResponse(ph.Logger, w, flairsJSON, http.StatusOK)
+}
+
 func (ph *PostHandler) GetPostInfo(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	postID := vars["POST_ID"]
-	curPost, err := ph.PostRepo.GetPostByID(postID)
+	viewer, _ := r.Context().Value(middleware.MyUserKey).(*user.User)
+	curPost, err := ph.PostRepo.GetPostByID(r.Context(), postID, viewer, ph.Moderators.IsModerator(viewer))
 
 	if errors.Is(err, post.ErrNoPost) {
 		errText := fmt.Sprintf(`{"message": "there is no post with id %s"}`, postID)
@@ -123,12 +382,15 @@ func (ph *PostHandler) GetPostInfo(w http.ResponseWriter, r *http.Request) {
 		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
 		return
 	}
+	ph.hideComments(r, curPost)
+	post.HidePollResults(viewerID(r), curPost)
 	postsJSON, err := json.Marshal(curPost)
 	if err != nil {
 		errText := fmt.Sprintf(`{"message": "error in coding posts: %s"}`, err)
 		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("ETag", postETag(curPost))
 	response.WriteResponse(ph.Logger, w, postsJSON, http.StatusOK)
 
 }
@@ -168,16 +430,41 @@ func (ph *PostHandler) NewComment(w http.ResponseWriter, r *http.Request) {
 		response.WriteResponse(ph.Logger, w, errorsJSON, http.StatusUnprocessableEntity)
 		return
 	}
-	myPost, err := ph.PostRepo.AddComment(commentFromForm.Body, author, postID)
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		ph.writePreconditionFailed(w)
+		return
+	}
+	myPost, err := ph.PostRepo.AddComment(r.Context(), commentFromForm.Body, author, postID, version)
+	if errors.Is(err, post.ErrPreconditionFailed) {
+		ph.writePreconditionFailed(w)
+		return
+	}
+	if errors.Is(err, post.ErrVersionConflict) {
+		response.WriteResponse(ph.Logger, w, []byte(`{"message": "post is being changed concurrently, try again"}`), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, post.ErrLocked) || errors.Is(err, post.ErrArchived) {
+		errText := fmt.Sprintf(`{"message": "can not comment: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusForbidden)
+		return
+	}
 	if errors.Is(err, post.ErrNoPost) {
 		errText := fmt.Sprintf(`{"message": "there is no post with id %s"}`, postID)
 		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusNotFound)
 	}
+	var rejectedErr *post.RejectedError
+	if errors.As(err, &rejectedErr) {
+		errText := fmt.Sprintf(`{"message": "%s"}`, rejectedErr.Message)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusUnprocessableEntity)
+		return
+	}
 	if err != nil {
 		errText := fmt.Sprintf(`{"message": "error in adding new comment: %s"}`, err)
 		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
 		return
 	}
+	post.HidePollResults(viewerID(r), myPost)
 	postJSON, err := json.Marshal(myPost)
 	if err != nil {
 		errText := fmt.Sprintf(`{"message": "error in coding posts: %s"}`, err)
@@ -185,6 +472,7 @@ func (ph *PostHandler) NewComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	ph.Logger.Infof("new comment created")
+	w.Header().Set("ETag", postETag(myPost))
 	response.WriteResponse(ph.Logger, w, postJSON, http.StatusCreated)
 }
 
@@ -198,7 +486,20 @@ func (ph *PostHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 		response.WriteResponse(ph.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
 		return
 	}
-	myPost, err := ph.PostRepo.DeleteComment(currentUser.ID, postID, commentID)
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		ph.writePreconditionFailed(w)
+		return
+	}
+	myPost, err := ph.PostRepo.DeleteComment(r.Context(), currentUser.ID, postID, commentID, version)
+	if errors.Is(err, post.ErrPreconditionFailed) {
+		ph.writePreconditionFailed(w)
+		return
+	}
+	if errors.Is(err, post.ErrVersionConflict) {
+		response.WriteResponse(ph.Logger, w, []byte(`{"message": "post is being changed concurrently, try again"}`), http.StatusConflict)
+		return
+	}
 	if errors.Is(err, post.ErrNoAccess) {
 		errText := fmt.Sprintf(`{"message": "forbidden for this user: %s"}`, err)
 		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusForbidden)
@@ -219,6 +520,7 @@ func (ph *PostHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
 		return
 	}
+	post.HidePollResults(viewerID(r), myPost)
 	postJSON, err := json.Marshal(myPost)
 	if err != nil {
 		errText := fmt.Sprintf(`{"message": "error in coding posts: %s"}`, err)
@@ -226,6 +528,7 @@ func (ph *PostHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	ph.Logger.Infof("comment deleted")
+	w.Header().Set("ETag", postETag(myPost))
 	response.WriteResponse(ph.Logger, w, postJSON, http.StatusOK)
 
 }
@@ -241,15 +544,33 @@ func (ph *PostHandler) MakeVote(w http.ResponseWriter, r *http.Request) {
 	}
 	segmentsURL := strings.Split(r.URL.Path, "/")
 	voteAction := segmentsURL[len(segmentsURL)-1]
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		ph.writePreconditionFailed(w)
+		return
+	}
 	var myPost *post.Post
 	var err error
 	switch voteAction {
 	case "upvote":
-		myPost, err = ph.PostRepo.UpVote(postID, curUser.ID)
+		myPost, err = ph.PostRepo.UpVote(r.Context(), postID, curUser.ID, version)
 	case "downvote":
-		myPost, err = ph.PostRepo.DownVote(postID, curUser.ID)
+		myPost, err = ph.PostRepo.DownVote(r.Context(), postID, curUser.ID, version)
 	default:
-		myPost, err = ph.PostRepo.UnVote(postID, curUser.ID)
+		myPost, err = ph.PostRepo.UnVote(r.Context(), postID, curUser.ID, version)
+	}
+	if errors.Is(err, post.ErrPreconditionFailed) {
+		ph.writePreconditionFailed(w)
+		return
+	}
+	if errors.Is(err, post.ErrVersionConflict) {
+		response.WriteResponse(ph.Logger, w, []byte(`{"message": "post is being changed concurrently, try again"}`), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, post.ErrLocked) || errors.Is(err, post.ErrArchived) {
+		errText := fmt.Sprintf(`{"message": "can not vote: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusForbidden)
+		return
 	}
 	if errors.Is(err, post.ErrNoPost) {
 		errText := fmt.Sprintf(`{"message": "there is no post with id %s"}`, postID)
@@ -261,6 +582,7 @@ func (ph *PostHandler) MakeVote(w http.ResponseWriter, r *http.Request) {
 		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
 		return
 	}
+	post.HidePollResults(viewerID(r), myPost)
 	postJSON, err := json.Marshal(myPost)
 	if err != nil {
 		errText := fmt.Sprintf(`{"message": "error in coding posts: %s"}`, err)
@@ -268,6 +590,7 @@ func (ph *PostHandler) MakeVote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	ph.Logger.Infof("vote added/deleted")
+	w.Header().Set("ETag", postETag(myPost))
 	response.WriteResponse(ph.Logger, w, postJSON, http.StatusOK)
 
 }
@@ -281,8 +604,20 @@ func (ph *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
 		response.WriteResponse(ph.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
 		return
 	}
-	isDeleted, err := ph.PostRepo.DeletePost(currentUser.ID, postID)
-
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		ph.writePreconditionFailed(w)
+		return
+	}
+	isDeleted, err := ph.PostRepo.DeletePost(r.Context(), currentUser.ID, postID, version)
+	if errors.Is(err, post.ErrPreconditionFailed) {
+		ph.writePreconditionFailed(w)
+		return
+	}
+	if errors.Is(err, post.ErrVersionConflict) {
+		response.WriteResponse(ph.Logger, w, []byte(`{"message": "post is being changed concurrently, try again"}`), http.StatusConflict)
+		return
+	}
 	if errors.Is(err, post.ErrNoPost) {
 		errText := fmt.Sprintf(`{"message": "there is no post with id %s"}`, postID)
 		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusNotFound)
@@ -305,10 +640,334 @@ func (ph *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
 	response.WriteResponse(ph.Logger, w, []byte(`{"message": "fail"}`), http.StatusUnprocessableEntity)
 }
 
+func (ph *PostHandler) RestorePost(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	postID := vars["POST_ID"]
+	ctx := r.Context()
+	currentUser, ok := ctx.Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(ph.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		ph.writePreconditionFailed(w)
+		return
+	}
+	restoredPost, err := ph.PostRepo.RestorePost(r.Context(), currentUser, postID, ph.Moderators.IsModerator(currentUser), version)
+	if errors.Is(err, post.ErrPreconditionFailed) {
+		ph.writePreconditionFailed(w)
+		return
+	}
+	if errors.Is(err, post.ErrVersionConflict) {
+		response.WriteResponse(ph.Logger, w, []byte(`{"message": "post is being changed concurrently, try again"}`), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, post.ErrNoPost) {
+		errText := fmt.Sprintf(`{"message": "there is no post with id %s"}`, postID)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, post.ErrNoAccess) {
+		errText := fmt.Sprintf(`{"message": "forbidden for this user: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, post.ErrExpired) {
+		errText := fmt.Sprintf(`{"message": "post %s can not be restored: %s"}`, postID, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusGone)
+		return
+	}
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in post restoring: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	post.HidePollResults(viewerID(r), restoredPost)
+	postJSON, err := json.Marshal(restoredPost)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in coding posts: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	ph.Logger.Infof("post %s restored", postID)
+	w.Header().Set("ETag", postETag(restoredPost))
+	response.WriteResponse(ph.Logger, w, postJSON, http.StatusOK)
+}
+
+func (ph *PostHandler) SetMarks(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	postID := vars["POST_ID"]
+	ctx := r.Context()
+	currentUser, ok := ctx.Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(ph.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	marks := &post.Marks{}
+	rBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in reading request body: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusBadRequest)
+		return
+	}
+	err = json.Unmarshal(rBody, marks)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in json decoding of marks: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusBadRequest)
+		return
+	}
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		ph.writePreconditionFailed(w)
+		return
+	}
+	markedPost, err := ph.PostRepo.SetMarks(r.Context(), currentUser, postID, ph.Moderators.IsModerator(currentUser), marks, version)
+	if errors.Is(err, post.ErrPreconditionFailed) {
+		ph.writePreconditionFailed(w)
+		return
+	}
+	if errors.Is(err, post.ErrVersionConflict) {
+		response.WriteResponse(ph.Logger, w, []byte(`{"message": "post is being changed concurrently, try again"}`), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, post.ErrNoPost) {
+		errText := fmt.Sprintf(`{"message": "there is no post with id %s"}`, postID)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, post.ErrNoAccess) {
+		errText := fmt.Sprintf(`{"message": "forbidden for this user: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in setting post marks: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	post.HidePollResults(viewerID(r), markedPost)
+	postJSON, err := json.Marshal(markedPost)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in coding posts: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", postETag(markedPost))
+	response.WriteResponse(ph.Logger, w, postJSON, http.StatusOK)
+}
+
+func (ph *PostHandler) Lock(w http.ResponseWriter, r *http.Request) {
+	ph.setLocked(w, r, true)
+}
+
+func (ph *PostHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	ph.setLocked(w, r, false)
+}
+
+func (ph *PostHandler) setLocked(w http.ResponseWriter, r *http.Request, locked bool) {
+	postID := mux.Vars(r)["POST_ID"]
+	moderator, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(ph.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	moderationForm := &report.ModerationForm{}
+	rBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in reading request body: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusBadRequest)
+		return
+	}
+	if len(rBody) != 0 {
+		err = json.Unmarshal(rBody, moderationForm)
+		if err != nil {
+			errText := fmt.Sprintf(`{"message": "error in json decoding of moderation form: %s"}`, err)
+			response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusBadRequest)
+			return
+		}
+	}
+	if validationErrors := moderationForm.Validate(); len(validationErrors) != 0 {
+		var errorsJSON []byte
+		errorsJSON, err = json.Marshal(validationErrors)
+		if err != nil {
+			errText := fmt.Sprintf(`{"message": "error in json coding of validation errors: %s"}`, err)
+			response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
+			return
+		}
+		response.WriteResponse(ph.Logger, w, errorsJSON, http.StatusUnprocessableEntity)
+		return
+	}
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		ph.writePreconditionFailed(w)
+		return
+	}
+	lockedPost, err := ph.PostRepo.SetLocked(r.Context(), moderator, postID, locked, moderationForm.Reason, version)
+	if errors.Is(err, post.ErrPreconditionFailed) {
+		ph.writePreconditionFailed(w)
+		return
+	}
+	if errors.Is(err, post.ErrVersionConflict) {
+		response.WriteResponse(ph.Logger, w, []byte(`{"message": "post is being changed concurrently, try again"}`), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, post.ErrNoPost) {
+		errText := fmt.Sprintf(`{"message": "there is no post with id %s"}`, postID)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in locking post: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	post.HidePollResults(moderator.ID, lockedPost)
+	postJSON, err := json.Marshal(lockedPost)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in coding posts: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", postETag(lockedPost))
+	response.WriteResponse(ph.Logger, w, postJSON, http.StatusOK)
+}
+
+type crosspostForm struct {
+	Category string `json:"category"`
+	Title    string `json:"title"`
+}
+
+func (ph *PostHandler) Crosspost(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	postID := vars["POST_ID"]
+	ctx := r.Context()
+	author, ok := ctx.Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(ph.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	form := &crosspostForm{}
+	rBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in reading request body: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusBadRequest)
+		return
+	}
+	err = json.Unmarshal(rBody, form)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in json decoding of crosspost form: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusBadRequest)
+		return
+	}
+	original, err := ph.PostRepo.FindPostByID(r.Context(), postID)
+	// отложенный пост до одобрения не кросспостится
+	if errors.Is(err, post.ErrNoPost) || err == nil && original.Status == post.StatusHeld {
+		errText := fmt.Sprintf(`{"message": "there is no post with id %s"}`, postID)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in getting post: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	crosspost, err := original.CrosspostTo(form.Category, form.Title)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "%s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusUnprocessableEntity)
+		return
+	}
+	if validationErrors := crosspost.Validate(); len(validationErrors) != 0 {
+		var errorsJSON []byte
+		errorsJSON, err = json.Marshal(validationErrors)
+		if err != nil {
+			errText := fmt.Sprintf(`{"message": "error in json coding of validation errors of post: %s"}`, err)
+			response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
+			return
+		}
+		response.WriteResponse(ph.Logger, w, errorsJSON, http.StatusUnprocessableEntity)
+		return
+	}
+	ph.addPost(w, r, crosspost, author)
+}
+
+type pollVoteForm struct {
+	Option string `json:"option"`
+}
+
+func (ph *PostHandler) VotePoll(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	postID := vars["POST_ID"]
+	ctx := r.Context()
+	currentUser, ok := ctx.Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(ph.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	form := &pollVoteForm{}
+	rBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in reading request body: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusBadRequest)
+		return
+	}
+	err = json.Unmarshal(rBody, form)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in json decoding of poll vote: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusBadRequest)
+		return
+	}
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		ph.writePreconditionFailed(w)
+		return
+	}
+	votedPost, err := ph.PostRepo.VotePoll(r.Context(), postID, currentUser.ID, form.Option, version)
+	if errors.Is(err, post.ErrPreconditionFailed) {
+		ph.writePreconditionFailed(w)
+		return
+	}
+	if errors.Is(err, post.ErrLocked) || errors.Is(err, post.ErrArchived) {
+		errText := fmt.Sprintf(`{"message": "can not vote: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, post.ErrNoPost) {
+		errText := fmt.Sprintf(`{"message": "there is no post with id %s"}`, postID)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, post.ErrNotPoll) || errors.Is(err, post.ErrNoPollOption) {
+		errText := fmt.Sprintf(`{"message": "%s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusUnprocessableEntity)
+		return
+	}
+	if errors.Is(err, post.ErrAlreadyVoted) || errors.Is(err, post.ErrPollClosed) {
+		errText := fmt.Sprintf(`{"message": "%s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in poll voting: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	post.HidePollResults(currentUser.ID, votedPost)
+	postJSON, err := json.Marshal(votedPost)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in coding posts: %s"}`, err)
+		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", postETag(votedPost))
+	response.WriteResponse(ph.Logger, w, postJSON, http.StatusOK)
+}
+
 func (ph *PostHandler) ListByUserLogin(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userLogin := vars["USER_LOGIN"]
-	posts, err := ph.PostRepo.GetPostsByUserID(userLogin)
+	posts, err := ph.PostRepo.GetPostsByUserID(r.Context(), userLogin, ph.listFilter(r))
 	if errors.Is(err, user.ErrNoUser) {
 		errText := fmt.Sprintf(`{"message": "there is no user with username %s"}`, userLogin)
 		response.WriteResponse(ph.Logger, w, []byte(errText), http.StatusNotFound)
@@ -319,6 +978,7 @@ func (ph *PostHandler) ListByUserLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	w.Header()
+	post.HidePollResults(viewerID(r), posts...)
 	postsJSON, err := json.Marshal(posts)
 	if err != nil {
 		errText := fmt.Sprintf(`{"message": "error in coding posts: %s"}`, err)
@@ -30,7 +30,7 @@ func TestPostHandlerList(t *testing.T) {
 		PostRepo: testRepo,
 	}
 
-	testRepo.EXPECT().GetAll().Return(nil, fmt.Errorf("error"))
+	testRepo.EXPECT().GetAll(gomock.Any(), &post.ListFilter{HideNSFW: true}).Return(nil, fmt.Errorf("error"))
 	request := httptest.NewRequest(http.MethodGet, "/api/posts/", nil)
 	respWriter := httptest.NewRecorder()
 	testHandler.List(respWriter, request)
@@ -75,7 +75,7 @@ func TestPostHandlerList(t *testing.T) {
 			ID:               objID,
 		},
 	}
-	testRepo.EXPECT().GetAll().Return(posts, nil)
+	testRepo.EXPECT().GetAll(gomock.Any(), &post.ListFilter{HideNSFW: true}).Return(posts, nil)
 	request = httptest.NewRequest(http.MethodGet, "/api/posts/", nil)
 	respWriter = httptest.NewRecorder()
 	testHandler.List(respWriter, request)
@@ -145,6 +145,25 @@ func TestPostHandlerNewPost(t *testing.T) {
 		t.Errorf("expected status %d, got status %d", http.StatusBadRequest, resp.StatusCode)
 	}
 
+	//  тело запроса больше допустимого
+	request = httptest.NewRequest(http.MethodPost, "/api/posts", strings.NewReader(strings.Repeat("a", maxPostBody+1)))
+	ctx = request.Context()
+	ctx = context.WithValue(ctx, middleware.MyUserKey, &user.User{
+		ID:       "fd3f43f3",
+		Username: "rvfvryby",
+	})
+	respWriter = httptest.NewRecorder()
+	testHandler.NewPost(respWriter, request.WithContext(ctx))
+	resp = respWriter.Result()
+	_, err = io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("unable to read response body")
+		return
+	}
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status %d, got status %d", http.StatusRequestEntityTooLarge, resp.StatusCode)
+	}
+
 	//  не получилось сделать анмаршал запроса
 	request = httptest.NewRequest(http.MethodPost, "/api/posts", strings.NewReader(`{""`))
 	ctx = request.Context()
@@ -198,7 +217,7 @@ func TestPostHandlerNewPost(t *testing.T) {
 		Username: "hhhhhhhh",
 	}
 
-	testRepo.EXPECT().AddPost(postToAdd, authorOfPost).Return(nil, fmt.Errorf("error"))
+	testRepo.EXPECT().AddPost(gomock.Any(), postToAdd, authorOfPost).Return(nil, fmt.Errorf("error"))
 	request = httptest.NewRequest(http.MethodPost, "/api/posts",
 		strings.NewReader(`{"category":"programming","text":"rferfer","title":"fef","type":"text"}`))
 	ctx = request.Context()
@@ -248,7 +267,7 @@ func TestPostHandlerNewPost(t *testing.T) {
 		ID:               objID,
 	}
 
-	testRepo.EXPECT().AddPost(postToAdd, authorOfPost).Return(createdPost, nil)
+	testRepo.EXPECT().AddPost(gomock.Any(), postToAdd, authorOfPost).Return(createdPost, nil)
 	request = httptest.NewRequest(http.MethodPost, "/api/posts",
 		strings.NewReader(`{"category":"programming","text":"rferfer","title":"fef","type":"text"}`))
 	ctx = request.Context()
@@ -287,7 +306,7 @@ func TestPostHandlerListByCategory(t *testing.T) {
 	}
 
 	// ошибка при поиске постов
-	testRepo.EXPECT().GetPostByCategory("programming").Return(nil, fmt.Errorf("error"))
+	testRepo.EXPECT().GetPostByCategory(gomock.Any(), "programming", &post.ListFilter{HideNSFW: true}).Return(nil, fmt.Errorf("error"))
 	request := httptest.NewRequest(http.MethodGet, "/api/posts/programming", nil)
 	request = mux.SetURLVars(request, map[string]string{"CATEGORY_NAME": "programming"})
 
@@ -336,9 +355,9 @@ func TestPostHandlerListByCategory(t *testing.T) {
 		},
 	}
 
-	//  корректный ответ с постами
-	testRepo.EXPECT().GetPostByCategory("programming").Return(posts, nil)
-	request = httptest.NewRequest(http.MethodGet, "/api/posts/programming", nil)
+	//  корректный ответ с постами, фильтры берутся из запроса
+	testRepo.EXPECT().GetPostByCategory(gomock.Any(), "programming", &post.ListFilter{Flair: "discussion", Tag: "go", HideNSFW: true}).Return(posts, nil)
+	request = httptest.NewRequest(http.MethodGet, "/api/posts/programming?flair=discussion&tag=go", nil)
 	request = mux.SetURLVars(request, map[string]string{"CATEGORY_NAME": "programming"})
 	respWriter = httptest.NewRecorder()
 	testHandler.ListByCategory(respWriter, request)
@@ -373,7 +392,7 @@ func TestPostHandlerGetPostInfo(t *testing.T) {
 	}
 
 	// пост не найден
-	testRepo.EXPECT().GetPostByID("id_which_not_exists").Return(nil, post.ErrNoPost)
+	testRepo.EXPECT().GetPostByID(gomock.Any(), "id_which_not_exists", gomock.Any(), false).Return(nil, post.ErrNoPost)
 	request := httptest.NewRequest(http.MethodGet, "/api/posts/id_which_not_exists", nil)
 	request = mux.SetURLVars(request, map[string]string{"POST_ID": "id_which_not_exists"})
 
@@ -391,7 +410,7 @@ func TestPostHandlerGetPostInfo(t *testing.T) {
 	}
 
 	//  какая то ошибка сервера при поиске поста
-	testRepo.EXPECT().GetPostByID("hrebhrbfher").Return(nil, fmt.Errorf("internal error"))
+	testRepo.EXPECT().GetPostByID(gomock.Any(), "hrebhrbfher", gomock.Any(), false).Return(nil, fmt.Errorf("internal error"))
 	request = httptest.NewRequest(http.MethodGet, "/api/posts/hrebhrbfher", nil)
 	request = mux.SetURLVars(request, map[string]string{"POST_ID": "hrebhrbfher"})
 
@@ -439,7 +458,7 @@ func TestPostHandlerGetPostInfo(t *testing.T) {
 	}
 
 	// пост найден
-	testRepo.EXPECT().GetPostByID("654f63e3a2414a2a554b6423").Return(post, nil)
+	testRepo.EXPECT().GetPostByID(gomock.Any(), "654f63e3a2414a2a554b6423", gomock.Any(), false).Return(post, nil)
 	request = httptest.NewRequest(http.MethodGet, "/api/posts/654f63e3a2414a2a554b6423", nil)
 	request = mux.SetURLVars(request, map[string]string{"POST_ID": "654f63e3a2414a2a554b6423"})
 	respWriter = httptest.NewRecorder()
@@ -556,7 +575,7 @@ func TestPostHandlerNewComment(t *testing.T) {
 		Username: "hhhhhhhh",
 	}
 
-	testRepo.EXPECT().AddComment("some comment", authorOfPost, "not_exist_post").Return(nil, post.ErrNoPost)
+	testRepo.EXPECT().AddComment(gomock.Any(), "some comment", authorOfPost, "not_exist_post", post.AnyVersion).Return(nil, post.ErrNoPost)
 	request = httptest.NewRequest(http.MethodPost, "/api/post/not_exist_post",
 		strings.NewReader(`{"comment":"some comment"}`))
 	request = mux.SetURLVars(request, map[string]string{"POST_ID": "not_exist_post"})
@@ -577,7 +596,7 @@ func TestPostHandlerNewComment(t *testing.T) {
 	}
 
 	//  неизвестная ошибка при добавлении коммента
-	testRepo.EXPECT().AddComment("some comment", authorOfPost, "some_post").Return(nil, fmt.Errorf("error"))
+	testRepo.EXPECT().AddComment(gomock.Any(), "some comment", authorOfPost, "some_post", post.AnyVersion).Return(nil, fmt.Errorf("error"))
 	request = httptest.NewRequest(http.MethodPost, "/api/post/some_post",
 		strings.NewReader(`{"comment":"some comment"}`))
 	request = mux.SetURLVars(request, map[string]string{"POST_ID": "some_post"})
@@ -642,7 +661,7 @@ func TestPostHandlerNewComment(t *testing.T) {
 		Username: "jjjjjjjj",
 	}
 
-	testRepo.EXPECT().AddComment("some comment", authorOfComment, "654f63e3a2414a2a554b6423").Return(post, nil)
+	testRepo.EXPECT().AddComment(gomock.Any(), "some comment", authorOfComment, "654f63e3a2414a2a554b6423", -1).Return(post, nil)
 	request = httptest.NewRequest(http.MethodPost, "/api/post/654f63e3a2414a2a554b6423",
 		strings.NewReader(`{"comment":"some comment"}`))
 	request = mux.SetURLVars(request, map[string]string{"POST_ID": "654f63e3a2414a2a554b6423"})
@@ -702,7 +721,7 @@ func TestPostHandlerDeleteComment(t *testing.T) {
 	}
 
 	//  удалить пытается юзер, не являющийся автором коммента
-	testRepo.EXPECT().DeleteComment("GgGHsZysctdVTaCvTZWhgzLReBThTXHc", "feygfyfe", "hrgyfrfb").Return(nil, post.ErrNoAccess)
+	testRepo.EXPECT().DeleteComment(gomock.Any(), "GgGHsZysctdVTaCvTZWhgzLReBThTXHc", "feygfyfe", "hrgyfrfb", post.AnyVersion).Return(nil, post.ErrNoAccess)
 	request = httptest.NewRequest(http.MethodDelete, "/api/post/feygfyfe/hrgyfrfb", nil)
 	request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe", "COMMENT_ID": "hrgyfrfb"})
 	ctx = request.Context()
@@ -722,7 +741,7 @@ func TestPostHandlerDeleteComment(t *testing.T) {
 	}
 
 	//  пост не найден
-	testRepo.EXPECT().DeleteComment("GgGHsZysctdVTaCvTZWhgzLReBThTXHc", "feygfyfe", "hrgyfrfb").Return(nil, post.ErrNoPost)
+	testRepo.EXPECT().DeleteComment(gomock.Any(), "GgGHsZysctdVTaCvTZWhgzLReBThTXHc", "feygfyfe", "hrgyfrfb", post.AnyVersion).Return(nil, post.ErrNoPost)
 	request = httptest.NewRequest(http.MethodDelete, "/api/post/feygfyfe/hrgyfrfb", nil)
 	request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe", "COMMENT_ID": "hrgyfrfb"})
 	ctx = request.Context()
@@ -742,7 +761,7 @@ func TestPostHandlerDeleteComment(t *testing.T) {
 	}
 
 	//  коммент не найден
-	testRepo.EXPECT().DeleteComment("GgGHsZysctdVTaCvTZWhgzLReBThTXHc", "feygfyfe", "hrgyfrfb").Return(nil, post.ErrNoComment)
+	testRepo.EXPECT().DeleteComment(gomock.Any(), "GgGHsZysctdVTaCvTZWhgzLReBThTXHc", "feygfyfe", "hrgyfrfb", post.AnyVersion).Return(nil, post.ErrNoComment)
 	request = httptest.NewRequest(http.MethodDelete, "/api/post/feygfyfe/hrgyfrfb", nil)
 	request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe", "COMMENT_ID": "hrgyfrfb"})
 	ctx = request.Context()
@@ -762,7 +781,7 @@ func TestPostHandlerDeleteComment(t *testing.T) {
 	}
 
 	//  какая то ошибка сервера
-	testRepo.EXPECT().DeleteComment("GgGHsZysctdVTaCvTZWhgzLReBThTXHc", "feygfyfe", "hrgyfrfb").Return(nil, fmt.Errorf("error"))
+	testRepo.EXPECT().DeleteComment(gomock.Any(), "GgGHsZysctdVTaCvTZWhgzLReBThTXHc", "feygfyfe", "hrgyfrfb", post.AnyVersion).Return(nil, fmt.Errorf("error"))
 	request = httptest.NewRequest(http.MethodDelete, "/api/post/feygfyfe/hrgyfrfb", nil)
 	request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe", "COMMENT_ID": "hrgyfrfb"})
 	ctx = request.Context()
@@ -811,7 +830,7 @@ func TestPostHandlerDeleteComment(t *testing.T) {
 		ID:               objID,
 	}
 
-	testRepo.EXPECT().DeleteComment("GgGHsZysctdVTaCvTZWhgzLReBThTXHc", "654f63e3a2414a2a554b6423", "hrgyfrfb").
+	testRepo.EXPECT().DeleteComment(gomock.Any(), "GgGHsZysctdVTaCvTZWhgzLReBThTXHc", "654f63e3a2414a2a554b6423", "hrgyfrfb", post.AnyVersion).
 		Return(postWithDeletedComment, nil)
 	request = httptest.NewRequest(http.MethodDelete, "/api/post/654f63e3a2414a2a554b6423/hrgyfrfb", nil)
 	request = mux.SetURLVars(request, map[string]string{"POST_ID": "654f63e3a2414a2a554b6423", "COMMENT_ID": "hrgyfrfb"})
@@ -867,7 +886,7 @@ func TestPostHandlerMakeVote(t *testing.T) {
 		ID:       "GgGHsZysctdVTaCvTZWhgzLReBThTXHc",
 		Username: "jjjjjjjj",
 	}
-	testRepo.EXPECT().UpVote("feygfyfe", "GgGHsZysctdVTaCvTZWhgzLReBThTXHc").Return(nil, post.ErrNoPost)
+	testRepo.EXPECT().UpVote(gomock.Any(), "feygfyfe", "GgGHsZysctdVTaCvTZWhgzLReBThTXHc", post.AnyVersion).Return(nil, post.ErrNoPost)
 	request = httptest.NewRequest(http.MethodGet, "/api/post/feygfyfe/upvote", nil)
 	request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe"})
 	ctx = request.Context()
@@ -887,7 +906,7 @@ func TestPostHandlerMakeVote(t *testing.T) {
 	}
 
 	//  какая то ошибка сервера
-	testRepo.EXPECT().UnVote("feygfyfe", "GgGHsZysctdVTaCvTZWhgzLReBThTXHc").Return(nil, fmt.Errorf("error"))
+	testRepo.EXPECT().UnVote(gomock.Any(), "feygfyfe", "GgGHsZysctdVTaCvTZWhgzLReBThTXHc", post.AnyVersion).Return(nil, fmt.Errorf("error"))
 	request = httptest.NewRequest(http.MethodGet, "/api/post/feygfyfe/unvote", nil)
 	request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe"})
 	ctx = request.Context()
@@ -936,7 +955,7 @@ func TestPostHandlerMakeVote(t *testing.T) {
 		ID:               objID,
 	}
 
-	testRepo.EXPECT().DownVote("654f63e3a2414a2a554b6423", "GgGHsZysctdVTaCvTZWhgzLReBThTXHc").Return(postWithDownVote, nil)
+	testRepo.EXPECT().DownVote(gomock.Any(), "654f63e3a2414a2a554b6423", "GgGHsZysctdVTaCvTZWhgzLReBThTXHc", post.AnyVersion).Return(postWithDownVote, nil)
 	request = httptest.NewRequest(http.MethodGet, "/api/post/654f63e3a2414a2a554b6423/downvote", nil)
 	request = mux.SetURLVars(request, map[string]string{"POST_ID": "654f63e3a2414a2a554b6423"})
 	ctx = request.Context()
@@ -989,7 +1008,7 @@ func TestPostHandlerDeletePost(t *testing.T) {
 		Username: "jjjjjjjj",
 	}
 	//  удалить пытается юзер, не являющийся автором поста
-	testRepo.EXPECT().DeletePost("GgGHsZysctdVTaCvTZWhgzLReBThTXHc", "feygfyfe").Return(false, post.ErrNoAccess)
+	testRepo.EXPECT().DeletePost(gomock.Any(), "GgGHsZysctdVTaCvTZWhgzLReBThTXHc", "feygfyfe", post.AnyVersion).Return(false, post.ErrNoAccess)
 	request = httptest.NewRequest(http.MethodDelete, "/api/post/feygfyfe", nil)
 	request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe"})
 	ctx = request.Context()
@@ -1009,7 +1028,7 @@ func TestPostHandlerDeletePost(t *testing.T) {
 	}
 
 	//  пост не найден
-	testRepo.EXPECT().DeletePost("GgGHsZysctdVTaCvTZWhgzLReBThTXHc", "feygfyfe").Return(false, post.ErrNoPost)
+	testRepo.EXPECT().DeletePost(gomock.Any(), "GgGHsZysctdVTaCvTZWhgzLReBThTXHc", "feygfyfe", post.AnyVersion).Return(false, post.ErrNoPost)
 	request = httptest.NewRequest(http.MethodDelete, "/api/post/feygfyfe", nil)
 	request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe"})
 	ctx = request.Context()
@@ -1029,7 +1048,7 @@ func TestPostHandlerDeletePost(t *testing.T) {
 	}
 
 	//  какая то ошибка сервера
-	testRepo.EXPECT().DeletePost("GgGHsZysctdVTaCvTZWhgzLReBThTXHc", "feygfyfe").Return(false, fmt.Errorf("error"))
+	testRepo.EXPECT().DeletePost(gomock.Any(), "GgGHsZysctdVTaCvTZWhgzLReBThTXHc", "feygfyfe", post.AnyVersion).Return(false, fmt.Errorf("error"))
 	request = httptest.NewRequest(http.MethodDelete, "/api/post/feygfyfe", nil)
 	request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe"})
 	ctx = request.Context()
@@ -1049,7 +1068,7 @@ func TestPostHandlerDeletePost(t *testing.T) {
 	}
 
 	//  пост удален
-	testRepo.EXPECT().DeletePost("GgGHsZysctdVTaCvTZWhgzLReBThTXHc", "feygfyfe").Return(true, nil)
+	testRepo.EXPECT().DeletePost(gomock.Any(), "GgGHsZysctdVTaCvTZWhgzLReBThTXHc", "feygfyfe", post.AnyVersion).Return(true, nil)
 	request = httptest.NewRequest(http.MethodDelete, "/api/post/feygfyfe", nil)
 	request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe"})
 	ctx = request.Context()
@@ -1081,7 +1100,7 @@ func TestPostHandlerListByUserLogin(t *testing.T) {
 	}
 	//  юзер не найден
 
-	testRepo.EXPECT().GetPostsByUserID("username_not_exist").Return(nil, user.ErrNoUser)
+	testRepo.EXPECT().GetPostsByUserID(gomock.Any(), "username_not_exist", &post.ListFilter{HideNSFW: true}).Return(nil, user.ErrNoUser)
 	request := httptest.NewRequest(http.MethodGet, "/api/user/username_not_exist", nil)
 	request = mux.SetURLVars(request, map[string]string{"USER_LOGIN": "username_not_exist"})
 	respWriter := httptest.NewRecorder()
@@ -1098,7 +1117,7 @@ func TestPostHandlerListByUserLogin(t *testing.T) {
 
 	}
 	//  какая то ошибка сервера
-	testRepo.EXPECT().GetPostsByUserID("username").Return(nil, fmt.Errorf("error"))
+	testRepo.EXPECT().GetPostsByUserID(gomock.Any(), "username", &post.ListFilter{HideNSFW: true}).Return(nil, fmt.Errorf("error"))
 	request = httptest.NewRequest(http.MethodGet, "/api/user/username", nil)
 	request = mux.SetURLVars(request, map[string]string{"USER_LOGIN": "username"})
 	respWriter = httptest.NewRecorder()
@@ -1116,7 +1135,7 @@ func TestPostHandlerListByUserLogin(t *testing.T) {
 	}
 
 	//  посты юзера найдены
-	testRepo.EXPECT().GetPostsByUserID("username").Return([]*post.Post{}, nil)
+	testRepo.EXPECT().GetPostsByUserID(gomock.Any(), "username", &post.ListFilter{HideNSFW: true}).Return([]*post.Post{}, nil)
 	request = httptest.NewRequest(http.MethodGet, "/api/user/username", nil)
 	request = mux.SetURLVars(request, map[string]string{"USER_LOGIN": "username"})
 	respWriter = httptest.NewRecorder()
@@ -1133,3 +1152,433 @@ func TestPostHandlerListByUserLogin(t *testing.T) {
 
 	}
 }
+
+func TestPostHandlerRestorePost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := post.NewMockPostRepo(ctrl)
+	testHandler := &PostHandler{
+		Logger:     zap.NewNop().Sugar(),
+		PostRepo:   testRepo,
+		Moderators: user.NewModerators([]string{"moderator"}),
+	}
+	currentUser := &user.User{
+		ID:       "GgGHsZysctdVTaCvTZWhgzLReBThTXHc",
+		Username: "jjjjjjjj",
+	}
+	moderator := &user.User{
+		ID:       "moderatorID",
+		Username: "moderator",
+	}
+
+	cases := []struct {
+		name       string
+		user       *user.User
+		isModer    bool
+		returnPost *post.Post
+		returnErr  error
+		status     int
+	}{
+		{"пост не найден", currentUser, false, nil, post.ErrNoPost, http.StatusNotFound},
+		{"восстановить пытается не автор", currentUser, false, nil, post.ErrNoAccess, http.StatusForbidden},
+		{"срок восстановления истек", currentUser, false, nil, post.ErrExpired, http.StatusGone},
+		{"какая то ошибка сервера", currentUser, false, nil, fmt.Errorf("error"), http.StatusInternalServerError},
+		{"пост восстановлен модератором", moderator, true, &post.Post{Title: "fef"}, nil, http.StatusOK},
+	}
+	for _, testCase := range cases {
+		testRepo.EXPECT().RestorePost(gomock.Any(), testCase.user, "feygfyfe", testCase.isModer, post.AnyVersion).Return(testCase.returnPost, testCase.returnErr)
+		request := httptest.NewRequest(http.MethodPost, "/api/post/feygfyfe/restore", nil)
+		request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe"})
+		ctx := context.WithValue(request.Context(), middleware.MyUserKey, testCase.user)
+		respWriter := httptest.NewRecorder()
+		testHandler.RestorePost(respWriter, request.WithContext(ctx))
+		resp := respWriter.Result()
+		if resp.StatusCode != testCase.status {
+			t.Errorf("%s: expected status %d, got status %d", testCase.name, testCase.status, resp.StatusCode)
+			return
+		}
+	}
+}
+
+func TestPostHandlerNSFW(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := post.NewMockPostRepo(ctrl)
+	testUserRepo := user.NewMockUserRepo(ctrl)
+	testHandler := &PostHandler{
+		Logger:   zap.NewNop().Sugar(),
+		PostRepo: testRepo,
+		UserRepo: testUserRepo,
+	}
+	currentUser := &user.User{
+		ID:       "GgGHsZysctdVTaCvTZWhgzLReBThTXHc",
+		Username: "jjjjjjjj",
+	}
+
+	// юзер выбрал размытие NSFW постов
+	testUserRepo.EXPECT().GetPreferences(gomock.Any(), currentUser.ID).Return(&user.Preferences{NSFW: user.NSFWBlur}, nil)
+	testRepo.EXPECT().GetAll(gomock.Any(), &post.ListFilter{BlurNSFW: true}).Return([]*post.Post{}, nil)
+	request := httptest.NewRequest(http.MethodGet, "/api/posts/", nil)
+	ctx := context.WithValue(request.Context(), middleware.MyUserKey, currentUser)
+	respWriter := httptest.NewRecorder()
+	testHandler.List(respWriter, request.WithContext(ctx))
+	if resp := respWriter.Result(); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status %d, got status %d", http.StatusOK, resp.StatusCode)
+		return
+	}
+
+	// настройки не получить - NSFW скрывается как для анонима
+	testUserRepo.EXPECT().GetPreferences(gomock.Any(), currentUser.ID).Return(nil, fmt.Errorf("error"))
+	testRepo.EXPECT().GetAll(gomock.Any(), &post.ListFilter{HideNSFW: true}).Return([]*post.Post{}, nil)
+	respWriter = httptest.NewRecorder()
+	testHandler.List(respWriter, request.WithContext(ctx))
+	if resp := respWriter.Result(); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status %d, got status %d", http.StatusOK, resp.StatusCode)
+		return
+	}
+
+	// пометить пост может только автор или модератор
+	isSet := true
+	testRepo.EXPECT().SetMarks(gomock.Any(), currentUser, "feygfyfe", false, &post.Marks{NSFW: &isSet}, post.AnyVersion).Return(nil, post.ErrNoAccess)
+	request = httptest.NewRequest(http.MethodPost, "/api/post/feygfyfe/marks", strings.NewReader(`{"nsfw": true}`))
+	request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe"})
+	ctx = context.WithValue(request.Context(), middleware.MyUserKey, currentUser)
+	respWriter = httptest.NewRecorder()
+	testHandler.SetMarks(respWriter, request.WithContext(ctx))
+	if resp := respWriter.Result(); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status %d, got status %d", http.StatusForbidden, resp.StatusCode)
+		return
+	}
+
+	// пост помечен
+	testRepo.EXPECT().SetMarks(gomock.Any(), currentUser, "feygfyfe", false, &post.Marks{NSFW: &isSet}, post.AnyVersion).Return(&post.Post{Title: "fef", NSFW: true}, nil)
+	request = httptest.NewRequest(http.MethodPost, "/api/post/feygfyfe/marks", strings.NewReader(`{"nsfw": true}`))
+	request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe"})
+	ctx = context.WithValue(request.Context(), middleware.MyUserKey, currentUser)
+	respWriter = httptest.NewRecorder()
+	testHandler.SetMarks(respWriter, request.WithContext(ctx))
+	if resp := respWriter.Result(); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status %d, got status %d", http.StatusOK, resp.StatusCode)
+		return
+	}
+
+	// правка по If-Match, в ответе новая версия
+	testRepo.EXPECT().SetMarks(gomock.Any(), currentUser, "feygfyfe", false, &post.Marks{NSFW: &isSet}, 2).Return(&post.Post{Title: "fef", NSFW: true, Version: 3}, nil)
+	request = httptest.NewRequest(http.MethodPost, "/api/post/feygfyfe/marks", strings.NewReader(`{"nsfw": true}`))
+	request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe"})
+	request.Header.Set("If-Match", `"2"`)
+	ctx = context.WithValue(request.Context(), middleware.MyUserKey, currentUser)
+	respWriter = httptest.NewRecorder()
+	testHandler.SetMarks(respWriter, request.WithContext(ctx))
+	if resp := respWriter.Result(); resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") != `"3"` {
+		t.Errorf("expected status %d and etag \"3\", got status %d and etag %s", http.StatusOK, resp.StatusCode, resp.Header.Get("ETag"))
+		return
+	}
+
+	// пост успели поменять
+	testRepo.EXPECT().SetMarks(gomock.Any(), currentUser, "feygfyfe", false, &post.Marks{NSFW: &isSet}, 2).Return(nil, post.ErrPreconditionFailed)
+	respWriter = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodPost, "/api/post/feygfyfe/marks", strings.NewReader(`{"nsfw": true}`))
+	request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe"})
+	request.Header.Set("If-Match", `"2"`)
+	testHandler.SetMarks(respWriter, request.WithContext(ctx))
+	if resp := respWriter.Result(); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("expected status %d, got status %d", http.StatusPreconditionFailed, resp.StatusCode)
+		return
+	}
+
+	// в If-Match не наш ETag
+	respWriter = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodPost, "/api/post/feygfyfe/marks", strings.NewReader(`{"nsfw": true}`))
+	request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe"})
+	request.Header.Set("If-Match", `W/"abc"`)
+	testHandler.SetMarks(respWriter, request.WithContext(ctx))
+	if resp := respWriter.Result(); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("expected status %d, got status %d", http.StatusPreconditionFailed, resp.StatusCode)
+		return
+	}
+}
+
+func TestPostHandlerVotePoll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := post.NewMockPostRepo(ctrl)
+	testHandler := &PostHandler{
+		Logger:   zap.NewNop().Sugar(),
+		PostRepo: testRepo,
+	}
+	currentUser := &user.User{
+		ID:       "GgGHsZysctdVTaCvTZWhgzLReBThTXHc",
+		Username: "jjjjjjjj",
+	}
+	votedPost := &post.Post{
+		Type:  "poll",
+		Title: "fef",
+		Poll: &post.Poll{
+			Options: []*post.PollOption{{ID: "1", Text: "go", Votes: 1}, {ID: "2", Text: "rust", Votes: 1}},
+			Voters:  []*post.PollVote{{UserID: "another", OptionID: "1"}, {UserID: currentUser.ID, OptionID: "2"}},
+		},
+	}
+
+	// кривой json
+	request := httptest.NewRequest(http.MethodPost, "/api/post/feygfyfe/poll", strings.NewReader(`{"option": `))
+	request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe"})
+	ctx := context.WithValue(request.Context(), middleware.MyUserKey, currentUser)
+	respWriter := httptest.NewRecorder()
+	testHandler.VotePoll(respWriter, request.WithContext(ctx))
+	if respWriter.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got status %d", http.StatusBadRequest, respWriter.Code)
+		return
+	}
+
+	cases := []struct {
+		name       string
+		returnPost *post.Post
+		returnErr  error
+		status     int
+	}{
+		{"пост не найден", nil, post.ErrNoPost, http.StatusNotFound},
+		{"пост не опрос", nil, post.ErrNotPoll, http.StatusUnprocessableEntity},
+		{"нет такого варианта", nil, post.ErrNoPollOption, http.StatusUnprocessableEntity},
+		{"опрос закрыт", nil, post.ErrPollClosed, http.StatusConflict},
+		{"повторный голос", nil, post.ErrAlreadyVoted, http.StatusConflict},
+		{"ветка закрыта", nil, post.ErrLocked, http.StatusForbidden},
+		{"ветка в архиве", nil, post.ErrArchived, http.StatusForbidden},
+		{"какая то ошибка сервера", nil, fmt.Errorf("error"), http.StatusInternalServerError},
+		{"голос принят", votedPost, nil, http.StatusOK},
+	}
+	lastBody := ""
+	for _, testCase := range cases {
+		testRepo.EXPECT().VotePoll(gomock.Any(), "feygfyfe", currentUser.ID, "2", post.AnyVersion).Return(testCase.returnPost, testCase.returnErr)
+		request := httptest.NewRequest(http.MethodPost, "/api/post/feygfyfe/poll", strings.NewReader(`{"option": "2"}`))
+		request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe"})
+		ctx := context.WithValue(request.Context(), middleware.MyUserKey, currentUser)
+		respWriter := httptest.NewRecorder()
+		testHandler.VotePoll(respWriter, request.WithContext(ctx))
+		resp := respWriter.Result()
+		if resp.StatusCode != testCase.status {
+			t.Errorf("%s: expected status %d, got status %d", testCase.name, testCase.status, resp.StatusCode)
+			return
+		}
+		lastBody = respWriter.Body.String()
+	}
+	expected := `"poll":{"options":[{"id":"1","text":"go","votes":1},{"id":"2","text":"rust","votes":1}],"totalVotes":2,"myChoice":"2"}`
+	if !strings.Contains(lastBody, expected) {
+		t.Errorf("wrong poll in response: %s", lastBody)
+		return
+	}
+}
+
+func TestPostHandlerCrosspost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := post.NewMockPostRepo(ctrl)
+	testHandler := &PostHandler{
+		Logger:   zap.NewNop().Sugar(),
+		PostRepo: testRepo,
+	}
+	currentUser := &user.User{
+		ID:       "GgGHsZysctdVTaCvTZWhgzLReBThTXHc",
+		Username: "jjjjjjjj",
+	}
+	objID, err := primitive.ObjectIDFromHex("654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Fatalf("error in id")
+		return
+	}
+	original := &post.Post{Type: "link", Title: "fef", Category: "programming", URL: "https://example.com/a", ID: objID}
+	send := func(body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/api/post/654f63e3a2414a2a554b6423/crosspost", strings.NewReader(body))
+		request = mux.SetURLVars(request, map[string]string{"POST_ID": "654f63e3a2414a2a554b6423"})
+		ctx := context.WithValue(request.Context(), middleware.MyUserKey, currentUser)
+		respWriter := httptest.NewRecorder()
+		testHandler.Crosspost(respWriter, request.WithContext(ctx))
+		return respWriter
+	}
+
+	// кривой json
+	if respWriter := send(`{"category": `); respWriter.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got status %d", http.StatusBadRequest, respWriter.Code)
+		return
+	}
+
+	// нет такого поста
+	testRepo.EXPECT().FindPostByID(gomock.Any(), "654f63e3a2414a2a554b6423").Return(nil, post.ErrNoPost)
+	if respWriter := send(`{"category": "music"}`); respWriter.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got status %d", http.StatusNotFound, respWriter.Code)
+		return
+	}
+
+	// отложенный модерацией пост как будто не существует
+	testRepo.EXPECT().FindPostByID(gomock.Any(), "654f63e3a2414a2a554b6423").Return(&post.Post{Type: "link", Category: "programming", Status: post.StatusHeld, ID: objID}, nil)
+	if respWriter := send(`{"category": "music"}`); respWriter.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got status %d", http.StatusNotFound, respWriter.Code)
+		return
+	}
+
+	// в ту же категорию нельзя
+	testRepo.EXPECT().FindPostByID(gomock.Any(), "654f63e3a2414a2a554b6423").Return(original, nil)
+	if respWriter := send(`{"category": "programming"}`); respWriter.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d, got status %d", http.StatusUnprocessableEntity, respWriter.Code)
+		return
+	}
+
+	// ссылка уже есть в категории
+	duplicate := &post.Post{ID: primitive.NewObjectID()}
+	testRepo.EXPECT().FindPostByID(gomock.Any(), "654f63e3a2414a2a554b6423").Return(original, nil)
+	testRepo.EXPECT().AddPost(gomock.Any(), gomock.Any(), currentUser).Return(nil, &post.DuplicateError{Original: duplicate})
+	respWriter := send(`{"category": "music"}`)
+	expected := fmt.Sprintf(`{"message": "%s", "duplicateOf": "%s"}`, post.ErrDuplicate, duplicate.ID.Hex())
+	if respWriter.Code != http.StatusConflict || respWriter.Body.String() != expected {
+		t.Errorf("expected status %d and body %s, got status %d and body %s", http.StatusConflict, expected, respWriter.Code, respWriter.Body.String())
+		return
+	}
+
+	// кросспост создан
+	testRepo.EXPECT().FindPostByID(gomock.Any(), "654f63e3a2414a2a554b6423").Return(original, nil)
+	testRepo.EXPECT().AddPost(gomock.Any(), gomock.Any(), currentUser).DoAndReturn(func(_ context.Context, crosspost *post.Post, _ *user.User) (*post.Post, error) {
+		if crosspost.CrosspostOf != objID.Hex() || crosspost.Category != "music" || crosspost.URL != original.URL {
+			t.Errorf("wrong crosspost: %+v", crosspost)
+		}
+		return crosspost, nil
+	})
+	if respWriter = send(`{"category": "music"}`); respWriter.Code != http.StatusCreated {
+		t.Errorf("expected status %d, got status %d", http.StatusCreated, respWriter.Code)
+		return
+	}
+}
+
+func TestPostHandlerLock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := post.NewMockPostRepo(ctrl)
+	testHandler := &PostHandler{
+		Logger:   zap.NewNop().Sugar(),
+		PostRepo: testRepo,
+	}
+	moderator := &user.User{
+		ID:       "GgGHsZysctdVTaCvTZWhgzLReBThTXHc",
+		Username: "jjjjjjjj",
+	}
+
+	// кривой json
+	request := httptest.NewRequest(http.MethodPost, "/api/moderation/post/feygfyfe/lock", strings.NewReader(`{"reason": `))
+	request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe"})
+	ctx := context.WithValue(request.Context(), middleware.MyUserKey, moderator)
+	respWriter := httptest.NewRecorder()
+	testHandler.Lock(respWriter, request.WithContext(ctx))
+	if respWriter.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got status %d", http.StatusBadRequest, respWriter.Code)
+		return
+	}
+
+	cases := []struct {
+		name       string
+		handler    http.HandlerFunc
+		locked     bool
+		body       string
+		reason     string
+		returnPost *post.Post
+		returnErr  error
+		status     int
+	}{
+		{"пост не найден", testHandler.Lock, true, `{"reason": "flame"}`, "flame", nil, post.ErrNoPost, http.StatusNotFound},
+		{"какая то ошибка сервера", testHandler.Lock, true, `{"reason": "flame"}`, "flame", nil, fmt.Errorf("error"), http.StatusInternalServerError},
+		{"ветка закрыта", testHandler.Lock, true, `{"reason": "flame"}`, "flame", &post.Post{Title: "fef", Locked: true}, nil, http.StatusOK},
+		{"ветка открыта без причины", testHandler.Unlock, false, "", "", &post.Post{Title: "fef"}, nil, http.StatusOK},
+	}
+	for _, testCase := range cases {
+		testRepo.EXPECT().SetLocked(gomock.Any(), moderator, "feygfyfe", testCase.locked, testCase.reason, post.AnyVersion).Return(testCase.returnPost, testCase.returnErr)
+		request := httptest.NewRequest(http.MethodPost, "/api/moderation/post/feygfyfe/lock", strings.NewReader(testCase.body))
+		request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe"})
+		ctx := context.WithValue(request.Context(), middleware.MyUserKey, moderator)
+		respWriter := httptest.NewRecorder()
+		testCase.handler(respWriter, request.WithContext(ctx))
+		if respWriter.Code != testCase.status {
+			t.Errorf("%s: expected status %d, got status %d", testCase.name, testCase.status, respWriter.Code)
+			return
+		}
+		if testCase.locked && testCase.returnErr == nil && !strings.Contains(respWriter.Body.String(), `"locked":true`) {
+			t.Errorf("%s: wrong body: %s", testCase.name, respWriter.Body.String())
+			return
+		}
+	}
+}
+
+func TestPostHandlerIfMatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := post.NewMockPostRepo(ctrl)
+	testHandler := &PostHandler{
+		Logger:     zap.NewNop().Sugar(),
+		PostRepo:   testRepo,
+		Moderators: user.NewModerators([]string{"moderator"}),
+	}
+	currentUser := &user.User{
+		ID:       "GgGHsZysctdVTaCvTZWhgzLReBThTXHc",
+		Username: "jjjjjjjj",
+	}
+
+	cases := []struct {
+		name    string
+		handler http.HandlerFunc
+		url     string
+		body    string
+		expect  func(version int)
+	}{
+		{"удаление поста", testHandler.DeletePost, "/api/post/feygfyfe", "", func(version int) {
+			testRepo.EXPECT().DeletePost(gomock.Any(), currentUser.ID, "feygfyfe", version).Return(false, post.ErrPreconditionFailed)
+		}},
+		{"восстановление поста", testHandler.RestorePost, "/api/post/feygfyfe/restore", "", func(version int) {
+			testRepo.EXPECT().RestorePost(gomock.Any(), currentUser, "feygfyfe", false, version).Return(nil, post.ErrPreconditionFailed)
+		}},
+		{"новый коммент", testHandler.NewComment, "/api/post/feygfyfe", `{"comment":"some comment"}`, func(version int) {
+			testRepo.EXPECT().AddComment(gomock.Any(), "some comment", currentUser, "feygfyfe", version).Return(nil, post.ErrPreconditionFailed)
+		}},
+		{"удаление коммента", testHandler.DeleteComment, "/api/post/feygfyfe/hrgyfrfb", "", func(version int) {
+			testRepo.EXPECT().DeleteComment(gomock.Any(), currentUser.ID, "feygfyfe", "hrgyfrfb", version).Return(nil, post.ErrPreconditionFailed)
+		}},
+		{"upvote", testHandler.MakeVote, "/api/post/feygfyfe/upvote", "", func(version int) {
+			testRepo.EXPECT().UpVote(gomock.Any(), "feygfyfe", currentUser.ID, version).Return(nil, post.ErrPreconditionFailed)
+		}},
+		{"downvote", testHandler.MakeVote, "/api/post/feygfyfe/downvote", "", func(version int) {
+			testRepo.EXPECT().DownVote(gomock.Any(), "feygfyfe", currentUser.ID, version).Return(nil, post.ErrPreconditionFailed)
+		}},
+		{"unvote", testHandler.MakeVote, "/api/post/feygfyfe/unvote", "", func(version int) {
+			testRepo.EXPECT().UnVote(gomock.Any(), "feygfyfe", currentUser.ID, version).Return(nil, post.ErrPreconditionFailed)
+		}},
+		{"голос в опросе", testHandler.VotePoll, "/api/post/feygfyfe/poll", `{"option": "2"}`, func(version int) {
+			testRepo.EXPECT().VotePoll(gomock.Any(), "feygfyfe", currentUser.ID, "2", version).Return(nil, post.ErrPreconditionFailed)
+		}},
+	}
+	newRequest := func(url, body, ifMatch string) *http.Request {
+		request := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe", "COMMENT_ID": "hrgyfrfb"})
+		request.Header.Set("If-Match", ifMatch)
+		return request.WithContext(context.WithValue(request.Context(), middleware.MyUserKey, currentUser))
+	}
+	for _, testCase := range cases {
+		// пост уже поменяли, версия из If-Match устарела
+		testCase.expect(2)
+		respWriter := httptest.NewRecorder()
+		testCase.handler(respWriter, newRequest(testCase.url, testCase.body, `"2"`))
+		if respWriter.Code != http.StatusPreconditionFailed {
+			t.Errorf("%s: expected status %d, got status %d", testCase.name, http.StatusPreconditionFailed, respWriter.Code)
+			return
+		}
+
+		// в If-Match не наш ETag, до репозитория запрос не доходит
+		respWriter = httptest.NewRecorder()
+		testCase.handler(respWriter, newRequest(testCase.url, testCase.body, `W/"abc"`))
+		if respWriter.Code != http.StatusPreconditionFailed {
+			t.Errorf("%s: expected status %d, got status %d", testCase.name, http.StatusPreconditionFailed, respWriter.Code)
+			return
+		}
+	}
+}
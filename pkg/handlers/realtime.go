@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"reddit/pkg/middleware"
+	"reddit/pkg/realtime"
+	"reddit/pkg/response"
+	"reddit/pkg/user"
+)
+
+type RealtimeHandler struct {
+	Hub      *realtime.Hub
+	Upgrader websocket.Upgrader
+	Logger   *zap.SugaredLogger
+}
+
+// Connect - подписаться можно сразу через ?subscribe=post:<id>&subscribe=notifications
+// или потом сообщениями {"action": "subscribe", "channel": "category:music"}
+func (rh *RealtimeHandler) Connect(w http.ResponseWriter, r *http.Request) {
+	userID := ""
+	if currentUser, ok := r.Context().Value(middleware.MyUserKey).(*user.User); ok {
+		userID = currentUser.ID
+	}
+	conn, err := rh.Upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// ответ с ошибкой Upgrade уже записал сам
+		rh.Logger.Infof("websocket upgrade failed: %s", err.Error())
+		return
+	}
+	rh.Hub.Serve(conn, userID, r.URL.Query()["subscribe"])
+}
+
+// CategoryStream - новые посты и прочие события категории для клиентов без websocket
+func (rh *RealtimeHandler) CategoryStream(w http.ResponseWriter, r *http.Request) {
+	rh.stream(w, r, realtime.CategoryChannel(mux.Vars(r)["CATEGORY_NAME"]))
+}
+
+// PostStream - комментарии и голоса поста для клиентов без websocket
+func (rh *RealtimeHandler) PostStream(w http.ResponseWriter, r *http.Request) {
+	rh.stream(w, r, realtime.PostChannel(mux.Vars(r)["POST_ID"]))
+}
+
+func (rh *RealtimeHandler) stream(w http.ResponseWriter, r *http.Request, channel string) {
+	err := rh.Hub.ServeStream(w, r, channel)
+	if errors.Is(err, realtime.ErrBadChannel) {
+		response.WriteResponse(rh.Logger, w, []byte(`{"message": "bad channel"}`), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "can not open event stream: %s"}`, err)
+		response.WriteResponse(rh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"reddit/pkg/middleware"
+	"reddit/pkg/post"
+	"reddit/pkg/report"
+	"reddit/pkg/response"
+	"reddit/pkg/user"
+)
+
+type ReportHandler struct {
+	ReportRepo report.ReportRepo
+	Logger     *zap.SugaredLogger
+}
+
+func (rh *ReportHandler) ReportPost(w http.ResponseWriter, r *http.Request) {
+	rh.newReport(w, r)
+}
+
+func (rh *ReportHandler) ReportComment(w http.ResponseWriter, r *http.Request) {
+	rh.newReport(w, r)
+}
+
+func (rh *ReportHandler) newReport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	postID := vars["POST_ID"]
+	commentID := vars["COMMENT_ID"]
+	reporter, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(rh.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	reportFromForm := &report.ReportForm{}
+	rBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in reading request body: %s"}`, err)
+		response.WriteResponse(rh.Logger, w, []byte(errText), http.StatusBadRequest)
+		return
+	}
+	err = json.Unmarshal(rBody, reportFromForm)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in json decoding of report form: %s"}`, err)
+		response.WriteResponse(rh.Logger, w, []byte(errText), http.StatusBadRequest)
+		return
+	}
+	if validationErrors := reportFromForm.Validate(); len(validationErrors) != 0 {
+		var errorsJSON []byte
+		errorsJSON, err = json.Marshal(validationErrors)
+		if err != nil {
+			errText := fmt.Sprintf(`{"message": "error in json coding of validation errors of report: %s"}`, err)
+			response.WriteResponse(rh.Logger, w, []byte(errText), http.StatusInternalServerError)
+			return
+		}
+		response.WriteResponse(rh.Logger, w, errorsJSON, http.StatusUnprocessableEntity)
+		return
+	}
+
+	var item *report.Item
+	if commentID == "" {
+		item, err = rh.ReportRepo.ReportPost(r.Context(), postID, reportFromForm.Reason, reporter)
+	} else {
+		item, err = rh.ReportRepo.ReportComment(r.Context(), postID, commentID, reportFromForm.Reason, reporter)
+	}
+	if errors.Is(err, post.ErrNoPost) {
+		errText := fmt.Sprintf(`{"message": "there is no post with id %s"}`, postID)
+		response.WriteResponse(rh.Logger, w, []byte(errText), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, post.ErrNoComment) {
+		errText := fmt.Sprintf(`{"message": "there is no comment with id %s"}`, commentID)
+		response.WriteResponse(rh.Logger, w, []byte(errText), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, report.ErrAlreadyReported) {
+		response.WriteResponse(rh.Logger, w, []byte(`{"message": "you have already reported this item"}`), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in adding report: %s"}`, err)
+		response.WriteResponse(rh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	itemJSON, err := json.Marshal(item)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in coding report: %s"}`, err)
+		response.WriteResponse(rh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	rh.Logger.Infof("new report for item %s", item.ID.Hex())
+	response.WriteResponse(rh.Logger, w, itemJSON, http.StatusCreated)
+}
+
+func (rh *ReportHandler) Queue(w http.ResponseWriter, r *http.Request) {
+	items, err := rh.ReportRepo.GetQueue(r.Context())
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "can not get moderation queue: %s"}`, err)
+		response.WriteResponse(rh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	itemsJSON, err := json.Marshal(items)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in coding moderation queue: %s"}`, err)
+		response.WriteResponse(rh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	response.WriteResponse(rh.Logger, w, itemsJSON, http.StatusOK)
+}
+
+func (rh *ReportHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	rh.queueAction(w, r, rh.ReportRepo.Approve)
+}
+
+func (rh *ReportHandler) Remove(w http.ResponseWriter, r *http.Request) {
+	rh.queueAction(w, r, rh.ReportRepo.Remove)
+}
+
+func (rh *ReportHandler) queueAction(w http.ResponseWriter, r *http.Request, action func(context.Context, string, *user.User, string, int) error) {
+	itemID := mux.Vars(r)["ITEM_ID"]
+	moderator, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(rh.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	moderationForm := &report.ModerationForm{}
+	rBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in reading request body: %s"}`, err)
+		response.WriteResponse(rh.Logger, w, []byte(errText), http.StatusBadRequest)
+		return
+	}
+	if len(rBody) != 0 {
+		err = json.Unmarshal(rBody, moderationForm)
+		if err != nil {
+			errText := fmt.Sprintf(`{"message": "error in json decoding of moderation form: %s"}`, err)
+			response.WriteResponse(rh.Logger, w, []byte(errText), http.StatusBadRequest)
+			return
+		}
+	}
+	if validationErrors := moderationForm.Validate(); len(validationErrors) != 0 {
+		var errorsJSON []byte
+		errorsJSON, err = json.Marshal(validationErrors)
+		if err != nil {
+			errText := fmt.Sprintf(`{"message": "error in json coding of validation errors: %s"}`, err)
+			response.WriteResponse(rh.Logger, w, []byte(errText), http.StatusInternalServerError)
+			return
+		}
+		response.WriteResponse(rh.Logger, w, errorsJSON, http.StatusUnprocessableEntity)
+		return
+	}
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		rh.writePreconditionFailed(w)
+		return
+	}
+	err = action(r.Context(), itemID, moderator, moderationForm.Reason, version)
+	if errors.Is(err, post.ErrPreconditionFailed) {
+		rh.writePreconditionFailed(w)
+		return
+	}
+	if errors.Is(err, post.ErrVersionConflict) {
+		response.WriteResponse(rh.Logger, w, []byte(`{"message": "post is being changed concurrently, try again"}`), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, report.ErrNoItem) {
+		errText := fmt.Sprintf(`{"message": "there is no reported item with id %s"}`, itemID)
+		response.WriteResponse(rh.Logger, w, []byte(errText), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in moderation action: %s"}`, err)
+		response.WriteResponse(rh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	rh.Logger.Infof("moderation action on item %s done", itemID)
+	response.WriteResponse(rh.Logger, w, []byte(`{"message": "success"}`), http.StatusOK)
+}
+
+func (rh *ReportHandler) writePreconditionFailed(w http.ResponseWriter) {
+	response.WriteResponse(rh.Logger, w, []byte(`{"message": "post was changed, reload it and try again"}`), http.StatusPreconditionFailed)
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"reddit/pkg/middleware"
+	"reddit/pkg/post"
+	"reddit/pkg/report"
+	"reddit/pkg/user"
+)
+
+func TestReportHandlerReportPost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := report.NewMockReportRepo(ctrl)
+	testHandler := &ReportHandler{
+		Logger:     zap.NewNop().Sugar(),
+		ReportRepo: testRepo,
+	}
+	currentUser := &user.User{
+		ID:       "GgGHsZysctdVTaCvTZWhgzLReBThTXHc",
+		Username: "jjjjjjjj",
+	}
+	cases := []struct {
+		name       string
+		body       string
+		commentID  string
+		prepare    func()
+		statusCode int
+	}{
+		{
+			name:       "причина не указана",
+			body:       `{"reason": ""}`,
+			prepare:    func() {},
+			statusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "битый json",
+			body:       `{"reason": `,
+			prepare:    func() {},
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name: "пост не найден",
+			body: `{"reason": "spam"}`,
+			prepare: func() {
+				testRepo.EXPECT().ReportPost(gomock.Any(), "feygfyfe", "spam", currentUser).Return(nil, post.ErrNoPost)
+			},
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:      "коммент не найден",
+			body:      `{"reason": "spam"}`,
+			commentID: "commentID",
+			prepare: func() {
+				testRepo.EXPECT().ReportComment(gomock.Any(), "feygfyfe", "commentID", "spam", currentUser).Return(nil, post.ErrNoComment)
+			},
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name: "повторная жалоба",
+			body: `{"reason": "spam"}`,
+			prepare: func() {
+				testRepo.EXPECT().ReportPost(gomock.Any(), "feygfyfe", "spam", currentUser).Return(nil, report.ErrAlreadyReported)
+			},
+			statusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "какая то ошибка сервера",
+			body: `{"reason": "spam"}`,
+			prepare: func() {
+				testRepo.EXPECT().ReportPost(gomock.Any(), "feygfyfe", "spam", currentUser).Return(nil, fmt.Errorf("error"))
+			},
+			statusCode: http.StatusInternalServerError,
+		},
+		{
+			name:      "жалоба добавлена",
+			body:      `{"reason": "spam"}`,
+			commentID: "commentID",
+			prepare: func() {
+				testRepo.EXPECT().ReportComment(gomock.Any(), "feygfyfe", "commentID", "spam", currentUser).Return(&report.Item{ID: primitive.NewObjectID(), ReportCount: 1}, nil)
+			},
+			statusCode: http.StatusCreated,
+		},
+	}
+	for _, tc := range cases {
+		tc.prepare()
+		request := httptest.NewRequest(http.MethodPost, "/api/post/feygfyfe/report", strings.NewReader(tc.body))
+		request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe", "COMMENT_ID": tc.commentID})
+		ctx := context.WithValue(request.Context(), middleware.MyUserKey, currentUser)
+		respWriter := httptest.NewRecorder()
+		testHandler.ReportPost(respWriter, request.WithContext(ctx))
+		resp := respWriter.Result()
+		_, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("unable to read response body")
+			return
+		}
+		if resp.StatusCode != tc.statusCode {
+			t.Errorf("%s: expected status %d, got status %d", tc.name, tc.statusCode, resp.StatusCode)
+		}
+	}
+}
+
+func TestReportHandlerQueue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := report.NewMockReportRepo(ctrl)
+	testHandler := &ReportHandler{
+		Logger:     zap.NewNop().Sugar(),
+		ReportRepo: testRepo,
+	}
+
+	// какая то ошибка сервера
+	testRepo.EXPECT().GetQueue(gomock.Any()).Return(nil, fmt.Errorf("error"))
+	respWriter := httptest.NewRecorder()
+	testHandler.Queue(respWriter, httptest.NewRequest(http.MethodGet, "/api/moderation/queue", nil))
+	if respWriter.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got status %d", http.StatusInternalServerError, respWriter.Code)
+		return
+	}
+
+	// очередь получена
+	objID, err := primitive.ObjectIDFromHex("654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Fatalf("error in id")
+		return
+	}
+	testRepo.EXPECT().GetQueue(gomock.Any()).Return([]*report.Item{
+		{
+			ID:          objID,
+			Kind:        report.KindPost,
+			PostID:      "postID",
+			Category:    "music",
+			Reports:     []*report.Report{{UserID: "u1", Reason: "spam", Created: "2023-11-11T14:22:11.695Z"}},
+			ReportCount: 1,
+			SpamScore:   0.42,
+		},
+	}, nil)
+	respWriter = httptest.NewRecorder()
+	testHandler.Queue(respWriter, httptest.NewRequest(http.MethodGet, "/api/moderation/queue", nil))
+	expectedBody := `[{"id":"654f63e3a2414a2a554b6423","kind":"post","postId":"postID","category":"music","reports":[{"user":"u1","reason":"spam","created":"2023-11-11T14:22:11.695Z"}],"reportCount":1,"spamScore":0.42}]`
+	if respWriter.Code != http.StatusOK {
+		t.Errorf("expected status %d, got status %d", http.StatusOK, respWriter.Code)
+		return
+	}
+	if respWriter.Body.String() != expectedBody {
+		t.Errorf("wrond response body: \nexpected %s, \ngot      %s", expectedBody, respWriter.Body.String())
+	}
+}
+
+func TestReportHandlerApproveRemove(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := report.NewMockReportRepo(ctrl)
+	testHandler := &ReportHandler{
+		Logger:     zap.NewNop().Sugar(),
+		ReportRepo: testRepo,
+	}
+
+	moderator := &user.User{ID: "moderatorID", Username: "moderator"}
+	newRequest := func(body string) *http.Request {
+		request := httptest.NewRequest(http.MethodPost, "/api/moderation/queue/itemID/approve", strings.NewReader(body))
+		request = mux.SetURLVars(request, map[string]string{"ITEM_ID": "itemID"})
+		return request.WithContext(context.WithValue(request.Context(), middleware.MyUserKey, moderator))
+	}
+
+	// жалоба не найдена
+	testRepo.EXPECT().Approve(gomock.Any(), "itemID", moderator, "", post.AnyVersion).Return(report.ErrNoItem)
+	respWriter := httptest.NewRecorder()
+	testHandler.Approve(respWriter, newRequest(""))
+	if respWriter.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got status %d", http.StatusNotFound, respWriter.Code)
+		return
+	}
+
+	// битый json
+	respWriter = httptest.NewRecorder()
+	testHandler.Remove(respWriter, newRequest(`{"reason": `))
+	if respWriter.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got status %d", http.StatusBadRequest, respWriter.Code)
+		return
+	}
+
+	// какая то ошибка сервера
+	testRepo.EXPECT().Remove(gomock.Any(), "itemID", moderator, "spam", post.AnyVersion).Return(fmt.Errorf("error"))
+	respWriter = httptest.NewRecorder()
+	testHandler.Remove(respWriter, newRequest(`{"reason": "spam"}`))
+	if respWriter.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got status %d", http.StatusInternalServerError, respWriter.Code)
+		return
+	}
+
+	// пост из жалобы поменяли, пока модератор ее смотрел
+	testRepo.EXPECT().Remove(gomock.Any(), "itemID", moderator, "spam", 2).Return(post.ErrPreconditionFailed)
+	respWriter = httptest.NewRecorder()
+	request := newRequest(`{"reason": "spam"}`)
+	request.Header.Set("If-Match", `"2"`)
+	testHandler.Remove(respWriter, request)
+	if respWriter.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status %d, got status %d", http.StatusPreconditionFailed, respWriter.Code)
+		return
+	}
+
+	// в If-Match не наш ETag
+	respWriter = httptest.NewRecorder()
+	request = newRequest(`{"reason": "spam"}`)
+	request.Header.Set("If-Match", `W/"abc"`)
+	testHandler.Remove(respWriter, request)
+	if respWriter.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status %d, got status %d", http.StatusPreconditionFailed, respWriter.Code)
+		return
+	}
+
+	// контент удален
+	testRepo.EXPECT().Remove(gomock.Any(), "itemID", moderator, "spam", post.AnyVersion).Return(nil)
+	respWriter = httptest.NewRecorder()
+	testHandler.Remove(respWriter, newRequest(`{"reason": "spam"}`))
+	if respWriter.Code != http.StatusOK {
+		t.Errorf("expected status %d, got status %d", http.StatusOK, respWriter.Code)
+		return
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"reddit/pkg/middleware"
+	"reddit/pkg/post"
+	"reddit/pkg/response"
+	"reddit/pkg/saved"
+	"reddit/pkg/user"
+)
+
+type SavedHandler struct {
+	SavedRepo saved.SavedRepo
+	Logger    *zap.SugaredLogger
+}
+
+func (sh *SavedHandler) Save(w http.ResponseWriter, r *http.Request) {
+	sh.change(w, r, sh.SavedRepo.Save)
+}
+
+func (sh *SavedHandler) Unsave(w http.ResponseWriter, r *http.Request) {
+	sh.change(w, r, sh.SavedRepo.Unsave)
+}
+
+func (sh *SavedHandler) Hide(w http.ResponseWriter, r *http.Request) {
+	sh.change(w, r, sh.SavedRepo.Hide)
+}
+
+func (sh *SavedHandler) Unhide(w http.ResponseWriter, r *http.Request) {
+	sh.change(w, r, sh.SavedRepo.Unhide)
+}
+
+func (sh *SavedHandler) change(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, userID, postID, commentID string) error) {
+	vars := mux.Vars(r)
+	postID := vars["POST_ID"]
+	commentID := vars["COMMENT_ID"]
+	currentUser, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(sh.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	err := action(r.Context(), currentUser.ID, postID, commentID)
+	if errors.Is(err, post.ErrNoPost) {
+		errText := fmt.Sprintf(`{"message": "there is no post with id %s"}`, postID)
+		response.WriteResponse(sh.Logger, w, []byte(errText), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, post.ErrNoComment) {
+		errText := fmt.Sprintf(`{"message": "there is no comment with id %s"}`, commentID)
+		response.WriteResponse(sh.Logger, w, []byte(errText), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, saved.ErrNoItem) {
+		response.WriteResponse(sh.Logger, w, []byte(`{"message": "item is not saved or hidden"}`), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in changing saved items: %s"}`, err)
+		response.WriteResponse(sh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	response.WriteResponse(sh.Logger, w, []byte(`{"message": "success"}`), http.StatusOK)
+}
+
+func (sh *SavedHandler) List(w http.ResponseWriter, r *http.Request) {
+	currentUser, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(sh.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	query := r.URL.Query()
+	filter := &saved.Filter{}
+	var err error
+	if page := query.Get("page"); page != "" {
+		filter.Page, err = strconv.Atoi(page)
+		if err != nil {
+			response.WriteResponse(sh.Logger, w, []byte(`{"message": "page must be a number"}`), http.StatusBadRequest)
+			return
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			response.WriteResponse(sh.Logger, w, []byte(`{"message": "limit must be a number"}`), http.StatusBadRequest)
+			return
+		}
+	}
+	entries, err := sh.SavedRepo.GetSaved(r.Context(), currentUser.ID, filter)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "can not get saved items: %s"}`, err)
+		response.WriteResponse(sh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	for _, entry := range entries {
+		post.HidePollResults(currentUser.ID, entry.Post)
+	}
+	entriesJSON, err := json.Marshal(entries)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in coding saved items: %s"}`, err)
+		response.WriteResponse(sh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	response.WriteResponse(sh.Logger, w, entriesJSON, http.StatusOK)
+}
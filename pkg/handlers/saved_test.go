@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"reddit/pkg/comment"
+	"reddit/pkg/middleware"
+	"reddit/pkg/post"
+	"reddit/pkg/saved"
+	"reddit/pkg/user"
+)
+
+func TestSavedHandlerChange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := saved.NewMockSavedRepo(ctrl)
+	testHandler := &SavedHandler{
+		Logger:    zap.NewNop().Sugar(),
+		SavedRepo: testRepo,
+	}
+	currentUser := &user.User{
+		ID:       "GgGHsZysctdVTaCvTZWhgzLReBThTXHc",
+		Username: "jjjjjjjj",
+	}
+
+	cases := []struct {
+		name      string
+		handler   http.HandlerFunc
+		expect    func() *gomock.Call
+		commentID string
+		status    int
+	}{
+		{"нет поста", testHandler.Save, func() *gomock.Call {
+			return testRepo.EXPECT().Save(gomock.Any(), currentUser.ID, "feygfyfe", "").Return(post.ErrNoPost)
+		}, "", http.StatusNotFound},
+		{"нет комментария", testHandler.Hide, func() *gomock.Call {
+			return testRepo.EXPECT().Hide(gomock.Any(), currentUser.ID, "feygfyfe", "comment_id").Return(post.ErrNoComment)
+		}, "comment_id", http.StatusNotFound},
+		{"не было сохранено", testHandler.Unsave, func() *gomock.Call {
+			return testRepo.EXPECT().Unsave(gomock.Any(), currentUser.ID, "feygfyfe", "").Return(saved.ErrNoItem)
+		}, "", http.StatusNotFound},
+		{"какая то ошибка сервера", testHandler.Unhide, func() *gomock.Call {
+			return testRepo.EXPECT().Unhide(gomock.Any(), currentUser.ID, "feygfyfe", "").Return(fmt.Errorf("error"))
+		}, "", http.StatusInternalServerError},
+		{"комментарий сохранен", testHandler.Save, func() *gomock.Call {
+			return testRepo.EXPECT().Save(gomock.Any(), currentUser.ID, "feygfyfe", "comment_id").Return(nil)
+		}, "comment_id", http.StatusOK},
+	}
+	for _, testCase := range cases {
+		testCase.expect()
+		request := httptest.NewRequest(http.MethodPost, "/api/post/feygfyfe/save", nil)
+		request = mux.SetURLVars(request, map[string]string{"POST_ID": "feygfyfe", "COMMENT_ID": testCase.commentID})
+		ctx := context.WithValue(request.Context(), middleware.MyUserKey, currentUser)
+		respWriter := httptest.NewRecorder()
+		testCase.handler(respWriter, request.WithContext(ctx))
+		if respWriter.Code != testCase.status {
+			t.Errorf("%s: expected status %d, got status %d", testCase.name, testCase.status, respWriter.Code)
+			return
+		}
+	}
+}
+
+func TestSavedHandlerList(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := saved.NewMockSavedRepo(ctrl)
+	testHandler := &SavedHandler{
+		Logger:    zap.NewNop().Sugar(),
+		SavedRepo: testRepo,
+	}
+	currentUser := &user.User{
+		ID:       "GgGHsZysctdVTaCvTZWhgzLReBThTXHc",
+		Username: "jjjjjjjj",
+	}
+	send := func(target string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, target, nil)
+		ctx := context.WithValue(request.Context(), middleware.MyUserKey, currentUser)
+		respWriter := httptest.NewRecorder()
+		testHandler.List(respWriter, request.WithContext(ctx))
+		return respWriter
+	}
+
+	// кривая пагинация
+	if respWriter := send("/api/user/me/saved?page=abc"); respWriter.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got status %d", http.StatusBadRequest, respWriter.Code)
+		return
+	}
+
+	// какая то ошибка сервера
+	testRepo.EXPECT().GetSaved(gomock.Any(), currentUser.ID, &saved.Filter{Page: 2, Limit: 10}).Return(nil, fmt.Errorf("error"))
+	if respWriter := send("/api/user/me/saved?page=2&limit=10"); respWriter.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got status %d", http.StatusInternalServerError, respWriter.Code)
+		return
+	}
+
+	// сохраненный комментарий
+	testRepo.EXPECT().GetSaved(gomock.Any(), currentUser.ID, &saved.Filter{}).Return([]*saved.Entry{{
+		Item:    &saved.Item{PostID: "feygfyfe", CommentID: "comment_id"},
+		Post:    &post.Post{Title: "fef"},
+		Comment: &comment.Comment{ID: "comment_id", Body: "body"},
+	}}, nil)
+	respWriter := send("/api/user/me/saved")
+	expected := `[{"postId":"feygfyfe","commentId":"comment_id","savedAt":"0001-01-01T00:00:00Z","post":{"score":0,"views":0,"type":"","title":"fef","author":null,"category":"","votes":null,"comments":null,"created":"","upvotePercentage":0,"id":"000000000000000000000000"},"comment":{"created":"","author":null,"body":"body","id":"comment_id"}}]`
+	if respWriter.Code != http.StatusOK || respWriter.Body.String() != expected {
+		t.Errorf("expected status %d and body %s, got status %d and body %s", http.StatusOK, expected, respWriter.Code, respWriter.Body.String())
+		return
+	}
+}
+
+func TestPostHandlerHidden(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := post.NewMockPostRepo(ctrl)
+	testSaved := saved.NewMockSavedRepo(ctrl)
+	testHandler := &PostHandler{
+		Logger:    zap.NewNop().Sugar(),
+		PostRepo:  testRepo,
+		SavedRepo: testSaved,
+	}
+	currentUser := &user.User{
+		ID:       "GgGHsZysctdVTaCvTZWhgzLReBThTXHc",
+		Username: "jjjjjjjj",
+	}
+
+	// скрытые посты не попадают в ленту
+	visibleID, hiddenID := primitive.NewObjectID(), primitive.NewObjectID()
+	feed := []*post.Post{{ID: visibleID}, {ID: hiddenID}}
+	testRepo.EXPECT().GetAll(gomock.Any(), &post.ListFilter{HideNSFW: true}).Return(feed, nil)
+	testSaved.EXPECT().HiddenPostIDs(gomock.Any(), currentUser.ID, []string{visibleID.Hex(), hiddenID.Hex()}).Return([]string{hiddenID.Hex()}, nil)
+	request := httptest.NewRequest(http.MethodGet, "/api/posts/", nil)
+	ctx := context.WithValue(request.Context(), middleware.MyUserKey, currentUser)
+	respWriter := httptest.NewRecorder()
+	testHandler.List(respWriter, request.WithContext(ctx))
+	if respWriter.Code != http.StatusOK || strings.Contains(respWriter.Body.String(), hiddenID.Hex()) || !strings.Contains(respWriter.Body.String(), visibleID.Hex()) {
+		t.Errorf("expected status %d without hidden post, got status %d and body %s", http.StatusOK, respWriter.Code, respWriter.Body.String())
+		return
+	}
+
+	// если скрытые не достались, лента все равно отдается
+	testRepo.EXPECT().GetPostByCategory(gomock.Any(), "music", &post.ListFilter{HideNSFW: true}).Return(feed, nil)
+	testSaved.EXPECT().HiddenPostIDs(gomock.Any(), currentUser.ID, gomock.Any()).Return(nil, fmt.Errorf("error"))
+	request = httptest.NewRequest(http.MethodGet, "/api/posts/music", nil)
+	request = mux.SetURLVars(request, map[string]string{"CATEGORY_NAME": "music"})
+	ctx = context.WithValue(request.Context(), middleware.MyUserKey, currentUser)
+	respWriter = httptest.NewRecorder()
+	testHandler.ListByCategory(respWriter, request.WithContext(ctx))
+	if respWriter.Code != http.StatusOK {
+		t.Errorf("expected status %d, got status %d", http.StatusOK, respWriter.Code)
+		return
+	}
+
+	// скрытые комментарии не отдаются
+	objID := primitive.NewObjectID()
+	testRepo.EXPECT().GetPostByID(gomock.Any(), objID.Hex(), gomock.Any(), false).Return(&post.Post{
+		ID:       objID,
+		Comments: []*comment.Comment{{ID: "visible"}, {ID: "hidden"}},
+	}, nil)
+	testSaved.EXPECT().HiddenCommentIDs(gomock.Any(), currentUser.ID, objID.Hex()).Return([]string{"hidden"}, nil)
+	request = httptest.NewRequest(http.MethodGet, "/api/post/"+objID.Hex(), nil)
+	request = mux.SetURLVars(request, map[string]string{"POST_ID": objID.Hex()})
+	ctx = context.WithValue(request.Context(), middleware.MyUserKey, currentUser)
+	respWriter = httptest.NewRecorder()
+	testHandler.GetPostInfo(respWriter, request.WithContext(ctx))
+	expected := `"comments":[{"created":"","author":null,"body":"","id":"visible"}]`
+	if respWriter.Code != http.StatusOK || !strings.Contains(respWriter.Body.String(), expected) {
+		t.Errorf("expected status %d and %s in body, got status %d and body %s", http.StatusOK, expected, respWriter.Code, respWriter.Body.String())
+		return
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"go.uber.org/zap"
 	"reddit/pkg/response"
 
+	"reddit/pkg/middleware"
 	"reddit/pkg/session"
 	"reddit/pkg/user"
 )
@@ -50,7 +51,7 @@ func (uh *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		response.WriteResponse(uh.Logger, w, []byte(errText), http.StatusUnauthorized)
 		return
 	}
-	loggedInUser, err := uh.UserRepo.Login(userFromLoginForm.Username, userFromLoginForm.Password)
+	loggedInUser, err := uh.UserRepo.Login(r.Context(), userFromLoginForm.Username, userFromLoginForm.Password)
 
 	if errors.Is(err, user.ErrNoUser) {
 		response.WriteResponse(uh.Logger, w, []byte(`{"message": "user not found"}`), http.StatusUnauthorized)
@@ -65,7 +66,7 @@ func (uh *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		response.WriteResponse(uh.Logger, w, []byte(errText), http.StatusInternalServerError)
 		return
 	}
-	uh.HandleGetToken(w, loggedInUser)
+	uh.HandleGetToken(w, r, loggedInUser)
 
 }
 
@@ -74,7 +75,7 @@ func (uh *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 	if err != nil || userFromLoginForm == nil {
 		return
 	}
-	newUser, err := uh.UserRepo.Register(userFromLoginForm.Username, userFromLoginForm.Password)
+	newUser, err := uh.UserRepo.Register(r.Context(), userFromLoginForm.Username, userFromLoginForm.Password)
 
 	if errors.Is(err, user.ErrAlreadyExist) {
 		response.WriteResponse(uh.Logger, w, []byte(`{"message": "user already exists"}`), http.StatusUnprocessableEntity)
@@ -85,11 +86,11 @@ func (uh *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 		response.WriteResponse(uh.Logger, w, []byte(errText), http.StatusInternalServerError)
 		return
 	}
-	uh.HandleGetToken(w, newUser)
+	uh.HandleGetToken(w, r, newUser)
 }
 
-func (uh *UserHandler) HandleGetToken(w http.ResponseWriter, newUser *user.User) {
-	token, err := uh.SessionManager.CreateNewSession(newUser)
+func (uh *UserHandler) HandleGetToken(w http.ResponseWriter, r *http.Request, newUser *user.User) {
+	token, err := uh.SessionManager.CreateNewSession(r.Context(), newUser)
 	if err != nil {
 		errText := fmt.Sprintf(`{"message": "error in session creation: %s"}`, err)
 		response.WriteResponse(uh.Logger, w, []byte(errText), http.StatusInternalServerError)
@@ -106,6 +107,75 @@ func (uh *UserHandler) HandleGetToken(w http.ResponseWriter, newUser *user.User)
 	response.WriteResponse(uh.Logger, w, tokenJSON, http.StatusOK)
 }
 
+func (uh *UserHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	currentUser, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(uh.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	preferences, err := uh.UserRepo.GetPreferences(r.Context(), currentUser.ID)
+	if errors.Is(err, user.ErrNoUser) {
+		response.WriteResponse(uh.Logger, w, []byte(`{"message": "user not found"}`), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in getting preferences: %s"}`, err)
+		response.WriteResponse(uh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	preferencesJSON, err := json.Marshal(preferences)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in coding preferences: %s"}`, err)
+		response.WriteResponse(uh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	response.WriteResponse(uh.Logger, w, preferencesJSON, http.StatusOK)
+}
+
+func (uh *UserHandler) SetPreferences(w http.ResponseWriter, r *http.Request) {
+	currentUser, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(uh.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	rBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in reading request body: %s"}`, err)
+		response.WriteResponse(uh.Logger, w, []byte(errText), http.StatusBadRequest)
+		return
+	}
+	preferences := user.DefaultPreferences()
+	err = json.Unmarshal(rBody, preferences)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in decoding preferences: %s"}`, err)
+		response.WriteResponse(uh.Logger, w, []byte(errText), http.StatusBadRequest)
+		return
+	}
+	if validationErrors := preferences.Validate(); len(validationErrors) != 0 {
+		errorsJSON, errJSON := json.Marshal(validationErrors)
+		if errJSON != nil {
+			errText := fmt.Sprintf(`{"message": "error in coding validation errors: %s"}`, errJSON)
+			response.WriteResponse(uh.Logger, w, []byte(errText), http.StatusInternalServerError)
+			return
+		}
+		response.WriteResponse(uh.Logger, w, errorsJSON, http.StatusUnprocessableEntity)
+		return
+	}
+	err = uh.UserRepo.SetPreferences(r.Context(), currentUser.ID, preferences)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in saving preferences: %s"}`, err)
+		response.WriteResponse(uh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	preferencesJSON, err := json.Marshal(preferences)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in coding preferences: %s"}`, err)
+		response.WriteResponse(uh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	response.WriteResponse(uh.Logger, w, preferencesJSON, http.StatusOK)
+}
+
 func checkRequestFormat(logger *zap.SugaredLogger, w http.ResponseWriter, r *http.Request) (*LoginRegisterRequestBody, error) {
 	rBody, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/golang/mock/gomock"
 	"go.uber.org/zap"
+	"reddit/pkg/middleware"
 	"reddit/pkg/session"
 	"reddit/pkg/user"
 )
@@ -76,7 +78,7 @@ func TestUserHandlerLogin(t *testing.T) {
 
 	//  запрос успешный, но юзера не существует
 	reqBody = `{"username":"qqqqqqqq", "password":"qqqqqqqq"}`
-	testRepo.EXPECT().Login("qqqqqqqq", "qqqqqqqq").Return(nil, user.ErrNoUser)
+	testRepo.EXPECT().Login(gomock.Any(), "qqqqqqqq", "qqqqqqqq").Return(nil, user.ErrNoUser)
 	request = httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(reqBody))
 	respWriter = httptest.NewRecorder()
 	testHandler.Login(respWriter, request)
@@ -92,7 +94,7 @@ func TestUserHandlerLogin(t *testing.T) {
 
 	//  неверный пароль
 	reqBody = `{"username":"qqqqqqqq", "password":"wrong_password"}`
-	testRepo.EXPECT().Login("qqqqqqqq", "wrong_password").Return(nil, user.ErrBadPass)
+	testRepo.EXPECT().Login(gomock.Any(), "qqqqqqqq", "wrong_password").Return(nil, user.ErrBadPass)
 	request = httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(reqBody))
 	respWriter = httptest.NewRecorder()
 	testHandler.Login(respWriter, request)
@@ -108,7 +110,7 @@ func TestUserHandlerLogin(t *testing.T) {
 
 	//  какая то ошибка сервера
 	reqBody = `{"username":"qqqqqqqq", "password":"brevhbehvbe"}`
-	testRepo.EXPECT().Login("qqqqqqqq", "brevhbehvbe").Return(nil, fmt.Errorf("error"))
+	testRepo.EXPECT().Login(gomock.Any(), "qqqqqqqq", "brevhbehvbe").Return(nil, fmt.Errorf("error"))
 	request = httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(reqBody))
 	respWriter = httptest.NewRecorder()
 	testHandler.Login(respWriter, request)
@@ -127,8 +129,8 @@ func TestUserHandlerLogin(t *testing.T) {
 		ID:       "some_id",
 		Username: "some_username",
 	}
-	testRepo.EXPECT().Login("some_username", "brevhbehvbe").Return(loggedInUser, nil)
-	testSessManager.EXPECT().CreateNewSession(loggedInUser).Return("", fmt.Errorf("error"))
+	testRepo.EXPECT().Login(gomock.Any(), "some_username", "brevhbehvbe").Return(loggedInUser, nil)
+	testSessManager.EXPECT().CreateNewSession(gomock.Any(), loggedInUser).Return("", fmt.Errorf("error"))
 	request = httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(defaultReqBody))
 	respWriter = httptest.NewRecorder()
 	testHandler.Login(respWriter, request)
@@ -143,8 +145,8 @@ func TestUserHandlerLogin(t *testing.T) {
 	}
 
 	//  возвращает нормально структуру с токеном
-	testRepo.EXPECT().Login("some_username", "brevhbehvbe").Return(loggedInUser, nil)
-	testSessManager.EXPECT().CreateNewSession(loggedInUser).Return("some_token", nil)
+	testRepo.EXPECT().Login(gomock.Any(), "some_username", "brevhbehvbe").Return(loggedInUser, nil)
+	testSessManager.EXPECT().CreateNewSession(gomock.Any(), loggedInUser).Return("some_token", nil)
 	request = httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(defaultReqBody))
 	respWriter = httptest.NewRecorder()
 	testHandler.Login(respWriter, request)
@@ -190,7 +192,7 @@ func TestUserHandlerRegister(t *testing.T) {
 
 	//  такой юзер уже существует
 	reqBody := `{"username":"already_exist_username", "password":"password"}`
-	testRepo.EXPECT().Register("already_exist_username", "password").Return(nil, user.ErrAlreadyExist)
+	testRepo.EXPECT().Register(gomock.Any(), "already_exist_username", "password").Return(nil, user.ErrAlreadyExist)
 	request = httptest.NewRequest(http.MethodPost, "/api/register", strings.NewReader(reqBody))
 	respWriter = httptest.NewRecorder()
 	testHandler.Register(respWriter, request)
@@ -206,7 +208,7 @@ func TestUserHandlerRegister(t *testing.T) {
 
 	// какая то ошибка сервера
 	reqBody = `{"username":"username", "password":"password"}`
-	testRepo.EXPECT().Register("username", "password").Return(nil, fmt.Errorf("error"))
+	testRepo.EXPECT().Register(gomock.Any(), "username", "password").Return(nil, fmt.Errorf("error"))
 	request = httptest.NewRequest(http.MethodPost, "/api/register", strings.NewReader(reqBody))
 	respWriter = httptest.NewRecorder()
 	testHandler.Register(respWriter, request)
@@ -225,8 +227,8 @@ func TestUserHandlerRegister(t *testing.T) {
 		ID:       "some_id",
 		Username: "some_username",
 	}
-	testRepo.EXPECT().Register("some_username", "brevhbehvbe").Return(registredUser, nil)
-	testSessManager.EXPECT().CreateNewSession(registredUser).Return("some_token", nil)
+	testRepo.EXPECT().Register(gomock.Any(), "some_username", "brevhbehvbe").Return(registredUser, nil)
+	testSessManager.EXPECT().CreateNewSession(gomock.Any(), registredUser).Return("some_token", nil)
 	request = httptest.NewRequest(http.MethodPost, "/api/register", strings.NewReader(defaultReqBody))
 	respWriter = httptest.NewRecorder()
 	testHandler.Register(respWriter, request)
@@ -241,3 +243,56 @@ func TestUserHandlerRegister(t *testing.T) {
 	}
 
 }
+
+func TestUserHandlerPreferences(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := user.NewMockUserRepo(ctrl)
+	testHandler := &UserHandler{
+		UserRepo: testRepo,
+		Logger:   zap.NewNop().Sugar(),
+	}
+	currentUser := &user.User{
+		ID:       "GgGHsZysctdVTaCvTZWhgzLReBThTXHc",
+		Username: "jjjjjjjj",
+	}
+
+	// получение настроек
+	testRepo.EXPECT().GetPreferences(gomock.Any(), currentUser.ID).Return(&user.Preferences{NSFW: user.NSFWHide}, nil)
+	request := httptest.NewRequest(http.MethodGet, "/api/user/me/preferences", nil)
+	ctx := context.WithValue(request.Context(), middleware.MyUserKey, currentUser)
+	respWriter := httptest.NewRecorder()
+	testHandler.GetPreferences(respWriter, request.WithContext(ctx))
+	resp := respWriter.Result()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("unable to read response body")
+		return
+	}
+	if resp.StatusCode != http.StatusOK || string(body) != `{"nsfw":"hide"}` {
+		t.Errorf("wrong response: %d %s", resp.StatusCode, body)
+		return
+	}
+
+	// некорректное значение не проходит валидацию
+	request = httptest.NewRequest(http.MethodPut, "/api/user/me/preferences", strings.NewReader(`{"nsfw": "sometimes"}`))
+	ctx = context.WithValue(request.Context(), middleware.MyUserKey, currentUser)
+	respWriter = httptest.NewRecorder()
+	testHandler.SetPreferences(respWriter, request.WithContext(ctx))
+	if resp = respWriter.Result(); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d, got status %d", http.StatusUnprocessableEntity, resp.StatusCode)
+		return
+	}
+
+	// настройки сохранены
+	testRepo.EXPECT().SetPreferences(gomock.Any(), currentUser.ID, &user.Preferences{NSFW: user.NSFWShow}).Return(nil)
+	request = httptest.NewRequest(http.MethodPut, "/api/user/me/preferences", strings.NewReader(`{"nsfw": "show"}`))
+	ctx = context.WithValue(request.Context(), middleware.MyUserKey, currentUser)
+	respWriter = httptest.NewRecorder()
+	testHandler.SetPreferences(respWriter, request.WithContext(ctx))
+	if resp = respWriter.Result(); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status %d, got status %d", http.StatusOK, resp.StatusCode)
+		return
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"reddit/pkg/middleware"
+	"reddit/pkg/response"
+	"reddit/pkg/user"
+	"reddit/pkg/webhook"
+)
+
+type WebhookHandler struct {
+	WebhookRepo webhook.WebhookRepo
+	Logger      *zap.SugaredLogger
+}
+
+// Subscribe - secret есть только в этом ответе, им подписчик проверяет X-Webhook-Signature
+func (wh *WebhookHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	author, ok := r.Context().Value(middleware.MyUserKey).(*user.User)
+	if !ok {
+		response.WriteResponse(wh.Logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+		return
+	}
+	rBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in reading request body: %s"}`, err)
+		response.WriteResponse(wh.Logger, w, []byte(errText), http.StatusBadRequest)
+		return
+	}
+	form := &webhook.SubscriptionForm{}
+	err = json.Unmarshal(rBody, form)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in json decoding of webhook form: %s"}`, err)
+		response.WriteResponse(wh.Logger, w, []byte(errText), http.StatusBadRequest)
+		return
+	}
+	if validationErrors := form.Validate(); len(validationErrors) != 0 {
+		wh.writeJSON(w, validationErrors, http.StatusUnprocessableEntity)
+		return
+	}
+	subscription, err := wh.WebhookRepo.Subscribe(r.Context(), author, form)
+	if err != nil {
+		wh.writeError(w, err)
+		return
+	}
+	wh.writeJSON(w, subscription, http.StatusCreated)
+}
+
+func (wh *WebhookHandler) List(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := wh.WebhookRepo.Subscriptions(r.Context())
+	if err != nil {
+		wh.writeError(w, err)
+		return
+	}
+	wh.writeJSON(w, subscriptions, http.StatusOK)
+}
+
+func (wh *WebhookHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	err := wh.WebhookRepo.Unsubscribe(r.Context(), mux.Vars(r)["WEBHOOK_ID"])
+	if err != nil {
+		wh.writeError(w, err)
+		return
+	}
+	response.WriteResponse(wh.Logger, w, []byte(`{"message": "success"}`), http.StatusOK)
+}
+
+// Deliveries - журнал последних доставок подписки, новые сверху
+func (wh *WebhookHandler) Deliveries(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := wh.WebhookRepo.Deliveries(r.Context(), mux.Vars(r)["WEBHOOK_ID"])
+	if err != nil {
+		wh.writeError(w, err)
+		return
+	}
+	wh.writeJSON(w, deliveries, http.StatusOK)
+}
+
+func (wh *WebhookHandler) DeadLetters(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := wh.WebhookRepo.DeadLetters(r.Context())
+	if err != nil {
+		wh.writeError(w, err)
+		return
+	}
+	wh.writeJSON(w, deliveries, http.StatusOK)
+}
+
+func (wh *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	err := wh.WebhookRepo.Redeliver(r.Context(), mux.Vars(r)["DELIVERY_ID"])
+	if err != nil {
+		wh.writeError(w, err)
+		return
+	}
+	response.WriteResponse(wh.Logger, w, []byte(`{"message": "success"}`), http.StatusAccepted)
+}
+
+func (wh *WebhookHandler) writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, webhook.ErrNoSubscription):
+		response.WriteResponse(wh.Logger, w, []byte(`{"message": "there is no such webhook"}`), http.StatusNotFound)
+	case errors.Is(err, webhook.ErrNoDelivery):
+		response.WriteResponse(wh.Logger, w, []byte(`{"message": "there is no such dead delivery"}`), http.StatusNotFound)
+	default:
+		errText := fmt.Sprintf(`{"message": "error in webhook processing: %s"}`, err)
+		response.WriteResponse(wh.Logger, w, []byte(errText), http.StatusInternalServerError)
+	}
+}
+
+func (wh *WebhookHandler) writeJSON(w http.ResponseWriter, value interface{}, status int) {
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		errText := fmt.Sprintf(`{"message": "error in coding webhooks: %s"}`, err)
+		response.WriteResponse(wh.Logger, w, []byte(errText), http.StatusInternalServerError)
+		return
+	}
+	response.WriteResponse(wh.Logger, w, valueJSON, status)
+}
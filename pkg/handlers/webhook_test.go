@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"reddit/pkg/middleware"
+	"reddit/pkg/user"
+	"reddit/pkg/webhook"
+)
+
+func TestWebhookHandlerSubscribe(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := webhook.NewMockWebhookRepo(ctrl)
+	testHandler := &WebhookHandler{
+		Logger:      zap.NewNop().Sugar(),
+		WebhookRepo: testRepo,
+	}
+	moderator := &user.User{ID: "moderator_id", Username: "jjjjjjjj"}
+
+	cases := []struct {
+		name    string
+		body    string
+		retErr  error
+		status  int
+		snippet string
+	}{
+		{"кривой json", `{"url": `, nil, http.StatusBadRequest, ""},
+		{"неизвестное событие", `{"url": "https://example.com", "events": ["vote"]}`, nil, http.StatusUnprocessableEntity, "unknown event vote"},
+		{"ошибка базы", `{"url": "https://example.com"}`, fmt.Errorf("db_error"), http.StatusInternalServerError, ""},
+		{"подписка создана", `{"url": "https://example.com", "category": "music"}`, nil, http.StatusCreated, `"secret":"abc"`},
+	}
+	for _, testCase := range cases {
+		if testCase.status == http.StatusCreated || testCase.status == http.StatusInternalServerError {
+			form := &webhook.SubscriptionForm{URL: "https://example.com"}
+			if testCase.status == http.StatusCreated {
+				form.Category = "music"
+			}
+			testRepo.EXPECT().Subscribe(gomock.Any(), moderator, form).Return(&webhook.Subscription{URL: form.URL, Secret: "abc"}, testCase.retErr)
+		}
+		request := httptest.NewRequest(http.MethodPost, "/api/moderation/webhooks", strings.NewReader(testCase.body))
+		ctx := context.WithValue(request.Context(), middleware.MyUserKey, moderator)
+		respWriter := httptest.NewRecorder()
+		testHandler.Subscribe(respWriter, request.WithContext(ctx))
+		if respWriter.Code != testCase.status {
+			t.Errorf("[%s] expected status %d, got status %d", testCase.name, testCase.status, respWriter.Code)
+			return
+		}
+		if !strings.Contains(respWriter.Body.String(), testCase.snippet) {
+			t.Errorf("[%s] wrong body: %s", testCase.name, respWriter.Body.String())
+			return
+		}
+	}
+}
+
+func TestWebhookHandlerDeliveries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testRepo := webhook.NewMockWebhookRepo(ctrl)
+	testHandler := &WebhookHandler{
+		Logger:      zap.NewNop().Sugar(),
+		WebhookRepo: testRepo,
+	}
+
+	// журнал доставок
+	testRepo.EXPECT().Deliveries(gomock.Any(), "webhook_id").Return([]*webhook.Delivery{{Event: "post_added", Status: webhook.StatusDead}}, nil)
+	request := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/moderation/webhooks/webhook_id/deliveries", nil), map[string]string{"WEBHOOK_ID": "webhook_id"})
+	respWriter := httptest.NewRecorder()
+	testHandler.Deliveries(respWriter, request)
+	if respWriter.Code != http.StatusOK || !strings.Contains(respWriter.Body.String(), `"status":"dead"`) {
+		t.Errorf("unexpected response: %d %s", respWriter.Code, respWriter.Body.String())
+		return
+	}
+
+	cases := []struct {
+		name    string
+		handler http.HandlerFunc
+		vars    map[string]string
+		retErr  error
+		status  int
+	}{
+		{"нет подписки", testHandler.Unsubscribe, map[string]string{"WEBHOOK_ID": "webhook_id"}, webhook.ErrNoSubscription, http.StatusNotFound},
+		{"подписка удалена", testHandler.Unsubscribe, map[string]string{"WEBHOOK_ID": "webhook_id"}, nil, http.StatusOK},
+		{"доставка не в dead-letter", testHandler.Redeliver, map[string]string{"DELIVERY_ID": "delivery_id"}, webhook.ErrNoDelivery, http.StatusNotFound},
+		{"доставка снова в очереди", testHandler.Redeliver, map[string]string{"DELIVERY_ID": "delivery_id"}, nil, http.StatusAccepted},
+	}
+	for _, testCase := range cases {
+		if _, ok := testCase.vars["WEBHOOK_ID"]; ok {
+			testRepo.EXPECT().Unsubscribe(gomock.Any(), "webhook_id").Return(testCase.retErr)
+		} else {
+			testRepo.EXPECT().Redeliver(gomock.Any(), "delivery_id").Return(testCase.retErr)
+		}
+		request = mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/", nil), testCase.vars)
+		respWriter = httptest.NewRecorder()
+		testCase.handler(respWriter, request)
+		if respWriter.Code != testCase.status {
+			t.Errorf("[%s] expected status %d, got status %d", testCase.name, testCase.status, respWriter.Code)
+			return
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package lock
+
+import (
+	"context"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// DefaultTimeout - сколько ждем redis на одну команду блокировки
+const DefaultTimeout = 2 * time.Second
+
+// extendScript продлевает блокировку, только если она все еще наша
+const extendScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+
+// releaseScript снимает блокировку, только если она наша
+const releaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+
+// RedisLock - лидер среди реплик: кто держит ключ, тот и работает,
+// ключ живет TTL и продлевается каждым удачным Acquire
+type RedisLock struct {
+	RedisPool *redis.Pool
+	Key       string
+	Owner     string
+	TTL       time.Duration
+	// Timeout - срок одной команды, 0 - DefaultTimeout
+	Timeout time.Duration
+}
+
+func NewRedisLock(redisPool *redis.Pool, key, owner string, ttl time.Duration) *RedisLock {
+	return &RedisLock{
+		RedisPool: redisPool,
+		Key:       key,
+		Owner:     owner,
+		TTL:       ttl,
+	}
+}
+
+func (l *RedisLock) do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	timeout := l.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := l.RedisPool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return redis.DoContext(conn, ctx, cmd, args...)
+}
+
+func (l *RedisLock) Acquire(ctx context.Context) (bool, error) {
+	ttl := l.TTL.Milliseconds()
+	_, err := redis.String(l.do(ctx, "SET", l.Key, l.Owner, "NX", "PX", ttl))
+	if err == nil {
+		return true, nil
+	}
+	if err != redis.ErrNil {
+		return false, err
+	}
+	extended, err := redis.Int(l.do(ctx, "EVAL", extendScript, 1, l.Key, l.Owner, ttl))
+	if err != nil {
+		return false, err
+	}
+	return extended == 1, nil
+}
+
+func (l *RedisLock) Release(ctx context.Context) error {
+	_, err := l.do(ctx, "EVAL", releaseScript, 1, l.Key, l.Owner)
+	return err
+}
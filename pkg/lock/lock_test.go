@@ -0,0 +1,148 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// fakeRedis - один ключ с временем жизни, SET NX PX и два наших скрипта.
+// hang - redis не отвечает, команда ждет, пока не истечет ее контекст
+type fakeRedis struct {
+	now     time.Time
+	values  map[string]string
+	expires map[string]time.Time
+	hang    bool
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{now: time.Now(), values: make(map[string]string), expires: make(map[string]time.Time)}
+}
+
+func (f *fakeRedis) get(key string) (string, bool) {
+	value, ok := f.values[key]
+	if ok && !f.now.Before(f.expires[key]) {
+		delete(f.values, key)
+		return "", false
+	}
+	return value, ok
+}
+
+func (f *fakeRedis) Close() error { return nil }
+func (f *fakeRedis) Err() error   { return nil }
+func (f *fakeRedis) Send(_ string, _ ...interface{}) error {
+	return nil
+}
+func (f *fakeRedis) Flush() error { return nil }
+func (f *fakeRedis) Receive() (interface{}, error) {
+	return nil, nil
+}
+func (f *fakeRedis) ReceiveContext(_ context.Context) (interface{}, error) {
+	return nil, nil
+}
+
+func (f *fakeRedis) DoContext(ctx context.Context, command string, args ...interface{}) (interface{}, error) {
+	if f.hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return f.Do(command, args...)
+}
+
+func (f *fakeRedis) Do(command string, args ...interface{}) (interface{}, error) {
+	switch command {
+	case "":
+		// пул так проверяет соединение перед возвратом
+		return nil, nil
+	case "SET":
+		key := args[0].(string)
+		if _, ok := f.get(key); ok {
+			return nil, nil
+		}
+		f.values[key] = args[1].(string)
+		f.expires[key] = f.now.Add(time.Duration(args[4].(int64)) * time.Millisecond)
+		return "OK", nil
+	case "EVAL":
+		key, owner := args[2].(string), args[3].(string)
+		if value, ok := f.get(key); !ok || value != owner {
+			return int64(0), nil
+		}
+		switch args[0] {
+		case extendScript:
+			f.expires[key] = f.now.Add(time.Duration(args[4].(int64)) * time.Millisecond)
+		case releaseScript:
+			delete(f.values, key)
+		}
+		return int64(1), nil
+	}
+	return nil, fmt.Errorf("unexpected command %s", command)
+}
+
+func newFakePool(redisConn *fakeRedis) *redis.Pool {
+	return &redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}
+}
+
+func TestRedisLock(t *testing.T) {
+	redisConn := newFakeRedis()
+	first := NewRedisLock(newFakePool(redisConn), "lock:scheduler", "first", time.Minute)
+	second := NewRedisLock(newFakePool(redisConn), "lock:scheduler", "second", time.Minute)
+
+	acquire := func(lock *RedisLock, expected bool) {
+		t.Helper()
+		leader, err := lock.Acquire(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if leader != expected {
+			t.Fatalf("%s: expected leader %t, got %t", lock.Owner, expected, leader)
+		}
+	}
+
+	// лидер один, повторный Acquire лидера продлевает ключ
+	acquire(first, true)
+	acquire(second, false)
+	redisConn.now = redisConn.now.Add(50 * time.Second)
+	acquire(first, true)
+	redisConn.now = redisConn.now.Add(50 * time.Second)
+	acquire(second, false)
+
+	// чужой Release ничего не снимает
+	if err := second.Release(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	acquire(second, false)
+
+	// лидер пропал, ключ протух - лидером становится другая реплика
+	redisConn.now = redisConn.now.Add(2 * time.Minute)
+	acquire(second, true)
+	acquire(first, false)
+
+	// лидер отпустил блокировку сам
+	if err := second.Release(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	acquire(first, true)
+}
+
+func TestRedisLockContext(t *testing.T) {
+	redisConn := newFakeRedis()
+	redisConn.hang = true
+	locker := NewRedisLock(newFakePool(redisConn), "lock:scheduler", "first", time.Minute)
+
+	// отмененный запрос не ждет redis
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := locker.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	// зависший redis обрывается по сроку команды
+	locker.Timeout = 10 * time.Millisecond
+	if err := locker.Release(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// maxQuoteDepth - глубже цитаты рендерятся как обычный текст
+const maxQuoteDepth = 8
+
+var (
+	unorderedItem  = regexp.MustCompile(`^ {0,3}[-*+][ \t]+(.*)$`)
+	orderedItem    = regexp.MustCompile(`^ {0,3}[0-9]{1,9}[.)][ \t]+(.*)$`)
+	allowedSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+)
+
+// Render переводит безопасное подмножество markdown (ссылки, выделение, код, цитаты, списки) в html.
+// Весь текст исходника экранируется, поэтому в результате бывают только теги, которые сгенерировал сам рендерер
+func Render(source string) string {
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	out := &strings.Builder{}
+	renderBlocks(out, strings.Split(source, "\n"), 0)
+	return out.String()
+}
+
+func renderBlocks(out *strings.Builder, lines []string, depth int) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+		case isFence(line):
+			i++
+			code := make([]string, 0)
+			for i < len(lines) && !isFence(lines[i]) {
+				code = append(code, lines[i])
+				i++
+			}
+			i++
+			out.WriteString("<pre><code>")
+			out.WriteString(html.EscapeString(strings.Join(code, "\n")))
+			out.WriteString("</code></pre>")
+		case isQuote(line) && depth < maxQuoteDepth:
+			quoted := make([]string, 0)
+			for i < len(lines) && isQuote(lines[i]) {
+				quoted = append(quoted, unquote(lines[i]))
+				i++
+			}
+			out.WriteString("<blockquote>")
+			renderBlocks(out, quoted, depth+1)
+			out.WriteString("</blockquote>")
+		case unorderedItem.MatchString(line):
+			i = renderList(out, lines, i, unorderedItem, "ul")
+		case orderedItem.MatchString(line):
+			i = renderList(out, lines, i, orderedItem, "ol")
+		default:
+			paragraph := []string{strings.TrimSpace(line)}
+			i++
+			for i < len(lines) && !startsBlock(lines[i]) {
+				paragraph = append(paragraph, strings.TrimSpace(lines[i]))
+				i++
+			}
+			out.WriteString("<p>")
+			out.WriteString(renderInline(strings.Join(paragraph, "\n"), true))
+			out.WriteString("</p>")
+		}
+	}
+}
+
+// renderList - строки без маркера продолжают предыдущий пункт, вложенных списков нет
+func renderList(out *strings.Builder, lines []string, i int, item *regexp.Regexp, tag string) int {
+	items := make([]string, 0)
+	for i < len(lines) {
+		if match := item.FindStringSubmatch(lines[i]); match != nil {
+			items = append(items, strings.TrimSpace(match[1]))
+		} else if startsBlock(lines[i]) {
+			break
+		} else {
+			items[len(items)-1] += "\n" + strings.TrimSpace(lines[i])
+		}
+		i++
+	}
+	out.WriteString("<" + tag + ">")
+	for _, text := range items {
+		out.WriteString("<li>")
+		out.WriteString(renderInline(text, true))
+		out.WriteString("</li>")
+	}
+	out.WriteString("</" + tag + ">")
+	return i
+}
+
+func startsBlock(line string) bool {
+	return strings.TrimSpace(line) == "" || isFence(line) || isQuote(line) ||
+		unorderedItem.MatchString(line) || orderedItem.MatchString(line)
+}
+
+func isFence(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "```")
+}
+
+func isQuote(line string) bool {
+	return strings.HasPrefix(strings.TrimLeft(line, " "), ">")
+}
+
+func unquote(line string) string {
+	line = strings.TrimPrefix(strings.TrimLeft(line, " "), ">")
+	return strings.TrimPrefix(line, " ")
+}
+
+func renderInline(text string, withLinks bool) string {
+	out := &strings.Builder{}
+	links := &linkScanner{text: text, closeBracket: -1, closeParen: -1}
+	for i := 0; i < len(text); {
+		switch c := text[i]; {
+		case c == '\\' && i+1 < len(text) && isPunct(text[i+1]):
+			out.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+		case c == '`':
+			end := strings.IndexByte(text[i+1:], '`')
+			if end < 0 {
+				out.WriteString("`")
+				i++
+				continue
+			}
+			out.WriteString("<code>")
+			out.WriteString(html.EscapeString(text[i+1 : i+1+end]))
+			out.WriteString("</code>")
+			i += end + 2
+		case c == '[' && withLinks:
+			label, target, size, ok := links.parseLink(i)
+			if !ok {
+				out.WriteString("[")
+				i++
+				continue
+			}
+			if safeURL(target) {
+				out.WriteString(`<a href="`)
+				out.WriteString(html.EscapeString(target))
+				out.WriteString(`" rel="nofollow noopener">`)
+				out.WriteString(renderInline(label, false))
+				out.WriteString("</a>")
+			} else {
+				out.WriteString(renderInline(label, false))
+			}
+			i += size
+		case (c == '*' || c == '_') && (c == '*' || i == 0 || !isWordChar(text[i-1])):
+			delimiter := text[i : i+1]
+			tag := "em"
+			if strings.HasPrefix(text[i:], delimiter+delimiter) {
+				delimiter += delimiter
+				tag = "strong"
+			}
+			start := i + len(delimiter)
+			end := strings.Index(text[start:], delimiter)
+			if end <= 0 {
+				out.WriteString(html.EscapeString(delimiter))
+				i = start
+				continue
+			}
+			out.WriteString("<" + tag + ">")
+			out.WriteString(renderInline(text[start:start+end], withLinks))
+			out.WriteString("</" + tag + ">")
+			i = start + end + len(delimiter)
+		default:
+			out.WriteString(html.EscapeString(text[i : i+1]))
+			i++
+		}
+	}
+	return out.String()
+}
+
+// linkScanner помнит найденные ] и ), так что текст из одних [ разбирается за один проход, а не за квадрат
+type linkScanner struct {
+	text         string
+	closeBracket int
+	closeParen   int
+}
+
+// next - первый c не раньше from. Позиции from только растут, поэтому найденное раньше остается верным,
+// пока from его не обогнал, а len(text) значит, что дальше c нет вовсе
+func (l *linkScanner) next(c byte, from int, found *int) int {
+	if *found < from {
+		*found = len(l.text)
+		if end := strings.IndexByte(l.text[from:], c); end >= 0 {
+			*found = from + end
+		}
+	}
+	return *found
+}
+
+// parseLink разбирает [текст](адрес), начинающийся с позиции start
+func (l *linkScanner) parseLink(start int) (label, target string, size int, ok bool) {
+	labelEnd := l.next(']', start, &l.closeBracket)
+	if labelEnd == len(l.text) || !strings.HasPrefix(l.text[labelEnd+1:], "(") {
+		return "", "", 0, false
+	}
+	targetEnd := l.next(')', labelEnd+2, &l.closeParen)
+	if targetEnd == len(l.text) {
+		return "", "", 0, false
+	}
+	label = l.text[start+1 : labelEnd]
+	target = strings.TrimSpace(l.text[labelEnd+2 : targetEnd])
+	return label, target, targetEnd + 1 - start, true
+}
+
+// safeURL - разрешены относительные ссылки и схемы из allowedSchemes
+func safeURL(target string) bool {
+	if target == "" || strings.ContainsAny(target, " \t\n\\") {
+		return false
+	}
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+	if parsed.Scheme == "" {
+		return !strings.Contains(strings.SplitN(target, "/", 2)[0], ":")
+	}
+	return allowedSchemes[parsed.Scheme]
+}
+
+func isPunct(c byte) bool {
+	return strings.IndexByte("\\`*_[]()>#+-.!~|", c) >= 0
+}
+
+func isWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
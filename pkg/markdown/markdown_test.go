@@ -0,0 +1,117 @@
+package markdown
+
+import (
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+func TestRender(t *testing.T) {
+	cases := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{"пустой текст", "", ""},
+		{"абзацы", "первый\nвсе еще первый\n\nвторой", "<p>первый\nвсе еще первый</p><p>второй</p>"},
+		{"выделение", "**жирный** и *курсив* и _тоже_", "<p><strong>жирный</strong> и <em>курсив</em> и <em>тоже</em></p>"},
+		{"подчеркивания внутри слова", "snake_case_name", "<p>snake_case_name</p>"},
+		{"незакрытое выделение", "2 * 3 = 6", "<p>2 * 3 = 6</p>"},
+		{"код", "вызов `a<b && c`", "<p>вызов <code>a&lt;b &amp;&amp; c</code></p>"},
+		{"блок кода", "```go\nfmt.Println(\"<hi>\")\n```", "<pre><code>fmt.Println(&#34;&lt;hi&gt;&#34;)</code></pre>"},
+		{"ссылка", "[go](https://go.dev/doc?a=1&b=2)", `<p><a href="https://go.dev/doc?a=1&amp;b=2" rel="nofollow noopener">go</a></p>`},
+		{"относительная ссылка", "[пост](/a/programming)", `<p><a href="/a/programming" rel="nofollow noopener">пост</a></p>`},
+		{"javascript ссылка", "[click](javascript:alert(1))", "<p>click)</p>"},
+		{"javascript с регистром", "[click](JaVaScRiPt:alert`1`)", "<p>click</p>"},
+		{"data ссылка", "[x](data:text/html;base64,PHNjcmlwdD4=)", "<p>x</p>"},
+		{"html экранируется", `<script>alert("x")</script><img src=x onerror=alert(1)>`, "<p>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;&lt;img src=x onerror=alert(1)&gt;</p>"},
+		{"экранирование markdown", `\*не курсив\*`, "<p>*не курсив*</p>"},
+		{"незакрытые скобки", "[a [b](/c) [d] (e) [f](g", `<p><a href="/c" rel="nofollow noopener">a [b</a> [d] (e) [f](g</p>`},
+		{"цитата", "> цитата\n>> вложенная\n\nпосле", "<blockquote><p>цитата</p><blockquote><p>вложенная</p></blockquote></blockquote><p>после</p>"},
+		{"списки", "- один\n- два\nпродолжение\n\n1. первый\n2. второй", "<ul><li>один</li><li>два\nпродолжение</li></ul><ol><li>первый</li><li>второй</li></ol>"},
+	}
+	for _, testCase := range cases {
+		rendered := Render(testCase.source)
+		if rendered != testCase.expected {
+			t.Errorf("%s: expected %q, got %q", testCase.name, testCase.expected, rendered)
+		}
+	}
+}
+
+// TestRenderLinear - незакрытые [ не должны пересматривать остаток текста заново
+func TestRenderLinear(t *testing.T) {
+	sources := []string{
+		strings.Repeat("[", 1000000),
+		strings.Repeat("[", 1000000) + "]",
+		strings.Repeat("[a](", 250000),
+	}
+	for _, source := range sources {
+		started := time.Now()
+		Render(source)
+		if elapsed := time.Since(started); elapsed > time.Second {
+			t.Errorf("render of %d bytes took %s", len(source), elapsed)
+		}
+	}
+}
+
+var allowedTags = map[string]bool{
+	"p": true, "em": true, "strong": true, "code": true, "pre": true,
+	"blockquote": true, "ul": true, "ol": true, "li": true, "a": true,
+}
+
+// FuzzRender - в результате могут быть только разрешенные теги и атрибуты, а ссылки - только безопасные
+func FuzzRender(f *testing.F) {
+	seeds := []string{
+		"**bold** _em_ `code` [link](https://example.com)",
+		"<script>alert(1)</script>",
+		`[x](javascript:alert(1)) [y](" onmouseover="alert(1))`,
+		"[x](java\tscript:alert(1)) [y](//evil.com) [z](&#106;avascript:alert(1))",
+		"> quote\n- item\n1. item\n```\n<b>code</b>\n```",
+		"*[a](http://x)* **`<i>`**",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, source string) {
+		rendered := Render(source)
+		tokenizer := html.NewTokenizer(strings.NewReader(rendered))
+		for {
+			tokenType := tokenizer.Next()
+			if tokenType == html.ErrorToken {
+				if tokenizer.Err() != io.EOF {
+					t.Fatalf("tokenizer error: %s", tokenizer.Err())
+				}
+				return
+			}
+			if tokenType != html.StartTagToken && tokenType != html.EndTagToken && tokenType != html.SelfClosingTagToken {
+				if tokenType != html.TextToken {
+					t.Fatalf("unexpected token %s in %q", tokenizer.Token().String(), rendered)
+				}
+				continue
+			}
+			token := tokenizer.Token()
+			if !allowedTags[token.Data] {
+				t.Fatalf("tag %q is not allowed: %q", token.Data, rendered)
+			}
+			for _, attr := range token.Attr {
+				switch {
+				case token.Data == "a" && attr.Key == "rel":
+				case token.Data == "a" && attr.Key == "href":
+					parsed, err := url.Parse(attr.Val)
+					if err != nil {
+						t.Fatalf("bad href %q: %s", attr.Val, err)
+					}
+					if parsed.Scheme != "" && !allowedSchemes[parsed.Scheme] {
+						t.Fatalf("scheme %q is not allowed: %q", parsed.Scheme, rendered)
+					}
+				default:
+					t.Fatalf("attribute %q of %q is not allowed: %q", attr.Key, token.Data, rendered)
+				}
+			}
+		}
+	})
+}
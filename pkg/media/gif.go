@@ -0,0 +1,70 @@
+package media
+
+// maxGIFFrames - столько кадров гифке хватит для любой разумной анимации
+const maxGIFFrames = 1000
+
+// checkGIF проходит по блокам гифки, не распаковывая кадры, и считает кадры и их площадь.
+// Сжатый кадр занимает несколько байт, а в памяти после gif.DecodeAll - байт на пиксель,
+// поэтому бюджет проверяется до декодирования
+func checkGIF(data []byte) error {
+	// заголовок GIF89a и логический экран
+	const headerSize = 6 + 7
+	if len(data) < headerSize {
+		return ErrBadImage
+	}
+	pos := headerSize
+	if flags := data[10]; flags&0x80 != 0 {
+		pos += 3 << (flags&0x07 + 1)
+	}
+	frames, pixels := 0, 0
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x3B:
+			return nil
+		case 0x21:
+			// расширение: метка и подблоки
+			var ok bool
+			if pos, ok = skipSubBlocks(data, pos+2); !ok {
+				return ErrBadImage
+			}
+		case 0x2C:
+			if pos+10 > len(data) {
+				return ErrBadImage
+			}
+			width := int(data[pos+5]) | int(data[pos+6])<<8
+			height := int(data[pos+7]) | int(data[pos+8])<<8
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (flags&0x07 + 1)
+			}
+			frames++
+			pixels += width * height
+			if frames > maxGIFFrames || pixels > maxPixels {
+				return ErrTooLarge
+			}
+			// минимальный размер кода LZW, потом сжатые данные подблоками
+			var ok bool
+			if pos, ok = skipSubBlocks(data, pos+1); !ok {
+				return ErrBadImage
+			}
+		default:
+			return ErrBadImage
+		}
+	}
+	// без завершающего блока - пусть разбирается декодер
+	return nil
+}
+
+// skipSubBlocks пропускает цепочку подблоков, начинающуюся с pos, вместе с нулевым терминатором
+func skipSubBlocks(data []byte, pos int) (int, bool) {
+	for pos < len(data) {
+		size := int(data[pos])
+		pos++
+		if size == 0 {
+			return pos, true
+		}
+		pos += size
+	}
+	return pos, false
+}
@@ -0,0 +1,164 @@
+package media
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"reddit/pkg/blob"
+)
+
+const (
+	DefaultMaxSize       = 10 << 20
+	DefaultThumbnailSide = 320
+	maxPixels            = 40_000_000
+	jpegQuality          = 90
+)
+
+var (
+	ErrTooLarge        = errors.New("file is too large")
+	ErrUnsupportedType = errors.New("unsupported file type")
+	ErrBadImage        = errors.New("file is not a valid image")
+)
+
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+type Uploader struct {
+	Storage       blob.Storage
+	MaxSize       int64
+	ThumbnailSide int
+}
+
+type Upload struct {
+	Key          string
+	ThumbnailKey string
+	ContentType  string
+	Width        int
+	Height       int
+}
+
+func NewUploader(storage blob.Storage) *Uploader {
+	return &Uploader{
+		Storage:       storage,
+		MaxSize:       DefaultMaxSize,
+		ThumbnailSide: DefaultThumbnailSide,
+	}
+}
+
+// Upload проверяет картинку, перекодирует ее без метаданных (EXIF в том числе),
+// делает превью и сохраняет оба файла в хранилище
+func (u *Uploader) Upload(data []byte) (*Upload, error) {
+	if int64(len(data)) > u.MaxSize {
+		return nil, ErrTooLarge
+	}
+	// тип определяем по содержимому, а не по тому, что прислал клиент
+	contentType := http.DetectContentType(data)
+	extension, ok := extensions[contentType]
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrBadImage
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+	// у гифки размер экрана ничего не говорит о числе кадров
+	if contentType == "image/gif" {
+		if err = checkGIF(data); err != nil {
+			return nil, err
+		}
+	}
+	cleaned, preview, err := reencode(data, contentType, u.ThumbnailSide)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(cleaned)
+	key := hex.EncodeToString(hash[:])
+	upload := &Upload{
+		Key:          key + extension,
+		ThumbnailKey: key + "_thumb" + thumbnailExtension(contentType),
+		ContentType:  contentType,
+		Width:        config.Width,
+		Height:       config.Height,
+	}
+	err = u.Storage.Put(upload.Key, cleaned, contentType)
+	if err != nil {
+		return nil, err
+	}
+	err = u.Storage.Put(upload.ThumbnailKey, preview, thumbnailType(contentType))
+	if err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+func reencode(data []byte, contentType string, thumbnailSide int) ([]byte, []byte, error) {
+	cleaned := &bytes.Buffer{}
+	var first image.Image
+	switch contentType {
+	case "image/gif":
+		// гифки перекодируем целиком, чтобы не потерять анимацию
+		animation, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil || len(animation.Image) == 0 {
+			return nil, nil, ErrBadImage
+		}
+		animation.Config.ColorModel = nil
+		if err = gif.EncodeAll(cleaned, animation); err != nil {
+			return nil, nil, err
+		}
+		first = animation.Image[0]
+	case "image/png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, ErrBadImage
+		}
+		if err = png.Encode(cleaned, img); err != nil {
+			return nil, nil, err
+		}
+		first = img
+	default:
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, ErrBadImage
+		}
+		if err = jpeg.Encode(cleaned, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, nil, err
+		}
+		first = img
+	}
+	preview := &bytes.Buffer{}
+	thumbnail := Thumbnail(first, thumbnailSide)
+	var err error
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(preview, thumbnail, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(preview, thumbnail)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return cleaned.Bytes(), preview.Bytes(), nil
+}
+
+func thumbnailType(contentType string) string {
+	if contentType == "image/jpeg" {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+func thumbnailExtension(contentType string) string {
+	return extensions[thumbnailType(contentType)]
+}
@@ -0,0 +1,179 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"testing"
+
+	"reddit/pkg/blob"
+)
+
+type testStorage struct {
+	objects map[string][]byte
+}
+
+func (s *testStorage) Put(key string, data []byte, _ string) error {
+	s.objects[key] = data
+	return nil
+}
+
+func (s *testStorage) Get(key string) (*blob.Object, error) {
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, blob.ErrNotFound
+	}
+	return &blob.Object{Data: data}, nil
+}
+
+func (s *testStorage) Delete(key string) error {
+	delete(s.objects, key)
+	return nil
+}
+
+func jpegWithExif(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	encoded := &bytes.Buffer{}
+	if err := jpeg.Encode(encoded, img, nil); err != nil {
+		t.Fatalf("can not encode jpeg: %s", err)
+	}
+	// вставляем APP1 сегмент с EXIF сразу после SOI
+	exif := append([]byte("Exif\x00\x00"), []byte("GPS 55.7558 37.6173")...)
+	segment := []byte{0xFF, 0xE1, byte((len(exif) + 2) >> 8), byte(len(exif) + 2)}
+	data := append([]byte{}, encoded.Bytes()[:2]...)
+	data = append(data, segment...)
+	data = append(data, exif...)
+	return append(data, encoded.Bytes()[2:]...)
+}
+
+func TestUpload(t *testing.T) {
+	storage := &testStorage{objects: make(map[string][]byte)}
+	uploader := NewUploader(storage)
+
+	// не картинка
+	_, err := uploader.Upload([]byte("<html><script>alert(1)</script></html>"))
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("wrong error: expected %s, got %v", ErrUnsupportedType, err)
+		return
+	}
+
+	// файл больше лимита
+	data := jpegWithExif(t, 640, 480)
+	uploader.MaxSize = int64(len(data) - 1)
+	_, err = uploader.Upload(data)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("wrong error: expected %s, got %v", ErrTooLarge, err)
+		return
+	}
+	uploader.MaxSize = DefaultMaxSize
+
+	// битая картинка с правильной сигнатурой
+	_, err = uploader.Upload(data[:200])
+	if !errors.Is(err, ErrBadImage) {
+		t.Errorf("wrong error: expected %s, got %v", ErrBadImage, err)
+		return
+	}
+
+	// картинка сохранена без EXIF, превью уменьшено
+	if !bytes.Contains(data, []byte("Exif")) {
+		t.Fatalf("test image must contain exif")
+	}
+	upload, err := uploader.Upload(data)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if upload.ContentType != "image/jpeg" || upload.Width != 640 || upload.Height != 480 {
+		t.Errorf("wrong upload: %+v", upload)
+		return
+	}
+	if bytes.Contains(storage.objects[upload.Key], []byte("Exif")) {
+		t.Errorf("exif is not stripped")
+		return
+	}
+	thumbnail, err := jpeg.DecodeConfig(bytes.NewReader(storage.objects[upload.ThumbnailKey]))
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if thumbnail.Width != DefaultThumbnailSide || thumbnail.Height != 240 {
+		t.Errorf("wrong thumbnail size: %dx%d", thumbnail.Width, thumbnail.Height)
+		return
+	}
+}
+
+// gifHeader - заголовок и логический экран без глобальной палитры
+func gifHeader(width, height int) []byte {
+	return []byte{'G', 'I', 'F', '8', '9', 'a', byte(width), byte(width >> 8), byte(height), byte(height >> 8), 0, 0, 0}
+}
+
+// gifFrame - описание кадра и один подблок, пиксели не нужны: до декодера такая гифка не доходит
+func gifFrame(width, height int) []byte {
+	return []byte{0x2C, 0, 0, 0, 0, byte(width), byte(width >> 8), byte(height), byte(height >> 8), 0, 2, 1, 0, 0}
+}
+
+func TestUploadGIF(t *testing.T) {
+	storage := &testStorage{objects: make(map[string][]byte)}
+	uploader := NewUploader(storage)
+
+	// анимация сохраняется со всеми кадрами
+	animation := &gif.GIF{}
+	for i := 0; i < 3; i++ {
+		animation.Image = append(animation.Image, image.NewPaletted(image.Rect(0, 0, 16, 16), palette.Plan9))
+		animation.Delay = append(animation.Delay, 10)
+	}
+	encoded := &bytes.Buffer{}
+	if err := gif.EncodeAll(encoded, animation); err != nil {
+		t.Fatalf("can not encode gif: %s", err)
+	}
+	upload, err := uploader.Upload(encoded.Bytes())
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	saved, err := gif.DecodeAll(bytes.NewReader(storage.objects[upload.Key]))
+	if err != nil || len(saved.Image) != 3 {
+		t.Errorf("animation is not kept: %v", err)
+		return
+	}
+
+	// маленький экран, но кадров слишком много
+	data := gifHeader(1, 1)
+	for i := 0; i <= maxGIFFrames; i++ {
+		data = append(data, gifFrame(1, 1)...)
+	}
+	_, err = uploader.Upload(append(data, 0x3B))
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("wrong error: expected %s, got %v", ErrTooLarge, err)
+		return
+	}
+
+	// кадров немного, но вместе они больше лимита пикселей
+	data = gifHeader(4000, 4000)
+	for i := 0; i*4000*4000 <= maxPixels; i++ {
+		data = append(data, gifFrame(4000, 4000)...)
+	}
+	_, err = uploader.Upload(append(data, 0x3B))
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("wrong error: expected %s, got %v", ErrTooLarge, err)
+		return
+	}
+
+	// обрезанный кадр
+	data = append(gifHeader(1, 1), gifFrame(1, 1)[:8]...)
+	_, err = uploader.Upload(data)
+	if !errors.Is(err, ErrBadImage) {
+		t.Errorf("wrong error: expected %s, got %v", ErrBadImage, err)
+		return
+	}
+}
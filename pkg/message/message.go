@@ -0,0 +1,108 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"reddit/pkg/user"
+)
+
+const (
+	// DefaultRateLimit сообщений за DefaultRateWindow - больше отправить нельзя
+	DefaultRateLimit  = 20
+	DefaultRateWindow = 10 * time.Minute
+	// maxThreadScan - из скольких последних сообщений собирается список переписок
+	maxThreadScan = 500
+	defaultLimit  = 50
+	maxLimit      = 200
+)
+
+var (
+	ErrNoMessage   = errors.New("no message found")
+	ErrNoBlock     = errors.New("user is not blocked")
+	ErrBlocked     = errors.New("messages between these users are blocked")
+	ErrSelfMessage = errors.New("can not message or block yourself")
+	ErrRateLimited = errors.New("too many messages, try again later")
+)
+
+type MessageRepo interface {
+	Send(ctx context.Context, from *user.User, toUsername, body string) (*Message, error)
+	Inbox(ctx context.Context, userID string, filter *Filter) ([]*Message, error)
+	Outbox(ctx context.Context, userID string, filter *Filter) ([]*Message, error)
+	Threads(ctx context.Context, userID string) ([]*Thread, error)
+	Thread(ctx context.Context, userID, peerUsername string, filter *Filter) ([]*Message, error)
+	MarkRead(ctx context.Context, userID, messageID string) error
+	Delete(ctx context.Context, userID, messageID string) error
+	Block(ctx context.Context, blocker *user.User, username string) error
+	Unblock(ctx context.Context, userID, username string) error
+	Blocks(ctx context.Context, userID string) ([]*Block, error)
+}
+
+type UserFinder interface {
+	FindUser(ctx context.Context, username string) (*user.User, error)
+}
+
+// Message - одно сообщение на двоих, удаление только прячет его у того, кто удалил
+type Message struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	From       *user.User         `json:"from" bson:"from"`
+	To         *user.User         `json:"to" bson:"to"`
+	Body       string             `json:"body" bson:"body"`
+	BodyHTML   string             `json:"bodyHTML" bson:"-"`
+	Read       bool               `json:"read" bson:"read"`
+	Created    time.Time          `json:"created" bson:"created"`
+	DeletedFor []string           `json:"-" bson:"deletedFor,omitempty"`
+}
+
+type Thread struct {
+	With   *user.User `json:"with"`
+	Last   *Message   `json:"lastMessage"`
+	Unread int        `json:"unread"`
+}
+
+type Block struct {
+	ID      primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	UserID  string             `json:"-" bson:"user"`
+	Blocked *user.User         `json:"user" bson:"blocked"`
+	Created time.Time          `json:"blockedAt" bson:"created"`
+}
+
+type Filter struct {
+	Page  int
+	Limit int
+}
+
+func (f *Filter) normalize() {
+	if f.Page < 1 {
+		f.Page = 1
+	}
+	if f.Limit < 1 {
+		f.Limit = defaultLimit
+	}
+	if f.Limit > maxLimit {
+		f.Limit = maxLimit
+	}
+}
+
+type MessageForm struct {
+	To   string `json:"to" valid:"required,matches(^[a-zA-Z0-9_]+$)"`
+	Body string `json:"body" valid:"required,length(1|10000)"`
+}
+
+func (m *MessageForm) Validate() []string {
+	_, err := govalidator.ValidateStruct(m)
+	if err == nil {
+		return nil
+	}
+	validationErrors := make([]string, 0)
+	if allErrs, ok := err.(govalidator.Errors); ok {
+		for _, fld := range allErrs {
+			validationErrors = append(validationErrors, fld.Error())
+		}
+	}
+	return validationErrors
+}
@@ -0,0 +1,260 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reddit/pkg/post"
+	"reddit/pkg/user"
+)
+
+// sinceMatcher - время в фильтре лимита зависит от time.Now, проверяем только окно
+type sinceMatcher struct {
+	senderID string
+	window   time.Duration
+}
+
+func (m sinceMatcher) Matches(x interface{}) bool {
+	filter, ok := x.(bson.M)
+	if !ok || filter["from.id"] != m.senderID {
+		return false
+	}
+	created, ok := filter["created"].(bson.M)
+	if !ok {
+		return false
+	}
+	since, ok := created["$gte"].(time.Time)
+	if !ok {
+		return false
+	}
+	age := time.Since(since)
+	return age >= m.window && age < m.window+time.Minute
+}
+
+func (m sinceMatcher) String() string {
+	return fmt.Sprintf("messages from %s during %s", m.senderID, m.window)
+}
+
+func TestSend(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testMessages := post.NewMockCollectionHelper(ctrl)
+	testBlocks := post.NewMockCollectionHelper(ctrl)
+	testUsers := NewMockUserFinder(ctrl)
+	testRepo := NewMessageBusinessLogic(&MessageDBRepo{Messages: testMessages, Blocks: testBlocks}, testUsers)
+	sender := &user.User{ID: "sender_id", Username: "sender"}
+	recipient := &user.User{ID: "recipient_id", Username: "recipient"}
+	blockFilter := bson.M{"$or": []bson.M{
+		{"user": "sender_id", "blocked.id": "recipient_id"},
+		{"user": "recipient_id", "blocked.id": "sender_id"},
+	}}
+
+	// себе писать нельзя
+	_, err := testRepo.Send(context.Background(), sender, "sender", "привет")
+	if !errors.Is(err, ErrSelfMessage) {
+		t.Errorf("wrong error: expected %s, got %v", ErrSelfMessage, err)
+		return
+	}
+
+	// нет такого пользователя
+	testUsers.EXPECT().FindUser(gomock.Any(), "ghost").Return(nil, user.ErrNoUser)
+	_, err = testRepo.Send(context.Background(), sender, "ghost", "привет")
+	if !errors.Is(err, user.ErrNoUser) {
+		t.Errorf("wrong error: expected %s, got %v", user.ErrNoUser, err)
+		return
+	}
+
+	// кто-то из двоих заблокировал другого
+	testUsers.EXPECT().FindUser(gomock.Any(), "recipient").Return(recipient, nil)
+	testBlocks.EXPECT().CountDocuments(gomock.Any(), blockFilter).Return(int64(1), nil)
+	_, err = testRepo.Send(context.Background(), sender, "recipient", "привет")
+	if !errors.Is(err, ErrBlocked) {
+		t.Errorf("wrong error: expected %s, got %v", ErrBlocked, err)
+		return
+	}
+
+	// лимит исчерпан
+	testUsers.EXPECT().FindUser(gomock.Any(), "recipient").Return(recipient, nil)
+	testBlocks.EXPECT().CountDocuments(gomock.Any(), blockFilter).Return(int64(0), nil)
+	testMessages.EXPECT().CountDocuments(gomock.Any(), sinceMatcher{"sender_id", DefaultRateWindow}).Return(int64(DefaultRateLimit), nil)
+	_, err = testRepo.Send(context.Background(), sender, "recipient", "привет")
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("wrong error: expected %s, got %v", ErrRateLimited, err)
+		return
+	}
+
+	// сообщение отправлено
+	testUsers.EXPECT().FindUser(gomock.Any(), "recipient").Return(recipient, nil)
+	testBlocks.EXPECT().CountDocuments(gomock.Any(), blockFilter).Return(int64(0), nil)
+	testMessages.EXPECT().CountDocuments(gomock.Any(), sinceMatcher{"sender_id", DefaultRateWindow}).Return(int64(DefaultRateLimit-1), nil)
+	testMessages.EXPECT().InsertOne(gomock.Any(), gomock.Any()).Return(nil, nil)
+	sent, err := testRepo.Send(context.Background(), sender, "recipient", "**привет**")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if sent.ID.IsZero() || sent.From.ID != "sender_id" || sent.To != recipient || sent.Read ||
+		sent.BodyHTML != "<p><strong>привет</strong></p>" {
+		t.Errorf("wrong message: %+v", sent)
+		return
+	}
+
+	// без лимита не считаем
+	testRepo.RateLimit = 0
+	testUsers.EXPECT().FindUser(gomock.Any(), "recipient").Return(recipient, nil)
+	testBlocks.EXPECT().CountDocuments(gomock.Any(), blockFilter).Return(int64(0), nil)
+	testMessages.EXPECT().InsertOne(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("db error"))
+	_, err = testRepo.Send(context.Background(), sender, "recipient", "привет")
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+}
+
+func TestThreads(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testMessages := post.NewMockCollectionHelper(ctrl)
+	testUsers := NewMockUserFinder(ctrl)
+	testRepo := NewMessageBusinessLogic(&MessageDBRepo{Messages: testMessages}, testUsers)
+	me := &user.User{ID: "me_id", Username: "me"}
+	alice := &user.User{ID: "alice_id", Username: "alice"}
+	bob := &user.User{ID: "bob_id", Username: "bob"}
+
+	// сообщения приходят свежими сверху, переписки идут в том же порядке
+	cursor, err := mongo.NewCursorFromDocuments([]interface{}{
+		&Message{From: alice, To: me, Body: "третье"},
+		&Message{From: me, To: bob, Body: "второе"},
+		&Message{From: alice, To: me, Body: "первое"},
+		&Message{From: alice, To: me, Body: "старое", Read: true},
+		&Message{From: bob, To: me, Body: "от боба", Read: true},
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("error in cursor creation")
+		return
+	}
+	recentFilter := bson.M{
+		"$or":        []bson.M{{"from.id": "me_id"}, {"to.id": "me_id"}},
+		"deletedFor": bson.M{"$ne": "me_id"},
+	}
+	testMessages.EXPECT().Find(gomock.Any(), recentFilter, gomock.Any()).Return(cursor, nil)
+	threads, err := testRepo.Threads(context.Background(), "me_id")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if len(threads) != 2 {
+		t.Errorf("expected 2 threads, got %d", len(threads))
+		return
+	}
+	if threads[0].With.ID != "alice_id" || threads[0].Last.Body != "третье" || threads[0].Unread != 2 {
+		t.Errorf("wrong first thread: %+v", threads[0])
+		return
+	}
+	if threads[1].With.ID != "bob_id" || threads[1].Last.Body != "второе" || threads[1].Unread != 0 {
+		t.Errorf("wrong second thread: %+v", threads[1])
+		return
+	}
+
+	// переписка с одним пользователем
+	testUsers.EXPECT().FindUser(gomock.Any(), "alice").Return(alice, nil)
+	threadFilter := bson.M{
+		"$or": []bson.M{
+			{"from.id": "me_id", "to.id": "alice_id"},
+			{"from.id": "alice_id", "to.id": "me_id"},
+		},
+		"deletedFor": bson.M{"$ne": "me_id"},
+	}
+	testMessages.EXPECT().Find(gomock.Any(), threadFilter, gomock.Any()).Return(nil, fmt.Errorf("db error"))
+	_, err = testRepo.Thread(context.Background(), "me_id", "alice", &Filter{})
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+}
+
+func TestReadDeleteAndBlock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testMessages := post.NewMockCollectionHelper(ctrl)
+	testBlocks := post.NewMockCollectionHelper(ctrl)
+	testUsers := NewMockUserFinder(ctrl)
+	testRepo := NewMessageBusinessLogic(&MessageDBRepo{Messages: testMessages, Blocks: testBlocks}, testUsers)
+	objID, err := primitive.ObjectIDFromHex("654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Fatalf("error in id")
+		return
+	}
+	me := &user.User{ID: "me_id", Username: "me"}
+	alice := &user.User{ID: "alice_id", Username: "alice"}
+
+	// кривой id
+	err = testRepo.MarkRead(context.Background(), "me_id", "bad id")
+	if !errors.Is(err, ErrNoMessage) {
+		t.Errorf("wrong error: expected %s, got %v", ErrNoMessage, err)
+		return
+	}
+
+	// прочитать можно только свое входящее
+	testMessages.EXPECT().UpdateOne(gomock.Any(),
+		bson.M{"_id": objID, "to.id": "me_id", "deletedFor": bson.M{"$ne": "me_id"}},
+		bson.M{"$set": bson.M{"read": true}}).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	err = testRepo.MarkRead(context.Background(), "me_id", "654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+
+	// удаление только у себя
+	deleteFilter := bson.M{
+		"_id":        objID,
+		"$or":        []bson.M{{"from.id": "me_id"}, {"to.id": "me_id"}},
+		"deletedFor": bson.M{"$ne": "me_id"},
+	}
+	testMessages.EXPECT().UpdateOne(gomock.Any(), deleteFilter, bson.M{"$addToSet": bson.M{"deletedFor": "me_id"}}).Return(&mongo.UpdateResult{}, nil)
+	err = testRepo.Delete(context.Background(), "me_id", "654f63e3a2414a2a554b6423")
+	if !errors.Is(err, ErrNoMessage) {
+		t.Errorf("wrong error: expected %s, got %v", ErrNoMessage, err)
+		return
+	}
+	testMessages.EXPECT().UpdateOne(gomock.Any(), deleteFilter, bson.M{"$addToSet": bson.M{"deletedFor": "me_id"}}).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	err = testRepo.Delete(context.Background(), "me_id", "654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+
+	// блокировка - upsert
+	testUsers.EXPECT().FindUser(gomock.Any(), "alice").Return(alice, nil)
+	testBlocks.EXPECT().UpdateOne(gomock.Any(), bson.M{"user": "me_id", "blocked.id": "alice_id"}, gomock.Any(), options.Update().SetUpsert(true)).Return(&mongo.UpdateResult{UpsertedCount: 1}, nil)
+	err = testRepo.Block(context.Background(), me, "alice")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	err = testRepo.Block(context.Background(), me, "me")
+	if !errors.Is(err, ErrSelfMessage) {
+		t.Errorf("wrong error: expected %s, got %v", ErrSelfMessage, err)
+		return
+	}
+
+	// разблокировать того, кто не заблокирован
+	testUsers.EXPECT().FindUser(gomock.Any(), "alice").Return(alice, nil)
+	testBlocks.EXPECT().DeleteOne(gomock.Any(), bson.M{"user": "me_id", "blocked.id": "alice_id"}).Return(int64(0), nil)
+	err = testRepo.Unblock(context.Background(), "me_id", "alice")
+	if !errors.Is(err, ErrNoBlock) {
+		t.Errorf("wrong error: expected %s, got %v", ErrNoBlock, err)
+		return
+	}
+}
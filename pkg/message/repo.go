@@ -0,0 +1,202 @@
+package message
+
+import (
+	"context"
+	"time"
+
+	"reddit/pkg/markdown"
+	"reddit/pkg/user"
+)
+
+type MessageDBRepository interface {
+	AddMessageDB(ctx context.Context, message *Message) error
+	CountSentSinceDB(ctx context.Context, senderID string, since time.Time) (int64, error)
+	GetInboxDB(ctx context.Context, userID string, filter *Filter) ([]*Message, error)
+	GetOutboxDB(ctx context.Context, userID string, filter *Filter) ([]*Message, error)
+	GetRecentDB(ctx context.Context, userID string, limit int) ([]*Message, error)
+	GetThreadDB(ctx context.Context, userID, peerID string, filter *Filter) ([]*Message, error)
+	MarkReadDB(ctx context.Context, userID, messageID string) (bool, error)
+	DeleteForDB(ctx context.Context, userID, messageID string) (bool, error)
+	AddBlockDB(ctx context.Context, block *Block) error
+	DeleteBlockDB(ctx context.Context, userID, blockedID string) (bool, error)
+	IsBlockedDB(ctx context.Context, firstID, secondID string) (bool, error)
+	GetBlocksDB(ctx context.Context, userID string) ([]*Block, error)
+}
+
+type MessageBusinessLogic struct {
+	MessageDBRepo MessageDBRepository
+	Users         UserFinder
+	// RateLimit сообщений за RateWindow, 0 - без ограничения
+	RateLimit  int
+	RateWindow time.Duration
+}
+
+func NewMessageBusinessLogic(repo MessageDBRepository, users UserFinder) *MessageBusinessLogic {
+	return &MessageBusinessLogic{
+		MessageDBRepo: repo,
+		Users:         users,
+		RateLimit:     DefaultRateLimit,
+		RateWindow:    DefaultRateWindow,
+	}
+}
+
+func (m *MessageBusinessLogic) Send(ctx context.Context, from *user.User, toUsername, body string) (*Message, error) {
+	to, err := m.findPeer(ctx, from.Username, toUsername)
+	if err != nil {
+		return nil, err
+	}
+	blocked, err := m.MessageDBRepo.IsBlockedDB(ctx, from.ID, to.ID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrBlocked
+	}
+	now := time.Now().UTC()
+	// лимит считается по уже сохраненным сообщениям, параллельные отправки могут его немного превысить
+	if m.RateLimit > 0 {
+		sent, errCount := m.MessageDBRepo.CountSentSinceDB(ctx, from.ID, now.Add(-m.RateWindow))
+		if errCount != nil {
+			return nil, errCount
+		}
+		if sent >= int64(m.RateLimit) {
+			return nil, ErrRateLimited
+		}
+	}
+	newMessage := &Message{
+		From:    &user.User{ID: from.ID, Username: from.Username},
+		To:      to,
+		Body:    body,
+		Created: now,
+	}
+	err = m.MessageDBRepo.AddMessageDB(ctx, newMessage)
+	if err != nil {
+		return nil, err
+	}
+	return renderMarkdown(newMessage)[0], nil
+}
+
+func (m *MessageBusinessLogic) Inbox(ctx context.Context, userID string, filter *Filter) ([]*Message, error) {
+	filter.normalize()
+	messages, err := m.MessageDBRepo.GetInboxDB(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	return renderMarkdown(messages...), nil
+}
+
+func (m *MessageBusinessLogic) Outbox(ctx context.Context, userID string, filter *Filter) ([]*Message, error) {
+	filter.normalize()
+	messages, err := m.MessageDBRepo.GetOutboxDB(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	return renderMarkdown(messages...), nil
+}
+
+// Threads собирает переписки из последних сообщений, свежие сверху
+func (m *MessageBusinessLogic) Threads(ctx context.Context, userID string) ([]*Thread, error) {
+	messages, err := m.MessageDBRepo.GetRecentDB(ctx, userID, maxThreadScan)
+	if err != nil {
+		return nil, err
+	}
+	threads := make([]*Thread, 0)
+	threadsByPeer := make(map[string]*Thread)
+	for _, currentMessage := range renderMarkdown(messages...) {
+		peer, incoming := currentMessage.To, false
+		if currentMessage.To.ID == userID {
+			peer, incoming = currentMessage.From, true
+		}
+		thread, ok := threadsByPeer[peer.ID]
+		if !ok {
+			thread = &Thread{With: peer, Last: currentMessage}
+			threadsByPeer[peer.ID] = thread
+			threads = append(threads, thread)
+		}
+		if incoming && !currentMessage.Read {
+			thread.Unread++
+		}
+	}
+	return threads, nil
+}
+
+func (m *MessageBusinessLogic) Thread(ctx context.Context, userID, peerUsername string, filter *Filter) ([]*Message, error) {
+	peer, err := m.Users.FindUser(ctx, peerUsername)
+	if err != nil {
+		return nil, err
+	}
+	filter.normalize()
+	messages, err := m.MessageDBRepo.GetThreadDB(ctx, userID, peer.ID, filter)
+	if err != nil {
+		return nil, err
+	}
+	return renderMarkdown(messages...), nil
+}
+
+// MarkRead - прочитать можно только входящее
+func (m *MessageBusinessLogic) MarkRead(ctx context.Context, userID, messageID string) error {
+	found, err := m.MessageDBRepo.MarkReadDB(ctx, userID, messageID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNoMessage
+	}
+	return nil
+}
+
+func (m *MessageBusinessLogic) Delete(ctx context.Context, userID, messageID string) error {
+	found, err := m.MessageDBRepo.DeleteForDB(ctx, userID, messageID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNoMessage
+	}
+	return nil
+}
+
+func (m *MessageBusinessLogic) Block(ctx context.Context, blocker *user.User, username string) error {
+	blocked, err := m.findPeer(ctx, blocker.Username, username)
+	if err != nil {
+		return err
+	}
+	return m.MessageDBRepo.AddBlockDB(ctx, &Block{
+		UserID:  blocker.ID,
+		Blocked: blocked,
+		Created: time.Now().UTC(),
+	})
+}
+
+func (m *MessageBusinessLogic) Unblock(ctx context.Context, userID, username string) error {
+	blocked, err := m.Users.FindUser(ctx, username)
+	if err != nil {
+		return err
+	}
+	deleted, err := m.MessageDBRepo.DeleteBlockDB(ctx, userID, blocked.ID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNoBlock
+	}
+	return nil
+}
+
+func (m *MessageBusinessLogic) Blocks(ctx context.Context, userID string) ([]*Block, error) {
+	return m.MessageDBRepo.GetBlocksDB(ctx, userID)
+}
+
+func (m *MessageBusinessLogic) findPeer(ctx context.Context, ownUsername, username string) (*user.User, error) {
+	if username == ownUsername {
+		return nil, ErrSelfMessage
+	}
+	return m.Users.FindUser(ctx, username)
+}
+
+func renderMarkdown(messages ...*Message) []*Message {
+	for _, currentMessage := range messages {
+		currentMessage.BodyHTML = markdown.Render(currentMessage.Body)
+	}
+	return messages
+}
@@ -0,0 +1,220 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: message.go
+
+// Package message is a generated GoMock package.
+package message
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	user "reddit/pkg/user"
+)
+
+// MockMessageRepo is a mock of MessageRepo interface.
+type MockMessageRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockMessageRepoMockRecorder
+}
+
+// MockMessageRepoMockRecorder is the mock recorder for MockMessageRepo.
+type MockMessageRepoMockRecorder struct {
+	mock *MockMessageRepo
+}
+
+// NewMockMessageRepo creates a new mock instance.
+func NewMockMessageRepo(ctrl *gomock.Controller) *MockMessageRepo {
+	mock := &MockMessageRepo{ctrl: ctrl}
+	mock.recorder = &MockMessageRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMessageRepo) EXPECT() *MockMessageRepoMockRecorder {
+	return m.recorder
+}
+
+// Block mocks base method.
+func (m *MockMessageRepo) Block(ctx context.Context, blocker *user.User, username string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Block", ctx, blocker, username)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Block indicates an expected call of Block.
+func (mr *MockMessageRepoMockRecorder) Block(ctx, blocker, username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Block", reflect.TypeOf((*MockMessageRepo)(nil).Block), ctx, blocker, username)
+}
+
+// Blocks mocks base method.
+func (m *MockMessageRepo) Blocks(ctx context.Context, userID string) ([]*Block, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Blocks", ctx, userID)
+	ret0, _ := ret[0].([]*Block)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Blocks indicates an expected call of Blocks.
+func (mr *MockMessageRepoMockRecorder) Blocks(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Blocks", reflect.TypeOf((*MockMessageRepo)(nil).Blocks), ctx, userID)
+}
+
+// Delete mocks base method.
+func (m *MockMessageRepo) Delete(ctx context.Context, userID, messageID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, userID, messageID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockMessageRepoMockRecorder) Delete(ctx, userID, messageID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockMessageRepo)(nil).Delete), ctx, userID, messageID)
+}
+
+// Inbox mocks base method.
+func (m *MockMessageRepo) Inbox(ctx context.Context, userID string, filter *Filter) ([]*Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Inbox", ctx, userID, filter)
+	ret0, _ := ret[0].([]*Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Inbox indicates an expected call of Inbox.
+func (mr *MockMessageRepoMockRecorder) Inbox(ctx, userID, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Inbox", reflect.TypeOf((*MockMessageRepo)(nil).Inbox), ctx, userID, filter)
+}
+
+// MarkRead mocks base method.
+func (m *MockMessageRepo) MarkRead(ctx context.Context, userID, messageID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkRead", ctx, userID, messageID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkRead indicates an expected call of MarkRead.
+func (mr *MockMessageRepoMockRecorder) MarkRead(ctx, userID, messageID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkRead", reflect.TypeOf((*MockMessageRepo)(nil).MarkRead), ctx, userID, messageID)
+}
+
+// Outbox mocks base method.
+func (m *MockMessageRepo) Outbox(ctx context.Context, userID string, filter *Filter) ([]*Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Outbox", ctx, userID, filter)
+	ret0, _ := ret[0].([]*Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Outbox indicates an expected call of Outbox.
+func (mr *MockMessageRepoMockRecorder) Outbox(ctx, userID, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Outbox", reflect.TypeOf((*MockMessageRepo)(nil).Outbox), ctx, userID, filter)
+}
+
+// Send mocks base method.
+func (m *MockMessageRepo) Send(ctx context.Context, from *user.User, toUsername, body string) (*Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Send", ctx, from, toUsername, body)
+	ret0, _ := ret[0].(*Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Send indicates an expected call of Send.
+func (mr *MockMessageRepoMockRecorder) Send(ctx, from, toUsername, body interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockMessageRepo)(nil).Send), ctx, from, toUsername, body)
+}
+
+// Thread mocks base method.
+func (m *MockMessageRepo) Thread(ctx context.Context, userID, peerUsername string, filter *Filter) ([]*Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Thread", ctx, userID, peerUsername, filter)
+	ret0, _ := ret[0].([]*Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Thread indicates an expected call of Thread.
+func (mr *MockMessageRepoMockRecorder) Thread(ctx, userID, peerUsername, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Thread", reflect.TypeOf((*MockMessageRepo)(nil).Thread), ctx, userID, peerUsername, filter)
+}
+
+// Threads mocks base method.
+func (m *MockMessageRepo) Threads(ctx context.Context, userID string) ([]*Thread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Threads", ctx, userID)
+	ret0, _ := ret[0].([]*Thread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Threads indicates an expected call of Threads.
+func (mr *MockMessageRepoMockRecorder) Threads(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Threads", reflect.TypeOf((*MockMessageRepo)(nil).Threads), ctx, userID)
+}
+
+// Unblock mocks base method.
+func (m *MockMessageRepo) Unblock(ctx context.Context, userID, username string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unblock", ctx, userID, username)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unblock indicates an expected call of Unblock.
+func (mr *MockMessageRepoMockRecorder) Unblock(ctx, userID, username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unblock", reflect.TypeOf((*MockMessageRepo)(nil).Unblock), ctx, userID, username)
+}
+
+// MockUserFinder is a mock of UserFinder interface.
+type MockUserFinder struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserFinderMockRecorder
+}
+
+// MockUserFinderMockRecorder is the mock recorder for MockUserFinder.
+type MockUserFinderMockRecorder struct {
+	mock *MockUserFinder
+}
+
+// NewMockUserFinder creates a new mock instance.
+func NewMockUserFinder(ctrl *gomock.Controller) *MockUserFinder {
+	mock := &MockUserFinder{ctrl: ctrl}
+	mock.recorder = &MockUserFinderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUserFinder) EXPECT() *MockUserFinderMockRecorder {
+	return m.recorder
+}
+
+// FindUser mocks base method.
+func (m *MockUserFinder) FindUser(ctx context.Context, username string) (*user.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindUser", ctx, username)
+	ret0, _ := ret[0].(*user.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindUser indicates an expected call of FindUser.
+func (mr *MockUserFinderMockRecorder) FindUser(ctx, username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindUser", reflect.TypeOf((*MockUserFinder)(nil).FindUser), ctx, username)
+}
@@ -0,0 +1,209 @@
+package message
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reddit/pkg/post"
+)
+
+type MessageDBRepo struct {
+	Messages post.CollectionHelper
+	Blocks   post.CollectionHelper
+	// Timeout - срок одной операции, 0 - post.DefaultTimeout
+	Timeout time.Duration
+}
+
+func (m *MessageDBRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return post.WithTimeout(ctx, m.Timeout)
+}
+
+func (m *MessageDBRepo) EnsureIndexesDB() error {
+	err := m.Messages.CreateIndexes(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "to.id", Value: 1}, {Key: "created", Value: -1}}},
+		{Keys: bson.D{{Key: "from.id", Value: 1}, {Key: "created", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+	return m.Blocks.CreateIndexes(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user", Value: 1}, {Key: "blocked.id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+}
+
+func getMongoID(messageID string) (primitive.ObjectID, error) {
+	messageIDMongo, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return primitive.NilObjectID, ErrNoMessage
+	}
+	return messageIDMongo, nil
+}
+
+// notDeletedFor - сообщения, которые пользователь у себя не удалял
+func notDeletedFor(userID string, filter bson.M) bson.M {
+	filter["deletedFor"] = bson.M{"$ne": userID}
+	return filter
+}
+
+func pageOptions(filter *Filter) *options.FindOptions {
+	return options.Find().
+		SetSort(bson.D{{Key: "created", Value: -1}}).
+		SetSkip(int64((filter.Page - 1) * filter.Limit)).
+		SetLimit(int64(filter.Limit))
+}
+
+func (m *MessageDBRepo) AddMessageDB(ctx context.Context, message *Message) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	message.ID = primitive.NewObjectID()
+	_, err := m.Messages.InsertOne(ctx, message)
+	return err
+}
+
+func (m *MessageDBRepo) CountSentSinceDB(ctx context.Context, senderID string, since time.Time) (int64, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	return m.Messages.CountDocuments(ctx, bson.M{"from.id": senderID, "created": bson.M{"$gte": since}})
+}
+
+func (m *MessageDBRepo) GetInboxDB(ctx context.Context, userID string, filter *Filter) ([]*Message, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	return m.findMessages(ctx, notDeletedFor(userID, bson.M{"to.id": userID}), pageOptions(filter))
+}
+
+func (m *MessageDBRepo) GetOutboxDB(ctx context.Context, userID string, filter *Filter) ([]*Message, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	return m.findMessages(ctx, notDeletedFor(userID, bson.M{"from.id": userID}), pageOptions(filter))
+}
+
+func (m *MessageDBRepo) GetRecentDB(ctx context.Context, userID string, limit int) ([]*Message, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	query := notDeletedFor(userID, bson.M{"$or": []bson.M{{"from.id": userID}, {"to.id": userID}}})
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created", Value: -1}}).
+		SetLimit(int64(limit))
+	return m.findMessages(ctx, query, opts)
+}
+
+func (m *MessageDBRepo) GetThreadDB(ctx context.Context, userID, peerID string, filter *Filter) ([]*Message, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	query := notDeletedFor(userID, bson.M{"$or": []bson.M{
+		{"from.id": userID, "to.id": peerID},
+		{"from.id": peerID, "to.id": userID},
+	}})
+	return m.findMessages(ctx, query, pageOptions(filter))
+}
+
+func (m *MessageDBRepo) findMessages(ctx context.Context, query bson.M, opts *options.FindOptions) ([]*Message, error) {
+	messages := make([]*Message, 0)
+	result, err := m.Messages.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = result.All(ctx, &messages)
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (m *MessageDBRepo) MarkReadDB(ctx context.Context, userID, messageID string) (bool, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	messageIDMongo, err := getMongoID(messageID)
+	if err != nil {
+		return false, nil
+	}
+	update := bson.M{
+		"$set": bson.M{"read": true},
+	}
+	result, err := m.Messages.UpdateOne(ctx, notDeletedFor(userID, bson.M{"_id": messageIDMongo, "to.id": userID}), update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+func (m *MessageDBRepo) DeleteForDB(ctx context.Context, userID, messageID string) (bool, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	messageIDMongo, err := getMongoID(messageID)
+	if err != nil {
+		return false, nil
+	}
+	query := notDeletedFor(userID, bson.M{
+		"_id": messageIDMongo,
+		"$or": []bson.M{{"from.id": userID}, {"to.id": userID}},
+	})
+	update := bson.M{
+		"$addToSet": bson.M{"deletedFor": userID},
+	}
+	result, err := m.Messages.UpdateOne(ctx, query, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// AddBlockDB - повторная блокировка ничего не меняет
+func (m *MessageDBRepo) AddBlockDB(ctx context.Context, block *Block) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	update := bson.M{
+		"$setOnInsert": bson.M{"blocked": block.Blocked, "created": block.Created},
+	}
+	_, err := m.Blocks.UpdateOne(ctx, bson.M{"user": block.UserID, "blocked.id": block.Blocked.ID}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (m *MessageDBRepo) DeleteBlockDB(ctx context.Context, userID, blockedID string) (bool, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	deleted, err := m.Blocks.DeleteOne(ctx, bson.M{"user": userID, "blocked.id": blockedID})
+	if err != nil {
+		return false, err
+	}
+	return deleted == 1, nil
+}
+
+// IsBlockedDB - блокировка действует в обе стороны, кто бы ее ни поставил
+func (m *MessageDBRepo) IsBlockedDB(ctx context.Context, firstID, secondID string) (bool, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	count, err := m.Blocks.CountDocuments(ctx, bson.M{"$or": []bson.M{
+		{"user": firstID, "blocked.id": secondID},
+		{"user": secondID, "blocked.id": firstID},
+	}})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (m *MessageDBRepo) GetBlocksDB(ctx context.Context, userID string) ([]*Block, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	blocks := make([]*Block, 0)
+	opts := options.Find().SetSort(bson.D{{Key: "created", Value: -1}})
+	result, err := m.Blocks.Find(ctx, bson.M{"user": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = result.All(ctx, &blocks)
+	if err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
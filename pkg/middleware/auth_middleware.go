@@ -26,7 +26,7 @@ func Auth(logger *zap.SugaredLogger, sm *session.SessionManager, next http.Handl
 			return
 		}
 		tokenValue := strings.TrimPrefix(authHeader, "Bearer ")
-		mySession, err := sm.GetSession(tokenValue)
+		mySession, err := sm.GetSession(r.Context(), tokenValue)
 		if err != nil || mySession == nil {
 			errText := fmt.Sprintf(`{"message": "there is no session for token %s}`, tokenValue)
 			response.WriteResponse(logger, w, []byte(errText), http.StatusUnauthorized)
@@ -38,3 +38,32 @@ func Auth(logger *zap.SugaredLogger, sm *session.SessionManager, next http.Handl
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// OptionalAuth кладет в контекст юзера, если передан валидный токен, но пускает и анонимов
+func OptionalAuth(logger *zap.SugaredLogger, sm *session.SessionManager, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		if !strings.HasPrefix(authHeader, "Bearer ") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		mySession, err := sm.GetSession(r.Context(), strings.TrimPrefix(authHeader, "Bearer "))
+		if err != nil || mySession == nil {
+			logger.Infof("anonymous request with unknown token")
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := context.WithValue(r.Context(), MyUserKey, mySession.User)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// QueryToken берет токен из ?token=, если нет заголовка: браузер не умеет ставить заголовки websocket запросу
+func QueryToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("token"); token != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,7 +1,10 @@
 package middleware
 
 import (
+	"bufio"
+	"errors"
 	"go.uber.org/zap"
+	"net"
 	"net/http"
 )
 
@@ -36,3 +39,19 @@ func (r *responseRecorder) Write(b []byte) (int, error) {
 func (r *responseRecorder) Header() http.Header {
 	return r.ResponseWriter.Header()
 }
+
+// Hijack нужен websocket соединениям, которые забирают себе tcp соединение
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+// Flush нужен потоку событий, иначе они копятся в буфере ответа
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
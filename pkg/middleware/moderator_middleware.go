@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"reddit/pkg/response"
+	"reddit/pkg/user"
+)
+
+func Moderator(logger *zap.SugaredLogger, moderators *user.Moderators, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.Infof("moderator middleware start")
+		currentUser, ok := r.Context().Value(MyUserKey).(*user.User)
+		if !ok {
+			response.WriteResponse(logger, w, []byte(`{"message": "can not cast context value to user"}`), http.StatusInternalServerError)
+			return
+		}
+		if !moderators.IsModerator(currentUser) {
+			response.WriteResponse(logger, w, []byte(`{"message": "action is allowed only for moderators"}`), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,116 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"reddit/pkg/user"
+)
+
+const (
+	KindReply   = "reply"
+	KindMention = "mention"
+)
+
+const EventNotification = "notification"
+
+const (
+	// DefaultTTL - через сколько уведомление удаляется само, прочитанное или нет
+	DefaultTTL = 30 * 24 * time.Hour
+	// maxMentions - сколько упоминаний из одного текста превращаются в уведомления
+	maxMentions  = 10
+	excerptSize  = 200
+	defaultLimit = 50
+	maxLimit     = 200
+)
+
+var ErrNoNotification = errors.New("no notification found")
+
+// mentionRe - перед @ не должно быть буквы или /, чтобы почта и ссылки не считались упоминаниями
+var mentionRe = regexp.MustCompile(`(?:^|[^a-zA-Z0-9_@/])@([a-zA-Z0-9_]+)`)
+
+type NotificationRepo interface {
+	GetNotifications(ctx context.Context, userID string, filter *Filter) (*Page, error)
+	MarkRead(ctx context.Context, userID, notificationID string) error
+	MarkAllRead(ctx context.Context, userID string) (int64, error)
+}
+
+type UserFinder interface {
+	FindUser(ctx context.Context, username string) (*user.User, error)
+}
+
+// EventPublisher - новое уведомление сразу уходит в открытые websocket соединения адресата
+type EventPublisher interface {
+	Publish(channel, kind string, data interface{}) error
+}
+
+type nopEventPublisher struct{}
+
+func (nopEventPublisher) Publish(_, _ string, _ interface{}) error {
+	return nil
+}
+
+type Notification struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID    string             `json:"-" bson:"user"`
+	Kind      string             `json:"kind" bson:"kind"`
+	From      *user.User         `json:"from" bson:"from"`
+	PostID    string             `json:"postId" bson:"post"`
+	CommentID string             `json:"commentId,omitempty" bson:"comment,omitempty"`
+	Title     string             `json:"title" bson:"title"`
+	Excerpt   string             `json:"excerpt" bson:"excerpt"`
+	Read      bool               `json:"read" bson:"read"`
+	Created   time.Time          `json:"created" bson:"created"`
+}
+
+type Page struct {
+	Notifications []*Notification `json:"notifications"`
+	Unread        int64           `json:"unread"`
+}
+
+type Filter struct {
+	Page       int
+	Limit      int
+	UnreadOnly bool
+}
+
+func (f *Filter) normalize() {
+	if f.Page < 1 {
+		f.Page = 1
+	}
+	if f.Limit < 1 {
+		f.Limit = defaultLimit
+	}
+	if f.Limit > maxLimit {
+		f.Limit = maxLimit
+	}
+}
+
+// ParseMentions отдает имена из @username без повторов в порядке появления
+func ParseMentions(text string) []string {
+	usernames := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, match := range mentionRe.FindAllStringSubmatch(text, -1) {
+		if seen[match[1]] {
+			continue
+		}
+		seen[match[1]] = true
+		usernames = append(usernames, match[1])
+		if len(usernames) == maxMentions {
+			break
+		}
+	}
+	return usernames
+}
+
+func excerpt(text string) string {
+	runes := []rune(text)
+	if len(runes) <= excerptSize {
+		return text
+	}
+	return string(runes[:excerptSize]) + "..."
+}
@@ -0,0 +1,212 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"reddit/pkg/comment"
+	"reddit/pkg/post"
+	"reddit/pkg/user"
+)
+
+func TestParseMentions(t *testing.T) {
+	cases := []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{"нет упоминаний", "просто текст", []string{}},
+		{"упоминания без повторов", "@alice, глянь что пишет @bob_2 и @alice", []string{"alice", "bob_2"}},
+		{"почта и ссылки не считаются", "mail@example.com https://site.com/@bob @@carol", []string{}},
+		{"в начале строк", "@dave\n@erin!", []string{"dave", "erin"}},
+	}
+	for _, testCase := range cases {
+		mentions := ParseMentions(testCase.text)
+		if !reflect.DeepEqual(mentions, testCase.expected) {
+			t.Errorf("%s: expected %v, got %v", testCase.name, testCase.expected, mentions)
+		}
+	}
+
+	// больше maxMentions не берем
+	text := ""
+	for i := 0; i < maxMentions+5; i++ {
+		text += fmt.Sprintf("@user%d ", i)
+	}
+	if mentions := ParseMentions(text); len(mentions) != maxMentions {
+		t.Errorf("expected %d mentions, got %d", maxMentions, len(mentions))
+	}
+}
+
+func TestNotify(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := post.NewMockCollectionHelper(ctrl)
+	testUsers := NewMockUserFinder(ctrl)
+	testRepo := NewNotificationBusinessLogic(&NotificationDBRepo{Notifications: testCollection}, testUsers)
+
+	objID, err := primitive.ObjectIDFromHex("654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Fatalf("error in id")
+		return
+	}
+	postAuthor := &user.User{ID: "author_id", Username: "author"}
+	commenter := &user.User{ID: "commenter_id", Username: "commenter"}
+	targetPost := &post.Post{ID: objID, Title: "fef", Author: postAuthor}
+	sent := make([]*Notification, 0)
+	collect := func(_ context.Context, document interface{}) (interface{}, error) {
+		sent = append(sent, document.(*Notification))
+		return nil, nil
+	}
+
+	// автору - ответ, упомянутому - упоминание; автор, сам комментатор и несуществующий пользователь пропускаются
+	newComment := &comment.Comment{ID: "comment_id", Author: commenter, Body: "@author @commenter @bob @ghost"}
+	testUsers.EXPECT().FindUser(gomock.Any(), "bob").Return(&user.User{ID: "bob_id", Username: "bob"}, nil)
+	testUsers.EXPECT().FindUser(gomock.Any(), "ghost").Return(nil, user.ErrNoUser)
+	testCollection.EXPECT().InsertOne(gomock.Any(), gomock.Any()).DoAndReturn(collect).Times(2)
+	err = testRepo.NotifyComment(context.Background(), targetPost, newComment)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if len(sent) != 2 {
+		t.Errorf("expected 2 notifications, got %d", len(sent))
+		return
+	}
+	reply, mention := sent[0], sent[1]
+	if reply.Kind != KindReply || reply.UserID != "author_id" || reply.From != commenter ||
+		reply.PostID != "654f63e3a2414a2a554b6423" || reply.CommentID != "comment_id" || reply.Read {
+		t.Errorf("wrong reply notification: %+v", reply)
+		return
+	}
+	if mention.Kind != KindMention || mention.UserID != "bob_id" || mention.Excerpt != newComment.Body {
+		t.Errorf("wrong mention notification: %+v", mention)
+		return
+	}
+
+	// автор комментирует свой пост - уведомлений нет
+	err = testRepo.NotifyComment(context.Background(), targetPost, &comment.Comment{ID: "comment_id", Author: postAuthor, Body: "спасибо"})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+
+	// ошибка поиска одного пользователя не мешает уведомить остальных
+	sent = sent[:0]
+	testUsers.EXPECT().FindUser(gomock.Any(), "bob").Return(nil, fmt.Errorf("db error"))
+	testUsers.EXPECT().FindUser(gomock.Any(), "carol").Return(&user.User{ID: "carol_id", Username: "carol"}, nil)
+	testCollection.EXPECT().InsertOne(gomock.Any(), gomock.Any()).DoAndReturn(collect)
+	err = testRepo.NotifyPost(context.Background(), &post.Post{ID: objID, Title: "fef", Author: postAuthor, Text: "@bob @carol @author"})
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+	if len(sent) != 1 || sent[0].UserID != "carol_id" || sent[0].CommentID != "" {
+		t.Errorf("wrong notifications: %v", sent)
+		return
+	}
+}
+
+func TestGetAndMarkRead(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := post.NewMockCollectionHelper(ctrl)
+	testRepo := NewNotificationBusinessLogic(&NotificationDBRepo{Notifications: testCollection}, NewMockUserFinder(ctrl))
+	objID, err := primitive.ObjectIDFromHex("654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Fatalf("error in id")
+		return
+	}
+
+	// ошибка базы
+	testCollection.EXPECT().Find(gomock.Any(), bson.M{"user": "user_id", "read": false}, gomock.Any()).Return(nil, fmt.Errorf("error"))
+	_, err = testRepo.GetNotifications(context.Background(), "user_id", &Filter{UnreadOnly: true})
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+
+	// страница и число непрочитанных
+	cursor, err := mongo.NewCursorFromDocuments([]interface{}{&Notification{ID: objID, Kind: KindReply}}, nil, nil)
+	if err != nil {
+		t.Fatalf("error in cursor creation")
+		return
+	}
+	testCollection.EXPECT().Find(gomock.Any(), bson.M{"user": "user_id"}, gomock.Any()).Return(cursor, nil)
+	testCollection.EXPECT().CountDocuments(gomock.Any(), bson.M{"user": "user_id", "read": false}).Return(int64(3), nil)
+	page, err := testRepo.GetNotifications(context.Background(), "user_id", &Filter{})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if len(page.Notifications) != 1 || page.Notifications[0].ID != objID || page.Unread != 3 {
+		t.Errorf("wrong page: %+v", page)
+		return
+	}
+
+	// чужое или несуществующее уведомление
+	err = testRepo.MarkRead(context.Background(), "user_id", "bad id")
+	if !errors.Is(err, ErrNoNotification) {
+		t.Errorf("wrong error: expected %s, got %v", ErrNoNotification, err)
+		return
+	}
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "user": "user_id"}, bson.M{"$set": bson.M{"read": true}}).Return(&mongo.UpdateResult{}, nil)
+	err = testRepo.MarkRead(context.Background(), "user_id", "654f63e3a2414a2a554b6423")
+	if !errors.Is(err, ErrNoNotification) {
+		t.Errorf("wrong error: expected %s, got %v", ErrNoNotification, err)
+		return
+	}
+
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "user": "user_id"}, bson.M{"$set": bson.M{"read": true}}).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	err = testRepo.MarkRead(context.Background(), "user_id", "654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+
+	testCollection.EXPECT().UpdateMany(gomock.Any(), bson.M{"user": "user_id", "read": false}, bson.M{"$set": bson.M{"read": true}}).Return(int64(2), nil)
+	marked, err := testRepo.MarkAllRead(context.Background(), "user_id")
+	if err != nil || marked != 2 {
+		t.Errorf("unexpected result: %d, %v", marked, err)
+		return
+	}
+}
+
+// testSession - только чтобы отличить контекст транзакции
+type testSession struct {
+	mongo.Session
+}
+
+func TestDropPost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := post.NewMockCollectionHelper(ctrl)
+	testRepo := NewNotificationBusinessLogic(&NotificationDBRepo{Notifications: testCollection}, NewMockUserFinder(ctrl))
+
+	// уведомления о посте удаляются в транзакции удаления поста
+	session := &testSession{}
+	testCollection.EXPECT().DeleteMany(gomock.Any(), bson.M{"post": "post_id"}).DoAndReturn(func(ctx context.Context, _ interface{}) (int64, error) {
+		if mongo.SessionFromContext(ctx) != session {
+			t.Errorf("delete must be in transaction")
+		}
+		return 3, nil
+	})
+	if err := testRepo.DropPost(mongo.NewSessionContext(context.Background(), session), "post_id"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	// ошибка отдается транзакции, чтобы она откатилась
+	testCollection.EXPECT().DeleteMany(gomock.Any(), bson.M{"post": "post_id"}).Return(int64(0), fmt.Errorf("error"))
+	if err := testRepo.DropPost(context.Background(), "post_id"); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
@@ -0,0 +1,131 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"reddit/pkg/comment"
+	"reddit/pkg/post"
+	"reddit/pkg/realtime"
+	"reddit/pkg/user"
+)
+
+type NotificationDBRepository interface {
+	AddNotificationDB(ctx context.Context, notification *Notification) error
+	GetNotificationsDB(ctx context.Context, userID string, filter *Filter) ([]*Notification, error)
+	CountUnreadDB(ctx context.Context, userID string) (int64, error)
+	MarkReadDB(ctx context.Context, userID, notificationID string) (bool, error)
+	MarkAllReadDB(ctx context.Context, userID string) (int64, error)
+	DeletePostNotificationsDB(ctx context.Context, postID string) error
+}
+
+type NotificationBusinessLogic struct {
+	NotificationDBRepo NotificationDBRepository
+	Users              UserFinder
+	Events             EventPublisher
+}
+
+func NewNotificationBusinessLogic(repo NotificationDBRepository, users UserFinder) *NotificationBusinessLogic {
+	return &NotificationBusinessLogic{
+		NotificationDBRepo: repo,
+		Users:              users,
+		Events:             nopEventPublisher{},
+	}
+}
+
+// NotifyPost - упоминания в тексте поста, себя не уведомляем
+func (n *NotificationBusinessLogic) NotifyPost(ctx context.Context, newPost *post.Post) error {
+	skip := map[string]bool{newPost.Author.Username: true}
+	return n.notifyMentions(ctx, newPost.Text, skip, &Notification{
+		From:    newPost.Author,
+		PostID:  newPost.ID.Hex(),
+		Title:   newPost.Title,
+		Excerpt: excerpt(newPost.Text),
+	})
+}
+
+// NotifyComment - автору поста приходит ответ, упомянутым - упоминание, но не оба сразу
+func (n *NotificationBusinessLogic) NotifyComment(ctx context.Context, targetPost *post.Post, newComment *comment.Comment) error {
+	template := &Notification{
+		From:      newComment.Author,
+		PostID:    targetPost.ID.Hex(),
+		CommentID: newComment.ID,
+		Title:     targetPost.Title,
+		Excerpt:   excerpt(newComment.Body),
+	}
+	skip := map[string]bool{newComment.Author.Username: true}
+	if targetPost.Author != nil && !skip[targetPost.Author.Username] {
+		skip[targetPost.Author.Username] = true
+		err := n.add(ctx, template, KindReply, targetPost.Author.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return n.notifyMentions(ctx, newComment.Body, skip, template)
+}
+
+func (n *NotificationBusinessLogic) notifyMentions(ctx context.Context, text string, skip map[string]bool, template *Notification) error {
+	var errs []error
+	for _, username := range ParseMentions(text) {
+		if skip[username] {
+			continue
+		}
+		skip[username] = true
+		mentioned, err := n.Users.FindUser(ctx, username)
+		if errors.Is(err, user.ErrNoUser) {
+			continue
+		}
+		if err == nil {
+			err = n.add(ctx, template, KindMention, mentioned.ID)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n *NotificationBusinessLogic) add(ctx context.Context, template *Notification, kind, userID string) error {
+	notification := *template
+	notification.Kind = kind
+	notification.UserID = userID
+	notification.Created = time.Now().UTC()
+	err := n.NotificationDBRepo.AddNotificationDB(ctx, &notification)
+	if err != nil {
+		return err
+	}
+	return n.Events.Publish(realtime.UserChannel(userID), EventNotification, &notification)
+}
+
+func (n *NotificationBusinessLogic) GetNotifications(ctx context.Context, userID string, filter *Filter) (*Page, error) {
+	filter.normalize()
+	notifications, err := n.NotificationDBRepo.GetNotificationsDB(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := n.NotificationDBRepo.CountUnreadDB(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &Page{Notifications: notifications, Unread: unread}, nil
+}
+
+func (n *NotificationBusinessLogic) MarkRead(ctx context.Context, userID, notificationID string) error {
+	found, err := n.NotificationDBRepo.MarkReadDB(ctx, userID, notificationID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNoNotification
+	}
+	return nil
+}
+
+func (n *NotificationBusinessLogic) MarkAllRead(ctx context.Context, userID string) (int64, error) {
+	return n.NotificationDBRepo.MarkAllReadDB(ctx, userID)
+}
+
+func (n *NotificationBusinessLogic) DropPost(ctx context.Context, postID string) error {
+	return n.NotificationDBRepo.DeletePostNotificationsDB(ctx, postID)
+}
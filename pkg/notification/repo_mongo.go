@@ -0,0 +1,114 @@
+package notification
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reddit/pkg/post"
+)
+
+type NotificationDBRepo struct {
+	Notifications post.CollectionHelper
+	TTL           time.Duration
+	// Timeout - срок одной операции, 0 - post.DefaultTimeout
+	Timeout time.Duration
+}
+
+func (n *NotificationDBRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return post.WithTimeout(ctx, n.Timeout)
+}
+
+// EnsureIndexesDB - старые уведомления удаляет сама монга по TTL индексу на created
+func (n *NotificationDBRepo) EnsureIndexesDB() error {
+	return n.Notifications.CreateIndexes(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user", Value: 1}, {Key: "created", Value: -1}}},
+		{Keys: bson.D{{Key: "user", Value: 1}, {Key: "read", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "created", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(n.TTL / time.Second)),
+		},
+	})
+}
+
+func getMongoID(notificationID string) (primitive.ObjectID, error) {
+	notificationIDMongo, err := primitive.ObjectIDFromHex(notificationID)
+	if err != nil {
+		return primitive.NilObjectID, ErrNoNotification
+	}
+	return notificationIDMongo, nil
+}
+
+func (n *NotificationDBRepo) AddNotificationDB(ctx context.Context, notification *Notification) error {
+	ctx, cancel := n.withTimeout(ctx)
+	defer cancel()
+	notification.ID = primitive.NewObjectID()
+	_, err := n.Notifications.InsertOne(ctx, notification)
+	return err
+}
+
+func (n *NotificationDBRepo) GetNotificationsDB(ctx context.Context, userID string, filter *Filter) ([]*Notification, error) {
+	ctx, cancel := n.withTimeout(ctx)
+	defer cancel()
+	notifications := make([]*Notification, 0)
+	query := bson.M{"user": userID}
+	if filter.UnreadOnly {
+		query["read"] = false
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created", Value: -1}}).
+		SetSkip(int64((filter.Page - 1) * filter.Limit)).
+		SetLimit(int64(filter.Limit))
+	result, err := n.Notifications.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = result.All(ctx, &notifications)
+	if err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (n *NotificationDBRepo) CountUnreadDB(ctx context.Context, userID string) (int64, error) {
+	ctx, cancel := n.withTimeout(ctx)
+	defer cancel()
+	return n.Notifications.CountDocuments(ctx, bson.M{"user": userID, "read": false})
+}
+
+func (n *NotificationDBRepo) MarkReadDB(ctx context.Context, userID, notificationID string) (bool, error) {
+	ctx, cancel := n.withTimeout(ctx)
+	defer cancel()
+	notificationIDMongo, err := getMongoID(notificationID)
+	if err != nil {
+		return false, nil
+	}
+	update := bson.M{
+		"$set": bson.M{"read": true},
+	}
+	result, err := n.Notifications.UpdateOne(ctx, bson.M{"_id": notificationIDMongo, "user": userID}, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+func (n *NotificationDBRepo) MarkAllReadDB(ctx context.Context, userID string) (int64, error) {
+	ctx, cancel := n.withTimeout(ctx)
+	defer cancel()
+	update := bson.M{
+		"$set": bson.M{"read": true},
+	}
+	return n.Notifications.UpdateMany(ctx, bson.M{"user": userID, "read": false}, update)
+}
+
+func (n *NotificationDBRepo) DeletePostNotificationsDB(ctx context.Context, postID string) error {
+	ctx, cancel := n.withTimeout(ctx)
+	defer cancel()
+	_, err := n.Notifications.DeleteMany(ctx, bson.M{"post": postID})
+	return err
+}
@@ -0,0 +1,43 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"reddit/pkg/post"
+)
+
+const (
+	StatusPending = post.OutboxPending
+	// StatusDone - событие приняли все стоки
+	StatusDone = "done"
+)
+
+const (
+	// DefaultBaseDelay - пауза перед повтором, дальше она удваивается до maxDelay
+	DefaultBaseDelay = 5 * time.Second
+	// DefaultTTL - сколько обработанные события хранятся после обработки
+	DefaultTTL   = 7 * 24 * time.Hour
+	maxDelay     = 10 * time.Minute
+	lease        = time.Minute
+	dueBatch     = 100
+	maxErrorSize = 512
+)
+
+// Sink - получатель доменных событий. Доставка at-least-once: событие может прийти повторно,
+// отсеивать дубли по ID - дело стока. Name пишется в событие, менять его нельзя
+type Sink interface {
+	Name() string
+	Handle(ctx context.Context, event *post.DomainEvent) error
+}
+
+func Backoff(base time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
@@ -0,0 +1,101 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reddit/pkg/post"
+)
+
+func TestBackoff(t *testing.T) {
+	expected := []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second}
+	for i, delay := range expected {
+		if got := Backoff(DefaultBaseDelay, i+1); got != delay {
+			t.Errorf("attempt %d: expected %s, got %s", i+1, delay, got)
+		}
+	}
+	if got := Backoff(DefaultBaseDelay, 100); got != maxDelay {
+		t.Errorf("expected %s, got %s", maxDelay, got)
+	}
+}
+
+func TestRelayDue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testEvents := post.NewMockCollectionHelper(ctrl)
+	testWebhooks := NewMockSink(ctrl)
+	testIndex := NewMockSink(ctrl)
+	testWebhooks.EXPECT().Name().Return("webhooks").AnyTimes()
+	testIndex.EXPECT().Name().Return("search").AnyTimes()
+	testRepo := NewRelay(&OutboxDBRepo{Events: testEvents}, testWebhooks, testIndex)
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	// одна попытка: выборка, захват и запись итога
+	round := func(event *post.DomainEvent, claimed bool) bson.M {
+		cursor, err := mongo.NewCursorFromDocuments([]interface{}{event}, nil, nil)
+		if err != nil {
+			t.Fatalf("error in cursor")
+		}
+		testEvents.EXPECT().Find(gomock.Any(), bson.M{"status": StatusPending, "nextAttempt": bson.M{"$lte": now}}, gomock.Any()).Return(cursor, nil)
+		claimFilter := bson.M{"_id": event.ID, "status": StatusPending, "attempts": event.Attempts}
+		if !claimed {
+			testEvents.EXPECT().UpdateOne(gomock.Any(), claimFilter, gomock.Any()).Return(&mongo.UpdateResult{}, nil)
+			return nil
+		}
+		testEvents.EXPECT().UpdateOne(gomock.Any(), claimFilter, gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+		var result bson.M
+		testEvents.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": event.ID}, gomock.Any()).DoAndReturn(
+			func(_ context.Context, _, update interface{}, _ ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+				result = update.(bson.M)["$set"].(bson.M)
+				return &mongo.UpdateResult{MatchedCount: 1}, nil
+			})
+		_, _ = testRepo.RelayDue(context.Background(), now)
+		return result
+	}
+
+	// событие уже забрала другая реплика - стоки не зовем
+	event := post.NewDomainEvent(post.DomainPostCreated, "music", nil)
+	event.Created, event.NextAttempt = now, now
+	if result := round(event, false); result != nil {
+		t.Errorf("unexpected result: %v", result)
+		return
+	}
+	if _, err := testRepo.RelayDue(context.Background(), now); err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+
+	// один сток упал - событие остается и повторится позже
+	testWebhooks.EXPECT().Handle(gomock.Any(), gomock.Any()).Return(nil)
+	testIndex.EXPECT().Handle(gomock.Any(), gomock.Any()).Return(errors.New("index_error"))
+	result := round(event, true)
+	if result["status"] != StatusPending || result["lastError"] != "sink search: index_error" {
+		t.Errorf("wrong result: %v", result)
+		return
+	}
+	if next := result["nextAttempt"].(time.Time); next.Before(now.Add(DefaultBaseDelay)) || next.After(now.Add(DefaultBaseDelay+time.Minute)) {
+		t.Errorf("wrong next attempt: %s", next)
+		return
+	}
+
+	// повтор идет только в тот сток, который событие еще не принял
+	event.Attempts = 1
+	event.Delivered = result["delivered"].([]string)
+	testIndex.EXPECT().Handle(gomock.Any(), gomock.Any()).Return(nil)
+	result = round(event, true)
+	if result["status"] != StatusDone || result["lastError"] != "" || result["processed"] == nil {
+		t.Errorf("wrong result: %v", result)
+		return
+	}
+	if delivered := result["delivered"].([]string); len(delivered) != 2 {
+		t.Errorf("wrong delivered sinks: %v", delivered)
+		return
+	}
+}
@@ -0,0 +1,123 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"reddit/pkg/post"
+)
+
+type OutboxDBRepository interface {
+	GetDueEventsDB(ctx context.Context, now time.Time, limit int) ([]*post.DomainEvent, error)
+	ClaimEventDB(ctx context.Context, event *post.DomainEvent, until time.Time) (bool, error)
+	UpdateEventDB(ctx context.Context, event *post.DomainEvent) error
+}
+
+// Relay разносит события из outbox по стокам. Событие остается в очереди, пока его не примут все стоки,
+// так что порядок между повторами не гарантируется
+type Relay struct {
+	OutboxDBRepo OutboxDBRepository
+	Sinks        []Sink
+	BaseDelay    time.Duration
+}
+
+func NewRelay(repo OutboxDBRepository, sinks ...Sink) *Relay {
+	return &Relay{
+		OutboxDBRepo: repo,
+		Sinks:        sinks,
+		BaseDelay:    DefaultBaseDelay,
+	}
+}
+
+// Run работает, пока не отменят ctx. Реплик может быть несколько, каждое событие забирает только одна
+func (r *Relay) Run(ctx context.Context, interval time.Duration, onError func(err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := r.RelayDue(ctx, time.Now()); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+// RelayDue отдает число событий, которые приняли все стоки
+func (r *Relay) RelayDue(ctx context.Context, now time.Time) (int, error) {
+	events, err := r.OutboxDBRepo.GetDueEventsDB(ctx, now, dueBatch)
+	if err != nil {
+		return 0, err
+	}
+	done := 0
+	var errs []error
+	for _, event := range events {
+		claimed, err := r.OutboxDBRepo.ClaimEventDB(ctx, event, now.Add(lease))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		event.Attempts++
+		err = r.relay(ctx, event)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("event %s %s: %w", event.Type, event.ID.Hex(), err))
+			continue
+		}
+		done++
+	}
+	return done, errors.Join(errs...)
+}
+
+// relay отдает событие стокам, которые его еще не приняли, и записывает итог
+func (r *Relay) relay(ctx context.Context, event *post.DomainEvent) error {
+	var errs []error
+	for _, sink := range r.Sinks {
+		if delivered(event, sink.Name()) {
+			continue
+		}
+		err := sink.Handle(ctx, event)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", sink.Name(), err))
+			continue
+		}
+		event.Delivered = append(event.Delivered, sink.Name())
+	}
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	sinkErr := errors.Join(errs...)
+	if sinkErr == nil {
+		event.Status = StatusDone
+		event.LastError = ""
+		event.Processed = &now
+	} else {
+		event.NextAttempt = now.Add(Backoff(r.BaseDelay, event.Attempts))
+		event.LastError = truncate(sinkErr.Error())
+	}
+	err := r.OutboxDBRepo.UpdateEventDB(ctx, event)
+	if err != nil {
+		return errors.Join(sinkErr, err)
+	}
+	return sinkErr
+}
+
+func delivered(event *post.DomainEvent, sinkName string) bool {
+	for _, name := range event.Delivered {
+		if name == sinkName {
+			return true
+		}
+	}
+	return false
+}
+
+func truncate(text string) string {
+	if len(text) > maxErrorSize {
+		return text[:maxErrorSize]
+	}
+	return text
+}
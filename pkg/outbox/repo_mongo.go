@@ -0,0 +1,87 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reddit/pkg/post"
+)
+
+// OutboxDBRepo читает ту же коллекцию, в которую события пишет post.PostDBRepo
+type OutboxDBRepo struct {
+	Events post.CollectionHelper
+	TTL    time.Duration
+	// Timeout - срок одной операции, 0 - post.DefaultTimeout
+	Timeout time.Duration
+}
+
+func (o *OutboxDBRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return post.WithTimeout(ctx, o.Timeout)
+}
+
+func (o *OutboxDBRepo) EnsureIndexesDB() error {
+	return o.Events.CreateIndexes(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttempt", Value: 1}}},
+		{
+			// у необработанных processed нет, их монга не тронет
+			Keys:    bson.D{{Key: "processed", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(o.TTL / time.Second)),
+		},
+	})
+}
+
+// GetDueEventsDB - в порядке записи, включая захваченные, у которых истек lease
+func (o *OutboxDBRepo) GetDueEventsDB(ctx context.Context, now time.Time, limit int) ([]*post.DomainEvent, error) {
+	ctx, cancel := o.withTimeout(ctx)
+	defer cancel()
+	events := make([]*post.DomainEvent, 0)
+	filter := bson.M{"status": StatusPending, "nextAttempt": bson.M{"$lte": now}}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(int64(limit))
+	result, err := o.Events.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = result.All(ctx, &events)
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ClaimEventDB забирает событие, только если его никто не тронул с момента выборки
+func (o *OutboxDBRepo) ClaimEventDB(ctx context.Context, event *post.DomainEvent, until time.Time) (bool, error) {
+	ctx, cancel := o.withTimeout(ctx)
+	defer cancel()
+	filter := bson.M{"_id": event.ID, "status": StatusPending, "attempts": event.Attempts}
+	update := bson.M{
+		"$set": bson.M{"nextAttempt": until},
+		"$inc": bson.M{"attempts": 1},
+	}
+	result, err := o.Events.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+func (o *OutboxDBRepo) UpdateEventDB(ctx context.Context, event *post.DomainEvent) error {
+	ctx, cancel := o.withTimeout(ctx)
+	defer cancel()
+	update := bson.M{
+		"$set": bson.M{
+			"status":      event.Status,
+			"nextAttempt": event.NextAttempt,
+			"delivered":   event.Delivered,
+			"lastError":   event.LastError,
+			"processed":   event.Processed,
+		},
+	}
+	_, err := o.Events.UpdateOne(ctx, bson.M{"_id": event.ID}, update)
+	return err
+}
@@ -0,0 +1,122 @@
+package post
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	maxTags      = 5
+	maxTagLength = 30
+)
+
+var (
+	colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+	tagPattern   = regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)
+)
+
+type Flair struct {
+	Name  string `json:"name" yaml:"name"`
+	Color string `json:"color" yaml:"color"`
+}
+
+type FlairSet struct {
+	mu         *sync.RWMutex
+	categories map[string][]*Flair
+}
+
+// flairs - флеры, разрешенные в категориях, по ним валидируется Post.Validate
+var flairs = &FlairSet{
+	mu:         &sync.RWMutex{},
+	categories: make(map[string][]*Flair),
+}
+
+func LoadFlairs(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	categories := make(map[string][]*Flair)
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &categories)
+	} else {
+		err = yaml.Unmarshal(data, &categories)
+	}
+	if err != nil {
+		return err
+	}
+	return SetFlairs(categories)
+}
+
+func SetFlairs(categories map[string][]*Flair) error {
+	for category, categoryFlairs := range categories {
+		for _, flair := range categoryFlairs {
+			if flair.Name == "" {
+				return fmt.Errorf("empty flair name in category %s", category)
+			}
+			if !colorPattern.MatchString(flair.Color) {
+				return fmt.Errorf("bad color %q of flair %s in category %s", flair.Color, flair.Name, category)
+			}
+		}
+	}
+	flairs.mu.Lock()
+	defer flairs.mu.Unlock()
+	flairs.categories = categories
+	return nil
+}
+
+func FlairsOf(category string) []*Flair {
+	flairs.mu.RLock()
+	defer flairs.mu.RUnlock()
+	categoryFlairs, ok := flairs.categories[category]
+	if !ok {
+		return make([]*Flair, 0)
+	}
+	return categoryFlairs
+}
+
+func findFlair(category, name string) *Flair {
+	for _, flair := range FlairsOf(category) {
+		if flair.Name == name {
+			return flair
+		}
+	}
+	return nil
+}
+
+func normalizeTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+func validateTags(tags []string) []string {
+	validationErrors := make([]string, 0)
+	if len(tags) > maxTags {
+		validationErrors = append(validationErrors, fmt.Sprintf("too many tags: at most %d allowed", maxTags))
+	}
+	for _, tag := range tags {
+		if len([]rune(tag)) > maxTagLength || !tagPattern.MatchString(tag) {
+			validationErrors = append(validationErrors, fmt.Sprintf("bad tag %q", tag))
+		}
+	}
+	return validationErrors
+}
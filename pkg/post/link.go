@@ -0,0 +1,116 @@
+package post
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const DefaultDuplicateWindow = 7 * 24 * time.Hour
+
+var (
+	ErrDuplicate = errors.New("link was already posted to this category")
+	ErrCrosspost = errors.New("post can not be crossposted")
+)
+
+type DuplicateError struct {
+	Original *Post
+}
+
+func (e *DuplicateError) Error() string {
+	return ErrDuplicate.Error() + ": " + e.Original.ID.Hex()
+}
+
+func (e *DuplicateError) Unwrap() error {
+	return ErrDuplicate
+}
+
+// trackingParams - параметры, которые не меняют страницу, а только метят переход
+var trackingParams = map[string]struct{}{
+	"fbclid":  {},
+	"gclid":   {},
+	"yclid":   {},
+	"dclid":   {},
+	"msclkid": {},
+	"mc_cid":  {},
+	"mc_eid":  {},
+	"igshid":  {},
+	"_ga":     {},
+	"ref_src": {},
+}
+
+func isTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	if strings.HasPrefix(name, "utm_") {
+		return true
+	}
+	_, ok := trackingParams[name]
+	return ok
+}
+
+// NormalizeURL приводит ссылку к виду, по которому ищутся дубли:
+// хост в нижнем регистре, без порта по умолчанию, фрагмента, трекинговых
+// параметров и слэша в конце пути; оставшиеся параметры сортируются
+func NormalizeURL(rawURL string) string {
+	link, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || link.Host == "" {
+		return strings.TrimSpace(rawURL)
+	}
+	link.Scheme = strings.ToLower(link.Scheme)
+	host := strings.ToLower(link.Hostname())
+	port := link.Port()
+	if (link.Scheme == "http" && port == "80") || (link.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	link.Host = host
+	if port != "" {
+		link.Host = host + ":" + port
+	}
+	link.Fragment = ""
+	link.RawFragment = ""
+	link.Path = strings.TrimRight(link.Path, "/")
+	link.RawPath = ""
+	query := link.Query()
+	for name := range query {
+		if isTrackingParam(name) {
+			query.Del(name)
+		}
+	}
+	// Encode сортирует параметры по имени
+	link.RawQuery = query.Encode()
+	return link.String()
+}
+
+// CrosspostTo - копия поста для другой категории, ссылается на самый первый пост
+func (p *Post) CrosspostTo(category, title string) (*Post, error) {
+	if p.Type == "poll" {
+		return nil, fmt.Errorf("%w: polls can not be crossposted", ErrCrosspost)
+	}
+	if p.Category == category {
+		return nil, fmt.Errorf("%w: post is already in category %s", ErrCrosspost, category)
+	}
+	originalID := p.CrosspostOf
+	if originalID == "" {
+		originalID = p.ID.Hex()
+	}
+	if title == "" {
+		title = p.Title
+	}
+	crosspost := &Post{
+		Type:        p.Type,
+		Title:       title,
+		URL:         p.URL,
+		Category:    category,
+		Text:        p.Text,
+		NSFW:        p.NSFW,
+		Spoiler:     p.Spoiler,
+		CrosspostOf: originalID,
+	}
+	if p.Image != nil {
+		image := *p.Image
+		crosspost.Image = &image
+	}
+	return crosspost, nil
+}
@@ -0,0 +1,71 @@
+package post
+
+import (
+	"context"
+	"time"
+
+	"reddit/pkg/automod"
+	"reddit/pkg/comment"
+	"reddit/pkg/preview"
+	"reddit/pkg/user"
+)
+
+type nopActionRecorder struct{}
+
+func (nopActionRecorder) RecordAction(_ context.Context, _ *user.User, _, _, _, _, _ string) error {
+	return nil
+}
+
+type nopContentModerator struct{}
+
+func (nopContentModerator) Evaluate(_ *automod.Content) (*automod.Decision, error) {
+	return &automod.Decision{}, nil
+}
+
+type nopModerationQueue struct{}
+
+func (nopModerationQueue) Enqueue(_ context.Context, _ *QueueRequest) error {
+	return nil
+}
+
+func (nopModerationQueue) DropPost(_ context.Context, _ string) error {
+	return nil
+}
+
+type nopSpamScorer struct{}
+
+func (nopSpamScorer) Score(_ string) (float64, error) {
+	return 0, nil
+}
+
+type nopAuthorRegistry struct{}
+
+func (nopAuthorRegistry) GetRegistrationTime(_ context.Context, _ string) (time.Time, error) {
+	return time.Time{}, nil
+}
+
+type nopLinkPreviewer struct{}
+
+func (nopLinkPreviewer) Fetch(_ context.Context, _ string) (*preview.Preview, error) {
+	return nil, nil
+}
+
+type nopNotifier struct{}
+
+func (nopNotifier) NotifyPost(_ context.Context, _ *Post) error {
+	return nil
+}
+
+func (nopNotifier) NotifyComment(_ context.Context, _ *Post, _ *comment.Comment) error {
+	return nil
+}
+
+func (nopNotifier) DropPost(_ context.Context, _ string) error {
+	return nil
+}
+
+type nopEventPublisher struct{}
+
+func (nopEventPublisher) Publish(_, _ string, _ interface{}) error {
+	return nil
+}
@@ -0,0 +1,50 @@
+package post
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// доменные события: пишутся в outbox в одной транзакции с постом и расходятся по стокам уже из relay
+const (
+	DomainPostCreated    = "PostCreated"
+	DomainCommentAdded   = "CommentAdded"
+	DomainCommentDeleted = "CommentDeleted"
+	DomainVoteCast       = "VoteCast"
+	DomainPostDeleted    = "PostDeleted"
+)
+
+const OutboxPending = "pending"
+
+type DomainEvent struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id"`
+	Type     string             `json:"type" bson:"type"`
+	PostID   string             `json:"postId" bson:"postId"`
+	Category string             `json:"category" bson:"category"`
+	// Payload - json данных на момент записи, пост потом может измениться
+	Payload     string      `json:"payload" bson:"payload"`
+	Data        interface{} `json:"-" bson:"-"`
+	Status      string      `json:"status" bson:"status"`
+	Attempts    int         `json:"attempts" bson:"attempts"`
+	NextAttempt time.Time   `json:"nextAttempt" bson:"nextAttempt"`
+	// Delivered - стоки, которые событие уже приняли, при повторе их не трогаем
+	Delivered []string   `json:"delivered,omitempty" bson:"delivered,omitempty"`
+	LastError string     `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	Created   time.Time  `json:"created" bson:"created"`
+	Processed *time.Time `json:"processed,omitempty" bson:"processed,omitempty"`
+}
+
+// NewDomainEvent - id поста и payload проставляет репозиторий при записи, у нового поста id еще нет
+func NewDomainEvent(kind, category string, data interface{}) *DomainEvent {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	return &DomainEvent{
+		ID:          primitive.NewObjectID(),
+		Type:        kind,
+		Category:    category,
+		Data:        data,
+		Status:      OutboxPending,
+		NextAttempt: now,
+		Created:     now,
+	}
+}
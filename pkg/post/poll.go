@@ -0,0 +1,127 @@
+package post
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	minPollOptions      = 2
+	maxPollOptions      = 6
+	maxPollOptionLength = 100
+)
+
+var (
+	ErrNotPoll      = errors.New("post is not a poll")
+	ErrPollClosed   = errors.New("poll is closed")
+	ErrNoPollOption = errors.New("no poll option found")
+	ErrAlreadyVoted = errors.New("user has already voted in this poll")
+)
+
+type Poll struct {
+	Options       []*PollOption `json:"options" bson:"options"`
+	ClosesAt      *time.Time    `json:"closesAt,omitempty" bson:"closesAt,omitempty"`
+	Voters        []*PollVote   `json:"-" bson:"voters"`
+	TotalVotes    int           `json:"totalVotes" bson:"-"`
+	MyChoice      string        `json:"myChoice,omitempty" bson:"-"`
+	ResultsHidden bool          `json:"resultsHidden,omitempty" bson:"-"`
+}
+
+type PollOption struct {
+	ID    string `json:"id" bson:"id"`
+	Text  string `json:"text" bson:"text"`
+	Votes int    `json:"votes" bson:"votes"`
+}
+
+type PollVote struct {
+	UserID   string `bson:"user"`
+	OptionID string `bson:"option"`
+}
+
+func (p *Poll) Closed(now time.Time) bool {
+	return p.ClosesAt != nil && !now.Before(*p.ClosesAt)
+}
+
+func (p *Poll) choiceOf(userID string) string {
+	for _, pollVote := range p.Voters {
+		if pollVote.UserID == userID {
+			return pollVote.OptionID
+		}
+	}
+	return ""
+}
+
+func (p *Poll) optionIndex(optionID string) int {
+	for i, option := range p.Options {
+		if option.ID == optionID {
+			return i
+		}
+	}
+	return -1
+}
+
+// prepare готовит опрос к сохранению: номера вариантов, пустые счетчики
+func (p *Poll) prepare() {
+	for i, option := range p.Options {
+		option.ID = strconv.Itoa(i + 1)
+		option.Text = strings.TrimSpace(option.Text)
+		option.Votes = 0
+	}
+	p.Voters = make([]*PollVote, 0)
+}
+
+func (p *Poll) validate(now time.Time) []string {
+	validationErrors := make([]string, 0)
+	if len(p.Options) < minPollOptions || len(p.Options) > maxPollOptions {
+		validationErrors = append(validationErrors, fmt.Sprintf("poll must have from %d to %d options", minPollOptions, maxPollOptions))
+	}
+	seen := make(map[string]struct{}, len(p.Options))
+	for _, option := range p.Options {
+		if option == nil {
+			validationErrors = append(validationErrors, "empty poll option")
+			continue
+		}
+		text := strings.TrimSpace(option.Text)
+		if text == "" || len([]rune(text)) > maxPollOptionLength {
+			validationErrors = append(validationErrors, fmt.Sprintf("poll option length must be from 1 to %d", maxPollOptionLength))
+			continue
+		}
+		if _, ok := seen[text]; ok {
+			validationErrors = append(validationErrors, fmt.Sprintf("duplicate poll option %q", text))
+		}
+		seen[text] = struct{}{}
+	}
+	if p.ClosesAt != nil && !p.ClosesAt.After(now) {
+		validationErrors = append(validationErrors, "poll closing time must be in the future")
+	}
+	return validationErrors
+}
+
+// HidePollResults прячет результаты опросов от тех, кто еще не проголосовал, пока опрос открыт
+func HidePollResults(viewerID string, posts ...*Post) {
+	now := time.Now()
+	for _, currentPost := range posts {
+		if currentPost == nil || currentPost.Poll == nil {
+			continue
+		}
+		poll := currentPost.Poll
+		poll.MyChoice = ""
+		if viewerID != "" {
+			poll.MyChoice = poll.choiceOf(viewerID)
+		}
+		poll.TotalVotes = 0
+		for _, option := range poll.Options {
+			poll.TotalVotes += option.Votes
+		}
+		poll.ResultsHidden = poll.MyChoice == "" && !poll.Closed(now)
+		if poll.ResultsHidden {
+			poll.TotalVotes = 0
+			for _, option := range poll.Options {
+				option.Votes = 0
+			}
+		}
+	}
+}
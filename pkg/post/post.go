@@ -1,13 +1,19 @@
 package post
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/asaskevich/govalidator"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
+	"reddit/pkg/automod"
 	"reddit/pkg/comment"
+	"reddit/pkg/preview"
 	"reddit/pkg/user"
 	"reddit/pkg/vote"
 )
@@ -16,36 +22,186 @@ var (
 	ErrNoPost    = errors.New("no post found")
 	ErrNoAccess  = errors.New("forbidden action")
 	ErrNoComment = errors.New("no comment found")
+	ErrRejected  = errors.New("rejected by automoderator")
+	ErrExpired   = errors.New("retention period is over")
+	ErrLocked    = errors.New("thread is locked")
+	ErrArchived  = errors.New("thread is archived")
+	// ErrVersionConflict - пост изменили между чтением и записью
+	ErrVersionConflict = errors.New("post was changed concurrently")
+	// ErrPreconditionFailed - версия, от которой клиент делал правку, уже устарела
+	ErrPreconditionFailed = errors.New("post version does not match")
 )
 
+const StatusHeld = "held"
+
+const (
+	ActionDeletePost    = "delete_post"
+	ActionDeleteComment = "delete_comment"
+	ActionRemovePost    = "remove_post"
+	ActionRemoveComment = "remove_comment"
+	ActionApprove       = "approve"
+	ActionRestorePost   = "restore_post"
+	ActionSetMarks      = "set_marks"
+	ActionLockPost      = "lock_post"
+	ActionUnlockPost    = "unlock_post"
+)
+
+type ActionRecorder interface {
+	RecordAction(ctx context.Context, actor *user.User, action, postID, commentID, category, reason string) error
+}
+
+type ContentModerator interface {
+	Evaluate(content *automod.Content) (*automod.Decision, error)
+}
+
+type ModerationQueue interface {
+	Enqueue(ctx context.Context, request *QueueRequest) error
+	// DropPost убирает из очереди жалобы на удаленный пост и его комментарии
+	DropPost(ctx context.Context, postID string) error
+}
+
+type QueueRequest struct {
+	Target    *Post
+	CommentID string
+	Reason    string
+	Held      bool
+	SpamScore float64
+}
+
+type SpamScorer interface {
+	Score(text string) (float64, error)
+}
+
+type LinkPreviewer interface {
+	Fetch(ctx context.Context, rawURL string) (*preview.Preview, error)
+}
+
+type AuthorRegistry interface {
+	GetRegistrationTime(ctx context.Context, userID string) (time.Time, error)
+}
+
+const (
+	EventPostAdded      = "post_added"
+	EventPostDeleted    = "post_deleted"
+	EventCommentAdded   = "comment_added"
+	EventCommentDeleted = "comment_deleted"
+	EventVote           = "vote"
+)
+
+// EventPublisher рассылает события подписчикам в реальном времени
+type EventPublisher interface {
+	Publish(channel, kind string, data interface{}) error
+}
+
+type CommentEvent struct {
+	PostID    string           `json:"postId"`
+	CommentID string           `json:"commentId,omitempty"`
+	Comment   *comment.Comment `json:"comment,omitempty"`
+}
+
+type VoteEvent struct {
+	PostID           string `json:"postId"`
+	Score            int    `json:"score"`
+	UpvotePercentage int    `json:"upvotePercentage"`
+}
+
+// Notifier - вызывается, когда пост или комментарий становится виден всем
+type Notifier interface {
+	NotifyPost(ctx context.Context, newPost *Post) error
+	NotifyComment(ctx context.Context, targetPost *Post, newComment *comment.Comment) error
+	// DropPost удаляет уведомления об удаленном посте
+	DropPost(ctx context.Context, postID string) error
+}
+
+type RejectedError struct {
+	Message string
+}
+
+func (e *RejectedError) Error() string {
+	return ErrRejected.Error() + ": " + e.Message
+}
+
+func (e *RejectedError) Unwrap() error {
+	return ErrRejected
+}
+
+type ListFilter struct {
+	Flair    string
+	Tag      string
+	HideNSFW bool
+	BlurNSFW bool
+}
+
+type Marks struct {
+	NSFW    *bool `json:"nsfw"`
+	Spoiler *bool `json:"spoiler"`
+}
+
 type PostRepo interface {
-	GetAll() ([]*Post, error)
-	AddPost(post *Post, author *user.User) (*Post, error)
-	GetPostByCategory(category string) ([]*Post, error)
-	GetPostByID(ID string) (*Post, error)
-	AddComment(commentBody string, author *user.User, postID string) (*Post, error)
-	DeleteComment(userID, postID string, commentID string) (*Post, error)
-	UpVote(postID string, userID string) (*Post, error)
-	DownVote(postID string, userID string) (*Post, error)
-	UnVote(postID string, userID string) (*Post, error)
-	DeletePost(userID, postID string) (bool, error)
-	GetPostsByUserID(userName string) ([]*Post, error)
+	GetAll(ctx context.Context, filter *ListFilter) ([]*Post, error)
+	AddPost(ctx context.Context, post *Post, author *user.User) (*Post, error)
+	GetPostByCategory(ctx context.Context, category string, filter *ListFilter) ([]*Post, error)
+	GetPostByID(ctx context.Context, ID string, viewer *user.User, isModerator bool) (*Post, error)
+	AddComment(ctx context.Context, commentBody string, author *user.User, postID string, version int) (*Post, error)
+	DeleteComment(ctx context.Context, userID, postID string, commentID string, version int) (*Post, error)
+	UpVote(ctx context.Context, postID string, userID string, version int) (*Post, error)
+	DownVote(ctx context.Context, postID string, userID string, version int) (*Post, error)
+	UnVote(ctx context.Context, postID string, userID string, version int) (*Post, error)
+	DeletePost(ctx context.Context, userID, postID string, version int) (bool, error)
+	GetPostsByUserID(ctx context.Context, userName string, filter *ListFilter) ([]*Post, error)
+	FindPostByID(ctx context.Context, postID string) (*Post, error)
+	RemovePost(ctx context.Context, moderator *user.User, postID, reason string, version int) (bool, error)
+	RemoveComment(ctx context.Context, moderator *user.User, postID, commentID, reason string, version int) (*Post, error)
+	ApproveHeld(ctx context.Context, postID, commentID string, version int) (*Post, error)
+	RestorePost(ctx context.Context, actor *user.User, postID string, isModerator bool, version int) (*Post, error)
+	SetMarks(ctx context.Context, actor *user.User, postID string, isModerator bool, marks *Marks, version int) (*Post, error)
+	VotePoll(ctx context.Context, postID, userID, optionID string, version int) (*Post, error)
+	FindPostsByIDs(ctx context.Context, postIDs []string) ([]*Post, error)
+	SetLocked(ctx context.Context, moderator *user.User, postID string, locked bool, reason string, version int) (*Post, error)
 }
 
 type Post struct {
 	Score            int                `json:"score" bson:"score"`
 	Views            int                `json:"views" bson:"views"`
-	Type             string             `json:"type" bson:"type" valid:"required,in(text|link)"`
+	Type             string             `json:"type" bson:"type" valid:"required,in(text|link|image|poll)"`
 	Title            string             `json:"title" bson:"title" valid:"required,length(1|100)"`
 	URL              string             `json:"url,omitempty" bson:"url" valid:"url"`
 	Author           *user.User         `json:"author" bson:"author"`
 	Category         string             `json:"category" bson:"category" valid:"required,length(1|300)"`
-	Text             string             `json:"text,omitempty" bson:"text"`
+	Text             string             `json:"text,omitempty" bson:"text" valid:"length(0|40000)"`
+	TextHTML         string             `json:"textHTML,omitempty" bson:"textHTML,omitempty"`
 	Votes            []*vote.Vote       `json:"votes" bson:"votes"`
 	Comments         []*comment.Comment `json:"comments" bson:"comments"`
 	Created          string             `json:"created" bson:"created"`
 	UpvotePercentage int                `json:"upvotePercentage" bson:"upvotePercentage"`
 	ID               primitive.ObjectID `json:"id" bson:"_id"`
+	Status           string             `json:"status,omitempty" bson:"status,omitempty"`
+	Flair            string             `json:"flair,omitempty" bson:"flair,omitempty"`
+	FlairColor       string             `json:"flairColor,omitempty" bson:"flairColor,omitempty"`
+	Tags             []string           `json:"tags,omitempty" bson:"tags,omitempty"`
+	NSFW             bool               `json:"nsfw,omitempty" bson:"nsfw,omitempty"`
+	Spoiler          bool               `json:"spoiler,omitempty" bson:"spoiler,omitempty"`
+	Blur             bool               `json:"blur,omitempty" bson:"-"`
+	Image            *Image             `json:"image,omitempty" bson:"image,omitempty"`
+	Poll             *Poll              `json:"poll,omitempty" bson:"poll,omitempty"`
+	Preview          *preview.Preview   `json:"preview,omitempty" bson:"preview,omitempty"`
+	CrosspostOf      string             `json:"crosspostOf,omitempty" bson:"crosspostOf,omitempty"`
+	DuplicateOf      string             `json:"duplicateOf,omitempty" bson:"-"`
+	Locked           bool               `json:"locked,omitempty" bson:"locked,omitempty"`
+	Archived         bool               `json:"archived,omitempty" bson:"-"`
+	HeldComments     []*comment.Comment `json:"-" bson:"heldComments,omitempty"`
+	SpamScore        float64            `json:"-" bson:"spamScore,omitempty"`
+	DeletedAt        *time.Time         `json:"deletedAt,omitempty" bson:"deleted_at,omitempty"`
+	DeletedBy        string             `json:"-" bson:"deleted_by,omitempty"`
+	// Version растет с каждым изменением поста, у постов до появления версий поля нет
+	Version int `json:"version,omitempty" bson:"version,omitempty"`
+}
+
+type Image struct {
+	URL          string `json:"url" bson:"url"`
+	ThumbnailURL string `json:"thumbnailUrl" bson:"thumbnailUrl"`
+	Width        int    `json:"width" bson:"width"`
+	Height       int    `json:"height" bson:"height"`
 }
 
 func init() {
@@ -61,25 +217,67 @@ func init() {
 
 }
 
+func (p *Post) TextOf(commentID string) string {
+	if commentID == "" {
+		return strings.Join([]string{p.Title, p.Text, p.URL}, "\n")
+	}
+	if targetComment := findComment(p, commentID); targetComment != nil {
+		return targetComment.Body
+	}
+	return ""
+}
+
+// Tombstone - то, что отдается вместо удаленного поста до его окончательного удаления
+func (p *Post) Tombstone() *Post {
+	title := "[removed]"
+	if p.Author != nil && p.DeletedBy == p.Author.ID {
+		title = "[deleted]"
+	}
+	return &Post{
+		Type:      p.Type,
+		Title:     title,
+		Category:  p.Category,
+		Votes:     make([]*vote.Vote, 0),
+		Comments:  make([]*comment.Comment, 0),
+		Created:   p.Created,
+		ID:        p.ID,
+		DeletedAt: p.DeletedAt,
+	}
+}
+
+// Validate - проверки по типу поста идут всегда, а не только когда govalidator уже что-то нашел
 func (p *Post) Validate() []string {
-	_, err := govalidator.ValidateStruct(p)
-	validationErrors := make([]string, 0)
-	if err == nil {
-		return validationErrors
+	validationErrors := validateTags(normalizeTags(p.Tags))
+	if p.Flair != "" && findFlair(p.Category, p.Flair) == nil {
+		validationErrors = append(validationErrors, fmt.Sprintf("flair %q is not allowed in category %s", p.Flair, p.Category))
+	}
+	switch p.Type {
+	case "text":
+		if p.Text == "" {
+			validationErrors = append(validationErrors, "text field required")
+		}
+	case "link":
+		if p.URL == "" {
+			validationErrors = append(validationErrors, "url field required")
+		}
+	case "image":
+		if p.Image == nil {
+			validationErrors = append(validationErrors, "image field required")
+		}
+	case "poll":
+		if p.Poll == nil {
+			validationErrors = append(validationErrors, "poll field required")
+		} else {
+			validationErrors = append(validationErrors, p.Poll.validate(time.Now())...)
+		}
+	default:
+		validationErrors = append(validationErrors, fmt.Sprintf("unknown post type %q", p.Type))
 	}
+	_, err := govalidator.ValidateStruct(p)
 	if allErrs, ok := err.(govalidator.Errors); ok {
 		for _, fld := range allErrs {
 			validationErrors = append(validationErrors, fld.Error())
 		}
 	}
-	if p.Type == "text" {
-		if p.Text == "" {
-			validationErrors = append(validationErrors, "text field required")
-
-		}
-	} else if p.URL == "" {
-		validationErrors = append(validationErrors, "url field required")
-	}
 	return validationErrors
-
 }
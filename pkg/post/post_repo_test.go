@@ -5,14 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reddit/pkg/automod"
 	"reddit/pkg/comment"
 	"reddit/pkg/idgenerator"
+	"reddit/pkg/preview"
 	"reddit/pkg/user"
 	"reddit/pkg/vote"
 )
@@ -29,8 +34,8 @@ func TestGetAll(t *testing.T) {
 	testRepo := NewPostBusinessLogic(testRepoDB, testIDGen)
 
 	// какая то ошибка в монго
-	testCollection.EXPECT().Find(context.Background(), bson.M{}).Return(nil, fmt.Errorf("error"))
-	_, err := testRepo.GetAll()
+	testCollection.EXPECT().Find(gomock.Any(), bson.M{"status": bson.M{"$ne": StatusHeld}, "deleted_at": bson.M{"$exists": false}}).Return(nil, fmt.Errorf("error"))
+	_, err := testRepo.GetAll(context.Background(), nil)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
@@ -74,8 +79,8 @@ func TestGetAll(t *testing.T) {
 		t.Fatalf("error on cursor creation")
 		return
 	}
-	testCollection.EXPECT().Find(context.Background(), bson.M{}).Return(cursor, nil)
-	_, err = testRepo.GetAll()
+	testCollection.EXPECT().Find(gomock.Any(), bson.M{"status": bson.M{"$ne": StatusHeld}, "deleted_at": bson.M{"$exists": false}}).Return(cursor, nil)
+	_, err = testRepo.GetAll(context.Background(), nil)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 		return
@@ -95,7 +100,7 @@ func TestAddPost(t *testing.T) {
 	testRepo := NewPostBusinessLogic(testRepoDB, testIDGen)
 
 	// ошибка добавления поста в бд
-	testCollection.EXPECT().InsertOne(context.Background(), gomock.Any()).Return(nil, fmt.Errorf("error"))
+	testCollection.EXPECT().InsertOne(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("error"))
 	postToAdd := &Post{
 		Type:     "text",
 		Title:    "fef",
@@ -106,14 +111,14 @@ func TestAddPost(t *testing.T) {
 		ID:       "310ca263",
 		Username: "hhhhhhhh",
 	}
-	_, err := testRepo.AddPost(postToAdd, author)
+	_, err := testRepo.AddPost(context.Background(), postToAdd, author)
 	if err == nil {
 		t.Errorf("ecpected error, got nil")
 		return
 	}
 
 	// пост добавлен
-	testCollection.EXPECT().InsertOne(context.Background(), gomock.Any()).Return("any", nil)
+	testCollection.EXPECT().InsertOne(gomock.Any(), gomock.Any()).Return("any", nil)
 	postToAdd = &Post{
 		Type:     "text",
 		Title:    "fef",
@@ -125,13 +130,222 @@ func TestAddPost(t *testing.T) {
 		Username: "hhhhhhhh",
 	}
 	postToAdd.Type = "link"
+	postToAdd.TextHTML = "<script>alert(1)</script>"
+	postToAdd.Locked = true
+	deletedAt := time.Now()
+	postToAdd.DeletedAt = &deletedAt
+	postToAdd.DeletedBy = "310ca263"
 
-	_, err = testRepo.AddPost(postToAdd, author)
+	addedPost, err := testRepo.AddPost(context.Background(), postToAdd, author)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 		return
 	}
+	// html от клиента не сохраняется, его считает сервер
+	if addedPost.TextHTML != "" {
+		t.Errorf("client html must be dropped: %s", addedPost.TextHTML)
+		return
+	}
+	// закрытым пост создать нельзя
+	if addedPost.Locked {
+		t.Errorf("new post must not be locked")
+		return
+	}
+	// и удаленным тоже
+	if addedPost.DeletedAt != nil || addedPost.DeletedBy != "" {
+		t.Errorf("new post must not be deleted")
+		return
+	}
+
+}
+
+func TestAddPostAutoModeration(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := NewMockCollectionHelper(ctrl)
+	testQueue := NewMockModerationQueue(ctrl)
+	testRepo := NewPostBusinessLogic(&PostDBRepo{Posts: testCollection}, &idgenerator.TestIDGenerator{})
+	testRepo.Queue = testQueue
+	rules, err := automod.ParseRules([]byte(`
+categories:
+  programming:
+    - name: shorteners
+      conditions:
+        domains: [bit.ly]
+      action:
+        type: reject
+        message: no shorteners
+    - name: crypto
+      conditions:
+        titleRegex: "(?i)crypto"
+      action:
+        type: hold
+    - name: crypto-flair
+      conditions:
+        titleRegex: "(?i)crypto"
+      action:
+        type: flair
+        flair: crypto
+`), "yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testRepo.AutoMod = rules
+	author := &user.User{
+		ID:       "310ca263",
+		Username: "hhhhhhhh",
+	}
+
+	// пост отклонен автомодератором
+	_, err = testRepo.AddPost(context.Background(), &Post{Type: "link", Title: "fef", Category: "programming", URL: "https://bit.ly/fef"}, author)
+	if !errors.Is(err, ErrRejected) {
+		t.Errorf("wrong error: expected %s, got %s", ErrRejected, err)
+		return
+	}
+
+	// пост отправлен на проверку модераторам
+	testCollection.EXPECT().InsertOne(gomock.Any(), gomock.Any()).Return("any", nil)
+	testQueue.EXPECT().Enqueue(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, request *QueueRequest) error {
+		if request.CommentID != "" || request.Reason != "automod: crypto, crypto-flair" || !request.Held {
+			t.Errorf("wrong queue request: %+v", request)
+		}
+		return nil
+	})
+	heldPost, err := testRepo.AddPost(context.Background(), &Post{Type: "text", Title: "Crypto", Category: "programming", Text: "fef"}, author)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if heldPost.Status != StatusHeld || heldPost.Flair != "crypto" {
+		t.Errorf("wrong post status %q or flair %q", heldPost.Status, heldPost.Flair)
+		return
+	}
+}
+
+type testSpamScorer struct {
+	score float64
+}
+
+func (s *testSpamScorer) Score(_ string) (float64, error) {
+	return s.score, nil
+}
+
+func TestAddCommentSpam(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := NewMockCollectionHelper(ctrl)
+	testQueue := NewMockModerationQueue(ctrl)
+	testRepo := NewPostBusinessLogic(&PostDBRepo{Posts: testCollection}, &idgenerator.TestIDGenerator{})
+	testRepo.Queue = testQueue
+	testRepo.Spam = &testSpamScorer{score: 0.95}
+	testRepo.SpamLimit = 0.9
+
+	objID, err := primitive.ObjectIDFromHex("654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Fatalf("error in id")
+		return
+	}
+	postToReturn := &Post{
+		Type:     "text",
+		Title:    "fef",
+		Category: "programming",
+		Text:     "rferfer",
+		Comments: []*comment.Comment{},
+		ID:       objID,
+	}
+
+	// коммент со спамом отложен и не опубликован
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(postToReturn, nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": bson.M{"$exists": false}}, gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	testQueue.EXPECT().Enqueue(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, request *QueueRequest) error {
+		if request.CommentID != "generated_id" || !request.Held || request.SpamScore != 0.95 || request.Reason != "spam filter" {
+			t.Errorf("wrong queue request: %+v", request)
+		}
+		return nil
+	})
+	commentedPost, err := testRepo.AddComment(context.Background(), "buy now", &user.User{ID: "310ca263"}, objID.Hex(), AnyVersion)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if len(commentedPost.Comments) != 0 || len(commentedPost.HeldComments) != 1 {
+		t.Errorf("spam comment was published")
+		return
+	}
+
+	// модератор одобрил коммент
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(commentedPost, nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": 1}, gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	approvedPost, err := testRepo.ApproveHeld(context.Background(), objID.Hex(), "generated_id", AnyVersion)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if len(approvedPost.Comments) != 1 || len(approvedPost.HeldComments) != 0 {
+		t.Errorf("comment was not published")
+		return
+	}
+}
+
+func TestApproveHeldPost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := NewMockCollectionHelper(ctrl)
+	testRepo := NewPostBusinessLogic(&PostDBRepo{Posts: testCollection}, &idgenerator.TestIDGenerator{})
+
+	objID, err := primitive.ObjectIDFromHex("654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Fatalf("error in id")
+		return
+	}
+	newHeldPost := func(version int) *Post {
+		return &Post{
+			Type:     "text",
+			Title:    "fef",
+			Category: "programming",
+			Text:     "rferfer",
+			Comments: []*comment.Comment{},
+			Status:   StatusHeld,
+			Version:  version,
+			ID:       objID,
+		}
+	}
+
+	// модератор одобряет устаревшую версию поста
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newHeldPost(2), nil, nil))
+	_, err = testRepo.ApproveHeld(context.Background(), objID.Hex(), "", 1)
+	if !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("wrong error: expected %s, got %v", ErrPreconditionFailed, err)
+		return
+	}
+
+	// пост поменяли между чтением и одобрением, статус пишется уже поверх свежей версии
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newHeldPost(2), nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": 2}, gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 0}, nil)
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newHeldPost(3), nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": 3}, bson.M{"$unset": bson.M{"status": ""}, "$set": bson.M{"version": 4}}).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	approvedPost, err := testRepo.ApproveHeld(context.Background(), objID.Hex(), "", AnyVersion)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if approvedPost.Status != "" || approvedPost.Version != 4 {
+		t.Errorf("wrong approved post: status %q, version %d", approvedPost.Status, approvedPost.Version)
+		return
+	}
 
+	// с If-Match та же гонка отдается как устаревшая версия
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newHeldPost(2), nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": 2}, gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 0}, nil)
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newHeldPost(3), nil, nil))
+	_, err = testRepo.ApproveHeld(context.Background(), objID.Hex(), "", 2)
+	if !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("wrong error: expected %s, got %v", ErrPreconditionFailed, err)
+		return
+	}
 }
 
 func TestGetPostByCategory(t *testing.T) {
@@ -146,8 +360,8 @@ func TestGetPostByCategory(t *testing.T) {
 	testRepo := NewPostBusinessLogic(testRepoDB, testIDGen)
 
 	// какая то ошибка в монго
-	testCollection.EXPECT().Find(context.Background(), bson.M{"category": "programming"}).Return(nil, fmt.Errorf("error"))
-	_, err := testRepo.GetPostByCategory("programming")
+	testCollection.EXPECT().Find(gomock.Any(), bson.M{"category": "programming", "status": bson.M{"$ne": StatusHeld}, "deleted_at": bson.M{"$exists": false}}).Return(nil, fmt.Errorf("error"))
+	_, err := testRepo.GetPostByCategory(context.Background(), "programming", nil)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
@@ -191,8 +405,8 @@ func TestGetPostByCategory(t *testing.T) {
 		t.Fatalf("error on cursor creation")
 		return
 	}
-	testCollection.EXPECT().Find(context.Background(), bson.M{"category": "programming"}).Return(cursor, nil)
-	_, err = testRepo.GetPostByCategory("programming")
+	testCollection.EXPECT().Find(gomock.Any(), bson.M{"category": "programming", "flair": "discussion", "tags": "go", "status": bson.M{"$ne": StatusHeld}, "deleted_at": bson.M{"$exists": false}}).Return(cursor, nil)
+	_, err = testRepo.GetPostByCategory(context.Background(), "programming", &ListFilter{Flair: "discussion", Tag: "Go"})
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 		return
@@ -219,8 +433,8 @@ func TestGetPostByID(t *testing.T) {
 		return
 	}
 	singleResponse := mongo.NewSingleResultFromDocument(nil, fmt.Errorf("error"), nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	_, err = testRepo.GetPostByID("654f63e3a2414a2a554b6423")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	_, err = testRepo.GetPostByID(context.Background(), "654f63e3a2414a2a554b6423", nil, false)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
@@ -228,7 +442,7 @@ func TestGetPostByID(t *testing.T) {
 
 	// некорректный айди
 
-	_, err = testRepo.GetPostByID("некорректный айди")
+	_, err = testRepo.GetPostByID(context.Background(), "некорректный айди", nil, false)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
@@ -265,18 +479,18 @@ func TestGetPostByID(t *testing.T) {
 	// пост найден но не получается увеличить счетчик просмотров
 
 	singleResponse = mongo.NewSingleResultFromDocument(postToReturn, nil, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	testCollection.EXPECT().UpdateOne(context.Background(), bson.M{"_id": objID}, bson.M{"$set": bson.M{"views": 2}}).Return(nil, fmt.Errorf("db_error"))
-	_, err = testRepo.GetPostByID("654f63e3a2414a2a554b6423")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID}, bson.M{"$set": bson.M{"views": 2}}).Return(nil, fmt.Errorf("db_error"))
+	_, err = testRepo.GetPostByID(context.Background(), "654f63e3a2414a2a554b6423", nil, false)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
 	}
 
 	// пот найден, просмотры обновлены
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	testCollection.EXPECT().UpdateOne(context.Background(), bson.M{"_id": objID}, bson.M{"$set": bson.M{"views": 2}}).Return(nil, nil)
-	postWithUpdatedViews, err := testRepo.GetPostByID("654f63e3a2414a2a554b6423")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID}, bson.M{"$set": bson.M{"views": 2}}).Return(nil, nil)
+	postWithUpdatedViews, err := testRepo.GetPostByID(context.Background(), "654f63e3a2414a2a554b6423", nil, false)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 		return
@@ -286,6 +500,218 @@ func TestGetPostByID(t *testing.T) {
 		return
 	}
 
+	// вместо удаленного поста отдается заглушка, просмотры не считаются
+	deletedAt := time.Now()
+	deletedPost := *postToReturn
+	deletedPost.DeletedAt = &deletedAt
+	deletedPost.DeletedBy = "310ca263"
+	singleResponse = mongo.NewSingleResultFromDocument(&deletedPost, nil, nil)
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	tombstone, err := testRepo.GetPostByID(context.Background(), "654f63e3a2414a2a554b6423", nil, false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if tombstone.Title != "[deleted]" || tombstone.Text != "" || tombstone.Author != nil || tombstone.DeletedAt == nil {
+		t.Errorf("wrong tombstone: %+v", tombstone)
+		return
+	}
+
+	// отложенный пост по айди видят только автор и модераторы
+	heldPost := *postToReturn
+	heldPost.Status = StatusHeld
+	viewers := []struct {
+		name        string
+		viewer      *user.User
+		isModerator bool
+		visible     bool
+	}{
+		{"аноним", nil, false, false},
+		{"чужой пользователь", &user.User{ID: "other_id"}, false, false},
+		{"автор", &user.User{ID: "310ca263"}, false, true},
+		{"модератор", &user.User{ID: "moderator_id"}, true, true},
+	}
+	for _, testCase := range viewers {
+		testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(&heldPost, nil, nil))
+		if testCase.visible {
+			testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID}, bson.M{"$set": bson.M{"views": 2}}).Return(nil, nil)
+		}
+		_, err = testRepo.GetPostByID(context.Background(), "654f63e3a2414a2a554b6423", testCase.viewer, testCase.isModerator)
+		if testCase.visible && err != nil {
+			t.Errorf("%s: unexpected error: %s", testCase.name, err)
+		}
+		if !testCase.visible && !errors.Is(err, ErrNoPost) {
+			t.Errorf("%s: wrong error: expected %s, got %v", testCase.name, ErrNoPost, err)
+		}
+	}
+
+	// голосовать и комментировать отложенный пост нельзя даже автору
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(&heldPost, nil, nil))
+	_, err = testRepo.UpVote(context.Background(), "654f63e3a2414a2a554b6423", "310ca263", AnyVersion)
+	if !errors.Is(err, ErrNoPost) {
+		t.Errorf("wrong error: expected %s, got %v", ErrNoPost, err)
+		return
+	}
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(&heldPost, nil, nil))
+	_, err = testRepo.AddComment(context.Background(), "comment", postToReturn.Author, "654f63e3a2414a2a554b6423", AnyVersion)
+	if !errors.Is(err, ErrNoPost) {
+		t.Errorf("wrong error: expected %s, got %v", ErrNoPost, err)
+		return
+	}
+}
+
+func TestRestorePost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := NewMockCollectionHelper(ctrl)
+	testRecorder := NewMockActionRecorder(ctrl)
+	testRepoDB := &PostDBRepo{
+		Posts: testCollection,
+	}
+	testIDGen := &idgenerator.TestIDGenerator{}
+	testRepo := NewPostBusinessLogic(testRepoDB, testIDGen)
+	testRepo.Recorder = testRecorder
+
+	objID, err := primitive.ObjectIDFromHex("654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Fatalf("error in id")
+		return
+	}
+	author := &user.User{ID: "user_id", Username: "hhhhhhhh"}
+	moderator := &user.User{ID: "moderator_id", Username: "moderator"}
+	deletedAt := time.Now().Add(-time.Hour)
+	newDeletedPost := func(deletedBy string) *Post {
+		return &Post{
+			Type:      "text",
+			Title:     "fef",
+			Author:    author,
+			Category:  "programming",
+			Text:      "rferfer",
+			Votes:     []*vote.Vote{},
+			Comments:  []*comment.Comment{},
+			ID:        objID,
+			DeletedAt: &deletedAt,
+			DeletedBy: deletedBy,
+		}
+	}
+
+	// автор не может восстановить пост, удаленный модератором
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newDeletedPost("moderator_id"), nil, nil))
+	_, err = testRepo.RestorePost(context.Background(), author, "654f63e3a2414a2a554b6423", false, AnyVersion)
+	if !errors.Is(err, ErrNoAccess) {
+		t.Errorf("wrong error: expected %s, got %v", ErrNoAccess, err)
+		return
+	}
+
+	// срок восстановления истек
+	testRepo.Retention = time.Minute
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newDeletedPost("user_id"), nil, nil))
+	_, err = testRepo.RestorePost(context.Background(), author, "654f63e3a2414a2a554b6423", false, AnyVersion)
+	if !errors.Is(err, ErrExpired) {
+		t.Errorf("wrong error: expected %s, got %v", ErrExpired, err)
+		return
+	}
+	testRepo.Retention = DefaultRetention
+
+	// автор восстанавливает свой пост
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newDeletedPost("user_id"), nil, nil))
+	testRecorder.EXPECT().RecordAction(gomock.Any(), author, ActionRestorePost, "654f63e3a2414a2a554b6423", "", "programming", "").Return(nil)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": bson.M{"$exists": false}}, bson.M{"$unset": bson.M{"deleted_at": "", "deleted_by": ""}, "$set": bson.M{"version": 1}}).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	restoredPost, err := testRepo.RestorePost(context.Background(), author, "654f63e3a2414a2a554b6423", false, AnyVersion)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if restoredPost.DeletedAt != nil || restoredPost.Title != "fef" {
+		t.Errorf("post is not restored: %+v", restoredPost)
+		return
+	}
+
+	// клиент восстанавливает устаревшую версию поста
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newDeletedPost("user_id"), nil, nil))
+	_, err = testRepo.RestorePost(context.Background(), author, "654f63e3a2414a2a554b6423", false, 3)
+	if !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("wrong error: expected %s, got %v", ErrPreconditionFailed, err)
+		return
+	}
+
+	// пост поменяли между чтением и восстановлением, в журнал ничего не пишется
+	for _, testCase := range []struct {
+		version int
+		err     error
+	}{
+		{version: 0, err: ErrPreconditionFailed},
+		{version: AnyVersion, err: ErrVersionConflict},
+	} {
+		testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newDeletedPost("user_id"), nil, nil))
+		testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": bson.M{"$exists": false}}, gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 0}, nil)
+		_, err = testRepo.RestorePost(context.Background(), author, "654f63e3a2414a2a554b6423", false, testCase.version)
+		if !errors.Is(err, testCase.err) {
+			t.Errorf("wrong error: expected %s, got %v", testCase.err, err)
+			return
+		}
+	}
+
+	// модератор восстанавливает пост, удаленный другим модератором, но запись в базу не прошла
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newDeletedPost("another_moderator_id"), nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": bson.M{"$exists": false}}, gomock.Any()).Return(nil, fmt.Errorf("db_error"))
+	_, err = testRepo.RestorePost(context.Background(), moderator, "654f63e3a2414a2a554b6423", true, AnyVersion)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := NewMockCollectionHelper(ctrl)
+	testRepoDB := &PostDBRepo{
+		Posts: testCollection,
+	}
+	testIDGen := &idgenerator.TestIDGenerator{}
+	testRepo := NewPostBusinessLogic(testRepoDB, testIDGen)
+
+	testCollection.EXPECT().DeleteMany(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, filter interface{}) (int64, error) {
+			deletedBefore := filter.(bson.M)["deleted_at"].(bson.M)["$lt"].(time.Time)
+			if time.Since(deletedBefore) < DefaultRetention {
+				t.Errorf("posts must be kept during retention period, got cutoff %s", deletedBefore)
+			}
+			return 3, nil
+		})
+	purged, err := testRepo.PurgeDeleted(context.Background())
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if purged != 3 {
+		t.Errorf("expected 3 purged posts, got %d", purged)
+		return
+	}
+
+	// фоновая очистка останавливается вместе с корневым контекстом
+	ctx, cancel := context.WithCancel(context.Background())
+	testCollection.EXPECT().DeleteMany(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(purgeCtx context.Context, _ interface{}) (int64, error) {
+			cancel()
+			if purgeCtx.Err() == nil {
+				t.Errorf("purge context must be derived from the root context")
+			}
+			return 0, nil
+		}).MinTimes(1)
+	stopped := make(chan struct{})
+	go func() {
+		testRepo.RunPurge(ctx, time.Millisecond, nil)
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Errorf("purge was not stopped by context")
+	}
 }
 
 func TestAddComment(t *testing.T) {
@@ -306,8 +732,8 @@ func TestAddComment(t *testing.T) {
 		return
 	}
 	singleResponse := mongo.NewSingleResultFromDocument(nil, mongo.ErrNilDocument, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	_, err = testRepo.AddComment("comment", &user.User{}, "654f63e3a2414a2a554b6423")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	_, err = testRepo.AddComment(context.Background(), "comment", &user.User{}, "654f63e3a2414a2a554b6423", AnyVersion)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
@@ -344,21 +770,65 @@ func TestAddComment(t *testing.T) {
 	}
 
 	singleResponse = mongo.NewSingleResultFromDocument(postToReturn, nil, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	filter := bson.M{"_id": objID}
-	testCollection.EXPECT().UpdateOne(context.TODO(), filter, gomock.Any()).Return(nil, fmt.Errorf("db_error"))
-	_, err = testRepo.AddComment("new_comment", authorOfComment, "654f63e3a2414a2a554b6423")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	filter := bson.M{"_id": objID, "version": bson.M{"$exists": false}}
+	testCollection.EXPECT().UpdateOne(gomock.Any(), filter, gomock.Any()).Return(nil, fmt.Errorf("db_error"))
+	_, err = testRepo.AddComment(context.Background(), "new_comment", authorOfComment, "654f63e3a2414a2a554b6423", AnyVersion)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
 	}
 
-	// коммент успешно добавлен
-
+	// коммент успешно добавлен, ошибка уведомления его не отменяет
+	testNotifier := NewMockNotifier(ctrl)
+	testRepo.Notifier = testNotifier
+	testEvents := NewMockEventPublisher(ctrl)
+	testRepo.Events = testEvents
+	testOutbox := NewMockCollectionHelper(ctrl)
+	testClient := NewMockClientHelper(ctrl)
+	testSession := &fakeSession{}
+	testRepoDB.Outbox = testOutbox
+	testRepoDB.Sess = testClient
+	var notifyErr error
+	testRepo.OnNotifyError = func(err error) {
+		notifyErr = err
+	}
 	singleResponse = mongo.NewSingleResultFromDocument(postToReturn, nil, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	testCollection.EXPECT().UpdateOne(context.TODO(), filter, gomock.Any()).Return(nil, nil)
-	postWithNewComment, err := testRepo.AddComment("new_comment", authorOfComment, "654f63e3a2414a2a554b6423")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	// комментарий и событие о нем пишутся в одной транзакции
+	testClient.EXPECT().StartSession().Return(testSession, nil)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), filter, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, _, _ interface{}, _ ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+			if mongo.SessionFromContext(ctx) != testSession {
+				t.Errorf("update must be in transaction")
+			}
+			return &mongo.UpdateResult{MatchedCount: 1}, nil
+		})
+	var outboxEvent *DomainEvent
+	testOutbox.EXPECT().InsertOne(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, document interface{}) (interface{}, error) {
+		if mongo.SessionFromContext(ctx) != testSession {
+			t.Errorf("outbox insert must be in transaction")
+		}
+		outboxEvent = document.(*DomainEvent)
+		return nil, nil
+	})
+	testNotifier.EXPECT().NotifyComment(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, targetPost *Post, newComment *comment.Comment) error {
+		if targetPost.ID != objID || newComment.Body != "new_comment" || newComment.Author != authorOfComment {
+			t.Errorf("wrong notification: %v, %v", targetPost, newComment)
+		}
+		return fmt.Errorf("notify_error")
+	})
+	// событие уходит и в канал поста, и в канал категории
+	for _, channel := range []string{"post:654f63e3a2414a2a554b6423", "category:programming"} {
+		testEvents.EXPECT().Publish(channel, EventCommentAdded, gomock.Any()).DoAndReturn(func(_, _ string, data interface{}) error {
+			event, ok := data.(*CommentEvent)
+			if !ok || event.PostID != "654f63e3a2414a2a554b6423" || event.Comment.BodyHTML != "<p>new_comment</p>" {
+				t.Errorf("wrong event: %v", data)
+			}
+			return nil
+		})
+	}
+	postWithNewComment, err := testRepo.AddComment(context.Background(), "new_comment", authorOfComment, "654f63e3a2414a2a554b6423", AnyVersion)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 		return
@@ -367,7 +837,41 @@ func TestAddComment(t *testing.T) {
 		t.Errorf("wrong number of comments: expected 1, got %d", len(postWithNewComment.Comments))
 		return
 	}
+	if notifyErr == nil {
+		t.Errorf("notification error must be reported")
+		return
+	}
+	if !testSession.committed || !testSession.ended {
+		t.Errorf("transaction must be committed and session ended")
+		return
+	}
+	if outboxEvent.Type != DomainCommentAdded || outboxEvent.PostID != "654f63e3a2414a2a554b6423" || outboxEvent.Category != "programming" ||
+		outboxEvent.Status != OutboxPending || !strings.Contains(outboxEvent.Payload, `"body":"new_comment"`) {
+		t.Errorf("wrong outbox event: %+v", outboxEvent)
+		return
+	}
+
+}
+
+// fakeSession - транзакция без сервера: колбэк выполняется один раз, а с err не выполняется вовсе
+type fakeSession struct {
+	mongo.Session
+	err       error
+	committed bool
+	ended     bool
+}
+
+func (s *fakeSession) WithTransaction(ctx context.Context, fn func(sc mongo.SessionContext) (interface{}, error), _ ...*options.TransactionOptions) (interface{}, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	result, err := fn(mongo.NewSessionContext(ctx, s))
+	s.committed = err == nil
+	return result, err
+}
 
+func (s *fakeSession) EndSession(_ context.Context) {
+	s.ended = true
 }
 
 func TestDeleteComment(t *testing.T) {
@@ -388,8 +892,8 @@ func TestDeleteComment(t *testing.T) {
 		return
 	}
 	singleResponse := mongo.NewSingleResultFromDocument(nil, mongo.ErrNilDocument, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	_, err = testRepo.DeleteComment("user_id", "654f63e3a2414a2a554b6423", "comment_id")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	_, err = testRepo.DeleteComment(context.Background(), "user_id", "654f63e3a2414a2a554b6423", "comment_id", AnyVersion)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
@@ -429,8 +933,8 @@ func TestDeleteComment(t *testing.T) {
 		ID:               objID,
 	}
 	singleResponse = mongo.NewSingleResultFromDocument(postToReturn, nil, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	_, err = testRepo.DeleteComment("user_id", "654f63e3a2414a2a554b6423", "comment_id_wrong")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	_, err = testRepo.DeleteComment(context.Background(), "user_id", "654f63e3a2414a2a554b6423", "comment_id_wrong", AnyVersion)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
@@ -442,8 +946,8 @@ func TestDeleteComment(t *testing.T) {
 	}
 
 	// у поста есть такой комментарий, но его хочет удалить не его автор
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	_, err = testRepo.DeleteComment("user_id_wrong", "654f63e3a2414a2a554b6423", "comment_id")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	_, err = testRepo.DeleteComment(context.Background(), "user_id_wrong", "654f63e3a2414a2a554b6423", "comment_id", AnyVersion)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
@@ -455,18 +959,18 @@ func TestDeleteComment(t *testing.T) {
 	}
 
 	// не получается удалить коммент из базы данных
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	testCollection.EXPECT().UpdateOne(context.TODO(), gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("dr_error"))
-	_, err = testRepo.DeleteComment("user_id", "654f63e3a2414a2a554b6423", "comment_id")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("dr_error"))
+	_, err = testRepo.DeleteComment(context.Background(), "user_id", "654f63e3a2414a2a554b6423", "comment_id", AnyVersion)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
 	}
 
 	// коммент успешно удален
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	testCollection.EXPECT().UpdateOne(context.TODO(), gomock.Any(), gomock.Any()).Return(nil, nil)
-	post, err := testRepo.DeleteComment("user_id", "654f63e3a2414a2a554b6423", "comment_id")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), gomock.Any(), gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	post, err := testRepo.DeleteComment(context.Background(), "user_id", "654f63e3a2414a2a554b6423", "comment_id", AnyVersion)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 		return
@@ -496,8 +1000,8 @@ func TestUpvote(t *testing.T) {
 		return
 	}
 	singleResponse := mongo.NewSingleResultFromDocument(nil, mongo.ErrNilDocument, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	_, err = testRepo.UpVote("654f63e3a2414a2a554b6423", "user_id")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	_, err = testRepo.UpVote(context.Background(), "654f63e3a2414a2a554b6423", "user_id", AnyVersion)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
@@ -515,6 +1019,7 @@ func TestUpvote(t *testing.T) {
 		},
 		Category: "programming",
 		Text:     "rferfer",
+		TextHTML: "<p>rferfer</p>",
 		Votes: []*vote.Vote{
 			{
 				Value:  1,
@@ -528,8 +1033,8 @@ func TestUpvote(t *testing.T) {
 		ID:               objID,
 	}
 	singleResponse = mongo.NewSingleResultFromDocument(postToReturn, nil, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	post, err := testRepo.UpVote("654f63e3a2414a2a554b6423", "user_id")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	post, err := testRepo.UpVote(context.Background(), "654f63e3a2414a2a554b6423", "user_id", AnyVersion)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 		return
@@ -551,6 +1056,7 @@ func TestUpvote(t *testing.T) {
 		},
 		Category: "programming",
 		Text:     "rferfer",
+		TextHTML: "<p>rferfer</p>",
 		Votes: []*vote.Vote{
 			{
 				Value:  -1,
@@ -564,9 +1070,9 @@ func TestUpvote(t *testing.T) {
 		ID:               objID,
 	}
 	singleResponse = mongo.NewSingleResultFromDocument(postToReturn, nil, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	testCollection.EXPECT().UpdateOne(context.Background(), gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("error"))
-	_, err = testRepo.UpVote("654f63e3a2414a2a554b6423", "user_id")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("error"))
+	_, err = testRepo.UpVote(context.Background(), "654f63e3a2414a2a554b6423", "user_id", AnyVersion)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
@@ -574,9 +1080,9 @@ func TestUpvote(t *testing.T) {
 
 	// на посте нет оценок от этого пользователя, не получается добавить upvote
 	singleResponse = mongo.NewSingleResultFromDocument(postToReturn, nil, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	testCollection.EXPECT().UpdateOne(context.Background(), gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("error"))
-	_, err = testRepo.UpVote("654f63e3a2414a2a554b6423", "user_id_another")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("error"))
+	_, err = testRepo.UpVote(context.Background(), "654f63e3a2414a2a554b6423", "user_id_another", AnyVersion)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
@@ -584,9 +1090,9 @@ func TestUpvote(t *testing.T) {
 
 	// на посте нет оценок пользователя, добавление успешно
 	singleResponse = mongo.NewSingleResultFromDocument(postToReturn, nil, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	testCollection.EXPECT().UpdateOne(context.Background(), gomock.Any(), gomock.Any()).Return(nil, nil)
-	post, err = testRepo.UpVote("654f63e3a2414a2a554b6423", "user_id_another")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), gomock.Any(), gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	post, err = testRepo.UpVote(context.Background(), "654f63e3a2414a2a554b6423", "user_id_another", AnyVersion)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 		return
@@ -596,6 +1102,15 @@ func TestUpvote(t *testing.T) {
 		return
 	}
 
+	// клиент голосует за устаревшую версию поста
+	singleResponse = mongo.NewSingleResultFromDocument(postToReturn, nil, nil)
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	_, err = testRepo.UpVote(context.Background(), "654f63e3a2414a2a554b6423", "user_id_another", 3)
+	if !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("wrong error: expected %s, got %v", ErrPreconditionFailed, err)
+		return
+	}
+
 }
 
 func TestDownVote(t *testing.T) {
@@ -616,8 +1131,8 @@ func TestDownVote(t *testing.T) {
 		return
 	}
 	singleResponse := mongo.NewSingleResultFromDocument(nil, mongo.ErrNilDocument, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	_, err = testRepo.DownVote("654f63e3a2414a2a554b6423", "user_id")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	_, err = testRepo.DownVote(context.Background(), "654f63e3a2414a2a554b6423", "user_id", AnyVersion)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
@@ -635,6 +1150,7 @@ func TestDownVote(t *testing.T) {
 		},
 		Category: "programming",
 		Text:     "rferfer",
+		TextHTML: "<p>rferfer</p>",
 		Votes: []*vote.Vote{
 			{
 				Value:  -1,
@@ -648,8 +1164,8 @@ func TestDownVote(t *testing.T) {
 		ID:               objID,
 	}
 	singleResponse = mongo.NewSingleResultFromDocument(postToReturn, nil, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	post, err := testRepo.DownVote("654f63e3a2414a2a554b6423", "user_id")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	post, err := testRepo.DownVote(context.Background(), "654f63e3a2414a2a554b6423", "user_id", AnyVersion)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 		return
@@ -671,6 +1187,7 @@ func TestDownVote(t *testing.T) {
 		},
 		Category: "programming",
 		Text:     "rferfer",
+		TextHTML: "<p>rferfer</p>",
 		Votes: []*vote.Vote{
 			{
 				Value:  1,
@@ -684,9 +1201,9 @@ func TestDownVote(t *testing.T) {
 		ID:               objID,
 	}
 	singleResponse = mongo.NewSingleResultFromDocument(postToReturn, nil, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	testCollection.EXPECT().UpdateOne(context.Background(), gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("error"))
-	_, err = testRepo.DownVote("654f63e3a2414a2a554b6423", "user_id")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("error"))
+	_, err = testRepo.DownVote(context.Background(), "654f63e3a2414a2a554b6423", "user_id", AnyVersion)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
@@ -694,9 +1211,9 @@ func TestDownVote(t *testing.T) {
 
 	// на посте нет оценок от этого пользователя, не получается добавить downvote
 	singleResponse = mongo.NewSingleResultFromDocument(postToReturn, nil, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	testCollection.EXPECT().UpdateOne(context.Background(), gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("error"))
-	_, err = testRepo.DownVote("654f63e3a2414a2a554b6423", "user_id_another")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("error"))
+	_, err = testRepo.DownVote(context.Background(), "654f63e3a2414a2a554b6423", "user_id_another", AnyVersion)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
@@ -704,9 +1221,9 @@ func TestDownVote(t *testing.T) {
 
 	// на посте нет оценок пользователя, добавление успешно
 	singleResponse = mongo.NewSingleResultFromDocument(postToReturn, nil, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	testCollection.EXPECT().UpdateOne(context.Background(), gomock.Any(), gomock.Any()).Return(nil, nil)
-	post, err = testRepo.DownVote("654f63e3a2414a2a554b6423", "user_id_another")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), gomock.Any(), gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	post, err = testRepo.DownVote(context.Background(), "654f63e3a2414a2a554b6423", "user_id_another", AnyVersion)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 		return
@@ -736,8 +1253,8 @@ func TestUnVote(t *testing.T) {
 		return
 	}
 	singleResponse := mongo.NewSingleResultFromDocument(nil, mongo.ErrNilDocument, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	_, err = testRepo.UnVote("654f63e3a2414a2a554b6423", "user_id")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	_, err = testRepo.UnVote(context.Background(), "654f63e3a2414a2a554b6423", "user_id", AnyVersion)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
@@ -755,6 +1272,7 @@ func TestUnVote(t *testing.T) {
 		},
 		Category: "programming",
 		Text:     "rferfer",
+		TextHTML: "<p>rferfer</p>",
 		Votes: []*vote.Vote{
 			{
 				Value:  1,
@@ -768,8 +1286,8 @@ func TestUnVote(t *testing.T) {
 		ID:               objID,
 	}
 	singleResponse = mongo.NewSingleResultFromDocument(postToReturn, nil, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	post, err := testRepo.UnVote("654f63e3a2414a2a554b6423", "user_id_another")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	post, err := testRepo.UnVote(context.Background(), "654f63e3a2414a2a554b6423", "user_id_another", AnyVersion)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 		return
@@ -781,9 +1299,9 @@ func TestUnVote(t *testing.T) {
 
 	// на посте есть оценка этого юзера, но в базу не получается отправить изменения
 	singleResponse = mongo.NewSingleResultFromDocument(postToReturn, nil, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	testCollection.EXPECT().UpdateOne(context.Background(), gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("error"))
-	_, err = testRepo.UnVote("654f63e3a2414a2a554b6423", "user_id")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("error"))
+	_, err = testRepo.UnVote(context.Background(), "654f63e3a2414a2a554b6423", "user_id", AnyVersion)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
@@ -795,9 +1313,9 @@ func TestUnVote(t *testing.T) {
 		Value: 1,
 	})
 	singleResponse = mongo.NewSingleResultFromDocument(postToReturn, nil, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	testCollection.EXPECT().UpdateOne(context.Background(), gomock.Any(), gomock.Any()).Return(nil, nil)
-	_, err = testRepo.UnVote("654f63e3a2414a2a554b6423", "user_id")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), gomock.Any(), gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	_, err = testRepo.UnVote(context.Background(), "654f63e3a2414a2a554b6423", "user_id", AnyVersion)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 		return
@@ -815,6 +1333,7 @@ func TestUnVote(t *testing.T) {
 		},
 		Category: "programming",
 		Text:     "rferfer",
+		TextHTML: "<p>rferfer</p>",
 		Votes: []*vote.Vote{
 			{
 				Value:  -1,
@@ -832,9 +1351,9 @@ func TestUnVote(t *testing.T) {
 		ID:               objID,
 	}
 	singleResponse = mongo.NewSingleResultFromDocument(postToReturn, nil, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	testCollection.EXPECT().UpdateOne(context.Background(), gomock.Any(), gomock.Any()).Return(nil, nil)
-	_, err = testRepo.UnVote("654f63e3a2414a2a554b6423", "user_id")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), gomock.Any(), gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	_, err = testRepo.UnVote(context.Background(), "654f63e3a2414a2a554b6423", "user_id", AnyVersion)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 		return
@@ -860,8 +1379,8 @@ func TestDeletePost(t *testing.T) {
 		return
 	}
 	singleResponse := mongo.NewSingleResultFromDocument(nil, mongo.ErrNilDocument, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	_, err = testRepo.DeletePost("user_id", "654f63e3a2414a2a554b6423")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	_, err = testRepo.DeletePost(context.Background(), "user_id", "654f63e3a2414a2a554b6423", AnyVersion)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
@@ -896,8 +1415,8 @@ func TestDeletePost(t *testing.T) {
 		ID:               objID,
 	}
 	singleResponse = mongo.NewSingleResultFromDocument(postToReturn, nil, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	_, err = testRepo.DeletePost("user_id_another", "654f63e3a2414a2a554b6423")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	_, err = testRepo.DeletePost(context.Background(), "user_id_another", "654f63e3a2414a2a554b6423", AnyVersion)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
@@ -909,9 +1428,9 @@ func TestDeletePost(t *testing.T) {
 
 	// ошибка удаления поста
 	singleResponse = mongo.NewSingleResultFromDocument(postToReturn, nil, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	testCollection.EXPECT().DeleteOne(context.Background(), gomock.Any()).Return(int64(0), fmt.Errorf("error"))
-	_, err = testRepo.DeletePost("user_id", "654f63e3a2414a2a554b6423")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": bson.M{"$exists": false}}, gomock.Any()).Return(nil, fmt.Errorf("error"))
+	_, err = testRepo.DeletePost(context.Background(), "user_id", "654f63e3a2414a2a554b6423", AnyVersion)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
@@ -919,14 +1438,68 @@ func TestDeletePost(t *testing.T) {
 
 	// пост успешно удален
 	singleResponse = mongo.NewSingleResultFromDocument(postToReturn, nil, nil)
-	testCollection.EXPECT().FindOne(context.Background(), bson.M{"_id": objID}).Return(singleResponse)
-	testCollection.EXPECT().DeleteOne(context.Background(), gomock.Any()).Return(int64(1), nil)
-	_, err = testRepo.DeletePost("user_id", "654f63e3a2414a2a554b6423")
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": bson.M{"$exists": false}}, gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ interface{}, update interface{}, _ ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+			fields := update.(bson.M)["$set"].(bson.M)
+			if fields["deleted_by"] != "user_id" {
+				t.Errorf("wrong deleted_by: %v", fields["deleted_by"])
+			}
+			if _, ok := fields["deleted_at"].(time.Time); !ok {
+				t.Errorf("deleted_at is not set")
+			}
+			return &mongo.UpdateResult{MatchedCount: 1}, nil
+		})
+	_, err = testRepo.DeletePost(context.Background(), "user_id", "654f63e3a2414a2a554b6423", AnyVersion)
 	if err != nil {
 		t.Errorf("enexpected error: %s", err)
 		return
 	}
 
+	// клиент удаляет устаревшую версию поста, в базу ничего не пишется
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(postToReturn, nil, nil))
+	_, err = testRepo.DeletePost(context.Background(), "user_id", "654f63e3a2414a2a554b6423", 3)
+	if !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("wrong error: expected %s, got %v", ErrPreconditionFailed, err)
+		return
+	}
+
+	// пост поменяли между чтением и удалением, клиент ждал прочитанную версию
+	changedPost := *postToReturn
+	changedPost.Version = 4
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(postToReturn, nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": bson.M{"$exists": false}}, gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 0}, nil)
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(&changedPost, nil, nil))
+	_, err = testRepo.DeletePost(context.Background(), "user_id", "654f63e3a2414a2a554b6423", 0)
+	if !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("wrong error: expected %s, got %v", ErrPreconditionFailed, err)
+		return
+	}
+
+	// без If-Match удаление повторяется на свежей версии
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(postToReturn, nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": bson.M{"$exists": false}}, gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 0}, nil)
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(&changedPost, nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": 4}, gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	isDeleted, err := testRepo.DeletePost(context.Background(), "user_id", "654f63e3a2414a2a554b6423", AnyVersion)
+	if err != nil || !isDeleted {
+		t.Errorf("post is not deleted: %v", err)
+		return
+	}
+
+	// удаленный пост нельзя удалить повторно
+	deletedAt := time.Now()
+	deletedPost := *postToReturn
+	deletedPost.DeletedAt = &deletedAt
+	deletedPost.DeletedBy = "user_id"
+	singleResponse = mongo.NewSingleResultFromDocument(&deletedPost, nil, nil)
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(singleResponse)
+	_, err = testRepo.DeletePost(context.Background(), "user_id", "654f63e3a2414a2a554b6423", AnyVersion)
+	if !errors.Is(err, ErrNoPost) {
+		t.Errorf("wrong error: expected %s, got %v", ErrNoPost, err)
+		return
+	}
+
 }
 
 func TestGetPostByUserID(t *testing.T) {
@@ -942,8 +1515,8 @@ func TestGetPostByUserID(t *testing.T) {
 
 	// нет поста с заданным автором
 
-	testCollection.EXPECT().Find(context.Background(), gomock.Any()).Return(nil, mongo.ErrNoDocuments)
-	_, err := testRepo.GetPostsByUserID("user_id")
+	testCollection.EXPECT().Find(gomock.Any(), gomock.Any()).Return(nil, mongo.ErrNoDocuments)
+	_, err := testRepo.GetPostsByUserID(context.Background(), "user_id", nil)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 		return
@@ -986,11 +1559,1121 @@ func TestGetPostByUserID(t *testing.T) {
 		t.Fatalf("error in cursor creation")
 		return
 	}
-	testCollection.EXPECT().Find(context.Background(), gomock.Any()).Return(cursor, nil)
-	_, err = testRepo.GetPostsByUserID("user_id")
+	testCollection.EXPECT().Find(gomock.Any(), gomock.Any()).Return(cursor, nil)
+	_, err = testRepo.GetPostsByUserID(context.Background(), "user_id", nil)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+
+}
+
+func TestValidateFlairAndTags(t *testing.T) {
+	err := SetFlairs(map[string][]*Flair{
+		"programming": {{Name: "discussion", Color: "#00ff00"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer func() {
+		_ = SetFlairs(map[string][]*Flair{})
+	}()
+
+	// цвет флера задается в формате #rrggbb
+	err = SetFlairs(map[string][]*Flair{"news": {{Name: "breaking", Color: "red"}}})
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+
+	postToValidate := &Post{
+		Type:     "text",
+		Title:    "fef",
+		Category: "programming",
+		Text:     "rferfer",
+		Flair:    "discussion",
+		Tags:     []string{"Go", "go", "mongo_db"},
+	}
+	if validationErrors := postToValidate.Validate(); len(validationErrors) != 0 {
+		t.Errorf("unexpected validation errors: %v", validationErrors)
+		return
+	}
+
+	// флер не из списка категории и некорректные теги
+	postToValidate.Flair = "breaking"
+	postToValidate.Tags = []string{"a", "b", "c", "d", "e", "f", "bad tag"}
+	validationErrors := postToValidate.Validate()
+	expected := []string{
+		"too many tags: at most 5 allowed",
+		`bad tag "bad tag"`,
+		`flair "breaking" is not allowed in category programming`,
+	}
+	if !reflect.DeepEqual(validationErrors, expected) {
+		t.Errorf("wrong validation errors: expected %v, got %v", expected, validationErrors)
+		return
+	}
+
+	// при создании поста теги нормализуются, проставляется цвет флера
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	testCollection := NewMockCollectionHelper(ctrl)
+	testRepo := NewPostBusinessLogic(&PostDBRepo{Posts: testCollection}, &idgenerator.TestIDGenerator{})
+	testCollection.EXPECT().InsertOne(gomock.Any(), gomock.Any()).Return(nil, nil)
+	postToValidate.Flair = "discussion"
+	postToValidate.Tags = []string{"Go", "go", "mongo_db"}
+	addedPost, err := testRepo.AddPost(context.Background(), postToValidate, &user.User{ID: "user_id", Username: "hhhhhhhh"})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if addedPost.FlairColor != "#00ff00" || !reflect.DeepEqual(addedPost.Tags, []string{"go", "mongo_db"}) {
+		t.Errorf("wrong flair or tags: %s, %v", addedPost.FlairColor, addedPost.Tags)
+		return
+	}
+}
+
+func TestSetMarks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := NewMockCollectionHelper(ctrl)
+	testRecorder := NewMockActionRecorder(ctrl)
+	testRepo := NewPostBusinessLogic(&PostDBRepo{Posts: testCollection}, &idgenerator.TestIDGenerator{})
+	testRepo.Recorder = testRecorder
+
+	objID, err := primitive.ObjectIDFromHex("654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Fatalf("error in id")
+		return
+	}
+	author := &user.User{ID: "user_id", Username: "hhhhhhhh"}
+	moderator := &user.User{ID: "moderator_id", Username: "moderator"}
+	postToReturn := &Post{
+		Type:     "text",
+		Title:    "fef",
+		Author:   author,
+		Category: "programming",
+		Text:     "rferfer",
+		Votes:    []*vote.Vote{},
+		Comments: []*comment.Comment{},
+		ID:       objID,
+	}
+	isSet := true
+
+	// чужой пост помечать нельзя
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(postToReturn, nil, nil))
+	_, err = testRepo.SetMarks(context.Background(), &user.User{ID: "another_id"}, "654f63e3a2414a2a554b6423", false, &Marks{NSFW: &isSet}, AnyVersion)
+	if !errors.Is(err, ErrNoAccess) {
+		t.Errorf("wrong error: expected %s, got %v", ErrNoAccess, err)
+		return
+	}
+
+	// автор помечает пост как спойлер, в журнал это не пишется
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(postToReturn, nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"nsfw": false, "spoiler": true, "version": 1}}).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	markedPost, err := testRepo.SetMarks(context.Background(), author, "654f63e3a2414a2a554b6423", false, &Marks{Spoiler: &isSet}, AnyVersion)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 		return
 	}
+	if !markedPost.Spoiler || markedPost.NSFW {
+		t.Errorf("wrong marks: nsfw %t, spoiler %t", markedPost.NSFW, markedPost.Spoiler)
+		return
+	}
 
+	// ошибка записи - в журнал ничего не попадает
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(postToReturn, nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"nsfw": true, "spoiler": false, "version": 1}}).Return(nil, fmt.Errorf("error"))
+	_, err = testRepo.SetMarks(context.Background(), moderator, "654f63e3a2414a2a554b6423", true, &Marks{NSFW: &isSet}, AnyVersion)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+
+	// модератор помечает пост как NSFW
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(postToReturn, nil, nil))
+	testRecorder.EXPECT().RecordAction(gomock.Any(), moderator, ActionSetMarks, "654f63e3a2414a2a554b6423", "", "programming", "nsfw=true spoiler=false").Return(nil)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"nsfw": true, "spoiler": false, "version": 1}}).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	_, err = testRepo.SetMarks(context.Background(), moderator, "654f63e3a2414a2a554b6423", true, &Marks{NSFW: &isSet}, AnyVersion)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+
+	// клиент правил устаревшую версию поста
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(postToReturn, nil, nil))
+	_, err = testRepo.SetMarks(context.Background(), author, "654f63e3a2414a2a554b6423", false, &Marks{Spoiler: &isSet}, 3)
+	if !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("wrong error: expected %s, got %v", ErrPreconditionFailed, err)
+		return
+	}
+
+	// пост поменяли между чтением и записью, правка повторяется на свежей версии
+	changedPost := *postToReturn
+	changedPost.Version = 4
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(postToReturn, nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": bson.M{"$exists": false}}, gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 0}, nil)
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(&changedPost, nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": 4}, bson.M{"$set": bson.M{"nsfw": false, "spoiler": true, "version": 5}}).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	markedPost, err = testRepo.SetMarks(context.Background(), author, "654f63e3a2414a2a554b6423", false, &Marks{Spoiler: &isSet}, AnyVersion)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if markedPost.Version != 5 {
+		t.Errorf("wrong version: expected 5, got %d", markedPost.Version)
+		return
+	}
+
+	// конфликт не проходит за все попытки
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(&changedPost, nil, nil)).Times(maxConflictRetries)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": 4}, gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 0}, nil).Times(maxConflictRetries)
+	_, err = testRepo.SetMarks(context.Background(), author, "654f63e3a2414a2a554b6423", false, &Marks{Spoiler: &isSet}, 4)
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("wrong error: expected %s, got %v", ErrVersionConflict, err)
+		return
+	}
+
+	// NSFW посты скрываются из списков или помечаются для размытия
+	nsfwPost := *postToReturn
+	nsfwPost.NSFW = true
+	cursor, err := mongo.NewCursorFromDocuments([]interface{}{&nsfwPost, postToReturn}, nil, nil)
+	if err != nil {
+		t.Fatalf("error in cursor creation")
+		return
+	}
+	testCollection.EXPECT().Find(gomock.Any(), bson.M{"status": bson.M{"$ne": StatusHeld}, "deleted_at": bson.M{"$exists": false}}).Return(cursor, nil)
+	posts, err := testRepo.GetAll(context.Background(), &ListFilter{BlurNSFW: true})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if len(posts) != 2 || !posts[0].Blur || posts[1].Blur {
+		t.Errorf("nsfw post must be blurred")
+		return
+	}
+	testCollection.EXPECT().Find(gomock.Any(), bson.M{"nsfw": bson.M{"$ne": true}, "status": bson.M{"$ne": StatusHeld}, "deleted_at": bson.M{"$exists": false}}).Return(nil, fmt.Errorf("error"))
+	_, err = testRepo.GetAll(context.Background(), &ListFilter{HideNSFW: true})
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+}
+
+func TestVotePoll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := NewMockCollectionHelper(ctrl)
+	testRepo := NewPostBusinessLogic(&PostDBRepo{Posts: testCollection}, &idgenerator.TestIDGenerator{})
+
+	objID, err := primitive.ObjectIDFromHex("654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Fatalf("error in id")
+		return
+	}
+	author := &user.User{ID: "user_id", Username: "hhhhhhhh"}
+	textPost := &Post{
+		Type:     "text",
+		Title:    "fef",
+		Author:   author,
+		Category: "programming",
+		Text:     "rferfer",
+		Votes:    []*vote.Vote{},
+		Comments: []*comment.Comment{},
+		ID:       objID,
+	}
+	newPoll := func() *Post {
+		return &Post{
+			Type:     "poll",
+			Title:    "fef",
+			Author:   author,
+			Category: "programming",
+			Votes:    []*vote.Vote{},
+			Comments: []*comment.Comment{},
+			ID:       objID,
+			Poll: &Poll{
+				Options: []*PollOption{{ID: "1", Text: "go", Votes: 1}, {ID: "2", Text: "rust"}},
+				Voters:  []*PollVote{{UserID: "voter_id", OptionID: "1"}},
+			},
+		}
+	}
+
+	// голосовать можно только в опросе
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(textPost, nil, nil))
+	_, err = testRepo.VotePoll(context.Background(), "654f63e3a2414a2a554b6423", "user_id", "1", AnyVersion)
+	if !errors.Is(err, ErrNotPoll) {
+		t.Errorf("wrong error: expected %s, got %v", ErrNotPoll, err)
+		return
+	}
+
+	// опрос закрыт
+	closedPoll := newPoll()
+	closedAt := time.Now().Add(-time.Hour)
+	closedPoll.Poll.ClosesAt = &closedAt
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(closedPoll, nil, nil))
+	_, err = testRepo.VotePoll(context.Background(), "654f63e3a2414a2a554b6423", "user_id", "1", AnyVersion)
+	if !errors.Is(err, ErrPollClosed) {
+		t.Errorf("wrong error: expected %s, got %v", ErrPollClosed, err)
+		return
+	}
+
+	// нет такого варианта
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newPoll(), nil, nil))
+	_, err = testRepo.VotePoll(context.Background(), "654f63e3a2414a2a554b6423", "user_id", "3", AnyVersion)
+	if !errors.Is(err, ErrNoPollOption) {
+		t.Errorf("wrong error: expected %s, got %v", ErrNoPollOption, err)
+		return
+	}
+
+	// второй голос того же пользователя
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newPoll(), nil, nil))
+	_, err = testRepo.VotePoll(context.Background(), "654f63e3a2414a2a554b6423", "voter_id", "2", AnyVersion)
+	if !errors.Is(err, ErrAlreadyVoted) {
+		t.Errorf("wrong error: expected %s, got %v", ErrAlreadyVoted, err)
+		return
+	}
+
+	pollFilter := bson.M{"_id": objID, "poll.voters.user": bson.M{"$ne": "user_id"}}
+	pollUpdate := bson.M{
+		"$inc":  bson.M{"poll.options.1.votes": 1, "version": 1},
+		"$push": bson.M{"poll.voters": &PollVote{UserID: "user_id", OptionID: "2"}},
+	}
+
+	// параллельный голос уже записан в базу
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newPoll(), nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), pollFilter, pollUpdate).Return(&mongo.UpdateResult{MatchedCount: 0}, nil)
+	_, err = testRepo.VotePoll(context.Background(), "654f63e3a2414a2a554b6423", "user_id", "2", AnyVersion)
+	if !errors.Is(err, ErrAlreadyVoted) {
+		t.Errorf("wrong error: expected %s, got %v", ErrAlreadyVoted, err)
+		return
+	}
+
+	// ошибка базы
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newPoll(), nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), pollFilter, pollUpdate).Return(nil, fmt.Errorf("error"))
+	_, err = testRepo.VotePoll(context.Background(), "654f63e3a2414a2a554b6423", "user_id", "2", AnyVersion)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+
+	// клиент голосует в устаревшей версии опроса
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newPoll(), nil, nil))
+	_, err = testRepo.VotePoll(context.Background(), "654f63e3a2414a2a554b6423", "user_id", "2", 3)
+	if !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("wrong error: expected %s, got %v", ErrPreconditionFailed, err)
+		return
+	}
+
+	// с If-Match версия проверяется и в запросе к базе
+	versionedPollFilter := bson.M{"_id": objID, "version": bson.M{"$exists": false}, "poll.voters.user": bson.M{"$ne": "user_id"}}
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newPoll(), nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), versionedPollFilter, pollUpdate).Return(&mongo.UpdateResult{MatchedCount: 0}, nil)
+	_, err = testRepo.VotePoll(context.Background(), "654f63e3a2414a2a554b6423", "user_id", "2", 0)
+	if !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("wrong error: expected %s, got %v", ErrPreconditionFailed, err)
+		return
+	}
+
+	// удачный голос
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newPoll(), nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), pollFilter, pollUpdate).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	votedPost, err := testRepo.VotePoll(context.Background(), "654f63e3a2414a2a554b6423", "user_id", "2", AnyVersion)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	HidePollResults("user_id", votedPost)
+	if votedPost.Poll.MyChoice != "2" || votedPost.Poll.TotalVotes != 2 || votedPost.Poll.ResultsHidden {
+		t.Errorf("wrong poll state: %+v", votedPost.Poll)
+		return
+	}
+
+	// не проголосовавший результатов не видит
+	hiddenPost := newPoll()
+	HidePollResults("another_id", hiddenPost)
+	if !hiddenPost.Poll.ResultsHidden || hiddenPost.Poll.TotalVotes != 0 || hiddenPost.Poll.Options[0].Votes != 0 {
+		t.Errorf("poll results must be hidden: %+v", hiddenPost.Poll)
+		return
+	}
+}
+
+func TestValidatePoll(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	cases := []struct {
+		poll   *Post
+		errors int
+	}{
+		// нормальный опрос
+		{&Post{Type: "poll", Title: "t", Category: "music", Poll: &Poll{Options: []*PollOption{{Text: "a"}, {Text: "b"}}}}, 0},
+		// нет опроса
+		{&Post{Type: "poll", Title: "t", Category: "music"}, 1},
+		// один вариант и время в прошлом
+		{&Post{Type: "poll", Title: "t", Category: "music", Poll: &Poll{Options: []*PollOption{{Text: "a"}}, ClosesAt: &past}}, 2},
+		// повтор и пустой вариант
+		{&Post{Type: "poll", Title: "t", Category: "music", Poll: &Poll{Options: []*PollOption{{Text: "a"}, {Text: " a "}, {Text: " "}}}}, 2},
+	}
+	for i, testCase := range cases {
+		validationErrors := testCase.poll.Validate()
+		if len(validationErrors) != testCase.errors {
+			t.Errorf("case %d: expected %d errors, got %v", i, testCase.errors, validationErrors)
+		}
+	}
+}
+
+func TestValidateByType(t *testing.T) {
+	cases := []struct {
+		name     string
+		post     *Post
+		expected []string
+	}{
+		{"текстовый пост", &Post{Type: "text", Title: "t", Category: "music", Text: "x"}, []string{}},
+		{"ссылка", &Post{Type: "link", Title: "t", Category: "music", URL: "https://example.com"}, []string{}},
+		{"картинка", &Post{Type: "image", Title: "t", Category: "music", Image: &Image{}}, []string{}},
+		// остальные поля в порядке, а поля своего типа нет
+		{"текст без текста", &Post{Type: "text", Title: "t", Category: "music"}, []string{"text field required"}},
+		{"ссылка без url", &Post{Type: "link", Title: "t", Category: "music"}, []string{"url field required"}},
+		{"картинка без картинки", &Post{Type: "image", Title: "t", Category: "music"}, []string{"image field required"}},
+		{"слишком длинный текст", &Post{Type: "text", Title: "t", Category: "music", Text: strings.Repeat("a", 40001)}, []string{"text: " + strings.Repeat("a", 40001) + " does not validate as length(0|40000)"}},
+		// опросу url не нужен
+		{"опрос без url", &Post{Type: "poll", Title: "t", Category: "music", Poll: &Poll{Options: []*PollOption{{Text: "a"}, {Text: "b"}}}}, []string{}},
+		{"неизвестный тип", &Post{Type: "video", Title: "t", Category: "music"}, []string{`unknown post type "video"`, "type: video does not validate as in(text|link|image|poll)"}},
+	}
+	for _, testCase := range cases {
+		validationErrors := testCase.post.Validate()
+		if !reflect.DeepEqual(validationErrors, testCase.expected) {
+			t.Errorf("%s: expected %v, got %v", testCase.name, testCase.expected, validationErrors)
+		}
+	}
+}
+
+func TestAttachPreview(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := NewMockCollectionHelper(ctrl)
+	testPreviews := NewMockLinkPreviewer(ctrl)
+	testRepo := NewPostBusinessLogic(&PostDBRepo{Posts: testCollection}, &idgenerator.TestIDGenerator{})
+	testRepo.Previews = testPreviews
+	previewErrors := make([]error, 0)
+	testRepo.OnPreviewError = func(err error) {
+		previewErrors = append(previewErrors, err)
+	}
+
+	objID, err := primitive.ObjectIDFromHex("654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Fatalf("error in id")
+		return
+	}
+	linkPreview := &preview.Preview{Title: "Заголовок", Image: "https://example.com/cover.png"}
+
+	// превью сохраняется в пост
+	testPreviews.EXPECT().Fetch(gomock.Any(), "https://example.com/article").Return(linkPreview, nil)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID}, bson.M{"$set": bson.M{"preview": linkPreview}, "$inc": bson.M{"version": 1}}).Return(nil, nil)
+	testRepo.attachPreview("654f63e3a2414a2a554b6423", "https://example.com/article")
+
+	// на странице нет ничего полезного
+	testPreviews.EXPECT().Fetch(gomock.Any(), "https://example.com/empty").Return(&preview.Preview{}, nil)
+	testRepo.attachPreview("654f63e3a2414a2a554b6423", "https://example.com/empty")
+
+	// ошибка загрузки и ошибка базы уходят в OnPreviewError
+	testPreviews.EXPECT().Fetch(gomock.Any(), "http://10.0.0.1/").Return(nil, preview.ErrBlockedHost)
+	testRepo.attachPreview("654f63e3a2414a2a554b6423", "http://10.0.0.1/")
+	testPreviews.EXPECT().Fetch(gomock.Any(), "https://example.com/article").Return(linkPreview, nil)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID}, bson.M{"$set": bson.M{"preview": linkPreview}, "$inc": bson.M{"version": 1}}).Return(nil, fmt.Errorf("error"))
+	testRepo.attachPreview("654f63e3a2414a2a554b6423", "https://example.com/article")
+	if len(previewErrors) != 2 || !errors.Is(previewErrors[0], preview.ErrBlockedHost) {
+		t.Errorf("wrong preview errors: %v", previewErrors)
+		return
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	cases := []struct {
+		in  string
+		out string
+	}{
+		{"HTTPS://Example.COM:443/Path/?utm_source=tg&b=2&a=1#top", "https://example.com/Path?a=1&b=2"},
+		{"http://example.com:80/", "http://example.com"},
+		{"https://example.com:8443/a//?fbclid=xyz", "https://example.com:8443/a"},
+		{"https://example.com/watch?v=abc&gclid=1&UTM_medium=x", "https://example.com/watch?v=abc"},
+		{"  not a url  ", "not a url"},
+	}
+	for _, testCase := range cases {
+		if normalized := NormalizeURL(testCase.in); normalized != testCase.out {
+			t.Errorf("%s: expected %s, got %s", testCase.in, testCase.out, normalized)
+		}
+	}
+}
+
+// duplicateFilterMatcher - в фильтре дублей есть время, поэтому сравниваем только нужные поля
+type duplicateFilterMatcher struct {
+	category string
+	url      string
+}
+
+func (m *duplicateFilterMatcher) Matches(x interface{}) bool {
+	filter, ok := x.(bson.M)
+	if !ok {
+		return false
+	}
+	since, ok := filter["_id"].(bson.M)["$gte"].(primitive.ObjectID)
+	return ok && filter["category"] == m.category && filter["url"] == m.url &&
+		filter["deleted_at"] != nil && time.Since(since.Timestamp()) > 6*24*time.Hour
+}
+
+func (m *duplicateFilterMatcher) String() string {
+	return fmt.Sprintf("duplicate filter for %s in %s", m.url, m.category)
+}
+
+func TestAddPostDuplicate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := NewMockCollectionHelper(ctrl)
+	testRepo := NewPostBusinessLogic(&PostDBRepo{Posts: testCollection}, &idgenerator.TestIDGenerator{})
+	author := &user.User{ID: "310ca263", Username: "hhhhhhhh"}
+	objID, err := primitive.ObjectIDFromHex("654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Fatalf("error in id")
+		return
+	}
+	original := &Post{Type: "link", Title: "fef", Category: "programming", URL: "https://example.com/a", ID: objID}
+	duplicateFilter := &duplicateFilterMatcher{category: "programming", url: "https://example.com/a"}
+
+	// такая ссылка уже была в категории
+	testCollection.EXPECT().FindOne(gomock.Any(), duplicateFilter).Return(mongo.NewSingleResultFromDocument(original, nil, nil))
+	_, err = testRepo.AddPost(context.Background(), &Post{Type: "link", Title: "fef", Category: "programming", URL: "https://Example.com/a/?utm_source=x"}, author)
+	var duplicateErr *DuplicateError
+	if !errors.As(err, &duplicateErr) || duplicateErr.Original.ID != objID {
+		t.Errorf("wrong error: expected duplicate of %s, got %v", objID.Hex(), err)
+		return
+	}
+
+	// ошибка базы при поиске дубля
+	testCollection.EXPECT().FindOne(gomock.Any(), duplicateFilter).Return(mongo.NewSingleResultFromDocument(bson.D{}, fmt.Errorf("error"), nil))
+	_, err = testRepo.AddPost(context.Background(), &Post{Type: "link", Title: "fef", Category: "programming", URL: "https://example.com/a"}, author)
+	if err == nil || errors.Is(err, ErrDuplicate) {
+		t.Errorf("expected db error, got %v", err)
+		return
+	}
+
+	// дублей нет, ссылка сохраняется нормализованной
+	testCollection.EXPECT().FindOne(gomock.Any(), duplicateFilter).Return(mongo.NewSingleResultFromDocument(bson.D{}, mongo.ErrNoDocuments, nil))
+	testCollection.EXPECT().InsertOne(gomock.Any(), gomock.Any()).Return("any", nil)
+	addedPost, err := testRepo.AddPost(context.Background(), &Post{Type: "link", Title: "fef", Category: "programming", URL: "https://EXAMPLE.com/a#comments"}, author)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if addedPost.URL != "https://example.com/a" || addedPost.DuplicateOf != "" {
+		t.Errorf("wrong url %s or duplicateOf %s", addedPost.URL, addedPost.DuplicateOf)
+		return
+	}
+
+	// в режиме предупреждения пост создается с пометкой
+	testRepo.RejectDuplicates = false
+	testCollection.EXPECT().FindOne(gomock.Any(), duplicateFilter).Return(mongo.NewSingleResultFromDocument(original, nil, nil))
+	testCollection.EXPECT().InsertOne(gomock.Any(), gomock.Any()).Return("any", nil)
+	addedPost, err = testRepo.AddPost(context.Background(), &Post{Type: "link", Title: "fef", Category: "programming", URL: "https://example.com/a"}, author)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if addedPost.DuplicateOf != objID.Hex() {
+		t.Errorf("wrong duplicateOf: expected %s, got %s", objID.Hex(), addedPost.DuplicateOf)
+		return
+	}
+}
+
+func TestCrosspostTo(t *testing.T) {
+	objID, err := primitive.ObjectIDFromHex("654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Fatalf("error in id")
+		return
+	}
+	original := &Post{Type: "image", Title: "fef", Category: "programming", Image: &Image{URL: "/media/a.png"}, NSFW: true, ID: objID}
+
+	// кросспост ссылается на оригинал и копирует содержимое
+	crosspost, err := original.CrosspostTo("music", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if crosspost.CrosspostOf != objID.Hex() || crosspost.Title != "fef" || crosspost.Category != "music" || !crosspost.NSFW || crosspost.Image.URL != "/media/a.png" || crosspost.Image == original.Image {
+		t.Errorf("wrong crosspost: %+v", crosspost)
+	}
+
+	// кросспост кросспоста ссылается на самый первый пост
+	crosspost.ID = primitive.NewObjectID()
+	second, err := crosspost.CrosspostTo("news", "другой заголовок")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if second.CrosspostOf != objID.Hex() || second.Title != "другой заголовок" {
+		t.Errorf("wrong crosspost: %+v", second)
+	}
+
+	// в ту же категорию и опросы нельзя
+	_, err = original.CrosspostTo("programming", "")
+	if !errors.Is(err, ErrCrosspost) {
+		t.Errorf("wrong error: expected %s, got %v", ErrCrosspost, err)
+	}
+	_, err = (&Post{Type: "poll", Category: "programming"}).CrosspostTo("music", "")
+	if !errors.Is(err, ErrCrosspost) {
+		t.Errorf("wrong error: expected %s, got %v", ErrCrosspost, err)
+	}
+}
+
+func TestHiddenAndByIDs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := NewMockCollectionHelper(ctrl)
+	testRepo := NewPostBusinessLogic(&PostDBRepo{Posts: testCollection}, &idgenerator.TestIDGenerator{})
+	objID, err := primitive.ObjectIDFromHex("654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Fatalf("error in id")
+		return
+	}
+
+	// пустой список - без похода в базу
+	posts, err := testRepo.FindPostsByIDs(context.Background(), nil)
+	if err != nil || len(posts) != 0 {
+		t.Errorf("unexpected result: %v, %v", posts, err)
+		return
+	}
+
+	cursor, err := mongo.NewCursorFromDocuments([]interface{}{&Post{ID: objID, Title: "fef"}}, nil, nil)
+	if err != nil {
+		t.Fatalf("error in cursor creation")
+		return
+	}
+	testCollection.EXPECT().Find(gomock.Any(), bson.M{
+		"_id":        bson.M{"$in": []primitive.ObjectID{objID}},
+		"status":     bson.M{"$ne": StatusHeld},
+		"deleted_at": bson.M{"$exists": false},
+	}).Return(cursor, nil)
+	posts, err = testRepo.FindPostsByIDs(context.Background(), []string{"654f63e3a2414a2a554b6423"})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if len(posts) != 1 || posts[0].ID != objID {
+		t.Errorf("wrong posts: %v", posts)
+		return
+	}
+}
+
+func TestLockedAndArchived(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := NewMockCollectionHelper(ctrl)
+	testRecorder := NewMockActionRecorder(ctrl)
+	testRepo := NewPostBusinessLogic(&PostDBRepo{Posts: testCollection}, &idgenerator.TestIDGenerator{})
+	testRepo.Recorder = testRecorder
+
+	objID, err := primitive.ObjectIDFromHex("654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Fatalf("error in id")
+		return
+	}
+	author := &user.User{ID: "user_id", Username: "hhhhhhhh"}
+	moderator := &user.User{ID: "moder_id", Username: "moderator"}
+	newPost := func(locked bool) *Post {
+		return &Post{
+			Type:     "text",
+			Title:    "fef",
+			Author:   author,
+			Category: "programming",
+			Text:     "rferfer",
+			Votes:    []*vote.Vote{},
+			Comments: []*comment.Comment{},
+			ID:       objID,
+			Locked:   locked,
+		}
+	}
+
+	// закрытую ветку нельзя комментировать и за неё нельзя голосовать
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newPost(true), nil, nil))
+	_, err = testRepo.AddComment(context.Background(), "comment", author, "654f63e3a2414a2a554b6423", AnyVersion)
+	if !errors.Is(err, ErrLocked) {
+		t.Errorf("wrong error: expected %s, got %v", ErrLocked, err)
+		return
+	}
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newPost(true), nil, nil))
+	_, err = testRepo.UpVote(context.Background(), "654f63e3a2414a2a554b6423", "user_id", AnyVersion)
+	if !errors.Is(err, ErrLocked) {
+		t.Errorf("wrong error: expected %s, got %v", ErrLocked, err)
+		return
+	}
+
+	// без ArchiveAfter старый пост открыт
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newPost(false), nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID}, bson.M{"$set": bson.M{"views": 1}}).Return(nil, nil)
+	openPost, err := testRepo.GetPostByID(context.Background(), "654f63e3a2414a2a554b6423", nil, false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if openPost.Archived {
+		t.Errorf("post must not be archived")
+		return
+	}
+
+	// пост старше ArchiveAfter архивный
+	testRepo.ArchiveAfter = 24 * time.Hour
+	for _, voteFunc := range []func(context.Context, string, string, int) (*Post, error){testRepo.UpVote, testRepo.DownVote, testRepo.UnVote} {
+		testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newPost(false), nil, nil))
+		_, err = voteFunc(context.Background(), "654f63e3a2414a2a554b6423", "user_id", AnyVersion)
+		if !errors.Is(err, ErrArchived) {
+			t.Errorf("wrong error: expected %s, got %v", ErrArchived, err)
+			return
+		}
+	}
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newPost(false), nil, nil))
+	_, err = testRepo.AddComment(context.Background(), "comment", author, "654f63e3a2414a2a554b6423", AnyVersion)
+	if !errors.Is(err, ErrArchived) {
+		t.Errorf("wrong error: expected %s, got %v", ErrArchived, err)
+		return
+	}
+	testRepo.ArchiveAfter = 0
+
+	// устаревший If-Match - ни записи, ни журнала
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newPost(false), nil, nil))
+	_, err = testRepo.SetLocked(context.Background(), moderator, "654f63e3a2414a2a554b6423", true, "flame", 3)
+	if !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("wrong error: expected %s, got %v", ErrPreconditionFailed, err)
+		return
+	}
+
+	// ошибка записи - в журнал ничего не попадает
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newPost(false), nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"locked": true, "version": 1}}).Return(nil, fmt.Errorf("error"))
+	_, err = testRepo.SetLocked(context.Background(), moderator, "654f63e3a2414a2a554b6423", true, "flame", AnyVersion)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+
+	// ошибка журнала возвращается
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newPost(false), nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"locked": true, "version": 1}}).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	testRecorder.EXPECT().RecordAction(gomock.Any(), moderator, ActionLockPost, "654f63e3a2414a2a554b6423", "", "programming", "flame").Return(fmt.Errorf("error"))
+	_, err = testRepo.SetLocked(context.Background(), moderator, "654f63e3a2414a2a554b6423", true, "flame", AnyVersion)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+
+	// модератор закрывает ветку
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newPost(false), nil, nil))
+	testRecorder.EXPECT().RecordAction(gomock.Any(), moderator, ActionLockPost, "654f63e3a2414a2a554b6423", "", "programming", "flame").Return(nil)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"locked": true, "version": 1}}).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	lockedPost, err := testRepo.SetLocked(context.Background(), moderator, "654f63e3a2414a2a554b6423", true, "flame", AnyVersion)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if !lockedPost.Locked {
+		t.Errorf("post must be locked")
+		return
+	}
+
+	// и открывает обратно
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newPost(true), nil, nil))
+	testRecorder.EXPECT().RecordAction(gomock.Any(), moderator, ActionUnlockPost, "654f63e3a2414a2a554b6423", "", "programming", "").Return(nil)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"locked": false, "version": 1}}).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	lockedPost, err = testRepo.SetLocked(context.Background(), moderator, "654f63e3a2414a2a554b6423", false, "", AnyVersion)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if lockedPost.Locked {
+		t.Errorf("post must be unlocked")
+		return
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := NewMockCollectionHelper(ctrl)
+	testRepo := NewPostBusinessLogic(&PostDBRepo{Posts: testCollection}, &idgenerator.TestIDGenerator{})
+	objID, err := primitive.ObjectIDFromHex("654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Fatalf("error in id")
+		return
+	}
+	postToReturn := &Post{
+		Type:     "text",
+		Title:    "fef",
+		Category: "programming",
+		Text:     "**жирный** <script>alert(1)</script>",
+		Comments: []*comment.Comment{{ID: "comment_id", Body: "[ссылка](javascript:alert(1))"}},
+		ID:       objID,
+	}
+
+	// старый документ без html дорисовывается при чтении, исходник не меняется
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(postToReturn, nil, nil))
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID}, bson.M{"$set": bson.M{"views": 1}}).Return(nil, nil)
+	renderedPost, err := testRepo.GetPostByID(context.Background(), "654f63e3a2414a2a554b6423", nil, false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if renderedPost.Text != postToReturn.Text {
+		t.Errorf("source must not change: %s", renderedPost.Text)
+		return
+	}
+	if renderedPost.TextHTML != "<p><strong>жирный</strong> &lt;script&gt;alert(1)&lt;/script&gt;</p>" {
+		t.Errorf("wrong text html: %s", renderedPost.TextHTML)
+		return
+	}
+	if renderedPost.Comments[0].BodyHTML != "<p>ссылка)</p>" {
+		t.Errorf("wrong comment html: %s", renderedPost.Comments[0].BodyHTML)
+		return
+	}
+
+	// сохраненный при записи html не пересчитывается
+	storedPost := &Post{Type: "text", Text: "*a*", TextHTML: "<p>stored</p>"}
+	renderMarkdown(storedPost)
+	if storedPost.TextHTML != "<p>stored</p>" {
+		t.Errorf("stored html must be kept: %s", storedPost.TextHTML)
+		return
+	}
+}
+
+func TestInTransaction(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testClient := NewMockClientHelper(ctrl)
+	testRepoDB := &PostDBRepo{Sess: testClient}
+
+	// на replica set работа идет в транзакции
+	testSession := &fakeSession{}
+	testClient.EXPECT().StartSession().Return(testSession, nil)
+	err := testRepoDB.InTransaction(context.Background(), func(ctx context.Context) error {
+		if mongo.SessionFromContext(ctx) != testSession {
+			t.Errorf("work must be in transaction")
+		}
+		return nil
+	})
+	if err != nil || !testSession.committed || !testSession.ended {
+		t.Errorf("transaction must be committed: %v", err)
+		return
+	}
+
+	// ошибка работы откатывает транзакцию и возвращается как есть
+	testSession = &fakeSession{}
+	testClient.EXPECT().StartSession().Return(testSession, nil)
+	workErr := errors.New("work_error")
+	err = testRepoDB.InTransaction(context.Background(), func(_ context.Context) error {
+		return workErr
+	})
+	if !errors.Is(err, workErr) || testSession.committed {
+		t.Errorf("expected work error, got %v", err)
+		return
+	}
+
+	// вложенная работа идет в той же транзакции, новая сессия не открывается
+	testSession = &fakeSession{}
+	testClient.EXPECT().StartSession().Return(testSession, nil)
+	err = testRepoDB.InTransaction(context.Background(), func(ctx context.Context) error {
+		return testRepoDB.InTransaction(ctx, func(ctx context.Context) error {
+			if mongo.SessionFromContext(ctx) != testSession {
+				t.Errorf("nested work must be in outer transaction")
+			}
+			return nil
+		})
+	})
+	if err != nil || !testSession.committed {
+		t.Errorf("transaction must be committed: %v", err)
+		return
+	}
+
+	// standalone сервер - работа выполняется без транзакции, и больше сессии не открываются
+	testSession = &fakeSession{err: mongo.CommandError{
+		Code:    20,
+		Message: "Transaction numbers are only allowed on a replica set member or mongos",
+	}}
+	testClient.EXPECT().StartSession().Return(testSession, nil)
+	for i := 0; i < 2; i++ {
+		done := false
+		err = testRepoDB.InTransaction(context.Background(), func(ctx context.Context) error {
+			if mongo.SessionFromContext(ctx) != nil {
+				t.Errorf("work must be without transaction")
+			}
+			done = true
+			return nil
+		})
+		if err != nil || !done {
+			t.Errorf("work must be done without transaction: %v", err)
+			return
+		}
+	}
+}
+
+func TestDeletePostTransaction(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := NewMockCollectionHelper(ctrl)
+	testClient := NewMockClientHelper(ctrl)
+	testRepoDB := &PostDBRepo{Posts: testCollection, Sess: testClient}
+	testRepo := NewPostBusinessLogic(testRepoDB, &idgenerator.TestIDGenerator{})
+	testQueue := NewMockModerationQueue(ctrl)
+	testNotifier := NewMockNotifier(ctrl)
+	testEvents := NewMockEventPublisher(ctrl)
+	testRecorder := NewMockActionRecorder(ctrl)
+	testRepo.Queue = testQueue
+	testRepo.Notifier = testNotifier
+	testRepo.Events = testEvents
+	testRepo.Recorder = testRecorder
+
+	postID := "654f63e3a2414a2a554b6423"
+	objID, _ := primitive.ObjectIDFromHex(postID)
+	author := &user.User{ID: "user_id"}
+	postToReturn := &Post{ID: objID, Type: "text", Title: "t", Text: "x", Category: "programming", Author: author}
+	var testSession *fakeSession
+	inTransaction := func(ctx context.Context) {
+		t.Helper()
+		if mongo.SessionFromContext(ctx) != testSession {
+			t.Errorf("write must be in transaction")
+		}
+	}
+	expectDelete := func(recordErr, notifierErr error) {
+		testSession = &fakeSession{}
+		testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(postToReturn, nil, nil))
+		testClient.EXPECT().StartSession().Return(testSession, nil)
+		testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": bson.M{"$exists": false}}, gomock.Any()).DoAndReturn(
+			func(ctx context.Context, _, _ interface{}, _ ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+				inTransaction(ctx)
+				return &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil
+			})
+		testRecorder.EXPECT().RecordAction(gomock.Any(), author, ActionDeletePost, postID, "", "programming", "").DoAndReturn(
+			func(ctx context.Context, _ *user.User, _, _, _, _, _ string) error {
+				inTransaction(ctx)
+				return recordErr
+			})
+		if recordErr != nil {
+			return
+		}
+		testQueue.EXPECT().DropPost(gomock.Any(), postID).DoAndReturn(func(ctx context.Context, _ string) error {
+			inTransaction(ctx)
+			return nil
+		})
+		testNotifier.EXPECT().DropPost(gomock.Any(), postID).DoAndReturn(func(ctx context.Context, _ string) error {
+			inTransaction(ctx)
+			return notifierErr
+		})
+	}
+
+	// пост не удалился - в журнал ничего не пишется
+	testSession = &fakeSession{}
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(postToReturn, nil, nil))
+	testClient.EXPECT().StartSession().Return(testSession, nil)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": bson.M{"$exists": false}}, gomock.Any()).Return(nil, fmt.Errorf("error"))
+	deleted, err := testRepo.DeletePost(context.Background(), "user_id", postID, AnyVersion)
+	if err == nil || deleted {
+		t.Errorf("expected error, got %t, %v", deleted, err)
+		return
+	}
+
+	// журнал не записался - удаление откатывается
+	expectDelete(fmt.Errorf("error"), nil)
+	deleted, err = testRepo.DeletePost(context.Background(), "user_id", postID, AnyVersion)
+	if err == nil || deleted {
+		t.Errorf("expected error, got %t, %v", deleted, err)
+		return
+	}
+	if testSession.committed || !testSession.ended {
+		t.Errorf("transaction must be rolled back and session ended")
+		return
+	}
+
+	// уведомления не удалились - транзакция откатывается вместе с удалением поста, жалоб и записью в журнал, события нет
+	expectDelete(nil, fmt.Errorf("error"))
+	deleted, err = testRepo.DeletePost(context.Background(), "user_id", postID, AnyVersion)
+	if err == nil || deleted {
+		t.Errorf("expected error, got %t, %v", deleted, err)
+		return
+	}
+	if testSession.committed || !testSession.ended {
+		t.Errorf("transaction must be rolled back and session ended")
+		return
+	}
+
+	// пост, жалобы, уведомления и запись в журнал пишутся одной транзакцией
+	expectDelete(nil, nil)
+	testEvents.EXPECT().Publish(gomock.Any(), EventPostDeleted, gomock.Any()).Return(nil).Times(2)
+	deleted, err = testRepo.DeletePost(context.Background(), "user_id", postID, AnyVersion)
+	if err != nil || !deleted {
+		t.Errorf("unexpected result: %t, %v", deleted, err)
+		return
+	}
+	if !testSession.committed {
+		t.Errorf("transaction must be committed")
+		return
+	}
+}
+
+func TestRemoveCommentAudit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := NewMockCollectionHelper(ctrl)
+	testClient := NewMockClientHelper(ctrl)
+	testRepo := NewPostBusinessLogic(&PostDBRepo{Posts: testCollection, Sess: testClient}, &idgenerator.TestIDGenerator{})
+	testRecorder := NewMockActionRecorder(ctrl)
+	testRepo.Recorder = testRecorder
+
+	postID := "654f63e3a2414a2a554b6423"
+	objID, _ := primitive.ObjectIDFromHex(postID)
+	moderator := &user.User{ID: "moderator_id", Username: "moderator"}
+	newPost := func() *Post {
+		return &Post{
+			ID:       objID,
+			Type:     "text",
+			Title:    "t",
+			Text:     "x",
+			Category: "programming",
+			Author:   &user.User{ID: "user_id"},
+			Comments: []*comment.Comment{{ID: "comment_id", Author: &user.User{ID: "user_id"}}},
+		}
+	}
+	var testSession *fakeSession
+	inTransaction := func(ctx context.Context) {
+		t.Helper()
+		if mongo.SessionFromContext(ctx) != testSession {
+			t.Errorf("write must be in transaction")
+		}
+	}
+	expectRemove := func(updateErr error) {
+		testSession = &fakeSession{}
+		testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": objID}).Return(mongo.NewSingleResultFromDocument(newPost(), nil, nil))
+		testClient.EXPECT().StartSession().Return(testSession, nil)
+		testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": objID, "version": bson.M{"$exists": false}}, gomock.Any()).DoAndReturn(
+			func(ctx context.Context, _, _ interface{}, _ ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+				inTransaction(ctx)
+				if updateErr != nil {
+					return nil, updateErr
+				}
+				return &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil
+			})
+	}
+
+	// коммент не удалился - в журнал ничего не пишется
+	expectRemove(fmt.Errorf("error"))
+	_, err := testRepo.RemoveComment(context.Background(), moderator, postID, "comment_id", "spam", AnyVersion)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+
+	// журнал не записался - удаление коммента откатывается
+	expectRemove(nil)
+	testRecorder.EXPECT().RecordAction(gomock.Any(), moderator, ActionRemoveComment, postID, "comment_id", "programming", "spam").Return(fmt.Errorf("error"))
+	_, err = testRepo.RemoveComment(context.Background(), moderator, postID, "comment_id", "spam", AnyVersion)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+	if testSession.committed || !testSession.ended {
+		t.Errorf("transaction must be rolled back and session ended")
+		return
+	}
+
+	// коммент и запись в журнал пишутся одной транзакцией
+	expectRemove(nil)
+	testRecorder.EXPECT().RecordAction(gomock.Any(), moderator, ActionRemoveComment, postID, "comment_id", "programming", "spam").DoAndReturn(
+		func(ctx context.Context, _ *user.User, _, _, _, _, _ string) error {
+			inTransaction(ctx)
+			return nil
+		})
+	removedPost, err := testRepo.RemoveComment(context.Background(), moderator, postID, "comment_id", "spam", AnyVersion)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if len(removedPost.Comments) != 0 || !testSession.committed {
+		t.Errorf("comment must be removed in committed transaction")
+		return
+	}
+}
+
+func TestAddPostTransaction(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := NewMockCollectionHelper(ctrl)
+	testClient := NewMockClientHelper(ctrl)
+	testRepoDB := &PostDBRepo{Posts: testCollection, Sess: testClient}
+	testRepo := NewPostBusinessLogic(testRepoDB, &idgenerator.TestIDGenerator{})
+	testModerator := NewMockContentModerator(ctrl)
+	testQueue := NewMockModerationQueue(ctrl)
+	testRepo.AutoMod = testModerator
+	testRepo.Queue = testQueue
+	author := &user.User{ID: "author_id", Username: "author"}
+	original := &Post{ID: primitive.NewObjectID(), Type: "text", Title: "t", Text: "x", Category: "music", Author: author}
+
+	// кросспост, задержанный автомодерацией: не встал в очередь - не сохраняется и сам пост
+	crosspost, err := original.CrosspostTo("programming", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testSession := &fakeSession{}
+	testModerator.EXPECT().Evaluate(gomock.Any()).Return(&automod.Decision{Hold: true}, nil)
+	testClient.EXPECT().StartSession().Return(testSession, nil)
+	testCollection.EXPECT().InsertOne(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, _ interface{}) (interface{}, error) {
+		if mongo.SessionFromContext(ctx) != testSession {
+			t.Errorf("insert must be in transaction")
+		}
+		return nil, nil
+	})
+	testQueue.EXPECT().Enqueue(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, request *QueueRequest) error {
+		if mongo.SessionFromContext(ctx) != testSession || !request.Held {
+			t.Errorf("held post must be enqueued in transaction")
+		}
+		return fmt.Errorf("error")
+	})
+	_, err = testRepo.AddPost(context.Background(), crosspost, author)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+	if testSession.committed || !testSession.ended {
+		t.Errorf("transaction must be rolled back and session ended")
+		return
+	}
+
+	// задержанный коммент: не встал в очередь - не сохраняется и сам коммент
+	testSession = &fakeSession{}
+	testModerator.EXPECT().Evaluate(gomock.Any()).Return(&automod.Decision{Hold: true}, nil)
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": original.ID}).Return(mongo.NewSingleResultFromDocument(original, nil, nil))
+	testClient.EXPECT().StartSession().Return(testSession, nil)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": original.ID, "version": bson.M{"$exists": false}}, gomock.Any()).DoAndReturn(func(ctx context.Context, _, _ interface{}, _ ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+		if mongo.SessionFromContext(ctx) != testSession {
+			t.Errorf("comment must be written in transaction")
+		}
+		return &mongo.UpdateResult{MatchedCount: 1}, nil
+	})
+	testQueue.EXPECT().Enqueue(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, request *QueueRequest) error {
+		if mongo.SessionFromContext(ctx) != testSession || !request.Held || request.CommentID == "" {
+			t.Errorf("held comment must be enqueued in transaction")
+		}
+		return fmt.Errorf("error")
+	})
+	_, err = testRepo.AddComment(context.Background(), "comment", author, original.ID.Hex(), AnyVersion)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+	if testSession.committed || !testSession.ended {
+		t.Errorf("transaction must be rolled back and session ended")
+		return
+	}
 }
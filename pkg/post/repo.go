@@ -1,39 +1,109 @@
 package post
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
 	"sync"
 	"time"
 
+	"reddit/pkg/automod"
 	"reddit/pkg/comment"
 	"reddit/pkg/idgenerator"
+	"reddit/pkg/markdown"
+	"reddit/pkg/preview"
+	"reddit/pkg/realtime"
 	"reddit/pkg/user"
 	"reddit/pkg/vote"
 )
 
+// UnitOfWork - несколько записей, в том числе в разные коллекции, одной транзакцией
+type UnitOfWork interface {
+	InTransaction(ctx context.Context, work func(ctx context.Context) error) error
+}
+
 type PostDBRepository interface {
-	IncreasePostViewsDB(post *Post, postID string) error
-	GetPostByCategoryDB(postOfCurrentCategory []*Post, category string) ([]*Post, error)
-	AddPostDB(post *Post) error
-	GetAllPostsDB(allPosts []*Post) ([]*Post, error)
-	AddCommentDB(post *Post, postID string) error
-	DeleteCommentDB(postWithCommentToDelete *Post, postID string) error
-	GetPostByIDDB(postID string) (*Post, error)
-	SetPostDB(postToSet *Post, postID string) error
-	GetPostByUsernameDB(userName string) ([]*Post, error)
-	DeletePostDB(postID string) (bool, error)
+	UnitOfWork
+	IncreasePostViewsDB(ctx context.Context, post *Post, postID string) error
+	GetPostByCategoryDB(ctx context.Context, postOfCurrentCategory []*Post, category string, filter *ListFilter) ([]*Post, error)
+	AddPostDB(ctx context.Context, post *Post, event *DomainEvent) error
+	GetAllPostsDB(ctx context.Context, allPosts []*Post, filter *ListFilter) ([]*Post, error)
+	AddCommentDB(ctx context.Context, post *Post, postID string, event *DomainEvent) error
+	DeleteCommentDB(ctx context.Context, postWithCommentToDelete *Post, postID string, event *DomainEvent) error
+	GetPostByIDDB(ctx context.Context, postID string) (*Post, error)
+	SetPostDB(ctx context.Context, postToSet *Post, postID string, event *DomainEvent) error
+	GetPostByUsernameDB(ctx context.Context, userName string, filter *ListFilter) ([]*Post, error)
+	DeletePostDB(ctx context.Context, postID, deletedBy string, version int, event *DomainEvent) (bool, error)
+	RestorePostDB(ctx context.Context, postID string, version int) error
+	PurgeDeletedDB(ctx context.Context, deletedBefore time.Time) (int64, error)
+	SetPostStatusDB(ctx context.Context, postID, status string, version int, event *DomainEvent) error
+	SetCommentsDB(ctx context.Context, post *Post, postID string, event *DomainEvent) error
+	SetMarksDB(ctx context.Context, postID string, nsfw, spoiler bool, version int) error
+	AddPollVoteDB(ctx context.Context, postID, userID string, optionIndex, version int, pollVote *PollVote) (bool, error)
+	SetPreviewDB(ctx context.Context, postID string, linkPreview *preview.Preview) error
+	FindDuplicateDB(ctx context.Context, category, normalizedURL string, since time.Time) (*Post, error)
+	GetPostsByIDsDB(ctx context.Context, postIDs []string) ([]*Post, error)
+	SetLockedDB(ctx context.Context, postID string, locked bool, version int) error
 }
 
+// DefaultRetention - сколько удаленный пост можно восстановить, после этого он удаляется насовсем
+const DefaultRetention = 30 * 24 * time.Hour
+
+// AnyVersion - правка без If-Match: при конфликте пост перечитывается, и правка повторяется
+const AnyVersion = -1
+
+const maxConflictRetries = 5
+
+// jobTimeout - срок одного прохода фоновой задачи
+const jobTimeout = time.Minute
+
 type PostBusinessLogic struct {
-	mu          *sync.RWMutex
-	PostDBRepo  PostDBRepository
+	mu         *sync.RWMutex
+	PostDBRepo PostDBRepository
+	Recorder   ActionRecorder
+	AutoMod    ContentModerator
+	Queue      ModerationQueue
+	Authors    AuthorRegistry
+	Spam       SpamScorer
+	SpamLimit  float64
+	Previews   LinkPreviewer
+	Notifier   Notifier
+	Events     EventPublisher
+	// DuplicateWindow - за какой срок ищутся дубли ссылок, 0 - не искать
+	DuplicateWindow time.Duration
+	// RejectDuplicates - отклонять дубли, иначе пост создается с пометкой duplicateOf
+	RejectDuplicates bool
+	// ArchiveAfter - с какого возраста пост архивируется, 0 - никогда
+	ArchiveAfter   time.Duration
+	OnPreviewError func(err error)
+	// OnNotifyError - ошибки уведомлений не отменяют уже сохраненный пост или комментарий
+	OnNotifyError func(err error)
+	OnEventError  func(err error)
+	Retention     time.Duration
+	// Jobs - корневой контекст фоновых задач, main отменяет его при остановке
+	Jobs        context.Context
 	generatorID idgenerator.IDGenerator
 }
 
 func NewPostBusinessLogic(repo PostDBRepository, idGenerator idgenerator.IDGenerator) *PostBusinessLogic {
 	return &PostBusinessLogic{
-		PostDBRepo:  repo,
-		mu:          &sync.RWMutex{},
-		generatorID: idGenerator,
+		PostDBRepo:       repo,
+		Recorder:         nopActionRecorder{},
+		AutoMod:          nopContentModerator{},
+		Queue:            nopModerationQueue{},
+		Authors:          nopAuthorRegistry{},
+		Spam:             nopSpamScorer{},
+		Previews:         nopLinkPreviewer{},
+		Notifier:         nopNotifier{},
+		Events:           nopEventPublisher{},
+		DuplicateWindow:  DefaultDuplicateWindow,
+		RejectDuplicates: true,
+		SpamLimit:        1,
+		Retention:        DefaultRetention,
+		Jobs:             context.Background(),
+		mu:               &sync.RWMutex{},
+		generatorID:      idGenerator,
 	}
 }
 
@@ -43,232 +113,832 @@ func getTimeOfCreation() string {
 
 }
 
-func (p *PostBusinessLogic) GetAll() ([]*Post, error) {
+func (p *PostBusinessLogic) GetAll(ctx context.Context, filter *ListFilter) ([]*Post, error) {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 	allPosts := make([]*Post, 0)
-	allPosts, err := p.PostDBRepo.GetAllPostsDB(allPosts)
+	allPosts, err := p.PostDBRepo.GetAllPostsDB(ctx, allPosts, filter)
 	if err != nil {
 		return nil, err
 	}
-	return allPosts, nil
+	return blurNSFW(renderMarkdown(p.markArchived(allPosts...)...), filter), nil
 }
 
-func (p *PostBusinessLogic) AddPost(post *Post, author *user.User) (*Post, error) {
+func (p *PostBusinessLogic) AddPost(ctx context.Context, post *Post, author *user.User) (*Post, error) {
 	post.Author = author
 	post.Votes = make([]*vote.Vote, 0, 1)
 	post.Votes = append(post.Votes, &vote.Vote{
 		Value:  1,
 		UserID: author.ID,
 	})
-	if post.Type == "text" {
+	switch post.Type {
+	case "text":
+		post.URL = ""
+		post.Image = nil
+	case "image":
 		post.URL = ""
-	} else {
 		post.Text = ""
+	case "poll":
+		post.URL = ""
+		post.Image = nil
+		post.Poll.prepare()
+	default:
+		post.Text = ""
+		post.Image = nil
+	}
+	if post.Type != "poll" {
+		post.Poll = nil
+	}
+	post.Preview = nil
+	post.DuplicateOf = ""
+	post.TextHTML = markdown.Render(post.Text)
+	// закрыть ветку может только модератор
+	post.Locked = false
+	// новый пост не бывает удаленным, иначе его сразу отдавали бы заглушкой и вычищали
+	post.DeletedAt = nil
+	post.DeletedBy = ""
+	if post.URL != "" {
+		post.URL = NormalizeURL(post.URL)
+	}
+	post.Tags = normalizeTags(post.Tags)
+	decision, err := p.AutoMod.Evaluate(&automod.Content{
+		Kind:     automod.KindPost,
+		Category: post.Category,
+		Title:    post.Title,
+		Body:     post.Text,
+		URL:      post.URL,
+		Author:   &authorStats{ctx: ctx, postRepo: p, author: author},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decision.Reject {
+		return nil, &RejectedError{Message: decision.Message}
+	}
+	if post.URL != "" && p.DuplicateWindow > 0 {
+		original, errDuplicate := p.PostDBRepo.FindDuplicateDB(ctx, post.Category, post.URL, time.Now().Add(-p.DuplicateWindow))
+		switch {
+		case errDuplicate == nil && p.RejectDuplicates:
+			return nil, &DuplicateError{Original: original}
+		case errDuplicate == nil:
+			post.DuplicateOf = original.ID.Hex()
+		case !errors.Is(errDuplicate, ErrNoPost):
+			return nil, errDuplicate
+		}
+	}
+	if decision.Flair != "" {
+		post.Flair = decision.Flair
+	}
+	post.FlairColor = ""
+	if flair := findFlair(post.Category, post.Flair); flair != nil {
+		post.FlairColor = flair.Color
+	}
+	post.SpamScore, err = p.Spam.Score(post.TextOf(""))
+	if err != nil {
+		return nil, err
+	}
+	isSpam := post.SpamScore >= p.SpamLimit
+	post.Status = ""
+	if decision.Hold || isSpam {
+		post.Status = StatusHeld
 	}
 	post.Views = 0
 	post.Comments = make([]*comment.Comment, 0)
+	post.HeldComments = nil
 	post.Created = getTimeOfCreation()
 	post.UpvotePercentage = 100
 	post.Score = 1
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	err := p.PostDBRepo.AddPostDB(post)
+	post.Version = 1
+	// скрытый пост без записи в очереди никто бы не увидел, поэтому они пишутся вместе
+	err = p.PostDBRepo.InTransaction(ctx, func(ctx context.Context) error {
+		p.mu.Lock()
+		err := p.PostDBRepo.AddPostDB(ctx, post, p.postCreated(post))
+		p.mu.Unlock()
+		if err != nil || !(decision.Hold || decision.Report || isSpam) {
+			return err
+		}
+		return p.Queue.Enqueue(ctx, &QueueRequest{
+			Target:    post,
+			Reason:    queueReason(decision, isSpam),
+			Held:      post.Status == StatusHeld,
+			SpamScore: post.SpamScore,
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
+	renderMarkdown(post)
+	if post.Status != StatusHeld {
+		p.notify(p.Notifier.NotifyPost(ctx, post))
+		p.publish(post, EventPostAdded, post)
+	}
+	if post.URL != "" {
+		// страницу качаем в фоне, превью появится в выдаче, когда загрузится
+		go p.attachPreview(post.ID.Hex(), post.URL)
+	}
 	return post, nil
 }
 
-func (p *PostBusinessLogic) GetPostByCategory(category string) ([]*Post, error) {
+// attachPreview - запрос, создавший пост, к этому времени уже завершен, поэтому контекст свой
+func (p *PostBusinessLogic) attachPreview(postID, rawURL string) {
+	ctx, cancel := context.WithTimeout(p.Jobs, jobTimeout)
+	defer cancel()
+	linkPreview, err := p.Previews.Fetch(ctx, rawURL)
+	if err == nil && linkPreview != nil && !linkPreview.Empty() {
+		err = p.PostDBRepo.SetPreviewDB(ctx, postID, linkPreview)
+	}
+	if err != nil && p.OnPreviewError != nil {
+		p.OnPreviewError(fmt.Errorf("preview of post %s: %w", postID, err))
+	}
+}
+
+// update применяет change к посту, change сам пишет его в базу. При конфликте версий пост перечитывается
+// и change повторяется на свежей копии, а если клиент ждал конкретную версию - отдается ErrPreconditionFailed
+func (p *PostBusinessLogic) update(ctx context.Context, target *Post, version int, change func(target *Post) error) (*Post, error) {
+	for attempt := 1; ; attempt++ {
+		err := checkVersion(target, version)
+		if err != nil {
+			return nil, err
+		}
+		err = change(target)
+		if err == nil {
+			return target, nil
+		}
+		if !errors.Is(err, ErrVersionConflict) || attempt == maxConflictRetries {
+			return nil, err
+		}
+		target, err = p.FindPostByID(ctx, target.ID.Hex())
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// checkVersion - ErrPreconditionFailed, если клиент правил не ту версию, что сейчас в базе
+func checkVersion(target *Post, version int) error {
+	if version != AnyVersion && target.Version != version {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+func (p *PostBusinessLogic) notify(err error) {
+	if err != nil && p.OnNotifyError != nil {
+		p.OnNotifyError(fmt.Errorf("notification: %w", err))
+	}
+}
+
+// publish - событие получают подписчики и самого поста, и его категории.
+// Это best-effort, надежные побочные эффекты идут через outbox
+func (p *PostBusinessLogic) publish(target *Post, kind string, data interface{}) {
+	for _, channel := range []string{realtime.PostChannel(target.ID.Hex()), realtime.CategoryChannel(target.Category)} {
+		err := p.Events.Publish(channel, kind, data)
+		if err != nil && p.OnEventError != nil {
+			p.OnEventError(fmt.Errorf("event %s to %s: %w", kind, channel, err))
+		}
+	}
+}
+
+func (p *PostBusinessLogic) publishVote(votedPost *Post) {
+	p.publish(votedPost, EventVote, newVoteEvent(votedPost))
+}
+
+// postCreated - скрытый пост еще никто не видел, событие о нем будет при одобрении
+func (p *PostBusinessLogic) postCreated(newPost *Post) *DomainEvent {
+	if newPost.Status == StatusHeld {
+		return nil
+	}
+	return NewDomainEvent(DomainPostCreated, newPost.Category, newPost)
+}
+
+func voteCast(votedPost *Post) *DomainEvent {
+	return NewDomainEvent(DomainVoteCast, votedPost.Category, newVoteEvent(votedPost))
+}
+
+func newVoteEvent(votedPost *Post) *VoteEvent {
+	return &VoteEvent{
+		PostID:           votedPost.ID.Hex(),
+		Score:            votedPost.Score,
+		UpvotePercentage: votedPost.UpvotePercentage,
+	}
+}
+
+func (p *PostBusinessLogic) GetPostByCategory(ctx context.Context, category string, filter *ListFilter) ([]*Post, error) {
 	postOfCurrentCategory := make([]*Post, 0)
 	p.mu.RLock()
 	defer p.mu.RUnlock()
-	postOfCurrentCategory, err := p.PostDBRepo.GetPostByCategoryDB(postOfCurrentCategory, category)
+	postOfCurrentCategory, err := p.PostDBRepo.GetPostByCategoryDB(ctx, postOfCurrentCategory, category, filter)
 	if err != nil {
 		return nil, err
 	}
-	return postOfCurrentCategory, nil
+	return blurNSFW(renderMarkdown(p.markArchived(postOfCurrentCategory...)...), filter), nil
 }
 
-func (p *PostBusinessLogic) GetPostByID(id string) (*Post, error) {
-	post, err := p.findPostByID(id)
+// GetPostByID - отложенный модерацией пост до одобрения видят только автор и модераторы, viewer nil - аноним
+func (p *PostBusinessLogic) GetPostByID(ctx context.Context, id string, viewer *user.User, isModerator bool) (*Post, error) {
+	post, err := p.getPost(ctx, id)
 	if err != nil {
 		return nil, err
 	}
+	if post.Status == StatusHeld && !isModerator && (viewer == nil || post.Author == nil || post.Author.ID != viewer.ID) {
+		return nil, ErrNoPost
+	}
+	if post.DeletedAt != nil {
+		return post.Tombstone(), nil
+	}
 	post.Views++
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	err = p.PostDBRepo.IncreasePostViewsDB(post, id)
+	err = p.PostDBRepo.IncreasePostViewsDB(ctx, post, id)
 	if err != nil {
 		return nil, err
 	}
 	return post, nil
 }
 
-func (p *PostBusinessLogic) AddComment(commentBody string, author *user.User, postID string) (*Post, error) {
-	post, err := p.findPostByID(postID)
+func (p *PostBusinessLogic) AddComment(ctx context.Context, commentBody string, author *user.User, postID string, version int) (*Post, error) {
+	post, err := p.FindPostByID(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkOpen(post); err != nil {
+		return nil, err
+	}
+	decision, err := p.AutoMod.Evaluate(&automod.Content{
+		Kind:     automod.KindComment,
+		Category: post.Category,
+		Body:     commentBody,
+		Author:   &authorStats{ctx: ctx, postRepo: p, author: author},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decision.Reject {
+		return nil, &RejectedError{Message: decision.Message}
+	}
+	spamScore, err := p.Spam.Score(commentBody)
 	if err != nil {
 		return nil, err
 	}
+	isSpam := spamScore >= p.SpamLimit
 	newComment := &comment.Comment{
-		Created: getTimeOfCreation(),
-		Author:  author,
-		Body:    commentBody,
-		ID:      p.generatorID.GenerateID(16),
+		Created:   getTimeOfCreation(),
+		Author:    author,
+		Body:      commentBody,
+		BodyHTML:  markdown.Render(commentBody),
+		ID:        p.generatorID.GenerateID(16),
+		SpamScore: spamScore,
 	}
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	post.Comments = append(post.Comments, newComment)
-	err = p.PostDBRepo.AddCommentDB(post, postID)
+	held := decision.Hold || isSpam
+	post, err = p.update(ctx, post, version, func(post *Post) error {
+		if err := checkOpen(post); err != nil {
+			return err
+		}
+		if held {
+			post.HeldComments = append(post.HeldComments, newComment)
+		} else {
+			post.Comments = append(post.Comments, newComment)
+		}
+		// как и в AddPost, скрытый коммент без записи в очереди никто бы не увидел
+		return p.PostDBRepo.InTransaction(ctx, func(ctx context.Context) error {
+			var err error
+			p.mu.Lock()
+			if held {
+				err = p.PostDBRepo.SetCommentsDB(ctx, post, postID, nil)
+			} else {
+				err = p.PostDBRepo.AddCommentDB(ctx, post, postID, NewDomainEvent(DomainCommentAdded, post.Category, &CommentEvent{PostID: postID, Comment: newComment}))
+			}
+			p.mu.Unlock()
+			if err != nil || !(held || decision.Report) {
+				return err
+			}
+			return p.Queue.Enqueue(ctx, &QueueRequest{
+				Target:    post,
+				CommentID: newComment.ID,
+				Reason:    queueReason(decision, isSpam),
+				Held:      held,
+				SpamScore: spamScore,
+			})
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
+	renderMarkdown(post)
+	if !held {
+		p.notify(p.Notifier.NotifyComment(ctx, post, newComment))
+		p.publish(post, EventCommentAdded, &CommentEvent{PostID: postID, Comment: newComment})
+	}
 	return post, nil
 }
 
-func (p *PostBusinessLogic) DeleteComment(userID, postID, commentID string) (*Post, error) {
-	postWithCommentToDelete, err := p.findPostByID(postID)
+func (p *PostBusinessLogic) DeleteComment(ctx context.Context, userID, postID, commentID string, version int) (*Post, error) {
+	postWithCommentToDelete, err := p.FindPostByID(ctx, postID)
 	if err != nil {
 		return nil, ErrNoPost
 	}
+	commentToDelete := findComment(postWithCommentToDelete, commentID)
+	if commentToDelete == nil {
+		return nil, ErrNoComment
+	}
+	if commentToDelete.Author.ID != userID {
+		return nil, ErrNoAccess
+	}
+	return p.deleteComment(ctx, postWithCommentToDelete, postID, commentID, commentToDelete.Author, ActionDeleteComment, "", version)
+}
+
+func (p *PostBusinessLogic) RemoveComment(ctx context.Context, moderator *user.User, postID, commentID, reason string, version int) (*Post, error) {
+	postWithCommentToRemove, err := p.FindPostByID(ctx, postID)
+	if err != nil {
+		return nil, ErrNoPost
+	}
+	if findComment(postWithCommentToRemove, commentID) == nil {
+		return nil, ErrNoComment
+	}
+	return p.deleteComment(ctx, postWithCommentToRemove, postID, commentID, moderator, ActionRemoveComment, reason, version)
+}
+
+// deleteComment - коммент удаляется вместе с записью в журнал одной транзакцией, так что в журнале нет неудавшихся удалений
+func (p *PostBusinessLogic) deleteComment(ctx context.Context, postWithCommentToDelete *Post, postID, commentID string, actor *user.User, action, reason string, version int) (*Post, error) {
+	visible := false
+	postWithCommentToDelete, err := p.update(ctx, postWithCommentToDelete, version, func(postWithCommentToDelete *Post) error {
+		return p.PostDBRepo.InTransaction(ctx, func(ctx context.Context) error {
+			var err error
+			visible, err = p.removeComment(ctx, postWithCommentToDelete, postID, commentID)
+			if err != nil {
+				return err
+			}
+			return p.Recorder.RecordAction(ctx, actor, action, postID, commentID, postWithCommentToDelete.Category, reason)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if visible {
+		p.publish(postWithCommentToDelete, EventCommentDeleted, &CommentEvent{PostID: postID, CommentID: commentID})
+	}
+	return postWithCommentToDelete, nil
+}
+
+// removeComment убирает коммент из поста, visible - был ли он опубликован
+func (p *PostBusinessLogic) removeComment(ctx context.Context, postWithCommentToDelete *Post, postID, commentID string) (bool, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	for i, currentComment := range postWithCommentToDelete.Comments {
 		if currentComment.ID == commentID {
-			if currentComment.Author.ID != userID {
-				return nil, ErrNoAccess
-			}
 			postWithCommentToDelete.Comments = append(postWithCommentToDelete.Comments[:i], postWithCommentToDelete.Comments[i+1:]...)
-			err = p.PostDBRepo.DeleteCommentDB(postWithCommentToDelete, postID)
-			if err != nil {
-				return nil, err
+			return true, p.PostDBRepo.DeleteCommentDB(ctx, postWithCommentToDelete, postID, NewDomainEvent(DomainCommentDeleted, postWithCommentToDelete.Category, &CommentEvent{PostID: postID, CommentID: commentID}))
+		}
+	}
+	for i, currentComment := range postWithCommentToDelete.HeldComments {
+		if currentComment.ID == commentID {
+			postWithCommentToDelete.HeldComments = append(postWithCommentToDelete.HeldComments[:i], postWithCommentToDelete.HeldComments[i+1:]...)
+			return false, p.PostDBRepo.SetCommentsDB(ctx, postWithCommentToDelete, postID, nil)
+		}
+	}
+	return false, ErrNoComment
+}
+
+func (p *PostBusinessLogic) ApproveHeld(ctx context.Context, postID, commentID string, version int) (*Post, error) {
+	heldPost, err := p.FindPostByID(ctx, postID)
+	if err != nil {
+		return nil, ErrNoPost
+	}
+	if commentID == "" {
+		return p.approveHeldPost(ctx, heldPost, version)
+	}
+	var approved *comment.Comment
+	heldPost, err = p.update(ctx, heldPost, version, func(heldPost *Post) error {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		approved = nil
+		for i, currentComment := range heldPost.HeldComments {
+			if currentComment.ID == commentID {
+				heldPost.HeldComments = append(heldPost.HeldComments[:i], heldPost.HeldComments[i+1:]...)
+				heldPost.Comments = append(heldPost.Comments, currentComment)
+				approved = currentComment
+				return p.PostDBRepo.SetCommentsDB(ctx, heldPost, postID, NewDomainEvent(DomainCommentAdded, heldPost.Category, &CommentEvent{PostID: postID, Comment: currentComment}))
 			}
-			return postWithCommentToDelete, nil
 		}
+		// уже одобрен кем-то еще
+		if findComment(heldPost, commentID) != nil {
+			return nil
+		}
+		return ErrNoComment
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil, ErrNoComment
+	if approved != nil {
+		renderMarkdown(heldPost)
+		p.notify(p.Notifier.NotifyComment(ctx, heldPost, approved))
+		p.publish(heldPost, EventCommentAdded, &CommentEvent{PostID: postID, Comment: approved})
+	}
+	return heldPost, nil
+}
 
+func (p *PostBusinessLogic) approveHeldPost(ctx context.Context, heldPost *Post, version int) (*Post, error) {
+	postID := heldPost.ID.Hex()
+	approved := false
+	heldPost, err := p.update(ctx, heldPost, version, func(heldPost *Post) error {
+		approved = false
+		// уже одобрен кем-то еще
+		if heldPost.Status != StatusHeld {
+			return nil
+		}
+		heldPost.Status = ""
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		err := p.PostDBRepo.SetPostStatusDB(ctx, postID, "", heldPost.Version, NewDomainEvent(DomainPostCreated, heldPost.Category, heldPost))
+		if err != nil {
+			return err
+		}
+		heldPost.Version++
+		approved = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if approved {
+		p.notify(p.Notifier.NotifyPost(ctx, heldPost))
+		p.publish(heldPost, EventPostAdded, heldPost)
+	}
+	return heldPost, nil
 }
 
-func (p *PostBusinessLogic) UpVote(postID string, userID string) (*Post, error) {
-	postToUpvote, err := p.findPostByID(postID)
+func queueReason(decision *automod.Decision, isSpam bool) string {
+	if len(decision.Rules) == 0 {
+		return "spam filter"
+	}
+	if isSpam {
+		return decision.Reason() + "; spam filter"
+	}
+	return decision.Reason()
+}
+
+func findComment(post *Post, commentID string) *comment.Comment {
+	for _, currentComment := range post.Comments {
+		if currentComment.ID == commentID {
+			return currentComment
+		}
+	}
+	for _, currentComment := range post.HeldComments {
+		if currentComment.ID == commentID {
+			return currentComment
+		}
+	}
+	return nil
+}
+
+func (p *PostBusinessLogic) UpVote(ctx context.Context, postID string, userID string, version int) (*Post, error) {
+	postToUpvote, err := p.FindPostByID(ctx, postID)
 	if err != nil {
 		return nil, err
 	}
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	for _, currentVote := range postToUpvote.Votes {
-		if currentVote.UserID == userID {
-			if currentVote.Value == -1 {
+	changed := false
+	postToUpvote, err = p.update(ctx, postToUpvote, version, func(postToUpvote *Post) error {
+		if err := checkOpen(postToUpvote); err != nil {
+			return err
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		changed = false
+		for _, currentVote := range postToUpvote.Votes {
+			if currentVote.UserID == userID {
+				if currentVote.Value != -1 {
+					return nil
+				}
 				currentVote.Value = 1
 				postToUpvote.Score += 2
 				postToUpvote.UpvotePercentage = countUpVotePercentage(postToUpvote)
-				err = p.PostDBRepo.SetPostDB(postToUpvote, postID)
-				if err != nil {
-					return nil, err
-				}
+				changed = true
+				return p.PostDBRepo.SetPostDB(ctx, postToUpvote, postID, voteCast(postToUpvote))
 			}
-			return postToUpvote, nil
 		}
-	}
-	postToUpvote.Votes = append(postToUpvote.Votes, vote.NewVote(1, userID))
-	postToUpvote.Score++
-	postToUpvote.UpvotePercentage = countUpVotePercentage(postToUpvote)
-	err = p.PostDBRepo.SetPostDB(postToUpvote, postID)
+		postToUpvote.Votes = append(postToUpvote.Votes, vote.NewVote(1, userID))
+		postToUpvote.Score++
+		postToUpvote.UpvotePercentage = countUpVotePercentage(postToUpvote)
+		changed = true
+		return p.PostDBRepo.SetPostDB(ctx, postToUpvote, postID, voteCast(postToUpvote))
+	})
 	if err != nil {
 		return nil, err
 	}
+	if changed {
+		p.publishVote(postToUpvote)
+	}
 	return postToUpvote, nil
 
 }
 
-func (p *PostBusinessLogic) DownVote(postID string, userID string) (*Post, error) {
-	postToDownvote, err := p.findPostByID(postID)
+func (p *PostBusinessLogic) DownVote(ctx context.Context, postID string, userID string, version int) (*Post, error) {
+	postToDownvote, err := p.FindPostByID(ctx, postID)
 	if err != nil {
 		return nil, err
 	}
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	for _, currentVote := range postToDownvote.Votes {
-		if currentVote.UserID == userID {
-			if currentVote.Value == 1 {
+	changed := false
+	postToDownvote, err = p.update(ctx, postToDownvote, version, func(postToDownvote *Post) error {
+		if err := checkOpen(postToDownvote); err != nil {
+			return err
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		changed = false
+		for _, currentVote := range postToDownvote.Votes {
+			if currentVote.UserID == userID {
+				if currentVote.Value != 1 {
+					return nil
+				}
 				postToDownvote.Score -= 2
 				currentVote.Value = -1
 				postToDownvote.UpvotePercentage = countUpVotePercentage(postToDownvote)
-				err = p.PostDBRepo.SetPostDB(postToDownvote, postID)
-
-				if err != nil {
-					return nil, err
-				}
+				changed = true
+				return p.PostDBRepo.SetPostDB(ctx, postToDownvote, postID, voteCast(postToDownvote))
 			}
-			return postToDownvote, nil
 		}
-	}
-	postToDownvote.Votes = append(postToDownvote.Votes, vote.NewVote(-1, userID))
-	postToDownvote.Score--
-	postToDownvote.UpvotePercentage = countUpVotePercentage(postToDownvote)
-	err = p.PostDBRepo.SetPostDB(postToDownvote, postID)
+		postToDownvote.Votes = append(postToDownvote.Votes, vote.NewVote(-1, userID))
+		postToDownvote.Score--
+		postToDownvote.UpvotePercentage = countUpVotePercentage(postToDownvote)
+		changed = true
+		return p.PostDBRepo.SetPostDB(ctx, postToDownvote, postID, voteCast(postToDownvote))
+	})
 	if err != nil {
 		return nil, err
 	}
+	if changed {
+		p.publishVote(postToDownvote)
+	}
 	return postToDownvote, nil
 
 }
 
-func (p *PostBusinessLogic) UnVote(postID string, userID string) (*Post, error) {
-	postToUnvote, err := p.findPostByID(postID)
+func (p *PostBusinessLogic) UnVote(ctx context.Context, postID string, userID string, version int) (*Post, error) {
+	postToUnvote, err := p.FindPostByID(ctx, postID)
 	if err != nil {
 		return nil, ErrNoPost
 	}
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	for i, currentVote := range postToUnvote.Votes {
-		if currentVote.UserID == userID {
-			postToUnvote.Votes = append(postToUnvote.Votes[:i], postToUnvote.Votes[i+1:]...)
-			if len(postToUnvote.Votes) == 0 {
-				postToUnvote.Score = 0
-				postToUnvote.UpvotePercentage = 0
-			} else {
-				if currentVote.Value == 1 {
-					postToUnvote.Score--
+	changed := false
+	postToUnvote, err = p.update(ctx, postToUnvote, version, func(postToUnvote *Post) error {
+		if err := checkOpen(postToUnvote); err != nil {
+			return err
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		changed = false
+		for i, currentVote := range postToUnvote.Votes {
+			if currentVote.UserID == userID {
+				postToUnvote.Votes = append(postToUnvote.Votes[:i], postToUnvote.Votes[i+1:]...)
+				if len(postToUnvote.Votes) == 0 {
+					postToUnvote.Score = 0
+					postToUnvote.UpvotePercentage = 0
 				} else {
-					postToUnvote.Score++
+					if currentVote.Value == 1 {
+						postToUnvote.Score--
+					} else {
+						postToUnvote.Score++
+					}
+					postToUnvote.UpvotePercentage = countUpVotePercentage(postToUnvote)
 				}
-				postToUnvote.UpvotePercentage = countUpVotePercentage(postToUnvote)
-			}
-			err = p.PostDBRepo.SetPostDB(postToUnvote, postID)
-			if err != nil {
-				return nil, err
+				changed = true
+				return p.PostDBRepo.SetPostDB(ctx, postToUnvote, postID, voteCast(postToUnvote))
 			}
-			return postToUnvote, nil
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if changed {
+		p.publishVote(postToUnvote)
 	}
 	return postToUnvote, nil
 
 }
 
-func (p *PostBusinessLogic) DeletePost(userID, postID string) (bool, error) {
-	postToDelete, err := p.findPostByID(postID)
+func (p *PostBusinessLogic) DeletePost(ctx context.Context, userID, postID string, version int) (bool, error) {
+	postToDelete, err := p.FindPostByID(ctx, postID)
 	if err != nil {
 		return false, ErrNoPost
 	}
 	if postToDelete.Author.ID != userID {
 		return false, ErrNoAccess
 	}
+	if err = checkVersion(postToDelete, version); err != nil {
+		return false, err
+	}
+	return p.deletePost(ctx, postToDelete, postToDelete.Author, ActionDeletePost, "", version)
+}
+
+func (p *PostBusinessLogic) RemovePost(ctx context.Context, moderator *user.User, postID, reason string, version int) (bool, error) {
+	postToRemove, err := p.FindPostByID(ctx, postID)
+	if err != nil {
+		return false, ErrNoPost
+	}
+	if err = checkVersion(postToRemove, version); err != nil {
+		return false, err
+	}
+	return p.deletePost(ctx, postToRemove, moderator, ActionRemovePost, reason, version)
+}
+
+// deletePost - пост, жалобы на него, уведомления о нем и запись в журнал пишутся одной транзакцией
+func (p *PostBusinessLogic) deletePost(ctx context.Context, postToDelete *Post, actor *user.User, action, reason string, version int) (bool, error) {
+	postID := postToDelete.ID.Hex()
+	deleted := false
+	_, err := p.update(ctx, postToDelete, version, func(postToDelete *Post) error {
+		return p.PostDBRepo.InTransaction(ctx, func(ctx context.Context) error {
+			var err error
+			p.mu.Lock()
+			deleted, err = p.PostDBRepo.DeletePostDB(ctx, postID, actor.ID, postToDelete.Version, NewDomainEvent(DomainPostDeleted, postToDelete.Category, &CommentEvent{PostID: postID}))
+			p.mu.Unlock()
+			if err != nil || !deleted {
+				return err
+			}
+			err = p.Recorder.RecordAction(ctx, actor, action, postID, "", postToDelete.Category, reason)
+			if err != nil {
+				return err
+			}
+			err = p.Queue.DropPost(ctx, postID)
+			if err != nil {
+				return err
+			}
+			return p.Notifier.DropPost(ctx, postID)
+		})
+	})
+	if err != nil {
+		return false, err
+	}
+	if deleted {
+		p.publish(postToDelete, EventPostDeleted, &CommentEvent{PostID: postID})
+	}
+	return deleted, nil
+}
+
+func (p *PostBusinessLogic) RestorePost(ctx context.Context, actor *user.User, postID string, isModerator bool, version int) (*Post, error) {
+	postToRestore, err := p.getPost(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkVersion(postToRestore, version); err != nil {
+		return nil, err
+	}
+	if postToRestore.DeletedAt == nil {
+		return postToRestore, nil
+	}
+	// автор может восстановить только то, что удалил сам, а не то, что удалил модератор
+	if !isModerator && (postToRestore.Author.ID != actor.ID || postToRestore.DeletedBy != actor.ID) {
+		return nil, ErrNoAccess
+	}
+	if time.Since(*postToRestore.DeletedAt) > p.Retention {
+		return nil, ErrExpired
+	}
+	err = p.PostDBRepo.InTransaction(ctx, func(ctx context.Context) error {
+		p.mu.Lock()
+		err := p.PostDBRepo.RestorePostDB(ctx, postID, postToRestore.Version)
+		p.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		return p.Recorder.RecordAction(ctx, actor, ActionRestorePost, postID, "", postToRestore.Category, "")
+	})
+	// удаленный пост не перечитать через update, поэтому без If-Match гонка отдается клиенту как конфликт
+	if errors.Is(err, ErrVersionConflict) && version != AnyVersion {
+		return nil, ErrPreconditionFailed
+	}
+	if err != nil {
+		return nil, err
+	}
+	postToRestore.DeletedAt = nil
+	postToRestore.DeletedBy = ""
+	postToRestore.Version++
+	return postToRestore, nil
+}
+
+func (p *PostBusinessLogic) PurgeDeleted(ctx context.Context) (int64, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	return p.PostDBRepo.DeletePostDB(postID)
+	return p.PostDBRepo.PurgeDeletedDB(ctx, time.Now().Add(-p.Retention))
 }
 
-func (p *PostBusinessLogic) GetPostsByUserID(userName string) ([]*Post, error) {
+// RunPurge раз в interval окончательно удаляет посты, у которых истек срок восстановления, пока не отменят ctx
+func (p *PostBusinessLogic) RunPurge(ctx context.Context, interval time.Duration, onError func(err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purgeCtx, cancel := context.WithTimeout(ctx, jobTimeout)
+			_, err := p.PurgeDeleted(purgeCtx)
+			cancel()
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+func (p *PostBusinessLogic) GetPostsByUserID(ctx context.Context, userName string, filter *ListFilter) ([]*Post, error) {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
-	userPosts, err := p.PostDBRepo.GetPostByUsernameDB(userName)
+	userPosts, err := p.PostDBRepo.GetPostByUsernameDB(ctx, userName, filter)
+	if err != nil {
+		return nil, err
+	}
+	return blurNSFW(renderMarkdown(p.markArchived(userPosts...)...), filter), nil
+}
+
+// SetMarks - version из If-Match или AnyVersion
+func (p *PostBusinessLogic) SetMarks(ctx context.Context, actor *user.User, postID string, isModerator bool, marks *Marks, version int) (*Post, error) {
+	postToMark, err := p.FindPostByID(ctx, postID)
 	if err != nil {
 		return nil, err
 	}
-	return userPosts, nil
+	isAuthor := postToMark.Author.ID == actor.ID
+	if !isAuthor && !isModerator {
+		return nil, ErrNoAccess
+	}
+	return p.update(ctx, postToMark, version, func(postToMark *Post) error {
+		if marks.NSFW != nil {
+			postToMark.NSFW = *marks.NSFW
+		}
+		if marks.Spoiler != nil {
+			postToMark.Spoiler = *marks.Spoiler
+		}
+		err := p.PostDBRepo.InTransaction(ctx, func(ctx context.Context) error {
+			p.mu.Lock()
+			err := p.PostDBRepo.SetMarksDB(ctx, postID, postToMark.NSFW, postToMark.Spoiler, postToMark.Version)
+			p.mu.Unlock()
+			if err != nil || isAuthor {
+				return err
+			}
+			reason := fmt.Sprintf("nsfw=%t spoiler=%t", postToMark.NSFW, postToMark.Spoiler)
+			return p.Recorder.RecordAction(ctx, actor, ActionSetMarks, postID, "", postToMark.Category, reason)
+		})
+		if err != nil {
+			return err
+		}
+		postToMark.Version++
+		return nil
+	})
+}
+
+func (p *PostBusinessLogic) VotePoll(ctx context.Context, postID, userID, optionID string, version int) (*Post, error) {
+	pollPost, err := p.FindPostByID(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkVersion(pollPost, version); err != nil {
+		return nil, err
+	}
+	if err = checkOpen(pollPost); err != nil {
+		return nil, err
+	}
+	if pollPost.Poll == nil {
+		return nil, ErrNotPoll
+	}
+	poll := pollPost.Poll
+	if poll.Closed(time.Now()) {
+		return nil, ErrPollClosed
+	}
+	optionIndex := poll.optionIndex(optionID)
+	if optionIndex < 0 {
+		return nil, ErrNoPollOption
+	}
+	if poll.choiceOf(userID) != "" {
+		return nil, ErrAlreadyVoted
+	}
+	pollVote := &PollVote{UserID: userID, OptionID: optionID}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// проверка на повторный голос делается и в самом запросе к базе, на случай гонки
+	added, err := p.PostDBRepo.AddPollVoteDB(ctx, postID, userID, optionIndex, version, pollVote)
+	if err != nil {
+		return nil, err
+	}
+	// с If-Match запись могла не пройти и из-за того, что пост успели поменять
+	if !added && version != AnyVersion {
+		return nil, ErrPreconditionFailed
+	}
+	if !added {
+		return nil, ErrAlreadyVoted
+	}
+	poll.Options[optionIndex].Votes++
+	poll.Voters = append(poll.Voters, pollVote)
+	pollPost.Version++
+	return pollPost, nil
+}
+
+// blurNSFW помечает NSFW посты для размытия на клиенте, если пользователь так настроил
+func blurNSFW(posts []*Post, filter *ListFilter) []*Post {
+	if filter == nil || !filter.BlurNSFW {
+		return posts
+	}
+	for _, currentPost := range posts {
+		currentPost.Blur = currentPost.NSFW
+	}
+	return posts
 }
 
 func countUpVotePercentage(postToCount *Post) int {
@@ -281,12 +951,136 @@ func countUpVotePercentage(postToCount *Post) int {
 	return 100 * numOfUpVotes / len(postToCount.Votes)
 }
 
-func (p *PostBusinessLogic) findPostByID(id string) (*Post, error) {
+func (p *PostBusinessLogic) FindPostByID(ctx context.Context, id string) (*Post, error) {
+	post, err := p.getPost(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if post.DeletedAt != nil {
+		return nil, ErrNoPost
+	}
+	return post, nil
+}
+
+// FindPostsByIDs отдает видимые посты из списка, удаленных и несуществующих в ответе нет
+func (p *PostBusinessLogic) FindPostsByIDs(ctx context.Context, postIDs []string) ([]*Post, error) {
+	if len(postIDs) == 0 {
+		return make([]*Post, 0), nil
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	posts, err := p.PostDBRepo.GetPostsByIDsDB(ctx, postIDs)
+	if err != nil {
+		return nil, err
+	}
+	return renderMarkdown(p.markArchived(posts...)...), nil
+}
+
+func (p *PostBusinessLogic) getPost(ctx context.Context, id string) (*Post, error) {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
-	post, err := p.PostDBRepo.GetPostByIDDB(id)
+	post, err := p.PostDBRepo.GetPostByIDDB(ctx, id)
 	if err != nil {
 		return nil, err
 	}
+	p.markArchived(post)
+	renderMarkdown(post)
 	return post, nil
 }
+
+// markArchived - архивность не хранится, а считается по возрасту поста при каждом чтении
+func (p *PostBusinessLogic) markArchived(posts ...*Post) []*Post {
+	now := time.Now()
+	for _, currentPost := range posts {
+		currentPost.Archived = p.ArchiveAfter > 0 && !currentPost.ID.IsZero() &&
+			now.Sub(currentPost.ID.Timestamp()) >= p.ArchiveAfter
+	}
+	return posts
+}
+
+// renderMarkdown - html считается один раз при записи, при чтении дорисовываются только старые документы без него
+func renderMarkdown(posts ...*Post) []*Post {
+	for _, currentPost := range posts {
+		if currentPost.TextHTML == "" && currentPost.Text != "" {
+			currentPost.TextHTML = markdown.Render(currentPost.Text)
+		}
+		for _, currentComment := range currentPost.Comments {
+			if currentComment.BodyHTML == "" && currentComment.Body != "" {
+				currentComment.BodyHTML = markdown.Render(currentComment.Body)
+			}
+		}
+	}
+	return posts
+}
+
+// checkOpen - в закрытую или архивную ветку нельзя комментировать и голосовать, а отложенной до одобрения как будто нет
+func checkOpen(post *Post) error {
+	if post.Status == StatusHeld {
+		return ErrNoPost
+	}
+	if post.Locked {
+		return ErrLocked
+	}
+	if post.Archived {
+		return ErrArchived
+	}
+	return nil
+}
+
+// SetLocked - version из If-Match или AnyVersion
+func (p *PostBusinessLogic) SetLocked(ctx context.Context, moderator *user.User, postID string, locked bool, reason string, version int) (*Post, error) {
+	postToLock, err := p.FindPostByID(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	action := ActionLockPost
+	if !locked {
+		action = ActionUnlockPost
+	}
+	return p.update(ctx, postToLock, version, func(postToLock *Post) error {
+		err := p.PostDBRepo.InTransaction(ctx, func(ctx context.Context) error {
+			p.mu.Lock()
+			err := p.PostDBRepo.SetLockedDB(ctx, postID, locked, postToLock.Version)
+			p.mu.Unlock()
+			if err != nil {
+				return err
+			}
+			return p.Recorder.RecordAction(ctx, moderator, action, postID, "", postToLock.Category, reason)
+		})
+		if err != nil {
+			return err
+		}
+		postToLock.Locked = locked
+		postToLock.Version++
+		return nil
+	})
+}
+
+type authorStats struct {
+	ctx      context.Context
+	postRepo *PostBusinessLogic
+	author   *user.User
+}
+
+func (a *authorStats) AccountAge() (time.Duration, error) {
+	registered, err := a.postRepo.Authors.GetRegistrationTime(a.ctx, a.author.ID)
+	if err != nil {
+		return 0, err
+	}
+	if registered.IsZero() {
+		return time.Duration(math.MaxInt64), nil
+	}
+	return time.Since(registered), nil
+}
+
+func (a *authorStats) Karma() (int, error) {
+	authorPosts, err := a.postRepo.PostDBRepo.GetPostByUsernameDB(a.ctx, a.author.Username, nil)
+	if err != nil {
+		return 0, err
+	}
+	karma := 0
+	for _, authorPost := range authorPosts {
+		karma += authorPost.Score
+	}
+	return karma, nil
+}
@@ -5,12 +5,359 @@
 package post
 
 import (
+	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
+	automod "reddit/pkg/automod"
+	comment "reddit/pkg/comment"
+	preview "reddit/pkg/preview"
 	user "reddit/pkg/user"
 )
 
+// MockActionRecorder is a mock of ActionRecorder interface.
+type MockActionRecorder struct {
+	ctrl     *gomock.Controller
+	recorder *MockActionRecorderMockRecorder
+}
+
+// MockActionRecorderMockRecorder is the mock recorder for MockActionRecorder.
+type MockActionRecorderMockRecorder struct {
+	mock *MockActionRecorder
+}
+
+// NewMockActionRecorder creates a new mock instance.
+func NewMockActionRecorder(ctrl *gomock.Controller) *MockActionRecorder {
+	mock := &MockActionRecorder{ctrl: ctrl}
+	mock.recorder = &MockActionRecorderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockActionRecorder) EXPECT() *MockActionRecorderMockRecorder {
+	return m.recorder
+}
+
+// RecordAction mocks base method.
+func (m *MockActionRecorder) RecordAction(ctx context.Context, actor *user.User, action, postID, commentID, category, reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordAction", ctx, actor, action, postID, commentID, category, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordAction indicates an expected call of RecordAction.
+func (mr *MockActionRecorderMockRecorder) RecordAction(ctx, actor, action, postID, commentID, category, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordAction", reflect.TypeOf((*MockActionRecorder)(nil).RecordAction), ctx, actor, action, postID, commentID, category, reason)
+}
+
+// MockContentModerator is a mock of ContentModerator interface.
+type MockContentModerator struct {
+	ctrl     *gomock.Controller
+	recorder *MockContentModeratorMockRecorder
+}
+
+// MockContentModeratorMockRecorder is the mock recorder for MockContentModerator.
+type MockContentModeratorMockRecorder struct {
+	mock *MockContentModerator
+}
+
+// NewMockContentModerator creates a new mock instance.
+func NewMockContentModerator(ctrl *gomock.Controller) *MockContentModerator {
+	mock := &MockContentModerator{ctrl: ctrl}
+	mock.recorder = &MockContentModeratorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockContentModerator) EXPECT() *MockContentModeratorMockRecorder {
+	return m.recorder
+}
+
+// Evaluate mocks base method.
+func (m *MockContentModerator) Evaluate(content *automod.Content) (*automod.Decision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Evaluate", content)
+	ret0, _ := ret[0].(*automod.Decision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Evaluate indicates an expected call of Evaluate.
+func (mr *MockContentModeratorMockRecorder) Evaluate(content interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Evaluate", reflect.TypeOf((*MockContentModerator)(nil).Evaluate), content)
+}
+
+// MockModerationQueue is a mock of ModerationQueue interface.
+type MockModerationQueue struct {
+	ctrl     *gomock.Controller
+	recorder *MockModerationQueueMockRecorder
+}
+
+// MockModerationQueueMockRecorder is the mock recorder for MockModerationQueue.
+type MockModerationQueueMockRecorder struct {
+	mock *MockModerationQueue
+}
+
+// NewMockModerationQueue creates a new mock instance.
+func NewMockModerationQueue(ctrl *gomock.Controller) *MockModerationQueue {
+	mock := &MockModerationQueue{ctrl: ctrl}
+	mock.recorder = &MockModerationQueueMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockModerationQueue) EXPECT() *MockModerationQueueMockRecorder {
+	return m.recorder
+}
+
+// DropPost mocks base method.
+func (m *MockModerationQueue) DropPost(ctx context.Context, postID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DropPost", ctx, postID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DropPost indicates an expected call of DropPost.
+func (mr *MockModerationQueueMockRecorder) DropPost(ctx, postID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DropPost", reflect.TypeOf((*MockModerationQueue)(nil).DropPost), ctx, postID)
+}
+
+// Enqueue mocks base method.
+func (m *MockModerationQueue) Enqueue(ctx context.Context, request *QueueRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enqueue", ctx, request)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Enqueue indicates an expected call of Enqueue.
+func (mr *MockModerationQueueMockRecorder) Enqueue(ctx, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enqueue", reflect.TypeOf((*MockModerationQueue)(nil).Enqueue), ctx, request)
+}
+
+// MockSpamScorer is a mock of SpamScorer interface.
+type MockSpamScorer struct {
+	ctrl     *gomock.Controller
+	recorder *MockSpamScorerMockRecorder
+}
+
+// MockSpamScorerMockRecorder is the mock recorder for MockSpamScorer.
+type MockSpamScorerMockRecorder struct {
+	mock *MockSpamScorer
+}
+
+// NewMockSpamScorer creates a new mock instance.
+func NewMockSpamScorer(ctrl *gomock.Controller) *MockSpamScorer {
+	mock := &MockSpamScorer{ctrl: ctrl}
+	mock.recorder = &MockSpamScorerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSpamScorer) EXPECT() *MockSpamScorerMockRecorder {
+	return m.recorder
+}
+
+// Score mocks base method.
+func (m *MockSpamScorer) Score(text string) (float64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Score", text)
+	ret0, _ := ret[0].(float64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Score indicates an expected call of Score.
+func (mr *MockSpamScorerMockRecorder) Score(text interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Score", reflect.TypeOf((*MockSpamScorer)(nil).Score), text)
+}
+
+// MockLinkPreviewer is a mock of LinkPreviewer interface.
+type MockLinkPreviewer struct {
+	ctrl     *gomock.Controller
+	recorder *MockLinkPreviewerMockRecorder
+}
+
+// MockLinkPreviewerMockRecorder is the mock recorder for MockLinkPreviewer.
+type MockLinkPreviewerMockRecorder struct {
+	mock *MockLinkPreviewer
+}
+
+// NewMockLinkPreviewer creates a new mock instance.
+func NewMockLinkPreviewer(ctrl *gomock.Controller) *MockLinkPreviewer {
+	mock := &MockLinkPreviewer{ctrl: ctrl}
+	mock.recorder = &MockLinkPreviewerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLinkPreviewer) EXPECT() *MockLinkPreviewerMockRecorder {
+	return m.recorder
+}
+
+// Fetch mocks base method.
+func (m *MockLinkPreviewer) Fetch(ctx context.Context, rawURL string) (*preview.Preview, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Fetch", ctx, rawURL)
+	ret0, _ := ret[0].(*preview.Preview)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Fetch indicates an expected call of Fetch.
+func (mr *MockLinkPreviewerMockRecorder) Fetch(ctx, rawURL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fetch", reflect.TypeOf((*MockLinkPreviewer)(nil).Fetch), ctx, rawURL)
+}
+
+// MockAuthorRegistry is a mock of AuthorRegistry interface.
+type MockAuthorRegistry struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuthorRegistryMockRecorder
+}
+
+// MockAuthorRegistryMockRecorder is the mock recorder for MockAuthorRegistry.
+type MockAuthorRegistryMockRecorder struct {
+	mock *MockAuthorRegistry
+}
+
+// NewMockAuthorRegistry creates a new mock instance.
+func NewMockAuthorRegistry(ctrl *gomock.Controller) *MockAuthorRegistry {
+	mock := &MockAuthorRegistry{ctrl: ctrl}
+	mock.recorder = &MockAuthorRegistryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuthorRegistry) EXPECT() *MockAuthorRegistryMockRecorder {
+	return m.recorder
+}
+
+// GetRegistrationTime mocks base method.
+func (m *MockAuthorRegistry) GetRegistrationTime(ctx context.Context, userID string) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRegistrationTime", ctx, userID)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRegistrationTime indicates an expected call of GetRegistrationTime.
+func (mr *MockAuthorRegistryMockRecorder) GetRegistrationTime(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRegistrationTime", reflect.TypeOf((*MockAuthorRegistry)(nil).GetRegistrationTime), ctx, userID)
+}
+
+// MockEventPublisher is a mock of EventPublisher interface.
+type MockEventPublisher struct {
+	ctrl     *gomock.Controller
+	recorder *MockEventPublisherMockRecorder
+}
+
+// MockEventPublisherMockRecorder is the mock recorder for MockEventPublisher.
+type MockEventPublisherMockRecorder struct {
+	mock *MockEventPublisher
+}
+
+// NewMockEventPublisher creates a new mock instance.
+func NewMockEventPublisher(ctrl *gomock.Controller) *MockEventPublisher {
+	mock := &MockEventPublisher{ctrl: ctrl}
+	mock.recorder = &MockEventPublisherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEventPublisher) EXPECT() *MockEventPublisherMockRecorder {
+	return m.recorder
+}
+
+// Publish mocks base method.
+func (m *MockEventPublisher) Publish(channel, kind string, data interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Publish", channel, kind, data)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Publish indicates an expected call of Publish.
+func (mr *MockEventPublisherMockRecorder) Publish(channel, kind, data interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockEventPublisher)(nil).Publish), channel, kind, data)
+}
+
+// MockNotifier is a mock of Notifier interface.
+type MockNotifier struct {
+	ctrl     *gomock.Controller
+	recorder *MockNotifierMockRecorder
+}
+
+// MockNotifierMockRecorder is the mock recorder for MockNotifier.
+type MockNotifierMockRecorder struct {
+	mock *MockNotifier
+}
+
+// NewMockNotifier creates a new mock instance.
+func NewMockNotifier(ctrl *gomock.Controller) *MockNotifier {
+	mock := &MockNotifier{ctrl: ctrl}
+	mock.recorder = &MockNotifierMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockNotifier) EXPECT() *MockNotifierMockRecorder {
+	return m.recorder
+}
+
+// DropPost mocks base method.
+func (m *MockNotifier) DropPost(ctx context.Context, postID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DropPost", ctx, postID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DropPost indicates an expected call of DropPost.
+func (mr *MockNotifierMockRecorder) DropPost(ctx, postID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DropPost", reflect.TypeOf((*MockNotifier)(nil).DropPost), ctx, postID)
+}
+
+// NotifyComment mocks base method.
+func (m *MockNotifier) NotifyComment(ctx context.Context, targetPost *Post, newComment *comment.Comment) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NotifyComment", ctx, targetPost, newComment)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// NotifyComment indicates an expected call of NotifyComment.
+func (mr *MockNotifierMockRecorder) NotifyComment(ctx, targetPost, newComment interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifyComment", reflect.TypeOf((*MockNotifier)(nil).NotifyComment), ctx, targetPost, newComment)
+}
+
+// NotifyPost mocks base method.
+func (m *MockNotifier) NotifyPost(ctx context.Context, newPost *Post) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NotifyPost", ctx, newPost)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// NotifyPost indicates an expected call of NotifyPost.
+func (mr *MockNotifierMockRecorder) NotifyPost(ctx, newPost interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifyPost", reflect.TypeOf((*MockNotifier)(nil).NotifyPost), ctx, newPost)
+}
+
 // MockPostRepo is a mock of PostRepo interface.
 type MockPostRepo struct {
 	ctrl     *gomock.Controller
@@ -35,166 +382,301 @@ func (m *MockPostRepo) EXPECT() *MockPostRepoMockRecorder {
 }
 
 // AddComment mocks base method.
-func (m *MockPostRepo) AddComment(commentBody string, author *user.User, postID string) (*Post, error) {
+func (m *MockPostRepo) AddComment(ctx context.Context, commentBody string, author *user.User, postID string, version int) (*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddComment", commentBody, author, postID)
+	ret := m.ctrl.Call(m, "AddComment", ctx, commentBody, author, postID, version)
 	ret0, _ := ret[0].(*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AddComment indicates an expected call of AddComment.
-func (mr *MockPostRepoMockRecorder) AddComment(commentBody, author, postID interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) AddComment(ctx, commentBody, author, postID, version interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddComment", reflect.TypeOf((*MockPostRepo)(nil).AddComment), commentBody, author, postID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddComment", reflect.TypeOf((*MockPostRepo)(nil).AddComment), ctx, commentBody, author, postID, version)
 }
 
 // AddPost mocks base method.
-func (m *MockPostRepo) AddPost(post *Post, author *user.User) (*Post, error) {
+func (m *MockPostRepo) AddPost(ctx context.Context, post *Post, author *user.User) (*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddPost", post, author)
+	ret := m.ctrl.Call(m, "AddPost", ctx, post, author)
 	ret0, _ := ret[0].(*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AddPost indicates an expected call of AddPost.
-func (mr *MockPostRepoMockRecorder) AddPost(post, author interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) AddPost(ctx, post, author interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPost", reflect.TypeOf((*MockPostRepo)(nil).AddPost), post, author)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPost", reflect.TypeOf((*MockPostRepo)(nil).AddPost), ctx, post, author)
+}
+
+// ApproveHeld mocks base method.
+func (m *MockPostRepo) ApproveHeld(ctx context.Context, postID, commentID string, version int) (*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApproveHeld", ctx, postID, commentID, version)
+	ret0, _ := ret[0].(*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ApproveHeld indicates an expected call of ApproveHeld.
+func (mr *MockPostRepoMockRecorder) ApproveHeld(ctx, postID, commentID, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApproveHeld", reflect.TypeOf((*MockPostRepo)(nil).ApproveHeld), ctx, postID, commentID, version)
 }
 
 // DeleteComment mocks base method.
-func (m *MockPostRepo) DeleteComment(userID, postID, commentID string) (*Post, error) {
+func (m *MockPostRepo) DeleteComment(ctx context.Context, userID, postID, commentID string, version int) (*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteComment", userID, postID, commentID)
+	ret := m.ctrl.Call(m, "DeleteComment", ctx, userID, postID, commentID, version)
 	ret0, _ := ret[0].(*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DeleteComment indicates an expected call of DeleteComment.
-func (mr *MockPostRepoMockRecorder) DeleteComment(userID, postID, commentID interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) DeleteComment(ctx, userID, postID, commentID, version interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteComment", reflect.TypeOf((*MockPostRepo)(nil).DeleteComment), userID, postID, commentID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteComment", reflect.TypeOf((*MockPostRepo)(nil).DeleteComment), ctx, userID, postID, commentID, version)
 }
 
 // DeletePost mocks base method.
-func (m *MockPostRepo) DeletePost(userID, postID string) (bool, error) {
+func (m *MockPostRepo) DeletePost(ctx context.Context, userID, postID string, version int) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeletePost", userID, postID)
+	ret := m.ctrl.Call(m, "DeletePost", ctx, userID, postID, version)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DeletePost indicates an expected call of DeletePost.
-func (mr *MockPostRepoMockRecorder) DeletePost(userID, postID interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) DeletePost(ctx, userID, postID, version interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePost", reflect.TypeOf((*MockPostRepo)(nil).DeletePost), userID, postID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePost", reflect.TypeOf((*MockPostRepo)(nil).DeletePost), ctx, userID, postID, version)
 }
 
 // DownVote mocks base method.
-func (m *MockPostRepo) DownVote(postID, userID string) (*Post, error) {
+func (m *MockPostRepo) DownVote(ctx context.Context, postID, userID string, version int) (*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DownVote", postID, userID)
+	ret := m.ctrl.Call(m, "DownVote", ctx, postID, userID, version)
 	ret0, _ := ret[0].(*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DownVote indicates an expected call of DownVote.
-func (mr *MockPostRepoMockRecorder) DownVote(postID, userID interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) DownVote(ctx, postID, userID, version interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DownVote", reflect.TypeOf((*MockPostRepo)(nil).DownVote), postID, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DownVote", reflect.TypeOf((*MockPostRepo)(nil).DownVote), ctx, postID, userID, version)
+}
+
+// FindPostByID mocks base method.
+func (m *MockPostRepo) FindPostByID(ctx context.Context, postID string) (*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindPostByID", ctx, postID)
+	ret0, _ := ret[0].(*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindPostByID indicates an expected call of FindPostByID.
+func (mr *MockPostRepoMockRecorder) FindPostByID(ctx, postID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindPostByID", reflect.TypeOf((*MockPostRepo)(nil).FindPostByID), ctx, postID)
+}
+
+// FindPostsByIDs mocks base method.
+func (m *MockPostRepo) FindPostsByIDs(ctx context.Context, postIDs []string) ([]*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindPostsByIDs", ctx, postIDs)
+	ret0, _ := ret[0].([]*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindPostsByIDs indicates an expected call of FindPostsByIDs.
+func (mr *MockPostRepoMockRecorder) FindPostsByIDs(ctx, postIDs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindPostsByIDs", reflect.TypeOf((*MockPostRepo)(nil).FindPostsByIDs), ctx, postIDs)
 }
 
 // GetAll mocks base method.
-func (m *MockPostRepo) GetAll() ([]*Post, error) {
+func (m *MockPostRepo) GetAll(ctx context.Context, filter *ListFilter) ([]*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAll")
+	ret := m.ctrl.Call(m, "GetAll", ctx, filter)
 	ret0, _ := ret[0].([]*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAll indicates an expected call of GetAll.
-func (mr *MockPostRepoMockRecorder) GetAll() *gomock.Call {
+func (mr *MockPostRepoMockRecorder) GetAll(ctx, filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockPostRepo)(nil).GetAll))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockPostRepo)(nil).GetAll), ctx, filter)
 }
 
 // GetPostByCategory mocks base method.
-func (m *MockPostRepo) GetPostByCategory(category string) ([]*Post, error) {
+func (m *MockPostRepo) GetPostByCategory(ctx context.Context, category string, filter *ListFilter) ([]*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPostByCategory", category)
+	ret := m.ctrl.Call(m, "GetPostByCategory", ctx, category, filter)
 	ret0, _ := ret[0].([]*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPostByCategory indicates an expected call of GetPostByCategory.
-func (mr *MockPostRepoMockRecorder) GetPostByCategory(category interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) GetPostByCategory(ctx, category, filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostByCategory", reflect.TypeOf((*MockPostRepo)(nil).GetPostByCategory), category)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostByCategory", reflect.TypeOf((*MockPostRepo)(nil).GetPostByCategory), ctx, category, filter)
 }
 
 // GetPostByID mocks base method.
-func (m *MockPostRepo) GetPostByID(ID string) (*Post, error) {
+func (m *MockPostRepo) GetPostByID(ctx context.Context, ID string, viewer *user.User, isModerator bool) (*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPostByID", ID)
+	ret := m.ctrl.Call(m, "GetPostByID", ctx, ID, viewer, isModerator)
 	ret0, _ := ret[0].(*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPostByID indicates an expected call of GetPostByID.
-func (mr *MockPostRepoMockRecorder) GetPostByID(ID interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) GetPostByID(ctx, ID, viewer, isModerator interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostByID", reflect.TypeOf((*MockPostRepo)(nil).GetPostByID), ID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostByID", reflect.TypeOf((*MockPostRepo)(nil).GetPostByID), ctx, ID, viewer, isModerator)
 }
 
 // GetPostsByUserID mocks base method.
-func (m *MockPostRepo) GetPostsByUserID(userName string) ([]*Post, error) {
+func (m *MockPostRepo) GetPostsByUserID(ctx context.Context, userName string, filter *ListFilter) ([]*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPostsByUserID", userName)
+	ret := m.ctrl.Call(m, "GetPostsByUserID", ctx, userName, filter)
 	ret0, _ := ret[0].([]*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPostsByUserID indicates an expected call of GetPostsByUserID.
-func (mr *MockPostRepoMockRecorder) GetPostsByUserID(userName interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) GetPostsByUserID(ctx, userName, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostsByUserID", reflect.TypeOf((*MockPostRepo)(nil).GetPostsByUserID), ctx, userName, filter)
+}
+
+// RemoveComment mocks base method.
+func (m *MockPostRepo) RemoveComment(ctx context.Context, moderator *user.User, postID, commentID, reason string, version int) (*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveComment", ctx, moderator, postID, commentID, reason, version)
+	ret0, _ := ret[0].(*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveComment indicates an expected call of RemoveComment.
+func (mr *MockPostRepoMockRecorder) RemoveComment(ctx, moderator, postID, commentID, reason, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveComment", reflect.TypeOf((*MockPostRepo)(nil).RemoveComment), ctx, moderator, postID, commentID, reason, version)
+}
+
+// RemovePost mocks base method.
+func (m *MockPostRepo) RemovePost(ctx context.Context, moderator *user.User, postID, reason string, version int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemovePost", ctx, moderator, postID, reason, version)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemovePost indicates an expected call of RemovePost.
+func (mr *MockPostRepoMockRecorder) RemovePost(ctx, moderator, postID, reason, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemovePost", reflect.TypeOf((*MockPostRepo)(nil).RemovePost), ctx, moderator, postID, reason, version)
+}
+
+// RestorePost mocks base method.
+func (m *MockPostRepo) RestorePost(ctx context.Context, actor *user.User, postID string, isModerator bool, version int) (*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestorePost", ctx, actor, postID, isModerator, version)
+	ret0, _ := ret[0].(*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestorePost indicates an expected call of RestorePost.
+func (mr *MockPostRepoMockRecorder) RestorePost(ctx, actor, postID, isModerator, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestorePost", reflect.TypeOf((*MockPostRepo)(nil).RestorePost), ctx, actor, postID, isModerator, version)
+}
+
+// SetLocked mocks base method.
+func (m *MockPostRepo) SetLocked(ctx context.Context, moderator *user.User, postID string, locked bool, reason string, version int) (*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetLocked", ctx, moderator, postID, locked, reason, version)
+	ret0, _ := ret[0].(*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetLocked indicates an expected call of SetLocked.
+func (mr *MockPostRepoMockRecorder) SetLocked(ctx, moderator, postID, locked, reason, version interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostsByUserID", reflect.TypeOf((*MockPostRepo)(nil).GetPostsByUserID), userName)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLocked", reflect.TypeOf((*MockPostRepo)(nil).SetLocked), ctx, moderator, postID, locked, reason, version)
+}
+
+// SetMarks mocks base method.
+func (m *MockPostRepo) SetMarks(ctx context.Context, actor *user.User, postID string, isModerator bool, marks *Marks, version int) (*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMarks", ctx, actor, postID, isModerator, marks, version)
+	ret0, _ := ret[0].(*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetMarks indicates an expected call of SetMarks.
+func (mr *MockPostRepoMockRecorder) SetMarks(ctx, actor, postID, isModerator, marks, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMarks", reflect.TypeOf((*MockPostRepo)(nil).SetMarks), ctx, actor, postID, isModerator, marks, version)
 }
 
 // UnVote mocks base method.
-func (m *MockPostRepo) UnVote(postID, userID string) (*Post, error) {
+func (m *MockPostRepo) UnVote(ctx context.Context, postID, userID string, version int) (*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UnVote", postID, userID)
+	ret := m.ctrl.Call(m, "UnVote", ctx, postID, userID, version)
 	ret0, _ := ret[0].(*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // UnVote indicates an expected call of UnVote.
-func (mr *MockPostRepoMockRecorder) UnVote(postID, userID interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) UnVote(ctx, postID, userID, version interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnVote", reflect.TypeOf((*MockPostRepo)(nil).UnVote), postID, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnVote", reflect.TypeOf((*MockPostRepo)(nil).UnVote), ctx, postID, userID, version)
 }
 
 // UpVote mocks base method.
-func (m *MockPostRepo) UpVote(postID, userID string) (*Post, error) {
+func (m *MockPostRepo) UpVote(ctx context.Context, postID, userID string, version int) (*Post, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpVote", postID, userID)
+	ret := m.ctrl.Call(m, "UpVote", ctx, postID, userID, version)
 	ret0, _ := ret[0].(*Post)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // UpVote indicates an expected call of UpVote.
-func (mr *MockPostRepoMockRecorder) UpVote(postID, userID interface{}) *gomock.Call {
+func (mr *MockPostRepoMockRecorder) UpVote(ctx, postID, userID, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpVote", reflect.TypeOf((*MockPostRepo)(nil).UpVote), ctx, postID, userID, version)
+}
+
+// VotePoll mocks base method.
+func (m *MockPostRepo) VotePoll(ctx context.Context, postID, userID, optionID string, version int) (*Post, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VotePoll", ctx, postID, userID, optionID, version)
+	ret0, _ := ret[0].(*Post)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VotePoll indicates an expected call of VotePoll.
+func (mr *MockPostRepoMockRecorder) VotePoll(ctx, postID, userID, optionID, version interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpVote", reflect.TypeOf((*MockPostRepo)(nil).UpVote), postID, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VotePoll", reflect.TypeOf((*MockPostRepo)(nil).VotePoll), ctx, postID, userID, optionID, version)
 }
@@ -2,23 +2,43 @@ package post
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reddit/pkg/preview"
 )
 
+// illegalOperation - код ошибки монги, в том числе на транзакцию вне replica set
+const illegalOperation = 20
+
+// DefaultTimeout - сколько ждем монгу на одну операцию, если клиент не отвалится раньше
+const DefaultTimeout = 5 * time.Second
+
 type DatabaseHelper interface {
 	Collection(name string) CollectionHelper
 	Client() ClientHelper
 }
 
 type CollectionHelper interface {
-	Find(ctx context.Context, filter interface{}) (*mongo.Cursor, error)
+	Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error)
 	FindOne(context.Context, interface{}) SingleResultHelper
 	InsertOne(context.Context, interface{}) (interface{}, error)
 	DeleteOne(ctx context.Context, filter interface{}) (int64, error)
-	UpdateOne(ctx context.Context, filter interface{}, update interface{}) (*mongo.UpdateResult, error)
+	DeleteMany(ctx context.Context, filter interface{}) (int64, error)
+	UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	UpdateMany(ctx context.Context, filter interface{}, update interface{}) (int64, error)
+	CountDocuments(ctx context.Context, filter interface{}) (int64, error)
+	CreateIndexes(ctx context.Context, models []mongo.IndexModel) error
+	BulkWrite(ctx context.Context, models []mongo.WriteModel) error
 }
 
 type SingleResultHelper interface {
@@ -34,124 +54,437 @@ type ClientHelper interface {
 type PostDBRepo struct {
 	Posts CollectionHelper
 	Sess  ClientHelper
+	// Outbox - доменные события, без него события не пишутся
+	Outbox CollectionHelper
+	// Timeout - срок одной операции, 0 - DefaultTimeout
+	Timeout time.Duration
+	// standalone - сервер без replica set, транзакций у него нет
+	standalone atomic.Bool
+}
+
+// WithTimeout ограничивает одну операцию с базой, 0 - DefaultTimeout
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func (p *PostDBRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return WithTimeout(ctx, p.Timeout)
+}
+
+// InTransaction выполняет work в транзакции монги: все записи через ctx применяются вместе или не применяются совсем.
+// work может выполниться повторно, если транзакцию придется перезапустить. На standalone сервере транзакций нет,
+// и work выполняется без нее - атомарность там только в пределах одного документа
+func (p *PostDBRepo) InTransaction(ctx context.Context, work func(ctx context.Context) error) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	// уже внутри транзакции: вложенная работа становится ее частью
+	if p.Sess == nil || p.standalone.Load() || mongo.SessionFromContext(ctx) != nil {
+		return work(ctx)
+	}
+	session, err := p.Sess.StartSession()
+	if err != nil {
+		return err
+	}
+	// сессию закрываем и после отмены запроса
+	defer session.EndSession(context.Background())
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, work(sc)
+	})
+	if transactionsUnsupported(err) {
+		// ошибку дает первая же запись в транзакции, так что ничего еще не записано
+		p.standalone.Store(true)
+		return work(ctx)
+	}
+	return err
+}
+
+func transactionsUnsupported(err error) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && serverErr.HasErrorCodeWithMessage(illegalOperation, "Transaction numbers are only allowed")
+}
+
+// withOutbox - изменение поста и его событие пишутся одной единицей работы
+func (p *PostDBRepo) withOutbox(ctx context.Context, postID string, event *DomainEvent, write func(ctx context.Context) error) error {
+	if p.Outbox == nil || event == nil {
+		ctx, cancel := p.withTimeout(ctx)
+		defer cancel()
+		return write(ctx)
+	}
+	return p.InTransaction(ctx, func(ctx context.Context) error {
+		if err := write(ctx); err != nil {
+			return err
+		}
+		event.PostID = postID
+		payload, err := json.Marshal(event.Data)
+		if err != nil {
+			return err
+		}
+		event.Payload = string(payload)
+		_, err = p.Outbox.InsertOne(ctx, event)
+		return err
+	})
 }
 
-func (p *PostDBRepo) IncreasePostViewsDB(post *Post, postID string) error {
+func (p *PostDBRepo) IncreasePostViewsDB(ctx context.Context, post *Post, postID string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 	postIDMongo, err := getMongoID(postID)
 	if err != nil {
 		return err
 	}
-	_, err = p.Posts.UpdateOne(context.Background(), bson.M{"_id": postIDMongo}, bson.M{"$set": bson.M{"views": post.Views}})
+	_, err = p.Posts.UpdateOne(ctx, bson.M{"_id": postIDMongo}, bson.M{"$set": bson.M{"views": post.Views}})
 	return err
 }
 
-func (p *PostDBRepo) GetPostByCategoryDB(postOfCurrentCategory []*Post, category string) ([]*Post, error) {
-	result, err := p.Posts.Find(context.Background(), bson.M{"category": category})
+func (p *PostDBRepo) EnsureIndexesDB() error {
+	return p.Posts.CreateIndexes(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "category", Value: 1}, {Key: "flair", Value: 1}}},
+		{Keys: bson.D{{Key: "category", Value: 1}, {Key: "tags", Value: 1}}},
+		{Keys: bson.D{{Key: "flair", Value: 1}}},
+		{Keys: bson.D{{Key: "tags", Value: 1}}},
+		{Keys: bson.D{{Key: "category", Value: 1}, {Key: "url", Value: 1}}},
+	})
+}
+
+func (p *PostDBRepo) GetPostByCategoryDB(ctx context.Context, postOfCurrentCategory []*Post, category string, filter *ListFilter) ([]*Post, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	result, err := p.Posts.Find(ctx, visibleFilter(listFilter(bson.M{"category": category}, filter)))
 	if err != nil {
 		return nil, err
 	}
-	err = result.All(context.Background(), &postOfCurrentCategory)
+	err = result.All(ctx, &postOfCurrentCategory)
 	if err != nil {
 		return nil, err
 	}
 	return postOfCurrentCategory, nil
 }
 
-func (p *PostDBRepo) AddPostDB(post *Post) error {
+func (p *PostDBRepo) AddPostDB(ctx context.Context, post *Post, event *DomainEvent) error {
 	post.ID = primitive.NewObjectID()
-	_, err := p.Posts.InsertOne(context.Background(), post)
-	return err
+	return p.withOutbox(ctx, post.ID.Hex(), event, func(ctx context.Context) error {
+		_, err := p.Posts.InsertOne(ctx, post)
+		return err
+	})
 }
 
-func (p *PostDBRepo) GetAllPostsDB(allPosts []*Post) ([]*Post, error) {
-	result, err := p.Posts.Find(context.Background(), bson.M{})
+func (p *PostDBRepo) GetAllPostsDB(ctx context.Context, allPosts []*Post, filter *ListFilter) ([]*Post, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	result, err := p.Posts.Find(ctx, visibleFilter(listFilter(bson.M{}, filter)))
 	if err != nil {
 		return nil, err
 	}
-	err = result.All(context.Background(), &allPosts)
+	err = result.All(ctx, &allPosts)
 	if err != nil {
 		return nil, err
 	}
 	return allPosts, nil
 }
 
-func (p *PostDBRepo) AddCommentDB(post *Post, postID string) error {
+func (p *PostDBRepo) AddCommentDB(ctx context.Context, post *Post, postID string, event *DomainEvent) error {
 	postIDMongo, err := getMongoID(postID)
 	if err != nil {
 		return err
 	}
-	update := bson.M{
-		"$set": bson.M{"comments": post.Comments},
+	err = p.withOutbox(ctx, postID, event, func(ctx context.Context) error {
+		return p.setVersioned(ctx, postIDMongo, post.Version, bson.M{"comments": post.Comments, "version": post.Version + 1})
+	})
+	if err != nil {
+		return err
 	}
-	filter := bson.M{"_id": postIDMongo}
-	_, err = p.Posts.UpdateOne(context.TODO(), filter, update)
-	return err
+	post.Version++
+	return nil
 }
 
-func (p *PostDBRepo) DeleteCommentDB(postWithCommentToDelete *Post, postID string) error {
+func (p *PostDBRepo) DeleteCommentDB(ctx context.Context, postWithCommentToDelete *Post, postID string, event *DomainEvent) error {
 	postIDMongo, err := getMongoID(postID)
 	if err != nil {
 		return err
 	}
-	update := bson.M{
-		"$set": bson.M{"comments": postWithCommentToDelete.Comments},
+	version := postWithCommentToDelete.Version
+	err = p.withOutbox(ctx, postID, event, func(ctx context.Context) error {
+		return p.setVersioned(ctx, postIDMongo, version, bson.M{"comments": postWithCommentToDelete.Comments, "version": version + 1})
+	})
+	if err != nil {
+		return err
 	}
-	filter := bson.M{"_id": postIDMongo}
-	_, err = p.Posts.UpdateOne(context.TODO(), filter, update)
-	return err
+	postWithCommentToDelete.Version++
+	return nil
 }
 
-func (p *PostDBRepo) GetPostByIDDB(postID string) (*Post, error) {
+func (p *PostDBRepo) GetPostByIDDB(ctx context.Context, postID string) (*Post, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 	postIDMongo, err := getMongoID(postID)
 	if err != nil {
 		return nil, err
 	}
 	post := &Post{}
-	err = p.Posts.FindOne(context.Background(), bson.M{"_id": postIDMongo}).Decode(post)
+	err = p.Posts.FindOne(ctx, bson.M{"_id": postIDMongo}).Decode(post)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNoPost
+	}
 	if err != nil {
 		return nil, err
 	}
 	return post, nil
 }
 
-func (p *PostDBRepo) SetPostDB(postToSet *Post, postID string) error {
+// FindDuplicateDB ищет видимый пост с той же ссылкой в категории, созданный не раньше since
+func (p *PostDBRepo) FindDuplicateDB(ctx context.Context, category, normalizedURL string, since time.Time) (*Post, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	filter := visibleFilter(bson.M{
+		"category": category,
+		"url":      normalizedURL,
+		"_id":      bson.M{"$gte": primitive.NewObjectIDFromTimestamp(since)},
+	})
+	post := &Post{}
+	err := p.Posts.FindOne(ctx, filter).Decode(post)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNoPost
+	}
+	if err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+func (p *PostDBRepo) GetPostsByIDsDB(ctx context.Context, postIDs []string) ([]*Post, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	posts := make([]*Post, 0, len(postIDs))
+	result, err := p.Posts.Find(ctx, visibleFilter(bson.M{"_id": bson.M{"$in": mongoIDs(postIDs)}}))
+	if err != nil {
+		return nil, err
+	}
+	err = result.All(ctx, &posts)
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+func (p *PostDBRepo) SetPostDB(ctx context.Context, postToSet *Post, postID string, event *DomainEvent) error {
 	postIDMongo, err := getMongoID(postID)
 	if err != nil {
 		return err
 	}
-	filter := bson.M{"_id": postIDMongo}
-	update := bson.M{
-		"$set": postToSet,
+	version := postToSet.Version
+	postToSet.Version++
+	err = p.withOutbox(ctx, postID, event, func(ctx context.Context) error {
+		return p.setVersioned(ctx, postIDMongo, version, postToSet)
+	})
+	if err != nil {
+		postToSet.Version = version
 	}
-	_, err = p.Posts.UpdateOne(context.Background(), filter, update)
 	return err
 }
 
-func (p *PostDBRepo) GetPostByUsernameDB(userName string) ([]*Post, error) {
+func (p *PostDBRepo) GetPostByUsernameDB(ctx context.Context, userName string, filter *ListFilter) ([]*Post, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 	userPosts := make([]*Post, 0)
-	result, err := p.Posts.Find(context.Background(), bson.M{"author.username": userName})
+	result, err := p.Posts.Find(ctx, visibleFilter(listFilter(bson.M{"author.username": userName}, filter)))
 	if err != nil {
 		return nil, err
 	}
-	err = result.All(context.Background(), &userPosts)
+	err = result.All(ctx, &userPosts)
 	if err != nil {
 		return nil, err
 	}
 	return userPosts, nil
 }
 
-func (p *PostDBRepo) DeletePostDB(postID string) (bool, error) {
+func (p *PostDBRepo) DeletePostDB(ctx context.Context, postID, deletedBy string, version int, event *DomainEvent) (bool, error) {
 	postIDMongo, err := getMongoID(postID)
 	if err != nil {
 		return false, err
 	}
-	_, err = p.Posts.DeleteOne(context.Background(), bson.M{"_id": postIDMongo})
+	set := bson.M{"deleted_at": time.Now(), "deleted_by": deletedBy, "version": version + 1}
+	err = p.withOutbox(ctx, postID, event, func(ctx context.Context) error {
+		return p.setVersioned(ctx, postIDMongo, version, set)
+	})
 	if err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
+func (p *PostDBRepo) RestorePostDB(ctx context.Context, postID string, version int) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	postIDMongo, err := getMongoID(postID)
+	if err != nil {
+		return err
+	}
+	update := bson.M{
+		"$unset": bson.M{"deleted_at": "", "deleted_by": ""},
+		"$set":   bson.M{"version": version + 1},
+	}
+	return p.updateVersioned(ctx, postIDMongo, version, update)
+}
+
+func (p *PostDBRepo) PurgeDeletedDB(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	return p.Posts.DeleteMany(ctx, bson.M{"deleted_at": bson.M{"$lt": deletedBefore}})
+}
+
+func (p *PostDBRepo) SetPostStatusDB(ctx context.Context, postID, status string, version int, event *DomainEvent) error {
+	postIDMongo, err := getMongoID(postID)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{"status": status, "version": version + 1}}
+	if status == "" {
+		update = bson.M{"$unset": bson.M{"status": ""}, "$set": bson.M{"version": version + 1}}
+	}
+	return p.withOutbox(ctx, postID, event, func(ctx context.Context) error {
+		return p.updateVersioned(ctx, postIDMongo, version, update)
+	})
+}
+
+func (p *PostDBRepo) SetCommentsDB(ctx context.Context, post *Post, postID string, event *DomainEvent) error {
+	postIDMongo, err := getMongoID(postID)
+	if err != nil {
+		return err
+	}
+	set := bson.M{"comments": post.Comments, "heldComments": post.HeldComments, "version": post.Version + 1}
+	err = p.withOutbox(ctx, postID, event, func(ctx context.Context) error {
+		return p.setVersioned(ctx, postIDMongo, post.Version, set)
+	})
+	if err != nil {
+		return err
+	}
+	post.Version++
+	return nil
+}
+
+func (p *PostDBRepo) SetMarksDB(ctx context.Context, postID string, nsfw, spoiler bool, version int) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	postIDMongo, err := getMongoID(postID)
+	if err != nil {
+		return err
+	}
+	return p.setVersioned(ctx, postIDMongo, version, bson.M{"nsfw": nsfw, "spoiler": spoiler, "version": version + 1})
+}
+
+func (p *PostDBRepo) SetLockedDB(ctx context.Context, postID string, locked bool, version int) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	postIDMongo, err := getMongoID(postID)
+	if err != nil {
+		return err
+	}
+	return p.setVersioned(ctx, postIDMongo, version, bson.M{"locked": locked, "version": version + 1})
+}
+
+func (p *PostDBRepo) SetPreviewDB(ctx context.Context, postID string, linkPreview *preview.Preview) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	postIDMongo, err := getMongoID(postID)
+	if err != nil {
+		return err
+	}
+	update := bson.M{
+		"$set": bson.M{"preview": linkPreview},
+		"$inc": bson.M{"version": 1},
+	}
+	_, err = p.Posts.UpdateOne(ctx, bson.M{"_id": postIDMongo}, update)
+	return err
+}
+
+// AddPollVoteDB - голоса в опросе пишутся через $inc и от версии не зависят, с AnyVersion она не проверяется
+func (p *PostDBRepo) AddPollVoteDB(ctx context.Context, postID, userID string, optionIndex, version int, pollVote *PollVote) (bool, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	postIDMongo, err := getMongoID(postID)
+	if err != nil {
+		return false, err
+	}
+	filter := bson.M{"_id": postIDMongo}
+	if version != AnyVersion {
+		filter = versionFilter(postIDMongo, version)
+	}
+	filter["poll.voters.user"] = bson.M{"$ne": userID}
+	update := bson.M{
+		"$inc":  bson.M{"poll.options." + strconv.Itoa(optionIndex) + ".votes": 1, "version": 1},
+		"$push": bson.M{"poll.voters": pollVote},
+	}
+	result, err := p.Posts.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// setVersioned пишет set, только если пост не меняли с момента чтения, иначе ErrVersionConflict.
+// Версию в set кладет вызывающий, у SetPostDB она внутри самого поста
+func (p *PostDBRepo) setVersioned(ctx context.Context, postIDMongo primitive.ObjectID, version int, set interface{}) error {
+	return p.updateVersioned(ctx, postIDMongo, version, bson.M{"$set": set})
+}
+
+// updateVersioned - то же для произвольного update, когда кроме $set нужен еще и $unset
+func (p *PostDBRepo) updateVersioned(ctx context.Context, postIDMongo primitive.ObjectID, version int, update bson.M) error {
+	result, err := p.Posts.UpdateOne(ctx, versionFilter(postIDMongo, version), update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// versionFilter - версия 0 у постов, записанных до появления версий, поля у них нет
+func versionFilter(postIDMongo primitive.ObjectID, version int) bson.M {
+	if version == 0 {
+		return bson.M{"_id": postIDMongo, "version": bson.M{"$exists": false}}
+	}
+	return bson.M{"_id": postIDMongo, "version": version}
+}
+
+func visibleFilter(filter bson.M) bson.M {
+	filter["status"] = bson.M{"$ne": StatusHeld}
+	filter["deleted_at"] = bson.M{"$exists": false}
+	return filter
+}
+
+func listFilter(filter bson.M, listFilter *ListFilter) bson.M {
+	if listFilter == nil {
+		return filter
+	}
+	if listFilter.Flair != "" {
+		filter["flair"] = listFilter.Flair
+	}
+	if listFilter.Tag != "" {
+		filter["tags"] = strings.ToLower(listFilter.Tag)
+	}
+	if listFilter.HideNSFW {
+		filter["nsfw"] = bson.M{"$ne": true}
+	}
+	return filter
+}
+
+// mongoIDs пропускает кривые id, по ним все равно ничего не найдется
+func mongoIDs(ids []string) []primitive.ObjectID {
+	mongoIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		mongoID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			continue
+		}
+		mongoIDs = append(mongoIDs, mongoID)
+	}
+	return mongoIDs
+}
+
 func getMongoID(id string) (primitive.ObjectID, error) {
 	postIDMongo, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -202,8 +535,8 @@ func (md *MongoDatabase) Client() ClientHelper {
 	return &MongoClient{Cl: client}
 }
 
-func (mc *MongoCollection) Find(ctx context.Context, filter interface{}) (*mongo.Cursor, error) {
-	return mc.Coll.Find(ctx, filter)
+func (mc *MongoCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	return mc.Coll.Find(ctx, filter, opts...)
 }
 
 func (mc *MongoCollection) FindOne(ctx context.Context, filter interface{}) SingleResultHelper {
@@ -221,10 +554,40 @@ func (mc *MongoCollection) DeleteOne(ctx context.Context, filter interface{}) (i
 	return count.DeletedCount, err
 }
 
-func (mc *MongoCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}) (*mongo.UpdateResult, error) {
-	return mc.Coll.UpdateOne(ctx, filter, update)
+func (mc *MongoCollection) DeleteMany(ctx context.Context, filter interface{}) (int64, error) {
+	result, err := mc.Coll.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (mc *MongoCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return mc.Coll.UpdateOne(ctx, filter, update, opts...)
+}
+
+func (mc *MongoCollection) UpdateMany(ctx context.Context, filter interface{}, update interface{}) (int64, error) {
+	result, err := mc.Coll.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (mc *MongoCollection) CountDocuments(ctx context.Context, filter interface{}) (int64, error) {
+	return mc.Coll.CountDocuments(ctx, filter)
 }
 
 func (sr *MongoSingleResult) Decode(v interface{}) error {
 	return sr.Sr.Decode(v)
 }
+
+func (mc *MongoCollection) CreateIndexes(ctx context.Context, models []mongo.IndexModel) error {
+	_, err := mc.Coll.Indexes().CreateMany(ctx, models)
+	return err
+}
+
+func (mc *MongoCollection) BulkWrite(ctx context.Context, models []mongo.WriteModel) error {
+	_, err := mc.Coll.BulkWrite(ctx, models)
+	return err
+}
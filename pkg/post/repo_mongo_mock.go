@@ -10,6 +10,7 @@ import (
 
 	gomock "github.com/golang/mock/gomock"
 	mongo "go.mongodb.org/mongo-driver/mongo"
+	options "go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // MockDatabaseHelper is a mock of DatabaseHelper interface.
@@ -86,6 +87,64 @@ func (m *MockCollectionHelper) EXPECT() *MockCollectionHelperMockRecorder {
 	return m.recorder
 }
 
+// BulkWrite mocks base method.
+func (m *MockCollectionHelper) BulkWrite(ctx context.Context, models []mongo.WriteModel) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BulkWrite", ctx, models)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BulkWrite indicates an expected call of BulkWrite.
+func (mr *MockCollectionHelperMockRecorder) BulkWrite(ctx, models interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BulkWrite", reflect.TypeOf((*MockCollectionHelper)(nil).BulkWrite), ctx, models)
+}
+
+// CountDocuments mocks base method.
+func (m *MockCollectionHelper) CountDocuments(ctx context.Context, filter interface{}) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountDocuments", ctx, filter)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountDocuments indicates an expected call of CountDocuments.
+func (mr *MockCollectionHelperMockRecorder) CountDocuments(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountDocuments", reflect.TypeOf((*MockCollectionHelper)(nil).CountDocuments), ctx, filter)
+}
+
+// CreateIndexes mocks base method.
+func (m *MockCollectionHelper) CreateIndexes(ctx context.Context, models []mongo.IndexModel) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateIndexes", ctx, models)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateIndexes indicates an expected call of CreateIndexes.
+func (mr *MockCollectionHelperMockRecorder) CreateIndexes(ctx, models interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateIndexes", reflect.TypeOf((*MockCollectionHelper)(nil).CreateIndexes), ctx, models)
+}
+
+// DeleteMany mocks base method.
+func (m *MockCollectionHelper) DeleteMany(ctx context.Context, filter interface{}) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMany", ctx, filter)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteMany indicates an expected call of DeleteMany.
+func (mr *MockCollectionHelperMockRecorder) DeleteMany(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMany", reflect.TypeOf((*MockCollectionHelper)(nil).DeleteMany), ctx, filter)
+}
+
 // DeleteOne mocks base method.
 func (m *MockCollectionHelper) DeleteOne(ctx context.Context, filter interface{}) (int64, error) {
 	m.ctrl.T.Helper()
@@ -102,18 +161,23 @@ func (mr *MockCollectionHelperMockRecorder) DeleteOne(ctx, filter interface{}) *
 }
 
 // Find mocks base method.
-func (m *MockCollectionHelper) Find(ctx context.Context, filter interface{}) (*mongo.Cursor, error) {
+func (m *MockCollectionHelper) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Find", ctx, filter)
+	varargs := []interface{}{ctx, filter}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Find", varargs...)
 	ret0, _ := ret[0].(*mongo.Cursor)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Find indicates an expected call of Find.
-func (mr *MockCollectionHelperMockRecorder) Find(ctx, filter interface{}) *gomock.Call {
+func (mr *MockCollectionHelperMockRecorder) Find(ctx, filter interface{}, opts ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockCollectionHelper)(nil).Find), ctx, filter)
+	varargs := append([]interface{}{ctx, filter}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockCollectionHelper)(nil).Find), varargs...)
 }
 
 // FindOne mocks base method.
@@ -145,19 +209,39 @@ func (mr *MockCollectionHelperMockRecorder) InsertOne(arg0, arg1 interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertOne", reflect.TypeOf((*MockCollectionHelper)(nil).InsertOne), arg0, arg1)
 }
 
+// UpdateMany mocks base method.
+func (m *MockCollectionHelper) UpdateMany(ctx context.Context, filter, update interface{}) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateMany", ctx, filter, update)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateMany indicates an expected call of UpdateMany.
+func (mr *MockCollectionHelperMockRecorder) UpdateMany(ctx, filter, update interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateMany", reflect.TypeOf((*MockCollectionHelper)(nil).UpdateMany), ctx, filter, update)
+}
+
 // UpdateOne mocks base method.
-func (m *MockCollectionHelper) UpdateOne(ctx context.Context, filter, update interface{}) (*mongo.UpdateResult, error) {
+func (m *MockCollectionHelper) UpdateOne(ctx context.Context, filter, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateOne", ctx, filter, update)
+	varargs := []interface{}{ctx, filter, update}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "UpdateOne", varargs...)
 	ret0, _ := ret[0].(*mongo.UpdateResult)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // UpdateOne indicates an expected call of UpdateOne.
-func (mr *MockCollectionHelperMockRecorder) UpdateOne(ctx, filter, update interface{}) *gomock.Call {
+func (mr *MockCollectionHelperMockRecorder) UpdateOne(ctx, filter, update interface{}, opts ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOne", reflect.TypeOf((*MockCollectionHelper)(nil).UpdateOne), ctx, filter, update)
+	varargs := append([]interface{}{ctx, filter, update}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOne", reflect.TypeOf((*MockCollectionHelper)(nil).UpdateOne), varargs...)
 }
 
 // MockSingleResultHelper is a mock of SingleResultHelper interface.
@@ -0,0 +1,76 @@
+package preview
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	cachePrefix = "preview:"
+	// DefaultCacheTimeout - кэш не должен задерживать превью дольше, чем сам запрос к сайту
+	DefaultCacheTimeout = time.Second
+)
+
+var ErrCacheMiss = errors.New("preview is not cached")
+
+type Cache interface {
+	Get(ctx context.Context, rawURL string) (*Preview, error)
+	Set(ctx context.Context, rawURL string, linkPreview *Preview, ttl time.Duration) error
+}
+
+// RedisCache берет соединение из пула на каждую команду, превью достаются из горутин
+type RedisCache struct {
+	RedisPool *redis.Pool
+	// Timeout - срок одной команды, 0 - DefaultCacheTimeout
+	Timeout time.Duration
+}
+
+func NewRedisCache(redisPool *redis.Pool) *RedisCache {
+	return &RedisCache{
+		RedisPool: redisPool,
+	}
+}
+
+func (c *RedisCache) do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultCacheTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := c.RedisPool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return redis.DoContext(conn, ctx, cmd, args...)
+}
+
+func (c *RedisCache) Get(ctx context.Context, rawURL string) (*Preview, error) {
+	data, err := redis.Bytes(c.do(ctx, "GET", cachePrefix+rawURL))
+	if errors.Is(err, redis.ErrNil) {
+		return nil, ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	linkPreview := &Preview{}
+	err = json.Unmarshal(data, linkPreview)
+	if err != nil {
+		return nil, ErrCacheMiss
+	}
+	return linkPreview, nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, rawURL string, linkPreview *Preview, ttl time.Duration) error {
+	data, err := json.Marshal(linkPreview)
+	if err != nil {
+		return err
+	}
+	_, err = c.do(ctx, "SET", cachePrefix+rawURL, data, "EX", int(ttl.Seconds()))
+	return err
+}
@@ -0,0 +1,121 @@
+package preview
+
+import (
+	"io"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+)
+
+// parse собирает превью из OpenGraph и Twitter Card тегов,
+// OpenGraph важнее, <title> - последний вариант для заголовка
+func parse(body io.Reader, base *url.URL) *Preview {
+	meta := make(map[string]string)
+	title := ""
+	inTitle := false
+	tokenizer := html.NewTokenizer(body)
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			return build(meta, title, base)
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.DataAtom {
+			case atom.Body:
+				return build(meta, title, base)
+			case atom.Title:
+				inTitle = tokenType == html.StartTagToken
+			case atom.Meta:
+				key, content := metaOf(token)
+				if _, ok := meta[key]; key != "" && !ok {
+					meta[key] = content
+				}
+			}
+		case html.EndTagToken:
+			token := tokenizer.Token()
+			if token.DataAtom == atom.Head {
+				return build(meta, title, base)
+			}
+			if token.DataAtom == atom.Title {
+				inTitle = false
+			}
+		case html.TextToken:
+			if inTitle && title == "" {
+				title = string(tokenizer.Text())
+			}
+		}
+	}
+}
+
+func metaOf(token html.Token) (string, string) {
+	key, content := "", ""
+	for _, attr := range token.Attr {
+		switch strings.ToLower(attr.Key) {
+		case "property", "name":
+			if key == "" {
+				key = strings.ToLower(strings.TrimSpace(attr.Val))
+			}
+		case "content":
+			content = attr.Val
+		}
+	}
+	return key, content
+}
+
+func build(meta map[string]string, title string, base *url.URL) *Preview {
+	first := func(keys ...string) string {
+		for _, key := range keys {
+			if value := strings.TrimSpace(meta[key]); value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+	linkPreview := &Preview{
+		Title:       first("og:title", "twitter:title"),
+		Description: first("og:description", "twitter:description", "description"),
+		Image:       resolveImage(first("og:image", "og:image:url", "twitter:image", "twitter:image:src"), base),
+		SiteName:    first("og:site_name"),
+	}
+	if linkPreview.Title == "" {
+		linkPreview.Title = strings.TrimSpace(title)
+	}
+	linkPreview.Title = truncate(strings.Join(strings.Fields(linkPreview.Title), " "), maxTitleLength)
+	linkPreview.Description = truncate(strings.Join(strings.Fields(linkPreview.Description), " "), maxDescriptionLength)
+	linkPreview.SiteName = truncate(linkPreview.SiteName, maxTitleLength)
+	return linkPreview
+}
+
+// resolveImage делает адрес картинки абсолютным и отбрасывает все, кроме http(s)
+func resolveImage(rawImage string, base *url.URL) string {
+	if rawImage == "" {
+		return ""
+	}
+	imageURL, err := url.Parse(rawImage)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		imageURL = base.ResolveReference(imageURL)
+	}
+	if imageURL.Scheme != "http" && imageURL.Scheme != "https" {
+		return ""
+	}
+	return imageURL.String()
+}
+
+func truncate(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	return string([]rune(text)[:limit])
+}
@@ -0,0 +1,213 @@
+package preview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+const (
+	DefaultTimeout  = 5 * time.Second
+	DefaultMaxSize  = 512 << 10
+	DefaultCacheTTL = 24 * time.Hour
+	maxRedirects    = 3
+	userAgent       = "redditclone-preview/1.0"
+)
+
+var (
+	ErrBadURL      = errors.New("bad preview url")
+	ErrBlockedHost = errors.New("preview host is not allowed")
+	ErrNotHTML     = errors.New("preview target is not an html page")
+	ErrBadStatus   = errors.New("preview target returned bad status")
+)
+
+type Preview struct {
+	Title       string `json:"title,omitempty" bson:"title,omitempty"`
+	Description string `json:"description,omitempty" bson:"description,omitempty"`
+	Image       string `json:"image,omitempty" bson:"image,omitempty"`
+	SiteName    string `json:"siteName,omitempty" bson:"siteName,omitempty"`
+}
+
+func (p *Preview) Empty() bool {
+	return p.Title == "" && p.Description == "" && p.Image == ""
+}
+
+type Fetcher struct {
+	Client   *http.Client
+	MaxSize  int64
+	Cache    Cache
+	CacheTTL time.Duration
+}
+
+// NewFetcher - клиент ходит только на публичные адреса: адрес проверяется
+// после резолва прямо перед соединением, поэтому DNS rebinding и редиректы
+// во внутреннюю сеть тоже не проходят
+func NewFetcher(cache Cache) *Fetcher {
+	return newFetcher(cache, IsBlocked)
+}
+
+// PublicDialer соединяется только с адресами, которые не отсекает blocked. Адрес проверяется после
+// резолва прямо перед соединением, поэтому его нельзя подменить ни DNS, ни редиректом. Им же ходят вебхуки
+func PublicDialer(timeout time.Duration, blocked func(ip net.IP) bool) *net.Dialer {
+	return &net.Dialer{
+		Timeout: timeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || blocked(ip) {
+				return ErrBlockedHost
+			}
+			return nil
+		},
+	}
+}
+
+func newFetcher(cache Cache, blocked func(ip net.IP) bool) *Fetcher {
+	dialer := PublicDialer(DefaultTimeout, blocked)
+	transport := &http.Transport{
+		// прокси из окружения не используем, иначе проверка адреса теряет смысл
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   DefaultTimeout,
+		ResponseHeaderTimeout: DefaultTimeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+	return &Fetcher{
+		Client: &http.Client{
+			Transport: transport,
+			Timeout:   DefaultTimeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return fmt.Errorf("too many redirects")
+				}
+				return checkURL(req.URL)
+			},
+		},
+		MaxSize:  DefaultMaxSize,
+		Cache:    cache,
+		CacheTTL: DefaultCacheTTL,
+	}
+}
+
+var privateNets = mustParseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+// IsBlocked - адреса, на которые превью не ходит: локальные, приватные, служебные
+func IsBlocked(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, ipNet := range privateNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkURL(target *url.URL) error {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return ErrBadURL
+	}
+	if target.Hostname() == "" || target.User != nil {
+		return ErrBadURL
+	}
+	return nil
+}
+
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Preview, error) {
+	if f.Cache != nil {
+		cached, err := f.Cache.Get(ctx, rawURL)
+		if err == nil {
+			return cached, nil
+		}
+		if !errors.Is(err, ErrCacheMiss) {
+			return nil, err
+		}
+	}
+	linkPreview, err := f.fetch(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if f.Cache != nil {
+		err = f.Cache.Set(ctx, rawURL, linkPreview, f.CacheTTL)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return linkPreview, nil
+}
+
+func (f *Fetcher) fetch(ctx context.Context, rawURL string) (*Preview, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, ErrBadURL
+	}
+	if err = checkURL(target); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html")
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrBlockedHost) || errors.Is(err, ErrBadURL) {
+			return nil, ErrBlockedHost
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrBadStatus
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || (mediaType != "text/html" && mediaType != "application/xhtml+xml") {
+		return nil, ErrNotHTML
+	}
+	// нужные теги лежат в head, поэтому хвост больше лимита просто не читаем
+	return parse(io.LimitReader(resp.Body, f.MaxSize), resp.Request.URL), nil
+}
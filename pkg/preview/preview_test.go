@@ -0,0 +1,229 @@
+package preview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const testPage = `<!DOCTYPE html>
+<html><head>
+<title>  Запасной   заголовок </title>
+<meta property="og:title" content="Заголовок статьи">
+<meta name="twitter:title" content="Другой заголовок">
+<meta name="twitter:description" content="Описание   статьи">
+<meta property="og:image" content="/img/cover.png">
+<meta property="og:site_name" content="Тестовый сайт">
+</head><body><meta property="og:description" content="из body не берем"></body></html>`
+
+func allowAll(_ net.IP) bool {
+	return false
+}
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/article":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, testPage)
+		case "/redirect":
+			http.Redirect(w, r, "/article", http.StatusFound)
+		case "/file":
+			w.Header().Set("Content-Type", "application/pdf")
+			fmt.Fprint(w, "%PDF")
+		case "/big":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<html><head>"+strings.Repeat("<!-- padding -->", 1000)+`<meta property="og:title" content="далеко"></head></html>`)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, testPage)
+		case "/ftp":
+			http.Redirect(w, r, "ftp://example.com/file", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	fetcher := newFetcher(nil, allowAll)
+
+	// OpenGraph важнее Twitter Card, картинка становится абсолютной
+	linkPreview, err := fetcher.Fetch(context.Background(), server.URL+"/redirect")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := Preview{
+		Title:       "Заголовок статьи",
+		Description: "Описание статьи",
+		Image:       server.URL + "/img/cover.png",
+		SiteName:    "Тестовый сайт",
+	}
+	if *linkPreview != expected {
+		t.Errorf("wrong preview: expected %+v, got %+v", expected, *linkPreview)
+	}
+
+	// не html
+	_, err = fetcher.Fetch(context.Background(), server.URL+"/file")
+	if !errors.Is(err, ErrNotHTML) {
+		t.Errorf("wrong error: expected %s, got %v", ErrNotHTML, err)
+	}
+
+	// плохой статус
+	_, err = fetcher.Fetch(context.Background(), server.URL+"/missing")
+	if !errors.Is(err, ErrBadStatus) {
+		t.Errorf("wrong error: expected %s, got %v", ErrBadStatus, err)
+	}
+
+	// редирект на чужую схему
+	_, err = fetcher.Fetch(context.Background(), server.URL+"/ftp")
+	if !errors.Is(err, ErrBlockedHost) {
+		t.Errorf("wrong error: expected %s, got %v", ErrBlockedHost, err)
+	}
+
+	// схема не http
+	_, err = fetcher.Fetch(context.Background(), "file:///etc/passwd")
+	if !errors.Is(err, ErrBadURL) {
+		t.Errorf("wrong error: expected %s, got %v", ErrBadURL, err)
+	}
+
+	// дальше лимита не читаем
+	fetcher.MaxSize = 1024
+	linkPreview, err = fetcher.Fetch(context.Background(), server.URL+"/big")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if linkPreview.Title != "" {
+		t.Errorf("tags after size limit must be ignored, got %q", linkPreview.Title)
+	}
+
+	// таймаут
+	fetcher.Client.Timeout = 50 * time.Millisecond
+	_, err = fetcher.Fetch(context.Background(), server.URL+"/slow")
+	if err == nil {
+		t.Errorf("expected timeout error, got nil")
+	}
+}
+
+func TestFetchBlocksPrivateHosts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request to private host must not be sent")
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher(nil)
+	_, err := fetcher.Fetch(context.Background(), server.URL)
+	if !errors.Is(err, ErrBlockedHost) {
+		t.Errorf("wrong error: expected %s, got %v", ErrBlockedHost, err)
+	}
+	_, err = fetcher.Fetch(context.Background(), strings.Replace(server.URL, "127.0.0.1", "localhost", 1))
+	if !errors.Is(err, ErrBlockedHost) {
+		t.Errorf("wrong error: expected %s, got %v", ErrBlockedHost, err)
+	}
+
+	cases := []struct {
+		ip      string
+		blocked bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.20.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"::1", true},
+		{"fd00::1", true},
+		{"fe80::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"8.8.8.8", false},
+		{"2001:4860:4860::8888", false},
+	}
+	for _, testCase := range cases {
+		if IsBlocked(net.ParseIP(testCase.ip)) != testCase.blocked {
+			t.Errorf("%s: expected blocked %t", testCase.ip, testCase.blocked)
+		}
+	}
+}
+
+// fakeRedis - минимальный redis.Conn для GET и SET
+type fakeRedis struct {
+	data map[string][]byte
+	ttl  map[string]interface{}
+}
+
+func (f *fakeRedis) Close() error { return nil }
+func (f *fakeRedis) Err() error   { return nil }
+func (f *fakeRedis) Send(_ string, _ ...interface{}) error {
+	return nil
+}
+func (f *fakeRedis) Flush() error { return nil }
+func (f *fakeRedis) Receive() (interface{}, error) {
+	return nil, nil
+}
+func (f *fakeRedis) ReceiveContext(_ context.Context) (interface{}, error) {
+	return nil, nil
+}
+func (f *fakeRedis) DoContext(_ context.Context, command string, args ...interface{}) (interface{}, error) {
+	return f.Do(command, args...)
+}
+
+func (f *fakeRedis) Do(command string, args ...interface{}) (interface{}, error) {
+	if command == "" {
+		return nil, nil
+	}
+	key := args[0].(string)
+	switch command {
+	case "GET":
+		value, ok := f.data[key]
+		if !ok {
+			return nil, nil
+		}
+		return value, nil
+	case "SET":
+		f.data[key] = args[1].([]byte)
+		f.ttl[key] = args[3]
+		return "OK", nil
+	}
+	return nil, fmt.Errorf("unexpected command %s", command)
+}
+
+var _ redis.ConnWithContext = &fakeRedis{}
+
+func TestFetchCache(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, testPage)
+	}))
+	defer server.Close()
+
+	redisConn := &fakeRedis{data: make(map[string][]byte), ttl: make(map[string]interface{})}
+	fetcher := newFetcher(NewRedisCache(&redis.Pool{Dial: func() (redis.Conn, error) { return redisConn, nil }}), allowAll)
+
+	// второй раз страница берется из кэша
+	for i := 0; i < 2; i++ {
+		linkPreview, err := fetcher.Fetch(context.Background(), server.URL+"/article")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if linkPreview.Title != "Заголовок статьи" {
+			t.Errorf("wrong title %q", linkPreview.Title)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected 1 request, got %d", requests)
+	}
+	if redisConn.ttl[cachePrefix+server.URL+"/article"] != int(DefaultCacheTTL.Seconds()) {
+		t.Errorf("wrong cache ttl %v", redisConn.ttl[cachePrefix+server.URL+"/article"])
+	}
+}
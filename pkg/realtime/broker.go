@@ -0,0 +1,114 @@
+package realtime
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	DefaultRedisChannel = "realtime:events"
+	maxReconnectDelay   = 30 * time.Second
+	// DefaultRedisTimeout - сколько ждем redis на одну команду брокера или истории
+	DefaultRedisTimeout = time.Second
+)
+
+// do берет соединение из пула на одну команду: зависший redis держит только его,
+// а отмена контекста закрывает соединение, не задевая остальные
+func do(ctx context.Context, redisPool *redis.Pool, timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	if timeout == 0 {
+		timeout = DefaultRedisTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := redisPool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return redis.DoContext(conn, ctx, cmd, args...)
+}
+
+// RedisBroker публикует через пул, а слушает через отдельное соединение,
+// потому что соединение в режиме SUBSCRIBE больше ни для чего не годится
+type RedisBroker struct {
+	RedisPool *redis.Pool
+	Dial      func() (redis.Conn, error)
+	Channel   string
+	// Timeout - срок одной публикации, 0 - DefaultRedisTimeout
+	Timeout time.Duration
+}
+
+func NewRedisBroker(redisPool *redis.Pool, dial func() (redis.Conn, error)) *RedisBroker {
+	return &RedisBroker{
+		RedisPool: redisPool,
+		Dial:      dial,
+		Channel:   DefaultRedisChannel,
+	}
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, payload []byte) error {
+	_, err := do(ctx, b.RedisPool, b.Timeout, "PUBLISH", b.Channel, payload)
+	return err
+}
+
+// Run переподключается с растущей паузой, пока не закроют stop
+func (b *RedisBroker) Run(stop <-chan struct{}, deliver func(payload []byte), onError func(err error)) {
+	delay := time.Second
+	for {
+		subscribed, err := b.listen(stop, deliver)
+		if subscribed {
+			delay = time.Second
+		}
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if err != nil && onError != nil {
+			onError(fmt.Errorf("realtime subscription: %w", err))
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+func (b *RedisBroker) listen(stop <-chan struct{}, deliver func(payload []byte)) (bool, error) {
+	conn, err := b.Dial()
+	if err != nil {
+		return false, err
+	}
+	pubSub := redis.PubSubConn{Conn: conn}
+	defer pubSub.Close()
+	err = pubSub.Subscribe(b.Channel)
+	if err != nil {
+		return false, err
+	}
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		// закрытие соединения прерывает блокирующий Receive
+		select {
+		case <-stop:
+			pubSub.Close()
+		case <-finished:
+		}
+	}()
+	for {
+		switch received := pubSub.Receive().(type) {
+		case redis.Message:
+			deliver(received.Data)
+		case error:
+			return true, received
+		}
+	}
+}
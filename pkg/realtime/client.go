@@ -0,0 +1,161 @@
+package realtime
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// SendBuffer - сколько событий может ждать отправки, дальше клиент считается медленным
+	SendBuffer     = 64
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = pongWait * 9 / 10
+	maxRequestSize = 1024
+)
+
+// Client - одно websocket соединение, читает команды подписки и пишет события.
+// У SSE клиента conn нет, его события читает ServeStream
+type Client struct {
+	UserID    string
+	hub       *Hub
+	conn      *websocket.Conn
+	send      chan []byte
+	done      chan struct{}
+	closeOnce *sync.Once
+	closeCode int
+	closeText string
+	// channels меняется только под hub.mu
+	channels map[string]struct{}
+}
+
+type request struct {
+	Action  string `json:"action"`
+	Channel string `json:"channel"`
+}
+
+// Serve обслуживает соединение, пока клиент не отключится или не будет отключен
+func (h *Hub) Serve(conn *websocket.Conn, userID string, channels []string) {
+	client := newClient(h, userID, conn)
+	if len(channels) > maxSubscriptions {
+		channels = channels[:maxSubscriptions]
+	}
+	for _, channel := range channels {
+		client.handle(&request{Action: "subscribe", Channel: channel})
+	}
+	go client.writePump()
+	client.readPump()
+}
+
+func newClient(h *Hub, userID string, conn *websocket.Conn) *Client {
+	return &Client{
+		UserID:    userID,
+		hub:       h,
+		conn:      conn,
+		send:      make(chan []byte, SendBuffer),
+		done:      make(chan struct{}),
+		closeOnce: &sync.Once{},
+		channels:  make(map[string]struct{}),
+	}
+}
+
+// enqueue никогда не блокируется: Deliver зовет его под hub.mu для всех подписчиков сразу
+func (c *Client) enqueue(payload []byte) {
+	select {
+	case c.send <- payload:
+	default:
+		c.close(websocket.CloseTryAgainLater, errSlowConsumer.Error())
+	}
+}
+
+func (c *Client) close(code int, text string) {
+	c.closeOnce.Do(func() {
+		c.closeCode = code
+		c.closeText = text
+		close(c.done)
+	})
+}
+
+func (c *Client) readPump() {
+	defer func() {
+		c.hub.unsubscribeAll(c)
+		c.close(websocket.CloseNormalClosure, "")
+	}()
+	c.conn.SetReadLimit(maxRequestSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		req := &request{}
+		if json.Unmarshal(data, req) != nil {
+			c.reply(&Event{Type: EventError, Data: "bad request"})
+			continue
+		}
+		c.handle(req)
+	}
+}
+
+func (c *Client) handle(req *request) {
+	var channel string
+	var err error
+	kind := EventSubscribed
+	switch req.Action {
+	case "subscribe":
+		channel, err = c.hub.subscribe(c, req.Channel)
+	case "unsubscribe":
+		kind = EventUnsubscribed
+		channel, err = c.hub.unsubscribe(c, req.Channel)
+	default:
+		c.reply(&Event{Channel: req.Channel, Type: EventError, Data: "unknown action"})
+		return
+	}
+	if err != nil {
+		c.reply(&Event{Channel: req.Channel, Type: EventError, Data: err.Error()})
+		return
+	}
+	c.reply(&Event{Channel: channel, Type: kind})
+}
+
+func (c *Client) reply(event *Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	c.enqueue(payload)
+}
+
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+	for {
+		select {
+		case payload := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ticker.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-c.done:
+			message := websocket.FormatCloseMessage(c.closeCode, c.closeText)
+			_ = c.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeWait))
+			return
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package realtime
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	DefaultHistoryPrefix = "realtime:stream:"
+	// DefaultHistoryLength - сколько последних событий канала можно дослать, стрим обрезается примерно до этого размера
+	DefaultHistoryLength = 1000
+	// DefaultHistoryTTL - стрим канала, в который давно ничего не писали, удаляется целиком
+	DefaultHistoryTTL = 24 * time.Hour
+)
+
+var streamIDRe = regexp.MustCompile(`^[0-9]+-[0-9]+$`)
+
+// ValidEventID отсекает Last-Event-ID, который не мог выдать redis
+func ValidEventID(id string) bool {
+	return streamIDRe.MatchString(id)
+}
+
+// RedisHistory хранит события каждого канала в коротком redis стриме
+type RedisHistory struct {
+	RedisPool *redis.Pool
+	Prefix    string
+	Length    int
+	TTL       time.Duration
+	// Timeout - срок одной команды, 0 - DefaultRedisTimeout
+	Timeout time.Duration
+}
+
+func NewRedisHistory(redisPool *redis.Pool) *RedisHistory {
+	return &RedisHistory{
+		RedisPool: redisPool,
+		Prefix:    DefaultHistoryPrefix,
+		Length:    DefaultHistoryLength,
+		TTL:       DefaultHistoryTTL,
+	}
+}
+
+func (rh *RedisHistory) Append(ctx context.Context, channel string, payload []byte) (string, error) {
+	key := rh.Prefix + channel
+	id, err := redis.String(do(ctx, rh.RedisPool, rh.Timeout, "XADD", key, "MAXLEN", "~", rh.Length, "*", "event", payload))
+	if err != nil {
+		return "", err
+	}
+	_, err = do(ctx, rh.RedisPool, rh.Timeout, "EXPIRE", key, int64(rh.TTL.Seconds()))
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Since отдает события строго после lastID. XREAD без BLOCK не ждет новых событий
+// и, в отличие от XRANGE с исключающей границей, работает и на redis 5
+func (rh *RedisHistory) Since(ctx context.Context, channel, lastID string) ([]*Entry, error) {
+	streams, err := redis.Values(do(ctx, rh.RedisPool, rh.Timeout, "XREAD", "COUNT", rh.Length, "STREAMS", rh.Prefix+channel, lastID))
+	if err != nil && err != redis.ErrNil {
+		return nil, err
+	}
+	if len(streams) == 0 {
+		return []*Entry{}, nil
+	}
+	// ответ: [[key, [[id, [field, value, ...]], ...]]]
+	stream, err := redis.Values(streams[0], nil)
+	if err != nil || len(stream) != 2 {
+		return nil, fmt.Errorf("unexpected XREAD reply: %v", streams[0])
+	}
+	rawEntries, err := redis.Values(stream[1], nil)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*Entry, 0, len(rawEntries))
+	for _, rawEntry := range rawEntries {
+		entry, err := redis.Values(rawEntry, nil)
+		if err != nil || len(entry) != 2 {
+			return nil, fmt.Errorf("unexpected stream entry: %v", rawEntry)
+		}
+		id, err := redis.String(entry[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		fields, err := redis.StringMap(entry[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &Entry{ID: id, Payload: []byte(fields["event"])})
+	}
+	return entries, nil
+}
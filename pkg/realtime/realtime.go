@@ -0,0 +1,223 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	EventSubscribed   = "subscribed"
+	EventUnsubscribed = "unsubscribed"
+	EventError        = "error"
+)
+
+const (
+	// ChannelNotifications - алиас, под которым клиент подписывается на свой user:<id>
+	ChannelNotifications = "notifications"
+	maxChannelSize       = 128
+	maxSubscriptions     = 50
+	// DefaultPublishTimeout - публикуют запросы, которые уже записали изменение в базу,
+	// история и брокер вместе не должны задерживать их ответ дольше этого
+	DefaultPublishTimeout = 300 * time.Millisecond
+)
+
+var (
+	ErrBadChannel      = errors.New("unknown channel")
+	ErrNeedAuth        = errors.New("channel requires authorization")
+	ErrTooManyChannels = errors.New("too many subscriptions")
+	ErrNotSubscribed   = errors.New("not subscribed to channel")
+	errSlowConsumer    = errors.New("slow consumer")
+)
+
+func PostChannel(postID string) string {
+	return "post:" + postID
+}
+
+func CategoryChannel(category string) string {
+	return "category:" + category
+}
+
+func UserChannel(userID string) string {
+	return "user:" + userID
+}
+
+// Event уходит клиенту как есть, Data - то, что передал издатель.
+// ID есть только у событий, записанных в историю канала
+type Event struct {
+	ID      string      `json:"id,omitempty"`
+	Channel string      `json:"channel"`
+	Type    string      `json:"type"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// Broker разносит события между репликами, без него события живут только внутри процесса
+type Broker interface {
+	Publish(ctx context.Context, payload []byte) error
+	Run(stop <-chan struct{}, deliver func(payload []byte), onError func(err error))
+}
+
+// History хранит последние события канала, чтобы переподключившийся клиент получил пропущенное
+type History interface {
+	Append(ctx context.Context, channel string, payload []byte) (string, error)
+	Since(ctx context.Context, channel, lastID string) ([]*Entry, error)
+}
+
+// Entry - событие из истории, Payload без ID
+type Entry struct {
+	ID      string
+	Payload []byte
+}
+
+// Hub держит подписки клиентов этой реплики
+type Hub struct {
+	mu          *sync.RWMutex
+	subscribers map[string]map[*Client]struct{}
+	Broker      Broker
+	History     History
+	// PublishTimeout - срок всей публикации, 0 - DefaultPublishTimeout
+	PublishTimeout time.Duration
+}
+
+func NewHub(broker Broker) *Hub {
+	return &Hub{
+		mu:             &sync.RWMutex{},
+		subscribers:    make(map[string]map[*Client]struct{}),
+		Broker:         broker,
+		PublishTimeout: DefaultPublishTimeout,
+	}
+}
+
+// Publish сначала пишет событие в историю, чтобы живое событие пришло уже с ID.
+// Если история недоступна, событие все равно уходит подписчикам, только без ID, а если недоступен
+// брокер - подписчикам этой реплики. Контекст свой: событие не должно пропасть из-за того,
+// что вызвавший его клиент отключился, а зависший redis держит публикацию не дольше PublishTimeout
+func (h *Hub) Publish(channel, kind string, data interface{}) error {
+	timeout := h.PublishTimeout
+	if timeout <= 0 {
+		timeout = DefaultPublishTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	event := &Event{Channel: channel, Type: kind, Data: data}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	var historyErr error
+	if h.History != nil && hasHistory(channel) {
+		event.ID, historyErr = h.History.Append(ctx, channel, payload)
+		if historyErr == nil {
+			payload, err = json.Marshal(event)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if h.Broker == nil {
+		h.Deliver(payload)
+		return historyErr
+	}
+	brokerErr := h.Broker.Publish(ctx, payload)
+	if brokerErr != nil {
+		h.Deliver(payload)
+	}
+	return errors.Join(historyErr, brokerErr)
+}
+
+// hasHistory - история нужна только публичным лентам, уведомления и так лежат в базе
+func hasHistory(channel string) bool {
+	return strings.HasPrefix(channel, PostChannel("")) || strings.HasPrefix(channel, CategoryChannel(""))
+}
+
+// Run принимает события от брокера, в том числе свои же, и раздает их подписчикам
+func (h *Hub) Run(stop <-chan struct{}, onError func(err error)) {
+	if h.Broker != nil {
+		h.Broker.Run(stop, h.Deliver, onError)
+	}
+}
+
+// Deliver не ждет медленных клиентов: у кого переполнен буфер, того отключаем
+func (h *Hub) Deliver(payload []byte) {
+	event := &struct {
+		Channel string `json:"channel"`
+	}{}
+	if json.Unmarshal(payload, event) != nil {
+		return
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for client := range h.subscribers[event.Channel] {
+		client.enqueue(payload)
+	}
+}
+
+// resolve проверяет, что клиенту можно слушать канал, и переводит алиасы в настоящие имена
+func (h *Hub) resolve(client *Client, channel string) (string, error) {
+	if channel == ChannelNotifications {
+		if client.UserID == "" {
+			return "", ErrNeedAuth
+		}
+		return UserChannel(client.UserID), nil
+	}
+	if len(channel) > maxChannelSize {
+		return "", ErrBadChannel
+	}
+	for _, prefix := range []string{PostChannel(""), CategoryChannel("")} {
+		if strings.HasPrefix(channel, prefix) && len(channel) > len(prefix) {
+			return channel, nil
+		}
+	}
+	return "", ErrBadChannel
+}
+
+func (h *Hub) subscribe(client *Client, channel string) (string, error) {
+	channel, err := h.resolve(client, channel)
+	if err != nil {
+		return "", err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := client.channels[channel]; !ok && len(client.channels) >= maxSubscriptions {
+		return "", ErrTooManyChannels
+	}
+	if h.subscribers[channel] == nil {
+		h.subscribers[channel] = make(map[*Client]struct{})
+	}
+	h.subscribers[channel][client] = struct{}{}
+	client.channels[channel] = struct{}{}
+	return channel, nil
+}
+
+func (h *Hub) unsubscribe(client *Client, channel string) (string, error) {
+	channel, err := h.resolve(client, channel)
+	if err != nil {
+		return "", err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := client.channels[channel]; !ok {
+		return "", ErrNotSubscribed
+	}
+	h.remove(client, channel)
+	return channel, nil
+}
+
+func (h *Hub) unsubscribeAll(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for channel := range client.channels {
+		h.remove(client, channel)
+	}
+}
+
+func (h *Hub) remove(client *Client, channel string) {
+	delete(client.channels, channel)
+	delete(h.subscribers[channel], client)
+	if len(h.subscribers[channel]) == 0 {
+		delete(h.subscribers, channel)
+	}
+}
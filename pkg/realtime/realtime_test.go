@@ -0,0 +1,284 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/gorilla/websocket"
+)
+
+// fakeBus - общий на все соединения redis, умеет только PUBLISH и SUBSCRIBE
+type fakeBus struct {
+	mu          sync.Mutex
+	subscribers []*fakeRedis
+}
+
+type fakeRedis struct {
+	bus     *fakeBus
+	replies chan []interface{}
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func newFakeRedis(bus *fakeBus) *fakeRedis {
+	return &fakeRedis{bus: bus, replies: make(chan []interface{}, 16), closed: make(chan struct{})}
+}
+
+func (f *fakeRedis) Close() error {
+	f.once.Do(func() { close(f.closed) })
+	return nil
+}
+func (f *fakeRedis) Err() error   { return nil }
+func (f *fakeRedis) Flush() error { return nil }
+
+func (f *fakeRedis) Send(command string, args ...interface{}) error {
+	if command != "SUBSCRIBE" {
+		return fmt.Errorf("unexpected command %s", command)
+	}
+	f.bus.mu.Lock()
+	f.bus.subscribers = append(f.bus.subscribers, f)
+	f.bus.mu.Unlock()
+	f.replies <- []interface{}{[]byte("subscribe"), []byte(args[0].(string)), int64(1)}
+	return nil
+}
+
+func (f *fakeRedis) Receive() (interface{}, error) {
+	select {
+	case reply := <-f.replies:
+		return reply, nil
+	case <-f.closed:
+		return nil, errors.New("connection closed")
+	}
+}
+
+func (f *fakeRedis) ReceiveContext(_ context.Context) (interface{}, error) {
+	return f.Receive()
+}
+
+func (f *fakeRedis) DoContext(_ context.Context, command string, args ...interface{}) (interface{}, error) {
+	return f.Do(command, args...)
+}
+
+func (f *fakeRedis) Do(command string, args ...interface{}) (interface{}, error) {
+	if command == "" {
+		// пул так проверяет соединение перед возвратом
+		return nil, nil
+	}
+	if command != "PUBLISH" {
+		return nil, fmt.Errorf("unexpected command %s", command)
+	}
+	f.bus.mu.Lock()
+	defer f.bus.mu.Unlock()
+	for _, subscriber := range f.bus.subscribers {
+		subscriber.replies <- []interface{}{[]byte("message"), []byte(args[0].(string)), args[1].([]byte)}
+	}
+	return int64(len(f.bus.subscribers)), nil
+}
+
+// fakePool раздает одно и то же соединение
+func fakePool(conn redis.Conn) *redis.Pool {
+	return &redis.Pool{MaxIdle: 1, Dial: func() (redis.Conn, error) { return conn, nil }}
+}
+
+func connect(t *testing.T, hub *Hub, userID, query string) *websocket.Conn {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		hub.Serve(conn, userID, r.URL.Query()["subscribe"])
+	}))
+	t.Cleanup(server.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+query, nil)
+	if err != nil {
+		t.Fatalf("dial error: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func read(t *testing.T, conn *websocket.Conn) string {
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+	return string(data)
+}
+
+func TestHub(t *testing.T) {
+	hub := NewHub(nil)
+
+	// подписка из запроса и сообщением, анониму уведомления недоступны
+	conn := connect(t, hub, "", "?subscribe=post:42&subscribe=notifications")
+	if got := read(t, conn); got != `{"channel":"post:42","type":"subscribed"}` {
+		t.Errorf("wrong ack: %s", got)
+		return
+	}
+	if got := read(t, conn); got != `{"channel":"notifications","type":"error","data":"channel requires authorization"}` {
+		t.Errorf("wrong error: %s", got)
+		return
+	}
+	err := conn.WriteMessage(websocket.TextMessage, []byte(`{"action": "subscribe", "channel": "category:music"}`))
+	if err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+	if got := read(t, conn); got != `{"channel":"category:music","type":"subscribed"}` {
+		t.Errorf("wrong ack: %s", got)
+		return
+	}
+
+	// события чужих каналов не приходят
+	if err = hub.Publish("post:43", "vote", map[string]int{"score": 1}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if err = hub.Publish(PostChannel("42"), "vote", map[string]int{"score": 2}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if got := read(t, conn); got != `{"channel":"post:42","type":"vote","data":{"score":2}}` {
+		t.Errorf("wrong event: %s", got)
+		return
+	}
+
+	// свои уведомления по алиасу
+	userConn := connect(t, hub, "user_id", "?subscribe=notifications")
+	if got := read(t, userConn); got != `{"channel":"user:user_id","type":"subscribed"}` {
+		t.Errorf("wrong ack: %s", got)
+		return
+	}
+	err = userConn.WriteMessage(websocket.TextMessage, []byte(`{"action": "subscribe", "channel": "user:another"}`))
+	if err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+	if got := read(t, userConn); !strings.Contains(got, `"type":"error"`) {
+		t.Errorf("foreign user channel must be rejected: %s", got)
+		return
+	}
+}
+
+func TestSlowConsumer(t *testing.T) {
+	hub := NewHub(nil)
+	client := &Client{
+		hub:       hub,
+		send:      make(chan []byte, 1),
+		done:      make(chan struct{}),
+		closeOnce: &sync.Once{},
+		channels:  make(map[string]struct{}),
+	}
+	if _, err := hub.subscribe(client, "post:42"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// первое событие ложится в буфер, второе не помещается - клиента отключаем, а не ждем
+	for i := 0; i < 2; i++ {
+		if err := hub.Publish("post:42", "vote", i); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	select {
+	case <-client.done:
+	default:
+		t.Errorf("slow client must be closed")
+		return
+	}
+	if client.closeCode != websocket.CloseTryAgainLater {
+		t.Errorf("wrong close code: %d", client.closeCode)
+		return
+	}
+	hub.unsubscribeAll(client)
+	if len(hub.subscribers) != 0 {
+		t.Errorf("subscriptions must be removed: %v", hub.subscribers)
+		return
+	}
+}
+
+func TestRedisBroker(t *testing.T) {
+	bus := &fakeBus{}
+	dial := func() (redis.Conn, error) {
+		return newFakeRedis(bus), nil
+	}
+
+	// две реплики с общим redis: событие с одной доходит до клиентов другой
+	first := NewHub(NewRedisBroker(fakePool(newFakeRedis(bus)), dial))
+	second := NewHub(NewRedisBroker(fakePool(newFakeRedis(bus)), dial))
+	stop := make(chan struct{})
+	defer close(stop)
+	for _, hub := range []*Hub{first, second} {
+		go hub.Run(stop, func(err error) {
+			t.Errorf("unexpected error: %s", err)
+		})
+	}
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		bus.mu.Lock()
+		subscribed := len(bus.subscribers)
+		bus.mu.Unlock()
+		if subscribed == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("brokers did not subscribe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	conn := connect(t, second, "", "?subscribe=category:music")
+	if got := read(t, conn); got != `{"channel":"category:music","type":"subscribed"}` {
+		t.Errorf("wrong ack: %s", got)
+		return
+	}
+	if err := first.Publish(CategoryChannel("music"), "post_added", "new post"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if got := read(t, conn); got != `{"channel":"category:music","type":"post_added","data":"new post"}` {
+		t.Errorf("wrong event: %s", got)
+		return
+	}
+}
+
+// hangingBroker - redis не отвечает, публикация ждет, пока не истечет ее контекст
+type hangingBroker struct{}
+
+func (hangingBroker) Publish(ctx context.Context, _ []byte) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (hangingBroker) Run(stop <-chan struct{}, _ func(payload []byte), _ func(err error)) {
+	<-stop
+}
+
+func TestPublishTimeout(t *testing.T) {
+	hub := NewHub(hangingBroker{})
+	hub.PublishTimeout = 20 * time.Millisecond
+	conn := connect(t, hub, "", "?subscribe=post:42")
+	if got := read(t, conn); got != `{"channel":"post:42","type":"subscribed"}` {
+		t.Errorf("wrong ack: %s", got)
+		return
+	}
+
+	// зависший брокер держит публикацию не дольше срока, событие доходит хотя бы до своей реплики
+	start := time.Now()
+	err := hub.Publish(PostChannel("42"), "vote", 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		return
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("publish took %s", elapsed)
+		return
+	}
+	if got := read(t, conn); got != `{"channel":"post:42","type":"vote","data":1}` {
+		t.Errorf("wrong event: %s", got)
+	}
+}
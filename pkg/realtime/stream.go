@@ -0,0 +1,140 @@
+package realtime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// streamRetry - через сколько браузер переподключится сам после обрыва
+	streamRetry     = 3 * time.Second
+	streamKeepAlive = 30 * time.Second
+)
+
+var ErrStreamingUnsupported = errors.New("streaming is not supported")
+
+// ServeStream отдает события одного канала как text/event-stream, пока клиент не отключится.
+// С Last-Event-ID сначала досылает пропущенное из истории. Ошибку возвращает только
+// до того, как начал писать ответ
+func (h *Hub) ServeStream(w http.ResponseWriter, r *http.Request, channel string) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return ErrStreamingUnsupported
+	}
+	client := newClient(h, "", nil)
+	// подписка до чтения истории, иначе события между ними потеряются
+	channel, err := h.subscribe(client, channel)
+	if err != nil {
+		return err
+	}
+	defer h.unsubscribeAll(client)
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if !ValidEventID(lastID) {
+		lastID = ""
+	}
+	backlog := []*Entry{}
+	if h.History != nil && lastID != "" {
+		backlog, err = h.History.Since(r.Context(), channel, lastID)
+		if err != nil {
+			return err
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// nginx иначе буферизует ответ целиком
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_, err = fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+	if err != nil {
+		return nil
+	}
+	for _, entry := range backlog {
+		err = writeStreamEvent(w, entry.ID, entry.Payload)
+		if err != nil {
+			return nil
+		}
+		lastID = entry.ID
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case payload := <-client.send:
+			event := &struct {
+				ID string `json:"id"`
+			}{}
+			if json.Unmarshal(payload, event) != nil {
+				continue
+			}
+			// уже отправлено из истории
+			if event.ID != "" && lastID != "" && !eventIDAfter(event.ID, lastID) {
+				continue
+			}
+			err = writeStreamEvent(w, event.ID, payload)
+			if event.ID != "" {
+				lastID = event.ID
+			}
+		case <-keepAlive.C:
+			_, err = io.WriteString(w, ": ping\n\n")
+		case <-client.done:
+			// медленный клиент: переподключится с Last-Event-ID и дочитает из истории
+			return nil
+		case <-r.Context().Done():
+			return nil
+		}
+		if err != nil {
+			return nil
+		}
+		flusher.Flush()
+	}
+}
+
+func writeStreamEvent(w io.Writer, id string, payload []byte) error {
+	event := &struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}{}
+	err := json.Unmarshal(payload, event)
+	if err != nil {
+		return err
+	}
+	if len(event.Data) == 0 {
+		event.Data = json.RawMessage("null")
+	}
+	if id != "" {
+		_, err = fmt.Fprintf(w, "id: %s\n", id)
+		if err != nil {
+			return err
+		}
+	}
+	// json.Marshal не оставляет переводов строк, так что data всегда в одну строку
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, event.Data)
+	return err
+}
+
+// eventIDAfter сравнивает id redis стрима вида <ms>-<seq>
+func eventIDAfter(id, other string) bool {
+	idTime, idSeq := splitEventID(id)
+	otherTime, otherSeq := splitEventID(other)
+	if idTime != otherTime {
+		return idTime > otherTime
+	}
+	return idSeq > otherSeq
+}
+
+func splitEventID(id string) (uint64, uint64) {
+	timePart, seqPart, _ := strings.Cut(id, "-")
+	ms, _ := strconv.ParseUint(timePart, 10, 64)
+	seq, _ := strconv.ParseUint(seqPart, 10, 64)
+	return ms, seq
+}
@@ -0,0 +1,274 @@
+package realtime
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+type memoryHistory struct {
+	mu      sync.Mutex
+	entries map[string][]*Entry
+	next    int
+}
+
+func (m *memoryHistory) Append(_ context.Context, channel string, payload []byte) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.next++
+	id := fmt.Sprintf("1700000000000-%d", m.next)
+	m.entries[channel] = append(m.entries[channel], &Entry{ID: id, Payload: payload})
+	return id, nil
+}
+
+func (m *memoryHistory) Since(_ context.Context, channel, lastID string) ([]*Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := make([]*Entry, 0)
+	for _, entry := range m.entries[channel] {
+		if eventIDAfter(entry.ID, lastID) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func openStream(t *testing.T, hub *Hub, channel, lastEventID string) *bufio.Reader {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := hub.ServeStream(w, r, channel); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request error: %s", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("wrong response: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+// readStreamEvent читает строки до пустой, пропуская retry
+func readStreamEvent(t *testing.T, reader *bufio.Reader) []string {
+	lines := make([]string, 0)
+	result := make(chan error, 1)
+	go func() {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				result <- err
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" && len(lines) > 0 {
+				result <- nil
+				return
+			}
+			if line != "" && !strings.HasPrefix(line, "retry:") {
+				lines = append(lines, line)
+			}
+		}
+	}()
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("read error: %s", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no event in stream")
+	}
+	return lines
+}
+
+func TestServeStream(t *testing.T) {
+	hub := NewHub(nil)
+	hub.History = &memoryHistory{entries: make(map[string][]*Entry)}
+
+	for i := 1; i <= 3; i++ {
+		if err := hub.Publish(CategoryChannel("music"), "post_added", map[string]int{"n": i}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	// в историю пишутся только публичные каналы
+	if err := hub.Publish(UserChannel("user_id"), "notification", "hidden"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// после переподключения досылается пропущенное, потом идут живые события
+	reader := openStream(t, hub, CategoryChannel("music"), "1700000000000-1")
+	expected := [][]string{
+		{"id: 1700000000000-2", "event: post_added", `data: {"n":2}`},
+		{"id: 1700000000000-3", "event: post_added", `data: {"n":3}`},
+	}
+	for _, want := range expected {
+		if got := readStreamEvent(t, reader); !reflect.DeepEqual(got, want) {
+			t.Errorf("wrong event: expected %v, got %v", want, got)
+			return
+		}
+	}
+	if err := hub.Publish(CategoryChannel("music"), "post_added", map[string]int{"n": 4}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []string{"id: 1700000000000-4", "event: post_added", `data: {"n":4}`}
+	if got := readStreamEvent(t, reader); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong live event: expected %v, got %v", want, got)
+		return
+	}
+
+	// без Last-Event-ID (или с мусором в нем) история не досылается
+	reader = openStream(t, hub, PostChannel("42"), "<script>")
+	if err := hub.Publish(PostChannel("42"), "vote", nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want = []string{"id: 1700000000000-5", "event: vote", "data: null"}
+	if got := readStreamEvent(t, reader); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong event: expected %v, got %v", want, got)
+		return
+	}
+}
+
+func TestServeStreamBadChannel(t *testing.T) {
+	hub := NewHub(nil)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	err := hub.ServeStream(httptest.NewRecorder(), req, UserChannel("user_id"))
+	if !errors.Is(err, ErrBadChannel) {
+		t.Errorf("expected ErrBadChannel, got %v", err)
+		return
+	}
+}
+
+func TestEventIDAfter(t *testing.T) {
+	cases := []struct {
+		id, other string
+		after     bool
+	}{
+		{"1700000000001-0", "1700000000000-5", true},
+		{"1700000000000-10", "1700000000000-9", true},
+		{"1700000000000-9", "1700000000000-9", false},
+		{"999-0", "1000-0", false},
+	}
+	for _, c := range cases {
+		if got := eventIDAfter(c.id, c.other); got != c.after {
+			t.Errorf("eventIDAfter(%s, %s): expected %t, got %t", c.id, c.other, c.after, got)
+		}
+	}
+}
+
+// streamRedis отвечает на XREAD заготовленным ответом и запоминает команды, с err соединение сломано
+type streamRedis struct {
+	fakeRedis
+	commands [][]interface{}
+	reply    interface{}
+	err      error
+}
+
+func (s *streamRedis) DoContext(_ context.Context, command string, args ...interface{}) (interface{}, error) {
+	return s.Do(command, args...)
+}
+
+func (s *streamRedis) Do(command string, args ...interface{}) (interface{}, error) {
+	if command == "" {
+		return nil, nil
+	}
+	s.commands = append(s.commands, append([]interface{}{command}, args...))
+	if s.err != nil {
+		return nil, s.err
+	}
+	switch command {
+	case "XADD":
+		return []byte("1700000000000-0"), nil
+	case "EXPIRE":
+		return int64(1), nil
+	case "XREAD":
+		return s.reply, nil
+	}
+	return nil, fmt.Errorf("unexpected command %s", command)
+}
+
+func TestRedisHistory(t *testing.T) {
+	conn := &streamRedis{}
+	history := NewRedisHistory(fakePool(conn))
+
+	id, err := history.Append(context.Background(), "post:42", []byte(`{"type":"vote"}`))
+	if err != nil || id != "1700000000000-0" {
+		t.Errorf("unexpected result: %s, %v", id, err)
+		return
+	}
+	expected := [][]interface{}{
+		{"XADD", "realtime:stream:post:42", "MAXLEN", "~", DefaultHistoryLength, "*", "event", []byte(`{"type":"vote"}`)},
+		{"EXPIRE", "realtime:stream:post:42", int64(86400)},
+	}
+	if !reflect.DeepEqual(conn.commands, expected) {
+		t.Errorf("wrong commands: %v", conn.commands)
+		return
+	}
+
+	// нет стрима - пустая история
+	entries, err := history.Since(context.Background(), "post:42", "1700000000000-0")
+	if err != nil || len(entries) != 0 {
+		t.Errorf("unexpected result: %v, %v", entries, err)
+		return
+	}
+
+	conn.reply = []interface{}{
+		[]interface{}{
+			[]byte("realtime:stream:post:42"),
+			[]interface{}{
+				[]interface{}{[]byte("1700000000000-1"), []interface{}{[]byte("event"), []byte(`{"type":"vote"}`)}},
+				[]interface{}{[]byte("1700000000000-2"), []interface{}{[]byte("event"), []byte(`{"type":"comment_added"}`)}},
+			},
+		},
+	}
+	entries, err = history.Since(context.Background(), "post:42", "1700000000000-0")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	expectedEntries := []*Entry{
+		{ID: "1700000000000-1", Payload: []byte(`{"type":"vote"}`)},
+		{ID: "1700000000000-2", Payload: []byte(`{"type":"comment_added"}`)},
+	}
+	if !reflect.DeepEqual(entries, expectedEntries) {
+		t.Errorf("wrong entries: %v", entries)
+		return
+	}
+}
+
+func TestRedisHistoryError(t *testing.T) {
+	// сломанное соединение - ошибка, а не пустая история
+	conn := &streamRedis{err: errors.New("connection reset")}
+	history := NewRedisHistory(fakePool(conn))
+	entries, err := history.Since(context.Background(), "post:42", "1700000000000-0")
+	if err == nil || entries != nil {
+		t.Errorf("expected error, got %v, %v", entries, err)
+	}
+
+	// redis недоступен вовсе
+	history = NewRedisHistory(&redis.Pool{Dial: func() (redis.Conn, error) {
+		return nil, errors.New("connection refused")
+	}})
+	entries, err = history.Since(context.Background(), "post:42", "1700000000000-0")
+	if err == nil || entries != nil {
+		t.Errorf("expected error, got %v, %v", entries, err)
+	}
+}
@@ -0,0 +1,235 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"reddit/pkg/post"
+	"reddit/pkg/user"
+)
+
+type ReportDBRepository interface {
+	FindItemDB(ctx context.Context, kind, postID, commentID string) (*Item, error)
+	GetItemByIDDB(ctx context.Context, itemID string) (*Item, error)
+	AddReportDB(ctx context.Context, item *Item, newReport *Report) error
+	GetAllItemsDB(ctx context.Context) ([]*Item, error)
+	DeleteItemDB(ctx context.Context, itemID string) error
+	DeletePostItemsDB(ctx context.Context, postID string) error
+}
+
+type ReportBusinessLogic struct {
+	mu           *sync.RWMutex
+	ReportDBRepo ReportDBRepository
+	PostRepo     post.PostRepo
+	Recorder     post.ActionRecorder
+	Spam         SpamTrainer
+	Tx           Transactor
+}
+
+// Transactor - транзакция монги из post.PostDBRepo: одобрение и запись журнала применяются вместе
+type Transactor interface {
+	InTransaction(ctx context.Context, work func(ctx context.Context) error) error
+}
+
+type noTransaction struct{}
+
+func (noTransaction) InTransaction(ctx context.Context, work func(ctx context.Context) error) error {
+	return work(ctx)
+}
+
+type SpamTrainer interface {
+	Train(ctx context.Context, text string, isSpam bool) error
+}
+
+type nopSpamTrainer struct{}
+
+func (nopSpamTrainer) Train(_ context.Context, _ string, _ bool) error {
+	return nil
+}
+
+func NewReportBusinessLogic(repo ReportDBRepository, postRepo post.PostRepo, recorder post.ActionRecorder) *ReportBusinessLogic {
+	return &ReportBusinessLogic{
+		mu:           &sync.RWMutex{},
+		ReportDBRepo: repo,
+		PostRepo:     postRepo,
+		Recorder:     recorder,
+		Spam:         nopSpamTrainer{},
+		Tx:           noTransaction{},
+	}
+}
+
+func getTimeOfCreation() string {
+	return time.Now().Format("2006-01-02T15:04:05.999Z")
+}
+
+func (r *ReportBusinessLogic) ReportPost(ctx context.Context, postID, reason string, reporter *user.User) (*Item, error) {
+	reportedPost, err := r.PostRepo.FindPostByID(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	// отложенный пост и так в очереди, а остальным его не видно
+	if reportedPost.Status == post.StatusHeld {
+		return nil, post.ErrNoPost
+	}
+	return r.addReport(ctx, &post.QueueRequest{
+		Target:    reportedPost,
+		SpamScore: reportedPost.SpamScore,
+	}, newReport(reporter.ID, reason))
+}
+
+func (r *ReportBusinessLogic) ReportComment(ctx context.Context, postID, commentID, reason string, reporter *user.User) (*Item, error) {
+	reportedPost, err := r.PostRepo.FindPostByID(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if reportedPost.Status == post.StatusHeld {
+		return nil, post.ErrNoPost
+	}
+	for _, currentComment := range reportedPost.Comments {
+		if currentComment.ID == commentID {
+			return r.addReport(ctx, &post.QueueRequest{
+				Target:    reportedPost,
+				CommentID: commentID,
+				SpamScore: currentComment.SpamScore,
+			}, newReport(reporter.ID, reason))
+		}
+	}
+	return nil, post.ErrNoComment
+}
+
+func (r *ReportBusinessLogic) Enqueue(ctx context.Context, request *post.QueueRequest) error {
+	_, err := r.addReport(ctx, request, newReport(AutoModeratorID, request.Reason))
+	if errors.Is(err, ErrAlreadyReported) {
+		return nil
+	}
+	return err
+}
+
+// DropPost без r.mu: удаление атомарно само по себе, а Remove зовет его через RemovePost, уже держа мьютекс
+func (r *ReportBusinessLogic) DropPost(ctx context.Context, postID string) error {
+	return r.ReportDBRepo.DeletePostItemsDB(ctx, postID)
+}
+
+func newReport(userID, reason string) *Report {
+	return &Report{
+		UserID:  userID,
+		Reason:  reason,
+		Created: getTimeOfCreation(),
+	}
+}
+
+func (r *ReportBusinessLogic) addReport(ctx context.Context, request *post.QueueRequest, newReport *Report) (*Item, error) {
+	postID := request.Target.ID.Hex()
+	commentID := request.CommentID
+	kind := KindPost
+	if commentID != "" {
+		kind = KindComment
+	}
+	err := r.ReportDBRepo.AddReportDB(ctx, &Item{
+		Kind:      kind,
+		PostID:    postID,
+		CommentID: commentID,
+		Category:  request.Target.Category,
+		Held:      request.Held,
+		SpamScore: request.SpamScore,
+	}, newReport)
+	if err != nil {
+		return nil, err
+	}
+	return r.ReportDBRepo.FindItemDB(ctx, kind, postID, commentID)
+}
+
+func (r *ReportBusinessLogic) GetQueue(ctx context.Context) ([]*Item, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	items, err := r.ReportDBRepo.GetAllItemsDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].ReportCount > items[j].ReportCount
+	})
+	return items, nil
+}
+
+// Approve и Remove - version из If-Match относится к посту из жалобы, AnyVersion - без проверки
+func (r *ReportBusinessLogic) Approve(ctx context.Context, itemID string, moderator *user.User, reason string, version int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	item, err := r.ReportDBRepo.GetItemByIDDB(ctx, itemID)
+	if err != nil {
+		return err
+	}
+	err = r.Tx.InTransaction(ctx, func(ctx context.Context) error {
+		if item.Held {
+			_, err := r.PostRepo.ApproveHeld(ctx, item.PostID, item.CommentID, version)
+			if err != nil && !errors.Is(err, post.ErrNoPost) && !errors.Is(err, post.ErrNoComment) {
+				return err
+			}
+		}
+		return r.Recorder.RecordAction(ctx, moderator, post.ActionApprove, item.PostID, item.CommentID, item.Category, reason)
+	})
+	if err != nil {
+		return err
+	}
+	text, err := r.spamText(ctx, item)
+	if err != nil {
+		return err
+	}
+	err = r.train(ctx, text, false)
+	if err != nil {
+		return err
+	}
+	return r.ReportDBRepo.DeleteItemDB(ctx, itemID)
+}
+
+func (r *ReportBusinessLogic) Remove(ctx context.Context, itemID string, moderator *user.User, reason string, version int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	item, err := r.ReportDBRepo.GetItemByIDDB(ctx, itemID)
+	if err != nil {
+		return err
+	}
+	// текст берется до удаления: после него пост - уже надгробие, а комментария нет
+	text, err := r.spamText(ctx, item)
+	if err != nil {
+		return err
+	}
+	if item.Kind == KindComment {
+		_, err = r.PostRepo.RemoveComment(ctx, moderator, item.PostID, item.CommentID, reason, version)
+	} else {
+		_, err = r.PostRepo.RemovePost(ctx, moderator, item.PostID, reason, version)
+	}
+	// контент уже удален автором, достаточно убрать его из очереди
+	if err != nil && !errors.Is(err, post.ErrNoPost) && !errors.Is(err, post.ErrNoComment) {
+		return err
+	}
+	// модель учится только на удаленном контенте, а при повторе текста уже нет и второй раз она не учится
+	err = r.train(ctx, text, true)
+	if err != nil {
+		return err
+	}
+	return r.ReportDBRepo.DeleteItemDB(ctx, itemID)
+}
+
+// spamText - текст поста или комментария из жалобы, пустой, если его уже нет
+func (r *ReportBusinessLogic) spamText(ctx context.Context, item *Item) (string, error) {
+	reportedPost, err := r.PostRepo.FindPostByID(ctx, item.PostID)
+	if errors.Is(err, post.ErrNoPost) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return reportedPost.TextOf(item.CommentID), nil
+}
+
+func (r *ReportBusinessLogic) train(ctx context.Context, text string, isSpam bool) error {
+	if text == "" {
+		return nil
+	}
+	return r.Spam.Train(ctx, text, isSpam)
+}
@@ -0,0 +1,109 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: report.go
+
+// Package report is a generated GoMock package.
+package report
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	user "reddit/pkg/user"
+)
+
+// MockReportRepo is a mock of ReportRepo interface.
+type MockReportRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockReportRepoMockRecorder
+}
+
+// MockReportRepoMockRecorder is the mock recorder for MockReportRepo.
+type MockReportRepoMockRecorder struct {
+	mock *MockReportRepo
+}
+
+// NewMockReportRepo creates a new mock instance.
+func NewMockReportRepo(ctrl *gomock.Controller) *MockReportRepo {
+	mock := &MockReportRepo{ctrl: ctrl}
+	mock.recorder = &MockReportRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockReportRepo) EXPECT() *MockReportRepoMockRecorder {
+	return m.recorder
+}
+
+// Approve mocks base method.
+func (m *MockReportRepo) Approve(ctx context.Context, itemID string, moderator *user.User, reason string, version int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Approve", ctx, itemID, moderator, reason, version)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Approve indicates an expected call of Approve.
+func (mr *MockReportRepoMockRecorder) Approve(ctx, itemID, moderator, reason, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Approve", reflect.TypeOf((*MockReportRepo)(nil).Approve), ctx, itemID, moderator, reason, version)
+}
+
+// GetQueue mocks base method.
+func (m *MockReportRepo) GetQueue(ctx context.Context) ([]*Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetQueue", ctx)
+	ret0, _ := ret[0].([]*Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetQueue indicates an expected call of GetQueue.
+func (mr *MockReportRepoMockRecorder) GetQueue(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetQueue", reflect.TypeOf((*MockReportRepo)(nil).GetQueue), ctx)
+}
+
+// Remove mocks base method.
+func (m *MockReportRepo) Remove(ctx context.Context, itemID string, moderator *user.User, reason string, version int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Remove", ctx, itemID, moderator, reason, version)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Remove indicates an expected call of Remove.
+func (mr *MockReportRepoMockRecorder) Remove(ctx, itemID, moderator, reason, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockReportRepo)(nil).Remove), ctx, itemID, moderator, reason, version)
+}
+
+// ReportComment mocks base method.
+func (m *MockReportRepo) ReportComment(ctx context.Context, postID, commentID, reason string, reporter *user.User) (*Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportComment", ctx, postID, commentID, reason, reporter)
+	ret0, _ := ret[0].(*Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReportComment indicates an expected call of ReportComment.
+func (mr *MockReportRepoMockRecorder) ReportComment(ctx, postID, commentID, reason, reporter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportComment", reflect.TypeOf((*MockReportRepo)(nil).ReportComment), ctx, postID, commentID, reason, reporter)
+}
+
+// ReportPost mocks base method.
+func (m *MockReportRepo) ReportPost(ctx context.Context, postID, reason string, reporter *user.User) (*Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportPost", ctx, postID, reason, reporter)
+	ret0, _ := ret[0].(*Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReportPost indicates an expected call of ReportPost.
+func (mr *MockReportRepoMockRecorder) ReportPost(ctx, postID, reason, reporter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportPost", reflect.TypeOf((*MockReportRepo)(nil).ReportPost), ctx, postID, reason, reporter)
+}
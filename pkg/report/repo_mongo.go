@@ -0,0 +1,149 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reddit/pkg/post"
+)
+
+type ReportDBRepo struct {
+	Reports post.CollectionHelper
+	// Timeout - срок одной операции, 0 - post.DefaultTimeout
+	Timeout time.Duration
+}
+
+func (r *ReportDBRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return post.WithTimeout(ctx, r.Timeout)
+}
+
+func (r *ReportDBRepo) EnsureIndexesDB() error {
+	return r.Reports.CreateIndexes(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "kind", Value: 1}, {Key: "postId", Value: 1}, {Key: "commentId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+}
+
+func (r *ReportDBRepo) FindItemDB(ctx context.Context, kind, postID, commentID string) (*Item, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	item := &Item{}
+	filter := bson.M{"kind": kind, "postId": postID, "commentId": commentID}
+	err := r.Reports.FindOne(ctx, filter).Decode(item)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNoItem
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (r *ReportDBRepo) GetItemByIDDB(ctx context.Context, itemID string) (*Item, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	itemIDMongo, err := getMongoID(itemID)
+	if err != nil {
+		return nil, err
+	}
+	item := &Item{}
+	err = r.Reports.FindOne(ctx, bson.M{"_id": itemIDMongo}).Decode(item)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNoItem
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// AddReportDB - одна запись в монгу: жалоба дописывается к элементу очереди, а если его нет - элемент создается.
+// Уникальный индекс не дает двум репликам создать два элемента на один пост или коммент
+func (r *ReportDBRepo) AddReportDB(ctx context.Context, item *Item, newReport *Report) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	filter := bson.M{
+		"kind":         item.Kind,
+		"postId":       item.PostID,
+		"commentId":    item.CommentID,
+		"reports.user": bson.M{"$ne": newReport.UserID},
+	}
+	update := bson.M{
+		"$push": bson.M{"reports": newReport},
+		"$inc":  bson.M{"reportCount": 1},
+		// false < true, так что отложенный элемент остается отложенным
+		"$max": bson.M{"held": item.Held},
+		"$setOnInsert": bson.M{
+			"category":  item.Category,
+			"spamScore": item.SpamScore,
+		},
+	}
+	for retried := false; ; retried = true {
+		_, err := r.Reports.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+		// элемент есть, но фильтр не подошел: либо юзер уже жаловался, либо элемент только что создала соседняя реплика
+		existing, errFind := r.FindItemDB(ctx, item.Kind, item.PostID, item.CommentID)
+		if errFind != nil {
+			return errFind
+		}
+		for _, currentReport := range existing.Reports {
+			if currentReport.UserID == newReport.UserID {
+				return ErrAlreadyReported
+			}
+		}
+		if retried {
+			return err
+		}
+	}
+}
+
+func (r *ReportDBRepo) GetAllItemsDB(ctx context.Context) ([]*Item, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	items := make([]*Item, 0)
+	result, err := r.Reports.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	err = result.All(ctx, &items)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *ReportDBRepo) DeleteItemDB(ctx context.Context, itemID string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	itemIDMongo, err := getMongoID(itemID)
+	if err != nil {
+		return err
+	}
+	_, err = r.Reports.DeleteOne(ctx, bson.M{"_id": itemIDMongo})
+	return err
+}
+
+func (r *ReportDBRepo) DeletePostItemsDB(ctx context.Context, postID string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.Reports.DeleteMany(ctx, bson.M{"postId": postID})
+	return err
+}
+
+func getMongoID(id string) (primitive.ObjectID, error) {
+	itemIDMongo, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, ErrNoItem
+	}
+	return itemIDMongo, nil
+}
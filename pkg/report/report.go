@@ -0,0 +1,79 @@
+package report
+
+import (
+	"context"
+	"errors"
+
+	"github.com/asaskevich/govalidator"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"reddit/pkg/user"
+)
+
+const (
+	KindPost    = "post"
+	KindComment = "comment"
+
+	AutoModeratorID = "automoderator"
+)
+
+var (
+	ErrNoItem          = errors.New("no reported item found")
+	ErrAlreadyReported = errors.New("item already reported by this user")
+)
+
+type ReportRepo interface {
+	ReportPost(ctx context.Context, postID, reason string, reporter *user.User) (*Item, error)
+	ReportComment(ctx context.Context, postID, commentID, reason string, reporter *user.User) (*Item, error)
+	GetQueue(ctx context.Context) ([]*Item, error)
+	Approve(ctx context.Context, itemID string, moderator *user.User, reason string, version int) error
+	Remove(ctx context.Context, itemID string, moderator *user.User, reason string, version int) error
+}
+
+type Report struct {
+	UserID  string `json:"user" bson:"user"`
+	Reason  string `json:"reason" bson:"reason"`
+	Created string `json:"created" bson:"created"`
+}
+
+type Item struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	Kind        string             `json:"kind" bson:"kind"`
+	PostID      string             `json:"postId" bson:"postId"`
+	CommentID   string             `json:"commentId,omitempty" bson:"commentId"`
+	Category    string             `json:"category" bson:"category"`
+	Reports     []*Report          `json:"reports" bson:"reports"`
+	ReportCount int                `json:"reportCount" bson:"reportCount"`
+	Held        bool               `json:"held,omitempty" bson:"held,omitempty"`
+	SpamScore   float64            `json:"spamScore" bson:"spamScore"`
+}
+
+type ModerationForm struct {
+	Reason string `json:"reason" valid:"length(0|500)"`
+}
+
+func (m *ModerationForm) Validate() []string {
+	return validate(m)
+}
+
+type ReportForm struct {
+	Reason string `json:"reason" valid:"required,length(1|500)"`
+}
+
+func (r *ReportForm) Validate() []string {
+	return validate(r)
+}
+
+func validate(form interface{}) []string {
+	_, err := govalidator.ValidateStruct(form)
+	validationErrors := make([]string, 0)
+	if err == nil {
+		return validationErrors
+	}
+	if allErrs, ok := err.(govalidator.Errors); ok {
+		for _, fld := range allErrs {
+			validationErrors = append(validationErrors, fld.Error())
+		}
+	}
+	return validationErrors
+}
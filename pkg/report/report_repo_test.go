@@ -0,0 +1,377 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reddit/pkg/comment"
+	"reddit/pkg/post"
+	"reddit/pkg/user"
+)
+
+func TestReportPost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := post.NewMockCollectionHelper(ctrl)
+	testPostRepo := post.NewMockPostRepo(ctrl)
+	testRepo := NewReportBusinessLogic(&ReportDBRepo{Reports: testCollection}, testPostRepo, post.NewMockActionRecorder(ctrl))
+
+	postID, err := primitive.ObjectIDFromHex("654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Fatalf("error in id")
+		return
+	}
+	reportedPost := &post.Post{
+		ID:       postID,
+		Category: "programming",
+	}
+	reporter := &user.User{
+		ID:       "310ca263",
+		Username: "hhhhhhhh",
+	}
+	filter := bson.M{"kind": KindPost, "postId": postID.Hex(), "commentId": ""}
+
+	// пост не найден
+	testPostRepo.EXPECT().FindPostByID(gomock.Any(), postID.Hex()).Return(nil, post.ErrNoPost)
+	_, err = testRepo.ReportPost(context.Background(), postID.Hex(), "spam", reporter)
+	if !errors.Is(err, post.ErrNoPost) {
+		t.Errorf("wrong error: expected %s, got %s", post.ErrNoPost, err)
+		return
+	}
+
+	// первая жалоба на пост - одна запись с upsert, отдается сохраненный элемент
+	stored := &Item{
+		ID:          postID,
+		Kind:        KindPost,
+		PostID:      postID.Hex(),
+		Category:    "programming",
+		Reports:     []*Report{{UserID: reporter.ID, Reason: "spam"}},
+		ReportCount: 1,
+	}
+	testPostRepo.EXPECT().FindPostByID(gomock.Any(), postID.Hex()).Return(reportedPost, nil)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), bson.M{
+		"kind":         KindPost,
+		"postId":       postID.Hex(),
+		"commentId":    "",
+		"reports.user": bson.M{"$ne": reporter.ID},
+	}, gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+		if len(opts) != 1 || opts[0].Upsert == nil || !*opts[0].Upsert {
+			t.Errorf("report must be upserted")
+		}
+		if update.(bson.M)["$setOnInsert"].(bson.M)["category"] != "programming" {
+			t.Errorf("wrong update: %v", update)
+		}
+		return &mongo.UpdateResult{UpsertedCount: 1}, nil
+	})
+	testCollection.EXPECT().FindOne(gomock.Any(), filter).Return(mongo.NewSingleResultFromDocument(stored, nil, nil))
+	item, err := testRepo.ReportPost(context.Background(), postID.Hex(), "spam", reporter)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if item.ReportCount != 1 || item.Category != "programming" {
+		t.Errorf("wrong item: %v", item)
+		return
+	}
+
+	// повторная жалоба от того же юзера: фильтр не подошел, upsert уперся в уникальный индекс
+	duplicate := mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}}
+	testPostRepo.EXPECT().FindPostByID(gomock.Any(), postID.Hex()).Return(reportedPost, nil)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, duplicate)
+	testCollection.EXPECT().FindOne(gomock.Any(), filter).Return(mongo.NewSingleResultFromDocument(stored, nil, nil))
+	_, err = testRepo.ReportPost(context.Background(), postID.Hex(), "spam", reporter)
+	if !errors.Is(err, ErrAlreadyReported) {
+		t.Errorf("wrong error: expected %s, got %s", ErrAlreadyReported, err)
+		return
+	}
+
+	// элемент одновременно создала другая реплика - запись повторяется и жалоба дописывается
+	another := &user.User{ID: "another"}
+	testPostRepo.EXPECT().FindPostByID(gomock.Any(), postID.Hex()).Return(reportedPost, nil)
+	gomock.InOrder(
+		testCollection.EXPECT().UpdateOne(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, duplicate),
+		testCollection.EXPECT().FindOne(gomock.Any(), filter).Return(mongo.NewSingleResultFromDocument(stored, nil, nil)),
+		testCollection.EXPECT().UpdateOne(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(&mongo.UpdateResult{ModifiedCount: 1}, nil),
+		testCollection.EXPECT().FindOne(gomock.Any(), filter).Return(mongo.NewSingleResultFromDocument(&Item{
+			ID:          postID,
+			Reports:     []*Report{{UserID: reporter.ID}, {UserID: another.ID}},
+			ReportCount: 2,
+		}, nil, nil)),
+	)
+	item, err = testRepo.ReportPost(context.Background(), postID.Hex(), "abuse", another)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if item.ReportCount != 2 {
+		t.Errorf("wrong report count: expected %d, got %d", 2, item.ReportCount)
+		return
+	}
+
+	// ошибка записи отдается как есть
+	testPostRepo.EXPECT().FindPostByID(gomock.Any(), postID.Hex()).Return(reportedPost, nil)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("db_error"))
+	_, err = testRepo.ReportPost(context.Background(), postID.Hex(), "abuse", another)
+	if err == nil || errors.Is(err, ErrAlreadyReported) {
+		t.Errorf("expected db error, got %v", err)
+		return
+	}
+}
+
+func TestReportComment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := post.NewMockCollectionHelper(ctrl)
+	testPostRepo := post.NewMockPostRepo(ctrl)
+	testRepo := NewReportBusinessLogic(&ReportDBRepo{Reports: testCollection}, testPostRepo, post.NewMockActionRecorder(ctrl))
+
+	postID, err := primitive.ObjectIDFromHex("654f63e3a2414a2a554b6423")
+	if err != nil {
+		t.Fatalf("error in id")
+		return
+	}
+	reportedPost := &post.Post{
+		ID:       postID,
+		Category: "programming",
+		Comments: []*comment.Comment{
+			{ID: "commentID"},
+		},
+	}
+	reporter := &user.User{ID: "310ca263"}
+
+	// коммент не найден
+	testPostRepo.EXPECT().FindPostByID(gomock.Any(), postID.Hex()).Return(reportedPost, nil)
+	_, err = testRepo.ReportComment(context.Background(), postID.Hex(), "unknown", "spam", reporter)
+	if !errors.Is(err, post.ErrNoComment) {
+		t.Errorf("wrong error: expected %s, got %s", post.ErrNoComment, err)
+		return
+	}
+
+	// ошибка в монго
+	filter := bson.M{"kind": KindComment, "postId": postID.Hex(), "commentId": "commentID"}
+	testPostRepo.EXPECT().FindPostByID(gomock.Any(), postID.Hex()).Return(reportedPost, nil)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("db_error"))
+	_, err = testRepo.ReportComment(context.Background(), postID.Hex(), "commentID", "spam", reporter)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+
+	// жалоба добавлена
+	testPostRepo.EXPECT().FindPostByID(gomock.Any(), postID.Hex()).Return(reportedPost, nil)
+	testCollection.EXPECT().UpdateOne(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(&mongo.UpdateResult{UpsertedCount: 1}, nil)
+	testCollection.EXPECT().FindOne(gomock.Any(), filter).Return(mongo.NewSingleResultFromDocument(&Item{
+		ID:        postID,
+		Kind:      KindComment,
+		PostID:    postID.Hex(),
+		CommentID: "commentID",
+	}, nil, nil))
+	item, err := testRepo.ReportComment(context.Background(), postID.Hex(), "commentID", "spam", reporter)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if item.Kind != KindComment || item.CommentID != "commentID" {
+		t.Errorf("wrong item: %v", item)
+		return
+	}
+}
+
+func TestGetQueue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := post.NewMockCollectionHelper(ctrl)
+	testRepo := NewReportBusinessLogic(&ReportDBRepo{Reports: testCollection}, post.NewMockPostRepo(ctrl), post.NewMockActionRecorder(ctrl))
+
+	// какая то ошибка в монго
+	testCollection.EXPECT().Find(gomock.Any(), bson.M{}).Return(nil, fmt.Errorf("error"))
+	_, err := testRepo.GetQueue(context.Background())
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+
+	// очередь отсортирована по количеству жалоб
+	items := []interface{}{
+		&Item{ID: primitive.NewObjectID(), Kind: KindPost, ReportCount: 1},
+		&Item{ID: primitive.NewObjectID(), Kind: KindComment, ReportCount: 3},
+		&Item{ID: primitive.NewObjectID(), Kind: KindPost, ReportCount: 2},
+	}
+	cursor, err := mongo.NewCursorFromDocuments(items, nil, nil)
+	if err != nil {
+		t.Fatalf("error on cursor creation")
+		return
+	}
+	testCollection.EXPECT().Find(gomock.Any(), bson.M{}).Return(cursor, nil)
+	queue, err := testRepo.GetQueue(context.Background())
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if len(queue) != 3 || queue[0].ReportCount != 3 || queue[1].ReportCount != 2 || queue[2].ReportCount != 1 {
+		t.Errorf("queue is not sorted by report count")
+		return
+	}
+}
+
+type testSpamTrainer struct {
+	spam []string
+	ham  []string
+}
+
+func (s *testSpamTrainer) Train(_ context.Context, text string, isSpam bool) error {
+	if isSpam {
+		s.spam = append(s.spam, text)
+	} else {
+		s.ham = append(s.ham, text)
+	}
+	return nil
+}
+
+func TestApproveAndRemove(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := post.NewMockCollectionHelper(ctrl)
+	testPostRepo := post.NewMockPostRepo(ctrl)
+	testRecorder := post.NewMockActionRecorder(ctrl)
+	testRepo := NewReportBusinessLogic(&ReportDBRepo{Reports: testCollection}, testPostRepo, testRecorder)
+	testTrainer := &testSpamTrainer{}
+	testRepo.Spam = testTrainer
+	moderator := &user.User{ID: "moderatorID", Username: "moderator"}
+	reportedPost := &post.Post{
+		Title: "buy",
+		Text:  "cheap",
+		Comments: []*comment.Comment{
+			{ID: "commentID", Body: "spam comment"},
+		},
+	}
+
+	itemID := primitive.NewObjectID()
+	postItem := &Item{ID: itemID, Kind: KindPost, PostID: "postID", ReportCount: 1}
+	commentItem := &Item{ID: itemID, Kind: KindComment, PostID: "postID", CommentID: "commentID", ReportCount: 1}
+
+	// некорректный айди
+	err := testRepo.Approve(context.Background(), "некорректный айди", moderator, "", post.AnyVersion)
+	if !errors.Is(err, ErrNoItem) {
+		t.Errorf("wrong error: expected %s, got %s", ErrNoItem, err)
+		return
+	}
+
+	// жалоба не найдена
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": itemID}).Return(mongo.NewSingleResultFromDocument(bson.D{}, mongo.ErrNoDocuments, nil))
+	err = testRepo.Approve(context.Background(), itemID.Hex(), moderator, "ok", post.AnyVersion)
+	if !errors.Is(err, ErrNoItem) {
+		t.Errorf("wrong error: expected %s, got %s", ErrNoItem, err)
+		return
+	}
+
+	// пост поменяли после загрузки очереди - ни одобрения, ни журнала, ни обучения
+	heldItem := &Item{ID: itemID, Kind: KindPost, PostID: "postID", ReportCount: 1, Held: true}
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": itemID}).Return(mongo.NewSingleResultFromDocument(heldItem, nil, nil))
+	testPostRepo.EXPECT().ApproveHeld(gomock.Any(), "postID", "", 3).Return(nil, post.ErrPreconditionFailed)
+	err = testRepo.Approve(context.Background(), itemID.Hex(), moderator, "ok", 3)
+	if !errors.Is(err, post.ErrPreconditionFailed) {
+		t.Errorf("wrong error: expected %s, got %v", post.ErrPreconditionFailed, err)
+		return
+	}
+
+	// не удалось записать действие в журнал
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": itemID}).Return(mongo.NewSingleResultFromDocument(postItem, nil, nil))
+	testRecorder.EXPECT().RecordAction(gomock.Any(), moderator, post.ActionApprove, "postID", "", "", "ok").Return(fmt.Errorf("db_error"))
+	err = testRepo.Approve(context.Background(), itemID.Hex(), moderator, "ok", post.AnyVersion)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+
+	// одобрение сбрасывает жалобы
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": itemID}).Return(mongo.NewSingleResultFromDocument(postItem, nil, nil))
+	testRecorder.EXPECT().RecordAction(gomock.Any(), moderator, post.ActionApprove, "postID", "", "", "ok").Return(nil)
+	testPostRepo.EXPECT().FindPostByID(gomock.Any(), "postID").Return(reportedPost, nil)
+	testCollection.EXPECT().DeleteOne(gomock.Any(), bson.M{"_id": itemID}).Return(int64(1), nil)
+	err = testRepo.Approve(context.Background(), itemID.Hex(), moderator, "ok", post.AnyVersion)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+
+	// удаление поста
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": itemID}).Return(mongo.NewSingleResultFromDocument(postItem, nil, nil))
+	testPostRepo.EXPECT().FindPostByID(gomock.Any(), "postID").Return(reportedPost, nil)
+	testPostRepo.EXPECT().RemovePost(gomock.Any(), moderator, "postID", "spam", post.AnyVersion).Return(true, nil)
+	testCollection.EXPECT().DeleteOne(gomock.Any(), bson.M{"_id": itemID}).Return(int64(1), nil)
+	err = testRepo.Remove(context.Background(), itemID.Hex(), moderator, "spam", post.AnyVersion)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+
+	// ошибка при удалении коммента - модель не учится
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": itemID}).Return(mongo.NewSingleResultFromDocument(commentItem, nil, nil))
+	testPostRepo.EXPECT().FindPostByID(gomock.Any(), "postID").Return(reportedPost, nil)
+	testPostRepo.EXPECT().RemoveComment(gomock.Any(), moderator, "postID", "commentID", "spam", post.AnyVersion).Return(nil, fmt.Errorf("db_error"))
+	err = testRepo.Remove(context.Background(), itemID.Hex(), moderator, "spam", post.AnyVersion)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+
+	// пост уже удален автором
+	testCollection.EXPECT().FindOne(gomock.Any(), bson.M{"_id": itemID}).Return(mongo.NewSingleResultFromDocument(commentItem, nil, nil))
+	testPostRepo.EXPECT().FindPostByID(gomock.Any(), "postID").Return(nil, post.ErrNoPost)
+	testPostRepo.EXPECT().RemoveComment(gomock.Any(), moderator, "postID", "commentID", "spam", post.AnyVersion).Return(nil, post.ErrNoPost)
+	testCollection.EXPECT().DeleteOne(gomock.Any(), bson.M{"_id": itemID}).Return(int64(1), nil)
+	err = testRepo.Remove(context.Background(), itemID.Hex(), moderator, "spam", post.AnyVersion)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+
+	// классификатор обучен на решениях модератора
+	if len(testTrainer.ham) != 1 || len(testTrainer.spam) != 1 || testTrainer.spam[0] == "spam comment" {
+		t.Errorf("wrong training data: spam %v, ham %v", testTrainer.spam, testTrainer.ham)
+		return
+	}
+}
+
+// testSession - только чтобы отличить контекст транзакции
+type testSession struct {
+	mongo.Session
+}
+
+func TestDropPost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := post.NewMockCollectionHelper(ctrl)
+	testRepo := NewReportBusinessLogic(&ReportDBRepo{Reports: testCollection}, post.NewMockPostRepo(ctrl), post.NewMockActionRecorder(ctrl))
+
+	// жалобы на пост и его комментарии удаляются в транзакции удаления поста
+	session := &testSession{}
+	testCollection.EXPECT().DeleteMany(gomock.Any(), bson.M{"postId": "post_id"}).DoAndReturn(func(ctx context.Context, _ interface{}) (int64, error) {
+		if mongo.SessionFromContext(ctx) != session {
+			t.Errorf("delete must be in transaction")
+		}
+		return 2, nil
+	})
+	if err := testRepo.DropPost(mongo.NewSessionContext(context.Background(), session), "post_id"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	// ошибка отдается транзакции, чтобы она откатилась
+	testCollection.EXPECT().DeleteMany(gomock.Any(), bson.M{"postId": "post_id"}).Return(int64(0), fmt.Errorf("error"))
+	if err := testRepo.DropPost(context.Background(), "post_id"); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
@@ -0,0 +1,136 @@
+package saved
+
+import (
+	"context"
+	"time"
+
+	"reddit/pkg/comment"
+	"reddit/pkg/post"
+)
+
+type SavedDBRepository interface {
+	AddItemDB(ctx context.Context, item *Item) error
+	DeleteItemDB(ctx context.Context, userID, kind, postID, commentID string) (bool, error)
+	GetItemsDB(ctx context.Context, userID, kind string, filter *Filter) ([]*Item, error)
+	GetHiddenPostIDsDB(ctx context.Context, userID string, postIDs []string) ([]string, error)
+	GetHiddenCommentIDsDB(ctx context.Context, userID, postID string) ([]string, error)
+}
+
+type SavedBusinessLogic struct {
+	SavedDBRepo SavedDBRepository
+	PostRepo    post.PostRepo
+}
+
+func NewSavedBusinessLogic(repo SavedDBRepository, postRepo post.PostRepo) *SavedBusinessLogic {
+	return &SavedBusinessLogic{
+		SavedDBRepo: repo,
+		PostRepo:    postRepo,
+	}
+}
+
+func (s *SavedBusinessLogic) Save(ctx context.Context, userID, postID, commentID string) error {
+	return s.add(ctx, userID, KindSaved, postID, commentID)
+}
+
+func (s *SavedBusinessLogic) Unsave(ctx context.Context, userID, postID, commentID string) error {
+	return s.remove(ctx, userID, KindSaved, postID, commentID)
+}
+
+func (s *SavedBusinessLogic) Hide(ctx context.Context, userID, postID, commentID string) error {
+	return s.add(ctx, userID, KindHidden, postID, commentID)
+}
+
+func (s *SavedBusinessLogic) Unhide(ctx context.Context, userID, postID, commentID string) error {
+	return s.remove(ctx, userID, KindHidden, postID, commentID)
+}
+
+func (s *SavedBusinessLogic) add(ctx context.Context, userID, kind, postID, commentID string) error {
+	targetPost, err := s.PostRepo.FindPostByID(ctx, postID)
+	if err != nil {
+		return err
+	}
+	// отложенный модерацией пост до одобрения не виден, как и в списках
+	if targetPost.Status == post.StatusHeld {
+		return post.ErrNoPost
+	}
+	if commentID != "" && findComment(targetPost, commentID) == nil {
+		return post.ErrNoComment
+	}
+	return s.SavedDBRepo.AddItemDB(ctx, &Item{
+		UserID:    userID,
+		Kind:      kind,
+		PostID:    postID,
+		CommentID: commentID,
+		Created:   time.Now().UTC(),
+	})
+}
+
+func (s *SavedBusinessLogic) remove(ctx context.Context, userID, kind, postID, commentID string) error {
+	deleted, err := s.SavedDBRepo.DeleteItemDB(ctx, userID, kind, postID, commentID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNoItem
+	}
+	return nil
+}
+
+// GetSaved отдает страницу сохраненного, свежие сверху; то, что успели удалить, пропускается
+func (s *SavedBusinessLogic) GetSaved(ctx context.Context, userID string, filter *Filter) ([]*Entry, error) {
+	filter.normalize()
+	items, err := s.SavedDBRepo.GetItemsDB(ctx, userID, KindSaved, filter)
+	if err != nil {
+		return nil, err
+	}
+	postIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		postIDs = append(postIDs, item.PostID)
+	}
+	posts, err := s.PostRepo.FindPostsByIDs(ctx, postIDs)
+	if err != nil {
+		return nil, err
+	}
+	postsByID := make(map[string]*post.Post, len(posts))
+	for _, savedPost := range posts {
+		postsByID[savedPost.ID.Hex()] = savedPost
+	}
+	entries := make([]*Entry, 0, len(items))
+	for _, item := range items {
+		savedPost, ok := postsByID[item.PostID]
+		if !ok {
+			continue
+		}
+		entry := &Entry{Item: item, Post: savedPost}
+		if item.CommentID != "" {
+			entry.Comment = findComment(savedPost, item.CommentID)
+			if entry.Comment == nil {
+				continue
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// HiddenPostIDs - какие из postIDs зритель скрыл. Спрашиваем только про уже выбранные посты,
+// так что запрос растет с размером выдачи, а не с числом скрытых
+func (s *SavedBusinessLogic) HiddenPostIDs(ctx context.Context, userID string, postIDs []string) ([]string, error) {
+	if len(postIDs) == 0 {
+		return make([]string, 0), nil
+	}
+	return s.SavedDBRepo.GetHiddenPostIDsDB(ctx, userID, postIDs)
+}
+
+func (s *SavedBusinessLogic) HiddenCommentIDs(ctx context.Context, userID, postID string) ([]string, error) {
+	return s.SavedDBRepo.GetHiddenCommentIDsDB(ctx, userID, postID)
+}
+
+func findComment(targetPost *post.Post, commentID string) *comment.Comment {
+	for _, currentComment := range targetPost.Comments {
+		if currentComment.ID == commentID {
+			return currentComment
+		}
+	}
+	return nil
+}
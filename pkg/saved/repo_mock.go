@@ -0,0 +1,136 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: saved.go
+
+// Package saved is a generated GoMock package.
+package saved
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockSavedRepo is a mock of SavedRepo interface.
+type MockSavedRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockSavedRepoMockRecorder
+}
+
+// MockSavedRepoMockRecorder is the mock recorder for MockSavedRepo.
+type MockSavedRepoMockRecorder struct {
+	mock *MockSavedRepo
+}
+
+// NewMockSavedRepo creates a new mock instance.
+func NewMockSavedRepo(ctrl *gomock.Controller) *MockSavedRepo {
+	mock := &MockSavedRepo{ctrl: ctrl}
+	mock.recorder = &MockSavedRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSavedRepo) EXPECT() *MockSavedRepoMockRecorder {
+	return m.recorder
+}
+
+// GetSaved mocks base method.
+func (m *MockSavedRepo) GetSaved(ctx context.Context, userID string, filter *Filter) ([]*Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSaved", ctx, userID, filter)
+	ret0, _ := ret[0].([]*Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSaved indicates an expected call of GetSaved.
+func (mr *MockSavedRepoMockRecorder) GetSaved(ctx, userID, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSaved", reflect.TypeOf((*MockSavedRepo)(nil).GetSaved), ctx, userID, filter)
+}
+
+// HiddenCommentIDs mocks base method.
+func (m *MockSavedRepo) HiddenCommentIDs(ctx context.Context, userID, postID string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HiddenCommentIDs", ctx, userID, postID)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HiddenCommentIDs indicates an expected call of HiddenCommentIDs.
+func (mr *MockSavedRepoMockRecorder) HiddenCommentIDs(ctx, userID, postID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HiddenCommentIDs", reflect.TypeOf((*MockSavedRepo)(nil).HiddenCommentIDs), ctx, userID, postID)
+}
+
+// HiddenPostIDs mocks base method.
+func (m *MockSavedRepo) HiddenPostIDs(ctx context.Context, userID string, postIDs []string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HiddenPostIDs", ctx, userID, postIDs)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HiddenPostIDs indicates an expected call of HiddenPostIDs.
+func (mr *MockSavedRepoMockRecorder) HiddenPostIDs(ctx, userID, postIDs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HiddenPostIDs", reflect.TypeOf((*MockSavedRepo)(nil).HiddenPostIDs), ctx, userID, postIDs)
+}
+
+// Hide mocks base method.
+func (m *MockSavedRepo) Hide(ctx context.Context, userID, postID, commentID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Hide", ctx, userID, postID, commentID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Hide indicates an expected call of Hide.
+func (mr *MockSavedRepoMockRecorder) Hide(ctx, userID, postID, commentID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Hide", reflect.TypeOf((*MockSavedRepo)(nil).Hide), ctx, userID, postID, commentID)
+}
+
+// Save mocks base method.
+func (m *MockSavedRepo) Save(ctx context.Context, userID, postID, commentID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", ctx, userID, postID, commentID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockSavedRepoMockRecorder) Save(ctx, userID, postID, commentID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockSavedRepo)(nil).Save), ctx, userID, postID, commentID)
+}
+
+// Unhide mocks base method.
+func (m *MockSavedRepo) Unhide(ctx context.Context, userID, postID, commentID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unhide", ctx, userID, postID, commentID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unhide indicates an expected call of Unhide.
+func (mr *MockSavedRepoMockRecorder) Unhide(ctx, userID, postID, commentID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unhide", reflect.TypeOf((*MockSavedRepo)(nil).Unhide), ctx, userID, postID, commentID)
+}
+
+// Unsave mocks base method.
+func (m *MockSavedRepo) Unsave(ctx context.Context, userID, postID, commentID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unsave", ctx, userID, postID, commentID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unsave indicates an expected call of Unsave.
+func (mr *MockSavedRepoMockRecorder) Unsave(ctx, userID, postID, commentID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unsave", reflect.TypeOf((*MockSavedRepo)(nil).Unsave), ctx, userID, postID, commentID)
+}
@@ -0,0 +1,118 @@
+package saved
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reddit/pkg/post"
+)
+
+type SavedDBRepo struct {
+	Items post.CollectionHelper
+	// Timeout - срок одной операции, 0 - post.DefaultTimeout
+	Timeout time.Duration
+}
+
+func (s *SavedDBRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return post.WithTimeout(ctx, s.Timeout)
+}
+
+func (s *SavedDBRepo) EnsureIndexesDB() error {
+	return s.Items.CreateIndexes(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user", Value: 1}, {Key: "kind", Value: 1}, {Key: "post", Value: 1}, {Key: "comment", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user", Value: 1}, {Key: "kind", Value: 1}, {Key: "created", Value: -1}}},
+	})
+}
+
+func itemFilter(userID, kind, postID, commentID string) bson.M {
+	return bson.M{"user": userID, "kind": kind, "post": postID, "comment": commentID}
+}
+
+// AddItemDB - повторное сохранение ничего не меняет, время остается первым
+func (s *SavedDBRepo) AddItemDB(ctx context.Context, item *Item) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	update := bson.M{
+		"$setOnInsert": bson.M{"created": item.Created},
+	}
+	_, err := s.Items.UpdateOne(ctx, itemFilter(item.UserID, item.Kind, item.PostID, item.CommentID), update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (s *SavedDBRepo) DeleteItemDB(ctx context.Context, userID, kind, postID, commentID string) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	deleted, err := s.Items.DeleteOne(ctx, itemFilter(userID, kind, postID, commentID))
+	if err != nil {
+		return false, err
+	}
+	return deleted == 1, nil
+}
+
+func (s *SavedDBRepo) GetItemsDB(ctx context.Context, userID, kind string, filter *Filter) ([]*Item, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	items := make([]*Item, 0)
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created", Value: -1}}).
+		SetSkip(int64((filter.Page - 1) * filter.Limit)).
+		SetLimit(int64(filter.Limit))
+	result, err := s.Items.Find(ctx, bson.M{"user": userID, "kind": kind}, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = result.All(ctx, &items)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (s *SavedDBRepo) GetHiddenPostIDsDB(ctx context.Context, userID string, postIDs []string) ([]string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	items, err := s.findIDs(ctx, bson.M{"user": userID, "kind": KindHidden, "post": bson.M{"$in": postIDs}, "comment": ""})
+	if err != nil {
+		return nil, err
+	}
+	hiddenIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		hiddenIDs = append(hiddenIDs, item.PostID)
+	}
+	return hiddenIDs, nil
+}
+
+func (s *SavedDBRepo) GetHiddenCommentIDsDB(ctx context.Context, userID, postID string) ([]string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	items, err := s.findIDs(ctx, bson.M{"user": userID, "kind": KindHidden, "post": postID, "comment": bson.M{"$ne": ""}})
+	if err != nil {
+		return nil, err
+	}
+	commentIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		commentIDs = append(commentIDs, item.CommentID)
+	}
+	return commentIDs, nil
+}
+
+func (s *SavedDBRepo) findIDs(ctx context.Context, filter bson.M) ([]*Item, error) {
+	items := make([]*Item, 0)
+	opts := options.Find().SetProjection(bson.M{"post": 1, "comment": 1})
+	result, err := s.Items.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = result.All(ctx, &items)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,68 @@
+package saved
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"reddit/pkg/comment"
+	"reddit/pkg/post"
+)
+
+const (
+	KindSaved  = "saved"
+	KindHidden = "hidden"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 200
+)
+
+var ErrNoItem = errors.New("no saved or hidden item found")
+
+type SavedRepo interface {
+	Save(ctx context.Context, userID, postID, commentID string) error
+	Unsave(ctx context.Context, userID, postID, commentID string) error
+	Hide(ctx context.Context, userID, postID, commentID string) error
+	Unhide(ctx context.Context, userID, postID, commentID string) error
+	GetSaved(ctx context.Context, userID string, filter *Filter) ([]*Entry, error)
+	HiddenPostIDs(ctx context.Context, userID string, postIDs []string) ([]string, error)
+	HiddenCommentIDs(ctx context.Context, userID, postID string) ([]string, error)
+}
+
+// Item - отдельный документ на каждое сохранение или скрытие,
+// у поста или комментария их может быть сколько угодно
+type Item struct {
+	ID        primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	UserID    string             `json:"-" bson:"user"`
+	Kind      string             `json:"-" bson:"kind"`
+	PostID    string             `json:"postId" bson:"post"`
+	CommentID string             `json:"commentId,omitempty" bson:"comment"`
+	Created   time.Time          `json:"savedAt" bson:"created"`
+}
+
+type Entry struct {
+	*Item
+	Post    *post.Post       `json:"post"`
+	Comment *comment.Comment `json:"comment,omitempty"`
+}
+
+type Filter struct {
+	Page  int
+	Limit int
+}
+
+func (f *Filter) normalize() {
+	if f.Page < 1 {
+		f.Page = 1
+	}
+	if f.Limit < 1 {
+		f.Limit = defaultLimit
+	}
+	if f.Limit > maxLimit {
+		f.Limit = maxLimit
+	}
+}
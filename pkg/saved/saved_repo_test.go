@@ -0,0 +1,180 @@
+package saved
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reddit/pkg/comment"
+	"reddit/pkg/post"
+)
+
+func TestSaveAndHide(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := post.NewMockCollectionHelper(ctrl)
+	testPostRepo := post.NewMockPostRepo(ctrl)
+	testRepo := NewSavedBusinessLogic(&SavedDBRepo{Items: testCollection}, testPostRepo)
+	savedPost := &post.Post{Title: "fef", Comments: []*comment.Comment{{ID: "comment_id"}}}
+
+	// нет такого поста
+	testPostRepo.EXPECT().FindPostByID(gomock.Any(), "post_id").Return(nil, post.ErrNoPost)
+	err := testRepo.Save(context.Background(), "user_id", "post_id", "")
+	if !errors.Is(err, post.ErrNoPost) {
+		t.Errorf("wrong error: expected %s, got %v", post.ErrNoPost, err)
+		return
+	}
+
+	// нет такого комментария
+	testPostRepo.EXPECT().FindPostByID(gomock.Any(), "post_id").Return(savedPost, nil)
+	err = testRepo.Hide(context.Background(), "user_id", "post_id", "another_comment")
+	if !errors.Is(err, post.ErrNoComment) {
+		t.Errorf("wrong error: expected %s, got %v", post.ErrNoComment, err)
+		return
+	}
+
+	// сохранение - upsert, повторное ничего не дублирует
+	testPostRepo.EXPECT().FindPostByID(gomock.Any(), "post_id").Return(savedPost, nil)
+	testCollection.EXPECT().UpdateOne(gomock.Any(),
+		bson.M{"user": "user_id", "kind": KindSaved, "post": "post_id", "comment": "comment_id"},
+		gomock.Any(), options.Update().SetUpsert(true)).Return(&mongo.UpdateResult{UpsertedCount: 1}, nil)
+	err = testRepo.Save(context.Background(), "user_id", "post_id", "comment_id")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+
+	// скрытый пост не был скрыт
+	testCollection.EXPECT().DeleteOne(gomock.Any(), bson.M{"user": "user_id", "kind": KindHidden, "post": "post_id", "comment": ""}).Return(int64(0), nil)
+	err = testRepo.Unhide(context.Background(), "user_id", "post_id", "")
+	if !errors.Is(err, ErrNoItem) {
+		t.Errorf("wrong error: expected %s, got %v", ErrNoItem, err)
+		return
+	}
+
+	// убрали из сохраненного
+	testCollection.EXPECT().DeleteOne(gomock.Any(), bson.M{"user": "user_id", "kind": KindSaved, "post": "post_id", "comment": ""}).Return(int64(1), nil)
+	err = testRepo.Unsave(context.Background(), "user_id", "post_id", "")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+
+	// ошибка базы
+	testCollection.EXPECT().DeleteOne(gomock.Any(), gomock.Any()).Return(int64(0), fmt.Errorf("error"))
+	err = testRepo.Unsave(context.Background(), "user_id", "post_id", "")
+	if err == nil || errors.Is(err, ErrNoItem) {
+		t.Errorf("expected db error, got %v", err)
+		return
+	}
+}
+
+func TestGetSaved(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := post.NewMockCollectionHelper(ctrl)
+	testPostRepo := post.NewMockPostRepo(ctrl)
+	testRepo := NewSavedBusinessLogic(&SavedDBRepo{Items: testCollection}, testPostRepo)
+
+	firstID := primitive.NewObjectID()
+	secondID := primitive.NewObjectID()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	items := []interface{}{
+		&Item{UserID: "user_id", Kind: KindSaved, PostID: firstID.Hex(), Created: now},
+		&Item{UserID: "user_id", Kind: KindSaved, PostID: secondID.Hex(), CommentID: "comment_id", Created: now.Add(-time.Hour)},
+		&Item{UserID: "user_id", Kind: KindSaved, PostID: "deleted_post", Created: now.Add(-2 * time.Hour)},
+	}
+
+	// ошибка базы
+	testCollection.EXPECT().Find(gomock.Any(), bson.M{"user": "user_id", "kind": KindSaved}, gomock.Any()).Return(nil, fmt.Errorf("error"))
+	_, err := testRepo.GetSaved(context.Background(), "user_id", &Filter{})
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+
+	// удаленный пост пропускается, комментарий достается из поста, пагинация нормализуется
+	cursor, err := mongo.NewCursorFromDocuments(items, nil, nil)
+	if err != nil {
+		t.Fatalf("error on cursor creation")
+		return
+	}
+	filter := &Filter{Page: 0, Limit: 1000}
+	testCollection.EXPECT().Find(gomock.Any(), bson.M{"user": "user_id", "kind": KindSaved}, gomock.Any()).Return(cursor, nil)
+	testPostRepo.EXPECT().FindPostsByIDs(gomock.Any(), []string{firstID.Hex(), secondID.Hex(), "deleted_post"}).Return([]*post.Post{
+		{ID: secondID, Title: "second", Comments: []*comment.Comment{{ID: "comment_id", Body: "saved comment"}}},
+		{ID: firstID, Title: "first"},
+	}, nil)
+	entries, err := testRepo.GetSaved(context.Background(), "user_id", filter)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if len(entries) != 2 || entries[0].Post.Title != "first" || entries[1].Comment == nil || entries[1].Comment.Body != "saved comment" {
+		t.Errorf("wrong saved entries: %+v", entries)
+		return
+	}
+	if filter.Page != 1 || filter.Limit != maxLimit {
+		t.Errorf("pagination was not normalized: page %d, limit %d", filter.Page, filter.Limit)
+		return
+	}
+}
+
+func TestHiddenIDs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testCollection := post.NewMockCollectionHelper(ctrl)
+	testRepo := NewSavedBusinessLogic(&SavedDBRepo{Items: testCollection}, post.NewMockPostRepo(ctrl))
+
+	// пустая выдача - без похода в базу
+	postIDs, err := testRepo.HiddenPostIDs(context.Background(), "user_id", nil)
+	if err != nil || len(postIDs) != 0 {
+		t.Errorf("unexpected result: %v, %v", postIDs, err)
+		return
+	}
+
+	// скрытые посты ищутся только среди постов выдачи и только записи без комментария
+	cursor, err := mongo.NewCursorFromDocuments([]interface{}{bson.M{"post": "first"}, bson.M{"post": "second"}}, nil, nil)
+	if err != nil {
+		t.Fatalf("error on cursor creation")
+		return
+	}
+	pageIDs := []string{"first", "second", "third"}
+	testCollection.EXPECT().Find(gomock.Any(), bson.M{"user": "user_id", "kind": KindHidden, "post": bson.M{"$in": pageIDs}, "comment": ""}, gomock.Any()).Return(cursor, nil)
+	postIDs, err = testRepo.HiddenPostIDs(context.Background(), "user_id", pageIDs)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if len(postIDs) != 2 || postIDs[0] != "first" || postIDs[1] != "second" {
+		t.Errorf("wrong hidden posts: %v", postIDs)
+		return
+	}
+
+	// скрытые комментарии поста
+	cursor, err = mongo.NewCursorFromDocuments([]interface{}{bson.M{"post": "first", "comment": "comment_id"}}, nil, nil)
+	if err != nil {
+		t.Fatalf("error on cursor creation")
+		return
+	}
+	testCollection.EXPECT().Find(gomock.Any(), bson.M{"user": "user_id", "kind": KindHidden, "post": "first", "comment": bson.M{"$ne": ""}}, gomock.Any()).Return(cursor, nil)
+	commentIDs, err := testRepo.HiddenCommentIDs(context.Background(), "user_id", "first")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if len(commentIDs) != 1 || commentIDs[0] != "comment_id" {
+		t.Errorf("wrong hidden comments: %v", commentIDs)
+		return
+	}
+}
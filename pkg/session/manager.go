@@ -1,6 +1,7 @@
 package session
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"sync"
@@ -12,8 +13,8 @@ import (
 )
 
 type SessionManagerDataBase interface {
-	CreateSessionDB(newSession *Session, token string) error
-	GetSessionDB(inToken string) (*Session, error)
+	CreateSessionDB(ctx context.Context, newSession *Session, token string) error
+	GetSessionDB(ctx context.Context, inToken string) (*Session, error)
 }
 
 type SessionManager struct {
@@ -44,7 +45,7 @@ func (sm *SessionManager) newToken(user *user.User) (string, error) {
 	return tokenString, nil
 }
 
-func (sm *SessionManager) CreateNewSession(u *user.User) (string, error) {
+func (sm *SessionManager) CreateNewSession(ctx context.Context, u *user.User) (string, error) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 	token, err := sm.newToken(u)
@@ -52,14 +53,14 @@ func (sm *SessionManager) CreateNewSession(u *user.User) (string, error) {
 		return "", err
 	}
 	newSession := newSession(u, token)
-	err = sm.SessionManDB.CreateSessionDB(newSession, token)
+	err = sm.SessionManDB.CreateSessionDB(ctx, newSession, token)
 	if err != nil {
 		return "", err
 	}
 	return token, nil
 }
 
-func (sm *SessionManager) GetSession(inToken string) (*Session, error) {
+func (sm *SessionManager) GetSession(ctx context.Context, inToken string) (*Session, error) {
 	hashSecretGetter := func(token *jwt.Token) (interface{}, error) {
 		method, ok := token.Method.(*jwt.SigningMethodHMAC)
 		if !ok || method.Alg() != "HS256" {
@@ -75,7 +76,7 @@ func (sm *SessionManager) GetSession(inToken string) (*Session, error) {
 		return nil, ErrNoAuth
 	}
 	sm.mu.RLock()
-	sess, err := sm.SessionManDB.GetSessionDB(inToken)
+	sess, err := sm.SessionManDB.GetSessionDB(ctx, inToken)
 	sm.mu.RUnlock()
 	if err != nil {
 		return nil, err
@@ -1,31 +1,34 @@
 package session
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
 type SessionManagerDB struct {
 	SessionManagerMS  SessionManagerMysql
 	SessionManagerRDS SessionManagerRedis
 }
 
-func (sm *SessionManagerDB) CreateSessionDB(newSession *Session, token string) error {
+func (sm *SessionManagerDB) CreateSessionDB(ctx context.Context, newSession *Session, token string) error {
 
-	err := sm.SessionManagerRDS.CreateSession(newSession, token)
+	err := sm.SessionManagerRDS.CreateSession(ctx, newSession, token)
 	if err != nil {
 		fmt.Println("can not create session in redis")
 	}
-	err = sm.SessionManagerMS.CreateSession(newSession, token)
+	err = sm.SessionManagerMS.CreateSession(ctx, newSession, token)
 	return err
 }
 
-func (sm *SessionManagerDB) GetSessionDB(inToken string) (*Session, error) {
-	sess, err := sm.SessionManagerRDS.GetSession(inToken)
+func (sm *SessionManagerDB) GetSessionDB(ctx context.Context, inToken string) (*Session, error) {
+	sess, err := sm.SessionManagerRDS.GetSession(ctx, inToken)
 	if err == nil && sess != nil {
 		fmt.Println("session from redis")
 		return sess, nil
 	} else {
 		fmt.Println("no session in redis")
 	}
-	sess, err = sm.SessionManagerMS.GetSession(inToken)
+	sess, err = sm.SessionManagerMS.GetSession(ctx, inToken)
 	if err != nil {
 		return nil, err
 	}
@@ -1,27 +1,35 @@
 package session
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"reddit/pkg/user"
 )
 
 type SessionManagerMysql struct {
 	DB *sql.DB
+	// Timeout - срок одного запроса, 0 - DefaultTimeout
+	Timeout time.Duration
 }
 
-func (sm *SessionManagerMysql) CreateSession(newSession *Session, token string) error {
-	_, err := sm.DB.Exec("INSERT INTO sessions(`token`, `user_id`) VALUES (?, ?)",
+func (sm *SessionManagerMysql) CreateSession(ctx context.Context, newSession *Session, token string) error {
+	ctx, cancel := withTimeout(ctx, sm.Timeout)
+	defer cancel()
+	_, err := sm.DB.ExecContext(ctx, "INSERT INTO sessions(`token`, `user_id`) VALUES (?, ?)",
 		token, newSession.User.ID)
 	return err
 }
 
-func (sm *SessionManagerMysql) GetSession(inToken string) (*Session, error) {
+func (sm *SessionManagerMysql) GetSession(ctx context.Context, inToken string) (*Session, error) {
+	ctx, cancel := withTimeout(ctx, sm.Timeout)
+	defer cancel()
 	currentSession := &Session{}
 	userForSession := &user.User{}
-	err := sm.DB.QueryRow("SELECT token, id, username FROM sessions JOIN users ON sessions.user_id = users.id WHERE sessions.token = ?", inToken).
+	err := sm.DB.QueryRowContext(ctx, "SELECT token, id, username FROM sessions JOIN users ON sessions.user_id = users.id WHERE sessions.token = ?", inToken).
 		Scan(&currentSession.ID, &userForSession.ID, &userForSession.Username)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -1,23 +1,40 @@
 package session
 
 import (
+	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/gomodule/redigo/redis"
 )
 
 const expireTime = 24 * 60 * 60
 
+// SessionManagerRedis берет соединение из пула на каждую команду: отмена запроса закрывает соединение,
+// и с одним общим соединением сессии пропали бы у всех
 type SessionManagerRedis struct {
-	RedisConn redis.Conn
+	RedisPool *redis.Pool
+	// Timeout - срок одной команды, 0 - DefaultTimeout
+	Timeout time.Duration
 }
 
-func (sm *SessionManagerRedis) CreateSession(newSession *Session, token string) error {
+func (sm *SessionManagerRedis) do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	ctx, cancel := withTimeout(ctx, sm.Timeout)
+	defer cancel()
+	conn, err := sm.RedisPool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return redis.DoContext(conn, ctx, cmd, args...)
+}
+
+func (sm *SessionManagerRedis) CreateSession(ctx context.Context, newSession *Session, token string) error {
 	sessionJSON, err := json.Marshal(newSession)
 	if err != nil {
 		return err
 	}
-	result, err := redis.String(sm.RedisConn.Do("SET", token, sessionJSON, "EX", expireTime))
+	result, err := redis.String(sm.do(ctx, "SET", token, sessionJSON, "EX", expireTime))
 	if err != nil || result != "OK" {
 		return err
 	}
@@ -25,9 +42,9 @@ func (sm *SessionManagerRedis) CreateSession(newSession *Session, token string)
 
 }
 
-func (sm *SessionManagerRedis) GetSession(inToken string) (*Session, error) {
+func (sm *SessionManagerRedis) GetSession(ctx context.Context, inToken string) (*Session, error) {
 	sess := &Session{}
-	sessFromRedis, err := redis.String(sm.RedisConn.Do("GET", inToken))
+	sessFromRedis, err := redis.String(sm.do(ctx, "GET", inToken))
 	if err != nil {
 		return nil, ErrNoAuth
 	}
@@ -1,14 +1,16 @@
 package session
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"reddit/pkg/user"
 )
 
 type SessManager interface {
-	CreateNewSession(u *user.User) (string, error)
-	GetSession(inToken string) (*Session, error)
+	CreateNewSession(ctx context.Context, u *user.User) (string, error)
+	GetSession(ctx context.Context, inToken string) (*Session, error)
 }
 
 type Session struct {
@@ -20,6 +22,16 @@ var (
 	ErrNoAuth = errors.New("no session found")
 )
 
+// DefaultTimeout - сколько ждем redis и mysql на одну операцию с сессией, если клиент не отвалится раньше
+const DefaultTimeout = 2 * time.Second
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithTimeout(ctx, DefaultTimeout)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
 func newSession(user *user.User, id string) *Session {
 	return &Session{
 		ID:   id,
@@ -5,6 +5,7 @@
 package session
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -35,31 +36,31 @@ func (m *MockSessManager) EXPECT() *MockSessManagerMockRecorder {
 }
 
 // CreateNewSession mocks base method.
-func (m *MockSessManager) CreateNewSession(u *user.User) (string, error) {
+func (m *MockSessManager) CreateNewSession(ctx context.Context, u *user.User) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateNewSession", u)
+	ret := m.ctrl.Call(m, "CreateNewSession", ctx, u)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateNewSession indicates an expected call of CreateNewSession.
-func (mr *MockSessManagerMockRecorder) CreateNewSession(u interface{}) *gomock.Call {
+func (mr *MockSessManagerMockRecorder) CreateNewSession(ctx, u interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateNewSession", reflect.TypeOf((*MockSessManager)(nil).CreateNewSession), u)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateNewSession", reflect.TypeOf((*MockSessManager)(nil).CreateNewSession), ctx, u)
 }
 
 // GetSession mocks base method.
-func (m *MockSessManager) GetSession(inToken string) (*Session, error) {
+func (m *MockSessManager) GetSession(ctx context.Context, inToken string) (*Session, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetSession", inToken)
+	ret := m.ctrl.Call(m, "GetSession", ctx, inToken)
 	ret0, _ := ret[0].(*Session)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetSession indicates an expected call of GetSession.
-func (mr *MockSessManagerMockRecorder) GetSession(inToken interface{}) *gomock.Call {
+func (mr *MockSessManagerMockRecorder) GetSession(ctx, inToken interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSession", reflect.TypeOf((*MockSessManager)(nil).GetSession), inToken)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSession", reflect.TypeOf((*MockSessManager)(nil).GetSession), ctx, inToken)
 }
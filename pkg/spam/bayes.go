@@ -0,0 +1,133 @@
+package spam
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+type ModelDBRepository interface {
+	LoadModelDB(ctx context.Context) (*Model, error)
+	TrainModelDB(ctx context.Context, tokens []string, isSpam bool) error
+}
+
+type TokenCount struct {
+	Token string `bson:"_id"`
+	Spam  int    `bson:"spam"`
+	Ham   int    `bson:"ham"`
+}
+
+type Model struct {
+	SpamDocs   int           `bson:"spamDocs"`
+	HamDocs    int           `bson:"hamDocs"`
+	SpamTokens int           `bson:"spamTokens"`
+	HamTokens  int           `bson:"hamTokens"`
+	Tokens     []*TokenCount `bson:"-"`
+}
+
+type NaiveBayes struct {
+	mu      *sync.RWMutex
+	ModelDB ModelDBRepository
+	model   *Model
+	tokens  map[string]*TokenCount
+}
+
+func NewNaiveBayes(ctx context.Context, repo ModelDBRepository) (*NaiveBayes, error) {
+	nb := &NaiveBayes{
+		mu:      &sync.RWMutex{},
+		ModelDB: repo,
+	}
+	err := nb.Refresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return nb, nil
+}
+
+// Refresh перечитывает модель из базы - так реплика видит обучение на остальных
+func (nb *NaiveBayes) Refresh(ctx context.Context) error {
+	model, err := nb.ModelDB.LoadModelDB(ctx)
+	if err != nil {
+		return err
+	}
+	tokens := make(map[string]*TokenCount, len(model.Tokens))
+	for _, count := range model.Tokens {
+		tokens[count.Token] = count
+	}
+	nb.mu.Lock()
+	defer nb.mu.Unlock()
+	nb.model = model
+	nb.tokens = tokens
+	return nil
+}
+
+func (nb *NaiveBayes) RunRefresh(ctx context.Context, interval time.Duration, onError func(err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := nb.Refresh(ctx)
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Score возвращает вероятность того, что текст спам, пока нет примеров обоих классов - 0
+func (nb *NaiveBayes) Score(text string) (float64, error) {
+	nb.mu.RLock()
+	defer nb.mu.RUnlock()
+	if nb.model.SpamDocs == 0 || nb.model.HamDocs == 0 {
+		return 0, nil
+	}
+	totalDocs := float64(nb.model.SpamDocs + nb.model.HamDocs)
+	vocabulary := float64(len(nb.tokens))
+	logSpam := math.Log(float64(nb.model.SpamDocs) / totalDocs)
+	logHam := math.Log(float64(nb.model.HamDocs) / totalDocs)
+	for _, token := range tokenize(text) {
+		spamCount, hamCount := 0, 0
+		if count, ok := nb.tokens[token]; ok {
+			spamCount, hamCount = count.Spam, count.Ham
+		}
+		logSpam += math.Log((float64(spamCount) + 1) / (float64(nb.model.SpamTokens) + vocabulary))
+		logHam += math.Log((float64(hamCount) + 1) / (float64(nb.model.HamTokens) + vocabulary))
+	}
+	return 1 / (1 + math.Exp(logHam-logSpam)), nil
+}
+
+// Train - модель в памяти меняется только после записи в базу, иначе она разошлась бы с базой
+func (nb *NaiveBayes) Train(ctx context.Context, text string, isSpam bool) error {
+	tokens := tokenize(text)
+	err := nb.ModelDB.TrainModelDB(ctx, tokens, isSpam)
+	if err != nil {
+		return err
+	}
+	nb.mu.Lock()
+	defer nb.mu.Unlock()
+	if isSpam {
+		nb.model.SpamDocs++
+		nb.model.SpamTokens += len(tokens)
+	} else {
+		nb.model.HamDocs++
+		nb.model.HamTokens += len(tokens)
+	}
+	for _, token := range tokens {
+		count, ok := nb.tokens[token]
+		if !ok {
+			count = &TokenCount{Token: token}
+			nb.tokens[token] = count
+			nb.model.Tokens = append(nb.model.Tokens, count)
+		}
+		if isSpam {
+			count.Spam++
+		} else {
+			count.Ham++
+		}
+	}
+	return nil
+}
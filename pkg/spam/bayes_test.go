@@ -0,0 +1,152 @@
+package spam
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// testModelRepo - база, общая для нескольких реплик классификатора
+type testModelRepo struct {
+	model *Model
+	saved int
+	err   error
+}
+
+func (r *testModelRepo) LoadModelDB(_ context.Context) (*Model, error) {
+	model := *r.model
+	model.Tokens = make([]*TokenCount, 0, len(r.model.Tokens))
+	for _, count := range r.model.Tokens {
+		tokenCopy := *count
+		model.Tokens = append(model.Tokens, &tokenCopy)
+	}
+	return &model, nil
+}
+
+func (r *testModelRepo) TrainModelDB(_ context.Context, tokens []string, isSpam bool) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.saved++
+	if isSpam {
+		r.model.SpamDocs++
+		r.model.SpamTokens += len(tokens)
+	} else {
+		r.model.HamDocs++
+		r.model.HamTokens += len(tokens)
+	}
+	for _, token := range tokens {
+		var count *TokenCount
+		for _, stored := range r.model.Tokens {
+			if stored.Token == token {
+				count = stored
+			}
+		}
+		if count == nil {
+			count = &TokenCount{Token: token}
+			r.model.Tokens = append(r.model.Tokens, count)
+		}
+		if isSpam {
+			count.Spam++
+		} else {
+			count.Ham++
+		}
+	}
+	return nil
+}
+
+func TestNaiveBayes(t *testing.T) {
+	repo := &testModelRepo{model: &Model{Tokens: make([]*TokenCount, 0)}}
+	classifier, err := NewNaiveBayes(context.Background(), repo)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+
+	// пока модель не обучена, все считается не спамом
+	score, err := classifier.Score("buy cheap pills now")
+	if err != nil || score != 0 {
+		t.Errorf("expected zero score for empty model, got %f, %v", score, err)
+		return
+	}
+
+	spamTexts := []string{
+		"buy cheap pills now",
+		"cheap pills discount buy",
+		"casino bonus buy now",
+	}
+	hamTexts := []string{
+		"golang generics discussion",
+		"how to write tests in golang",
+		"mongo transactions discussion",
+	}
+	for _, text := range spamTexts {
+		err = classifier.Train(context.Background(), text, true)
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+			return
+		}
+	}
+	for _, text := range hamTexts {
+		err = classifier.Train(context.Background(), text, false)
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+			return
+		}
+	}
+	if repo.saved != len(spamTexts)+len(hamTexts) {
+		t.Errorf("model must be saved after each training, saved %d times", repo.saved)
+		return
+	}
+
+	spamScore, _ := classifier.Score("cheap pills buy")
+	hamScore, _ := classifier.Score("golang discussion")
+	if spamScore <= 0.5 || hamScore >= 0.5 {
+		t.Errorf("wrong scores: spam %f, ham %f", spamScore, hamScore)
+		return
+	}
+
+	// модель восстанавливается из сохраненной
+	restored, err := NewNaiveBayes(context.Background(), repo)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	restoredScore, _ := restored.Score("cheap pills buy")
+	if restoredScore != spamScore {
+		t.Errorf("restored model gives %f, expected %f", restoredScore, spamScore)
+		return
+	}
+
+	// запись не удалась - модель в памяти не меняется
+	repo.err = fmt.Errorf("db_error")
+	err = classifier.Train(context.Background(), "golang pills", false)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+	failedScore, _ := classifier.Score("cheap pills buy")
+	if failedScore != spamScore {
+		t.Errorf("failed training changed the model: %f, expected %f", failedScore, spamScore)
+		return
+	}
+	repo.err = nil
+
+	// обучение на другой реплике видно после обновления
+	err = restored.Train(context.Background(), "cheap pills buy", false)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	err = classifier.Refresh(context.Background())
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	refreshedScore, _ := classifier.Score("cheap pills buy")
+	restoredScore, _ = restored.Score("cheap pills buy")
+	if refreshedScore != restoredScore || refreshedScore >= spamScore {
+		t.Errorf("refreshed model gives %f, expected %f", refreshedScore, restoredScore)
+		return
+	}
+}
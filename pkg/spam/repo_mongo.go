@@ -0,0 +1,75 @@
+package spam
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reddit/pkg/post"
+)
+
+const modelID = "naive_bayes"
+
+// ModelDBRepo - счетчики документов лежат одним документом в Models, а каждый токен - своим документом в Tokens,
+// так что обучение на разных репликах только увеличивает счетчики и не затирает чужие
+type ModelDBRepo struct {
+	Models post.CollectionHelper
+	Tokens post.CollectionHelper
+	// Timeout - срок одной операции, 0 - post.DefaultTimeout
+	Timeout time.Duration
+}
+
+func (m *ModelDBRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return post.WithTimeout(ctx, m.Timeout)
+}
+
+func (m *ModelDBRepo) LoadModelDB(ctx context.Context) (*Model, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	model := &Model{}
+	err := m.Models.FindOne(ctx, bson.M{"_id": modelID}).Decode(model)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	cursor, err := m.Tokens.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	model.Tokens = make([]*TokenCount, 0)
+	err = cursor.All(ctx, &model.Tokens)
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// TrainModelDB - сначала токены, потом счетчики документов: при ошибке посередине модель только чуть завышает
+// счетчики этих токенов, а не теряет документ целиком
+func (m *ModelDBRepo) TrainModelDB(ctx context.Context, tokens []string, isSpam bool) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	tokenField, docsField, tokensField := "ham", "hamDocs", "hamTokens"
+	if isSpam {
+		tokenField, docsField, tokensField = "spam", "spamDocs", "spamTokens"
+	}
+	if len(tokens) != 0 {
+		updates := make([]mongo.WriteModel, 0, len(tokens))
+		for _, token := range tokens {
+			updates = append(updates, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": token}).
+				SetUpdate(bson.M{"$inc": bson.M{tokenField: 1}}).
+				SetUpsert(true))
+		}
+		err := m.Tokens.BulkWrite(ctx, updates)
+		if err != nil {
+			return err
+		}
+	}
+	update := bson.M{"$inc": bson.M{docsField: 1, tokensField: len(tokens)}}
+	_, err := m.Models.UpdateOne(ctx, bson.M{"_id": modelID}, update, options.Update().SetUpsert(true))
+	return err
+}
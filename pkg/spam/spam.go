@@ -0,0 +1,37 @@
+package spam
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+type Classifier interface {
+	Score(text string) (float64, error)
+	Train(ctx context.Context, text string, isSpam bool) error
+}
+
+const (
+	minTokenLength = 2
+	maxTokenLength = 40
+)
+
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	seen := make(map[string]struct{}, len(words))
+	tokens := make([]string, 0, len(words))
+	for _, word := range words {
+		length := len([]rune(word))
+		if length < minTokenLength || length > maxTokenLength {
+			continue
+		}
+		if _, ok := seen[word]; ok {
+			continue
+		}
+		seen[word] = struct{}{}
+		tokens = append(tokens, word)
+	}
+	return tokens
+}
@@ -0,0 +1,28 @@
+package user
+
+import "strings"
+
+type Moderators struct {
+	usernames map[string]struct{}
+}
+
+func NewModerators(usernames []string) *Moderators {
+	moderators := &Moderators{
+		usernames: make(map[string]struct{}, len(usernames)),
+	}
+	for _, username := range usernames {
+		username = strings.TrimSpace(username)
+		if username != "" {
+			moderators.usernames[username] = struct{}{}
+		}
+	}
+	return moderators
+}
+
+func (m *Moderators) IsModerator(u *User) bool {
+	if m == nil || u == nil {
+		return false
+	}
+	_, ok := m.usernames[u.Username]
+	return ok
+}
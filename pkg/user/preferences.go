@@ -0,0 +1,33 @@
+package user
+
+import "github.com/asaskevich/govalidator"
+
+const (
+	NSFWHide = "hide"
+	NSFWBlur = "blur"
+	NSFWShow = "show"
+)
+
+type Preferences struct {
+	NSFW string `json:"nsfw" valid:"required,in(hide|blur|show)"`
+}
+
+func DefaultPreferences() *Preferences {
+	return &Preferences{
+		NSFW: NSFWHide,
+	}
+}
+
+func (p *Preferences) Validate() []string {
+	_, err := govalidator.ValidateStruct(p)
+	validationErrors := make([]string, 0)
+	if err == nil {
+		return validationErrors
+	}
+	if allErrs, ok := err.(govalidator.Errors); ok {
+		for _, fld := range allErrs {
+			validationErrors = append(validationErrors, fld.Error())
+		}
+	}
+	return validationErrors
+}
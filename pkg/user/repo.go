@@ -1,8 +1,10 @@
 package user
 
 import (
+	"context"
 	"errors"
 	"sync"
+	"time"
 
 	"reddit/pkg/idgenerator"
 
@@ -16,8 +18,11 @@ var (
 )
 
 type UserDBRepository interface {
-	FindUserByUsernameDB(username string) (*User, error)
-	AddNewUserDB(newUser *User) error
+	FindUserByUsernameDB(ctx context.Context, username string) (*User, error)
+	AddNewUserDB(ctx context.Context, newUser *User) error
+	GetRegistrationTimeDB(ctx context.Context, userID string) (time.Time, error)
+	GetPreferencesDB(ctx context.Context, userID string) (*Preferences, error)
+	SetPreferencesDB(ctx context.Context, userID string, preferences *Preferences) error
 }
 
 type UserMemoryRepository struct {
@@ -34,10 +39,10 @@ func NewUserMemoryRepository(userDBRepo UserDBRepository, idGenerator idgenerato
 	}
 }
 
-func (u *UserMemoryRepository) Login(username, password string) (*User, error) {
+func (u *UserMemoryRepository) Login(ctx context.Context, username, password string) (*User, error) {
 	u.mu.RLock()
 	defer u.mu.RUnlock()
-	loginUser, err := u.UserDBRepo.FindUserByUsernameDB(username)
+	loginUser, err := u.UserDBRepo.FindUserByUsernameDB(ctx, username)
 	if err != nil {
 		return nil, err
 	}
@@ -51,7 +56,7 @@ func (u *UserMemoryRepository) Login(username, password string) (*User, error) {
 	return loginUser, nil
 }
 
-func (u *UserMemoryRepository) Register(username, password string) (*User, error) {
+func (u *UserMemoryRepository) Register(ctx context.Context, username, password string) (*User, error) {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 	hashedPassword, err := hasher.GetHashPassword(password)
@@ -59,9 +64,37 @@ func (u *UserMemoryRepository) Register(username, password string) (*User, error
 		return nil, err
 	}
 	newUser := newUser(u.generatorID.GenerateID(16), username, hashedPassword)
-	err = u.UserDBRepo.AddNewUserDB(newUser)
+	err = u.UserDBRepo.AddNewUserDB(ctx, newUser)
 	if err != nil {
 		return nil, err
 	}
 	return newUser, nil
 }
+
+func (u *UserMemoryRepository) FindUser(ctx context.Context, username string) (*User, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	foundUser, err := u.UserDBRepo.FindUserByUsernameDB(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return &User{ID: foundUser.ID, Username: foundUser.Username}, nil
+}
+
+func (u *UserMemoryRepository) GetRegistrationTime(ctx context.Context, userID string) (time.Time, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.UserDBRepo.GetRegistrationTimeDB(ctx, userID)
+}
+
+func (u *UserMemoryRepository) GetPreferences(ctx context.Context, userID string) (*Preferences, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.UserDBRepo.GetPreferencesDB(ctx, userID)
+}
+
+func (u *UserMemoryRepository) SetPreferences(ctx context.Context, userID string, preferences *Preferences) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.UserDBRepo.SetPreferencesDB(ctx, userID, preferences)
+}
@@ -5,6 +5,7 @@
 package user
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -33,32 +34,61 @@ func (m *MockUserRepo) EXPECT() *MockUserRepoMockRecorder {
 	return m.recorder
 }
 
+// GetPreferences mocks base method.
+func (m *MockUserRepo) GetPreferences(ctx context.Context, userID string) (*Preferences, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPreferences", ctx, userID)
+	ret0, _ := ret[0].(*Preferences)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPreferences indicates an expected call of GetPreferences.
+func (mr *MockUserRepoMockRecorder) GetPreferences(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPreferences", reflect.TypeOf((*MockUserRepo)(nil).GetPreferences), ctx, userID)
+}
+
 // Login mocks base method.
-func (m *MockUserRepo) Login(username, password string) (*User, error) {
+func (m *MockUserRepo) Login(ctx context.Context, username, password string) (*User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Login", username, password)
+	ret := m.ctrl.Call(m, "Login", ctx, username, password)
 	ret0, _ := ret[0].(*User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Login indicates an expected call of Login.
-func (mr *MockUserRepoMockRecorder) Login(username, password interface{}) *gomock.Call {
+func (mr *MockUserRepoMockRecorder) Login(ctx, username, password interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockUserRepo)(nil).Login), username, password)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockUserRepo)(nil).Login), ctx, username, password)
 }
 
 // Register mocks base method.
-func (m *MockUserRepo) Register(username, password string) (*User, error) {
+func (m *MockUserRepo) Register(ctx context.Context, username, password string) (*User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Register", username, password)
+	ret := m.ctrl.Call(m, "Register", ctx, username, password)
 	ret0, _ := ret[0].(*User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Register indicates an expected call of Register.
-func (mr *MockUserRepoMockRecorder) Register(username, password interface{}) *gomock.Call {
+func (mr *MockUserRepoMockRecorder) Register(ctx, username, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockUserRepo)(nil).Register), ctx, username, password)
+}
+
+// SetPreferences mocks base method.
+func (m *MockUserRepo) SetPreferences(ctx context.Context, userID string, preferences *Preferences) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPreferences", ctx, userID, preferences)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPreferences indicates an expected call of SetPreferences.
+func (mr *MockUserRepoMockRecorder) SetPreferences(ctx, userID, preferences interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockUserRepo)(nil).Register), username, password)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPreferences", reflect.TypeOf((*MockUserRepo)(nil).SetPreferences), ctx, userID, preferences)
 }
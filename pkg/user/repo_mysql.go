@@ -1,20 +1,36 @@
 package user
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
 )
 
+// DefaultTimeout - сколько ждем mysql на один запрос, если клиент не отвалится раньше
+const DefaultTimeout = 5 * time.Second
+
 type UserDBRepo struct {
 	DB *sql.DB
+	// Timeout - срок одного запроса, 0 - DefaultTimeout
+	Timeout time.Duration
+}
+
+func (u *UserDBRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if u.Timeout <= 0 {
+		return context.WithTimeout(ctx, DefaultTimeout)
+	}
+	return context.WithTimeout(ctx, u.Timeout)
 }
 
-func (u *UserDBRepo) FindUserByUsernameDB(username string) (*User, error) {
+func (u *UserDBRepo) FindUserByUsernameDB(ctx context.Context, username string) (*User, error) {
+	ctx, cancel := u.withTimeout(ctx)
+	defer cancel()
 	loginUser := &User{}
 	err := u.DB.
-		QueryRow("SELECT id, username, password FROM users WHERE username = ?", username).
+		QueryRowContext(ctx, "SELECT id, username, password FROM users WHERE username = ?", username).
 		Scan(&loginUser.ID, &loginUser.Username, &loginUser.password)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -25,8 +41,11 @@ func (u *UserDBRepo) FindUserByUsernameDB(username string) (*User, error) {
 	return loginUser, nil
 }
 
-func (u *UserDBRepo) AddNewUserDB(newUser *User) error {
-	_, err := u.DB.Exec(
+func (u *UserDBRepo) AddNewUserDB(ctx context.Context, newUser *User) error {
+	ctx, cancel := u.withTimeout(ctx)
+	defer cancel()
+	_, err := u.DB.ExecContext(
+		ctx,
 		"INSERT INTO users (`id`, `username`, `password`) VALUES (?, ?, ?)",
 		newUser.ID,
 		newUser.Username,
@@ -41,6 +60,50 @@ func (u *UserDBRepo) AddNewUserDB(newUser *User) error {
 	return nil
 }
 
+func (u *UserDBRepo) GetRegistrationTimeDB(ctx context.Context, userID string) (time.Time, error) {
+	ctx, cancel := u.withTimeout(ctx)
+	defer cancel()
+	var registered time.Time
+	err := u.DB.
+		QueryRowContext(ctx, "SELECT created_at FROM users WHERE id = ?", userID).
+		Scan(&registered)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, ErrNoUser
+		}
+		return time.Time{}, err
+	}
+	return registered, nil
+}
+
+func (u *UserDBRepo) GetPreferencesDB(ctx context.Context, userID string) (*Preferences, error) {
+	ctx, cancel := u.withTimeout(ctx)
+	defer cancel()
+	preferences := &Preferences{}
+	err := u.DB.
+		QueryRowContext(ctx, "SELECT nsfw_mode FROM users WHERE id = ?", userID).
+		Scan(&preferences.NSFW)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoUser
+		}
+		return nil, err
+	}
+	return preferences, nil
+}
+
+func (u *UserDBRepo) SetPreferencesDB(ctx context.Context, userID string, preferences *Preferences) error {
+	ctx, cancel := u.withTimeout(ctx)
+	defer cancel()
+	_, err := u.DB.ExecContext(
+		ctx,
+		"UPDATE users SET `nsfw_mode` = ? WHERE id = ?",
+		preferences.NSFW,
+		userID,
+	)
+	return err
+}
+
 func isAlreadyExists(err error) bool {
 	mysqlError, ok := err.(*mysql.MySQLError)
 	return ok && mysqlError.Number == 1062
@@ -1,5 +1,7 @@
 package user
 
+import "context"
+
 type User struct {
 	ID       string `json:"id"`
 	Username string `json:"username"`
@@ -7,8 +9,10 @@ type User struct {
 }
 
 type UserRepo interface {
-	Login(username, password string) (*User, error)
-	Register(username, password string) (*User, error)
+	Login(ctx context.Context, username, password string) (*User, error)
+	Register(ctx context.Context, username, password string) (*User, error)
+	GetPreferences(ctx context.Context, userID string) (*Preferences, error)
+	SetPreferences(ctx context.Context, userID string, preferences *Preferences) error
 }
 
 func newUser(id, uName, pass string) *User {
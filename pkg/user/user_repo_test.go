@@ -1,6 +1,7 @@
 package user
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -34,7 +35,7 @@ func TestLogin(t *testing.T) {
 		WithArgs(username).
 		WillReturnError(fmt.Errorf("db_error"))
 
-	_, err = repo.Login(username, password)
+	_, err = repo.Login(context.Background(), username, password)
 	if err := mock.ExpectationsWereMet(); err != nil { // nolint govet
 		t.Errorf("there were unfulfilled expectations: %s", err)
 		return
@@ -50,7 +51,7 @@ func TestLogin(t *testing.T) {
 		WithArgs(username).
 		WillReturnError(sql.ErrNoRows)
 
-	_, err = repo.Login(username, password)
+	_, err = repo.Login(context.Background(), username, password)
 	if err := mock.ExpectationsWereMet(); err != nil { // nolint govet
 		t.Errorf("there were unfulfilled expectations: %s", err)
 		return
@@ -77,7 +78,7 @@ func TestLogin(t *testing.T) {
 		ExpectQuery("SELECT id, username, password FROM users WHERE").
 		WithArgs(username).
 		WillReturnRows(rows)
-	loggedInUser, err := repo.Login(username, wrongPassword)
+	loggedInUser, err := repo.Login(context.Background(), username, wrongPassword)
 	if err := mock.ExpectationsWereMet(); err != nil { // nolint govet
 		t.Errorf("there were unfulfilled expectations: %s", err)
 		return
@@ -110,7 +111,7 @@ func TestLogin(t *testing.T) {
 		ExpectQuery("SELECT id, username, password FROM users WHERE").
 		WithArgs(username).
 		WillReturnRows(rows)
-	loggedInUser, err = repo.Login(username, unHashedPassword)
+	loggedInUser, err = repo.Login(context.Background(), username, unHashedPassword)
 	if err := mock.ExpectationsWereMet(); err != nil { // nolint govet
 		t.Errorf("there were unfulfilled expectations: %s", err)
 		return
@@ -154,7 +155,7 @@ func TestRegister(t *testing.T) {
 		WithArgs(userToInsert.ID, userToInsert.Username, userToInsert.password).
 		WillReturnError(fmt.Errorf("db_error"))
 
-	_, err = repo.Register(userToInsert.Username, "some_password")
+	_, err = repo.Register(context.Background(), userToInsert.Username, "some_password")
 
 	if err := mock.ExpectationsWereMet(); err != nil { // nolint govet
 		t.Errorf("there were unfulfilled expectations: %s", err)
@@ -173,7 +174,7 @@ func TestRegister(t *testing.T) {
 			Number: 1062,
 		})
 
-	_, err = repo.Register(userToInsert.Username, "some_password")
+	_, err = repo.Register(context.Background(), userToInsert.Username, "some_password")
 
 	if err := mock.ExpectationsWereMet(); err != nil { // nolint govet
 		t.Errorf("there were unfulfilled expectations: %s", err)
@@ -196,7 +197,7 @@ func TestRegister(t *testing.T) {
 		WithArgs(userToInsert.ID, userToInsert.Username, userToInsert.password).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	registredUser, err := repo.Register(userToInsert.Username, "some_password")
+	registredUser, err := repo.Register(context.Background(), userToInsert.Username, "some_password")
 
 	if err := mock.ExpectationsWereMet(); err != nil { // nolint govet
 		t.Errorf("there were unfulfilled expectations: %s", err)
@@ -214,3 +215,59 @@ func TestRegister(t *testing.T) {
 	}
 
 }
+
+func TestPreferences(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("can not create mock")
+	}
+	defer db.Close()
+	repo := NewUserMemoryRepository(&UserDBRepo{DB: db}, &idgenerator.TestIDGenerator{})
+
+	// юзера не существует
+	mock.
+		ExpectQuery("SELECT nsfw_mode FROM users WHERE").
+		WithArgs("some_id").
+		WillReturnError(sql.ErrNoRows)
+	_, err = repo.GetPreferences(context.Background(), "some_id")
+	if !errors.Is(err, ErrNoUser) {
+		t.Errorf("wrong error: expected %s, got %v", ErrNoUser, err)
+		return
+	}
+
+	// настройки получены
+	mock.
+		ExpectQuery("SELECT nsfw_mode FROM users WHERE").
+		WithArgs("some_id").
+		WillReturnRows(sqlmock.NewRows([]string{"nsfw_mode"}).AddRow(NSFWBlur))
+	preferences, err := repo.GetPreferences(context.Background(), "some_id")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if preferences.NSFW != NSFWBlur {
+		t.Errorf("wrong nsfw mode: expected %s, got %s", NSFWBlur, preferences.NSFW)
+		return
+	}
+
+	// настройки сохранены
+	mock.
+		ExpectExec("UPDATE users SET").
+		WithArgs(NSFWShow, "some_id").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	err = repo.SetPreferences(context.Background(), "some_id", &Preferences{NSFW: NSFWShow})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if err := mock.ExpectationsWereMet(); err != nil { // nolint govet
+		t.Errorf("there were unfulfilled expectations: %s", err)
+		return
+	}
+
+	// некорректное значение настройки
+	if validationErrors := (&Preferences{NSFW: "sometimes"}).Validate(); len(validationErrors) == 0 {
+		t.Errorf("expected validation errors, got nothing")
+		return
+	}
+}
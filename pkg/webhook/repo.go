@@ -0,0 +1,336 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"reddit/pkg/post"
+	"reddit/pkg/preview"
+	"reddit/pkg/user"
+)
+
+type WebhookDBRepository interface {
+	AddSubscriptionDB(ctx context.Context, subscription *Subscription) error
+	GetSubscriptionsDB(ctx context.Context) ([]*Subscription, error)
+	MatchSubscriptionsDB(ctx context.Context, category, event string) ([]*Subscription, error)
+	DeleteSubscriptionDB(ctx context.Context, subscriptionID string) (bool, error)
+	AddDeliveryDB(ctx context.Context, delivery *Delivery) error
+	GetDueDeliveriesDB(ctx context.Context, now time.Time, limit int) ([]*Delivery, error)
+	ClaimDeliveryDB(ctx context.Context, delivery *Delivery, until time.Time) (bool, error)
+	UpdateDeliveryDB(ctx context.Context, delivery *Delivery) error
+	GetDeliveriesDB(ctx context.Context, subscriptionID string, limit int) ([]*Delivery, error)
+	GetDeadDeliveriesDB(ctx context.Context, limit int) ([]*Delivery, error)
+	ResetDeliveryDB(ctx context.Context, deliveryID string, now time.Time) (bool, error)
+}
+
+type WebhookBusinessLogic struct {
+	WebhookDBRepo WebhookDBRepository
+	Client        HTTPDoer
+	MaxAttempts   int
+	BaseDelay     time.Duration
+	Workers       int
+	wake          chan struct{}
+}
+
+func NewWebhookBusinessLogic(repo WebhookDBRepository) *WebhookBusinessLogic {
+	return newWebhookBusinessLogic(repo, preview.IsBlocked)
+}
+
+// newWebhookBusinessLogic - адрес задает подписчик, поэтому клиент, как и превью, ходит только на публичные адреса
+// и не идет по редиректам: ответ 3xx считается неудачной попыткой
+func newWebhookBusinessLogic(repo WebhookDBRepository, blocked func(ip net.IP) bool) *WebhookBusinessLogic {
+	transport := &http.Transport{
+		// прокси из окружения не используем, иначе проверка адреса теряет смысл
+		Proxy:                 nil,
+		DialContext:           preview.PublicDialer(deliveryTimeout, blocked).DialContext,
+		TLSHandshakeTimeout:   deliveryTimeout,
+		ResponseHeaderTimeout: deliveryTimeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+	return &WebhookBusinessLogic{
+		WebhookDBRepo: repo,
+		Client: &http.Client{
+			Transport: transport,
+			Timeout:   deliveryTimeout,
+			CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		MaxAttempts: DefaultMaxAttempts,
+		BaseDelay:   DefaultBaseDelay,
+		Workers:     DefaultWorkers,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Name - вебхуки как сток outbox
+func (w *WebhookBusinessLogic) Name() string {
+	return "webhooks"
+}
+
+// Handle раскладывает доменное событие по подпискам. Если упадет на середине, relay повторит событие целиком,
+// но уже созданные доставки не задвоятся: на пару событие-подписка доставка одна
+func (w *WebhookBusinessLogic) Handle(ctx context.Context, event *post.DomainEvent) error {
+	kind, ok := outboxEvents[event.Type]
+	if !ok {
+		return nil
+	}
+	payload, err := json.Marshal(&Payload{
+		ID:       event.ID.Hex(),
+		Event:    kind,
+		Category: event.Category,
+		Created:  event.Created,
+		Data:     json.RawMessage(event.Payload),
+	})
+	if err != nil {
+		return err
+	}
+	subscriptions, err := w.WebhookDBRepo.MatchSubscriptionsDB(ctx, event.Category, kind)
+	if err != nil {
+		return fmt.Errorf("webhook %s: %w", kind, err)
+	}
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	var errs []error
+	for _, subscription := range subscriptions {
+		err = w.WebhookDBRepo.AddDeliveryDB(ctx, &Delivery{
+			EventID:        event.ID.Hex(),
+			SubscriptionID: subscription.ID.Hex(),
+			Event:          kind,
+			Payload:        string(payload),
+			Status:         StatusPending,
+			NextAttempt:    now,
+			Created:        now,
+			Updated:        now,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s for %s: %w", kind, subscription.ID.Hex(), err))
+		}
+	}
+	if len(subscriptions) > 0 {
+		w.signal()
+	}
+	return errors.Join(errs...)
+}
+
+func (w *WebhookBusinessLogic) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// RunSender отправляет доставки, которым пришло время: по таймеру и сразу после новых событий.
+// Реплик может быть несколько, каждую доставку забирает только одна. Работает, пока не отменят ctx
+func (w *WebhookBusinessLogic) RunSender(ctx context.Context, interval time.Duration, onError func(err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+		if _, err := w.SendDue(ctx, time.Now()); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+// SendDue отдает число удачных доставок; неудачная доставка - не ошибка, ошибки тут только от базы
+func (w *WebhookBusinessLogic) SendDue(ctx context.Context, now time.Time) (int, error) {
+	deliveries, err := w.WebhookDBRepo.GetDueDeliveriesDB(ctx, now, dueBatch)
+	if err != nil || len(deliveries) == 0 {
+		return 0, err
+	}
+	subscriptions, err := w.WebhookDBRepo.GetSubscriptionsDB(ctx)
+	if err != nil {
+		return 0, err
+	}
+	byID := make(map[string]*Subscription, len(subscriptions))
+	for _, subscription := range subscriptions {
+		byID[subscription.ID.Hex()] = subscription
+	}
+
+	mu := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	slots := make(chan struct{}, w.Workers)
+	delivered := 0
+	var errs []error
+	for _, delivery := range deliveries {
+		claimed, err := w.WebhookDBRepo.ClaimDeliveryDB(ctx, delivery, now.Add(lease))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		// попытка считается сразу, чтобы упавшая на ней реплика не повторяла ее бесконечно
+		delivery.Attempts++
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(delivery *Delivery) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			ok, err := w.deliver(ctx, delivery, byID[delivery.SubscriptionID])
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+			}
+			if ok {
+				delivered++
+			}
+		}(delivery)
+	}
+	wg.Wait()
+	return delivered, errors.Join(errs...)
+}
+
+// deliver делает одну попытку и записывает ее итог в журнал
+func (w *WebhookBusinessLogic) deliver(ctx context.Context, delivery *Delivery, subscription *Subscription) (bool, error) {
+	delivery.LastStatusCode = 0
+	delivery.LastError = ErrNoSubscription.Error()
+	if subscription != nil {
+		delivery.LastStatusCode, delivery.LastError = w.post(ctx, delivery, subscription)
+	}
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	delivery.Updated = now
+	switch {
+	case delivery.LastError == "":
+		delivery.Status = StatusDelivered
+	case subscription == nil || delivery.Attempts >= w.MaxAttempts:
+		delivery.Status = StatusDead
+	default:
+		delivery.Status = StatusPending
+		delivery.NextAttempt = now.Add(Backoff(w.BaseDelay, delivery.Attempts))
+	}
+	err := w.WebhookDBRepo.UpdateDeliveryDB(ctx, delivery)
+	if err != nil {
+		return false, err
+	}
+	return delivery.Status == StatusDelivered, nil
+}
+
+// post возвращает код ответа и текст ошибки, пустой при успехе
+func (w *WebhookBusinessLogic) post(ctx context.Context, delivery *Delivery, subscription *Subscription) (int, string) {
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err.Error()
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID.Hex())
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(subscription.Secret, timestamp, body))
+	resp, err := w.Client.Do(req)
+	if errors.Is(err, preview.ErrBlockedHost) {
+		return 0, ErrBlockedHost.Error()
+	}
+	if err != nil {
+		return 0, truncate(err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorSize))
+		return resp.StatusCode, ""
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorSize))
+	return resp.StatusCode, truncate(fmt.Sprintf("unexpected status %d: %s", resp.StatusCode, respBody))
+}
+
+func truncate(text string) string {
+	if len(text) > maxErrorSize {
+		return text[:maxErrorSize]
+	}
+	return text
+}
+
+// Subscribe без событий подписывает на все
+func (w *WebhookBusinessLogic) Subscribe(ctx context.Context, author *user.User, form *SubscriptionForm) (*Subscription, error) {
+	secret := make([]byte, secretSize)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return nil, err
+	}
+	events := form.Events
+	if len(events) == 0 {
+		events = Events
+	}
+	subscription := &Subscription{
+		Category:  form.Category,
+		URL:       form.URL,
+		Events:    events,
+		Secret:    hex.EncodeToString(secret),
+		CreatedBy: author,
+		Created:   time.Now().UTC(),
+	}
+	err = w.WebhookDBRepo.AddSubscriptionDB(ctx, subscription)
+	if err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+func (w *WebhookBusinessLogic) Subscriptions(ctx context.Context) ([]*Subscription, error) {
+	subscriptions, err := w.WebhookDBRepo.GetSubscriptionsDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, subscription := range subscriptions {
+		subscription.Secret = ""
+	}
+	return subscriptions, nil
+}
+
+// Unsubscribe - недоставленное по удаленной подписке уйдет в dead-letter при следующей попытке
+func (w *WebhookBusinessLogic) Unsubscribe(ctx context.Context, subscriptionID string) error {
+	deleted, err := w.WebhookDBRepo.DeleteSubscriptionDB(ctx, subscriptionID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNoSubscription
+	}
+	return nil
+}
+
+func (w *WebhookBusinessLogic) Deliveries(ctx context.Context, subscriptionID string) ([]*Delivery, error) {
+	return w.WebhookDBRepo.GetDeliveriesDB(ctx, subscriptionID, maxLogSize)
+}
+
+func (w *WebhookBusinessLogic) DeadLetters(ctx context.Context) ([]*Delivery, error) {
+	return w.WebhookDBRepo.GetDeadDeliveriesDB(ctx, maxLogSize)
+}
+
+// Redeliver возвращает доставку из dead-letter списка в очередь с полным числом попыток
+func (w *WebhookBusinessLogic) Redeliver(ctx context.Context, deliveryID string) error {
+	reset, err := w.WebhookDBRepo.ResetDeliveryDB(ctx, deliveryID, time.Now().UTC().Truncate(time.Millisecond))
+	if err != nil {
+		return err
+	}
+	if !reset {
+		return ErrNoDelivery
+	}
+	w.signal()
+	return nil
+}
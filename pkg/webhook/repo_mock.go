@@ -0,0 +1,163 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webhook.go
+
+// Package webhook is a generated GoMock package.
+package webhook
+
+import (
+	context "context"
+	http "net/http"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	user "reddit/pkg/user"
+)
+
+// MockWebhookRepo is a mock of WebhookRepo interface.
+type MockWebhookRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebhookRepoMockRecorder
+}
+
+// MockWebhookRepoMockRecorder is the mock recorder for MockWebhookRepo.
+type MockWebhookRepoMockRecorder struct {
+	mock *MockWebhookRepo
+}
+
+// NewMockWebhookRepo creates a new mock instance.
+func NewMockWebhookRepo(ctrl *gomock.Controller) *MockWebhookRepo {
+	mock := &MockWebhookRepo{ctrl: ctrl}
+	mock.recorder = &MockWebhookRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebhookRepo) EXPECT() *MockWebhookRepoMockRecorder {
+	return m.recorder
+}
+
+// DeadLetters mocks base method.
+func (m *MockWebhookRepo) DeadLetters(ctx context.Context) ([]*Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeadLetters", ctx)
+	ret0, _ := ret[0].([]*Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeadLetters indicates an expected call of DeadLetters.
+func (mr *MockWebhookRepoMockRecorder) DeadLetters(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeadLetters", reflect.TypeOf((*MockWebhookRepo)(nil).DeadLetters), ctx)
+}
+
+// Deliveries mocks base method.
+func (m *MockWebhookRepo) Deliveries(ctx context.Context, subscriptionID string) ([]*Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Deliveries", ctx, subscriptionID)
+	ret0, _ := ret[0].([]*Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Deliveries indicates an expected call of Deliveries.
+func (mr *MockWebhookRepoMockRecorder) Deliveries(ctx, subscriptionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deliveries", reflect.TypeOf((*MockWebhookRepo)(nil).Deliveries), ctx, subscriptionID)
+}
+
+// Redeliver mocks base method.
+func (m *MockWebhookRepo) Redeliver(ctx context.Context, deliveryID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Redeliver", ctx, deliveryID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Redeliver indicates an expected call of Redeliver.
+func (mr *MockWebhookRepoMockRecorder) Redeliver(ctx, deliveryID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Redeliver", reflect.TypeOf((*MockWebhookRepo)(nil).Redeliver), ctx, deliveryID)
+}
+
+// Subscribe mocks base method.
+func (m *MockWebhookRepo) Subscribe(ctx context.Context, author *user.User, form *SubscriptionForm) (*Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Subscribe", ctx, author, form)
+	ret0, _ := ret[0].(*Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Subscribe indicates an expected call of Subscribe.
+func (mr *MockWebhookRepoMockRecorder) Subscribe(ctx, author, form interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockWebhookRepo)(nil).Subscribe), ctx, author, form)
+}
+
+// Subscriptions mocks base method.
+func (m *MockWebhookRepo) Subscriptions(ctx context.Context) ([]*Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Subscriptions", ctx)
+	ret0, _ := ret[0].([]*Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Subscriptions indicates an expected call of Subscriptions.
+func (mr *MockWebhookRepoMockRecorder) Subscriptions(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscriptions", reflect.TypeOf((*MockWebhookRepo)(nil).Subscriptions), ctx)
+}
+
+// Unsubscribe mocks base method.
+func (m *MockWebhookRepo) Unsubscribe(ctx context.Context, subscriptionID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unsubscribe", ctx, subscriptionID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unsubscribe indicates an expected call of Unsubscribe.
+func (mr *MockWebhookRepoMockRecorder) Unsubscribe(ctx, subscriptionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unsubscribe", reflect.TypeOf((*MockWebhookRepo)(nil).Unsubscribe), ctx, subscriptionID)
+}
+
+// MockHTTPDoer is a mock of HTTPDoer interface.
+type MockHTTPDoer struct {
+	ctrl     *gomock.Controller
+	recorder *MockHTTPDoerMockRecorder
+}
+
+// MockHTTPDoerMockRecorder is the mock recorder for MockHTTPDoer.
+type MockHTTPDoerMockRecorder struct {
+	mock *MockHTTPDoer
+}
+
+// NewMockHTTPDoer creates a new mock instance.
+func NewMockHTTPDoer(ctrl *gomock.Controller) *MockHTTPDoer {
+	mock := &MockHTTPDoer{ctrl: ctrl}
+	mock.recorder = &MockHTTPDoerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHTTPDoer) EXPECT() *MockHTTPDoerMockRecorder {
+	return m.recorder
+}
+
+// Do mocks base method.
+func (m *MockHTTPDoer) Do(req *http.Request) (*http.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Do", req)
+	ret0, _ := ret[0].(*http.Response)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Do indicates an expected call of Do.
+func (mr *MockHTTPDoerMockRecorder) Do(req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Do", reflect.TypeOf((*MockHTTPDoer)(nil).Do), req)
+}
@@ -0,0 +1,215 @@
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"reddit/pkg/post"
+)
+
+type WebhookDBRepo struct {
+	Subscriptions post.CollectionHelper
+	Deliveries    post.CollectionHelper
+	// LogTTL - журнал доставок, в том числе dead-letter, монга чистит сама
+	LogTTL time.Duration
+	// Timeout - срок одной операции, 0 - post.DefaultTimeout
+	Timeout time.Duration
+}
+
+func (w *WebhookDBRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return post.WithTimeout(ctx, w.Timeout)
+}
+
+func (w *WebhookDBRepo) EnsureIndexesDB() error {
+	err := w.Subscriptions.CreateIndexes(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "category", Value: 1}, {Key: "events", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	return w.Deliveries.CreateIndexes(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttempt", Value: 1}}},
+		{Keys: bson.D{{Key: "subscription", Value: 1}, {Key: "created", Value: -1}}},
+		// у доставок до появления eventId поля нет, они в индекс не попадают
+		{
+			Keys: bson.D{{Key: "eventId", Value: 1}, {Key: "subscription", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"eventId": bson.M{"$exists": true}}),
+		},
+		{
+			Keys:    bson.D{{Key: "created", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(w.LogTTL / time.Second)),
+		},
+	})
+}
+
+func getMongoID(id string, notFound error) (primitive.ObjectID, error) {
+	idMongo, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, notFound
+	}
+	return idMongo, nil
+}
+
+func (w *WebhookDBRepo) AddSubscriptionDB(ctx context.Context, subscription *Subscription) error {
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	subscription.ID = primitive.NewObjectID()
+	_, err := w.Subscriptions.InsertOne(ctx, subscription)
+	return err
+}
+
+func (w *WebhookDBRepo) findSubscriptions(ctx context.Context, filter interface{}) ([]*Subscription, error) {
+	subscriptions := make([]*Subscription, 0)
+	opts := options.Find().SetSort(bson.D{{Key: "created", Value: 1}})
+	result, err := w.Subscriptions.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = result.All(ctx, &subscriptions)
+	if err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+func (w *WebhookDBRepo) GetSubscriptionsDB(ctx context.Context) ([]*Subscription, error) {
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	return w.findSubscriptions(ctx, bson.M{})
+}
+
+// MatchSubscriptionsDB - подписки на категорию и на весь сайт
+func (w *WebhookDBRepo) MatchSubscriptionsDB(ctx context.Context, category, event string) ([]*Subscription, error) {
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	return w.findSubscriptions(ctx, bson.M{"category": bson.M{"$in": []string{"", category}}, "events": event})
+}
+
+func (w *WebhookDBRepo) DeleteSubscriptionDB(ctx context.Context, subscriptionID string) (bool, error) {
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	subscriptionIDMongo, err := getMongoID(subscriptionID, ErrNoSubscription)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := w.Subscriptions.DeleteOne(ctx, bson.M{"_id": subscriptionIDMongo})
+	if err != nil {
+		return false, err
+	}
+	return deleted == 1, nil
+}
+
+// AddDeliveryDB - повтор того же события от relay находит уже созданную доставку и ничего не меняет
+func (w *WebhookDBRepo) AddDeliveryDB(ctx context.Context, delivery *Delivery) error {
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	delivery.ID = primitive.NewObjectID()
+	filter := bson.M{"eventId": delivery.EventID, "subscription": delivery.SubscriptionID}
+	_, err := w.Deliveries.UpdateOne(ctx, filter, bson.M{"$setOnInsert": delivery}, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// параллельный повтор вставил ту же доставку раньше
+		return nil
+	}
+	return err
+}
+
+func (w *WebhookDBRepo) findDeliveries(ctx context.Context, filter interface{}, opts *options.FindOptions) ([]*Delivery, error) {
+	deliveries := make([]*Delivery, 0)
+	result, err := w.Deliveries.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = result.All(ctx, &deliveries)
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// GetDueDeliveriesDB - в том числе зависшие в sending, у которых истек lease
+func (w *WebhookDBRepo) GetDueDeliveriesDB(ctx context.Context, now time.Time, limit int) ([]*Delivery, error) {
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	filter := bson.M{
+		"status":      bson.M{"$in": []string{StatusPending, StatusSending}},
+		"nextAttempt": bson.M{"$lte": now},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "nextAttempt", Value: 1}}).
+		SetLimit(int64(limit))
+	return w.findDeliveries(ctx, filter, opts)
+}
+
+// ClaimDeliveryDB забирает доставку, только если ее никто не тронул с момента выборки
+func (w *WebhookDBRepo) ClaimDeliveryDB(ctx context.Context, delivery *Delivery, until time.Time) (bool, error) {
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	filter := bson.M{"_id": delivery.ID, "status": delivery.Status, "attempts": delivery.Attempts}
+	update := bson.M{
+		"$set": bson.M{"status": StatusSending, "nextAttempt": until},
+		"$inc": bson.M{"attempts": 1},
+	}
+	result, err := w.Deliveries.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+func (w *WebhookDBRepo) UpdateDeliveryDB(ctx context.Context, delivery *Delivery) error {
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	update := bson.M{
+		"$set": bson.M{
+			"status":         delivery.Status,
+			"attempts":       delivery.Attempts,
+			"nextAttempt":    delivery.NextAttempt,
+			"lastStatusCode": delivery.LastStatusCode,
+			"lastError":      delivery.LastError,
+			"updated":        delivery.Updated,
+		},
+	}
+	_, err := w.Deliveries.UpdateOne(ctx, bson.M{"_id": delivery.ID}, update)
+	return err
+}
+
+func (w *WebhookDBRepo) GetDeliveriesDB(ctx context.Context, subscriptionID string, limit int) ([]*Delivery, error) {
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created", Value: -1}}).
+		SetLimit(int64(limit))
+	return w.findDeliveries(ctx, bson.M{"subscription": subscriptionID}, opts)
+}
+
+func (w *WebhookDBRepo) GetDeadDeliveriesDB(ctx context.Context, limit int) ([]*Delivery, error) {
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	opts := options.Find().
+		SetSort(bson.D{{Key: "updated", Value: -1}}).
+		SetLimit(int64(limit))
+	return w.findDeliveries(ctx, bson.M{"status": StatusDead}, opts)
+}
+
+func (w *WebhookDBRepo) ResetDeliveryDB(ctx context.Context, deliveryID string, now time.Time) (bool, error) {
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	deliveryIDMongo, err := getMongoID(deliveryID, ErrNoDelivery)
+	if err != nil {
+		return false, err
+	}
+	update := bson.M{
+		"$set": bson.M{"status": StatusPending, "attempts": 0, "nextAttempt": now, "updated": now},
+	}
+	result, err := w.Deliveries.UpdateOne(ctx, bson.M{"_id": deliveryIDMongo, "status": StatusDead}, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
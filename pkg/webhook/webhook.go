@@ -0,0 +1,197 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"reddit/pkg/post"
+	"reddit/pkg/preview"
+	"reddit/pkg/user"
+)
+
+const (
+	StatusPending = "pending"
+	// StatusSending - доставка забрана отправщиком; если реплика упала, через lease ее заберет другая
+	StatusSending   = "sending"
+	StatusDelivered = "delivered"
+	// StatusDead - все попытки кончились, доставка лежит в dead-letter списке до ручного повтора
+	StatusDead = "dead"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+const (
+	DefaultMaxAttempts = 8
+	// DefaultBaseDelay - пауза перед второй попыткой, дальше она удваивается до maxDelay
+	DefaultBaseDelay = 30 * time.Second
+	// DefaultLogTTL - сколько хранится журнал доставок
+	DefaultLogTTL   = 30 * 24 * time.Hour
+	DefaultWorkers  = 4
+	maxDelay        = 6 * time.Hour
+	deliveryTimeout = 10 * time.Second
+	lease           = 3 * deliveryTimeout
+	dueBatch        = 100
+	maxLogSize      = 100
+	maxErrorSize    = 512
+	secretSize      = 32
+)
+
+// Events - на что можно подписаться, голоса слишком частые для вебхуков
+var Events = []string{post.EventPostAdded, post.EventPostDeleted, post.EventCommentAdded, post.EventCommentDeleted}
+
+// outboxEvents - под каким именем доменное событие уходит подписчикам
+var outboxEvents = map[string]string{
+	post.DomainPostCreated:    post.EventPostAdded,
+	post.DomainPostDeleted:    post.EventPostDeleted,
+	post.DomainCommentAdded:   post.EventCommentAdded,
+	post.DomainCommentDeleted: post.EventCommentDeleted,
+}
+
+var (
+	ErrNoSubscription = errors.New("no webhook subscription found")
+	ErrNoDelivery     = errors.New("no dead delivery found")
+	ErrBlockedHost    = errors.New("webhook host is not allowed")
+)
+
+type WebhookRepo interface {
+	Subscribe(ctx context.Context, author *user.User, form *SubscriptionForm) (*Subscription, error)
+	Subscriptions(ctx context.Context) ([]*Subscription, error)
+	Unsubscribe(ctx context.Context, subscriptionID string) error
+	Deliveries(ctx context.Context, subscriptionID string) ([]*Delivery, error)
+	DeadLetters(ctx context.Context) ([]*Delivery, error)
+	Redeliver(ctx context.Context, deliveryID string) error
+}
+
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Subscription - пустая категория значит весь сайт. Secret отдается только при создании
+type Subscription struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Category  string             `json:"category" bson:"category"`
+	URL       string             `json:"url" bson:"url"`
+	Events    []string           `json:"events" bson:"events"`
+	Secret    string             `json:"secret,omitempty" bson:"secret"`
+	CreatedBy *user.User         `json:"createdBy" bson:"createdBy"`
+	Created   time.Time          `json:"created" bson:"created"`
+}
+
+// Delivery - одно событие для одной подписки, заодно и запись журнала доставок.
+// EventID - id доменного события, на пару событие-подписка доставка одна
+type Delivery struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	EventID        string             `json:"eventId,omitempty" bson:"eventId,omitempty"`
+	SubscriptionID string             `json:"subscriptionId" bson:"subscription"`
+	Event          string             `json:"event" bson:"event"`
+	Payload        string             `json:"payload" bson:"payload"`
+	Status         string             `json:"status" bson:"status"`
+	Attempts       int                `json:"attempts" bson:"attempts"`
+	NextAttempt    time.Time          `json:"nextAttempt" bson:"nextAttempt"`
+	LastStatusCode int                `json:"lastStatusCode,omitempty" bson:"lastStatusCode,omitempty"`
+	LastError      string             `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	Created        time.Time          `json:"created" bson:"created"`
+	Updated        time.Time          `json:"updated" bson:"updated"`
+}
+
+// Payload - тело запроса к подписчику, ID одинаковый у всех подписок одного события
+type Payload struct {
+	ID       string      `json:"id"`
+	Event    string      `json:"event"`
+	Category string      `json:"category"`
+	Created  time.Time   `json:"created"`
+	Data     interface{} `json:"data"`
+}
+
+type SubscriptionForm struct {
+	URL      string   `json:"url" valid:"required,requrl"`
+	Category string   `json:"category" valid:"length(0|300)"`
+	Events   []string `json:"events"`
+}
+
+func (s *SubscriptionForm) Validate() []string {
+	validationErrors := make([]string, 0)
+	_, err := govalidator.ValidateStruct(s)
+	if allErrs, ok := err.(govalidator.Errors); ok {
+		for _, fld := range allErrs {
+			validationErrors = append(validationErrors, fld.Error())
+		}
+	}
+	if parsed, err := url.Parse(s.URL); err == nil {
+		if parsed.Scheme != "http" && parsed.Scheme != "https" {
+			validationErrors = append(validationErrors, "url: only http and https are allowed")
+		}
+		if blockedHost(parsed.Hostname()) {
+			validationErrors = append(validationErrors, "url: local and private addresses are not allowed")
+		}
+	}
+	for _, event := range s.Events {
+		if !knownEvent(event) {
+			validationErrors = append(validationErrors, fmt.Sprintf("unknown event %s", event))
+		}
+	}
+	if len(validationErrors) == 0 {
+		return nil
+	}
+	return validationErrors
+}
+
+// blockedHost отсекает при регистрации то, что видно без резолва. Имена, которые резолвятся
+// во внутреннюю сеть, отсекает уже дозвон при каждой доставке
+func blockedHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && preview.IsBlocked(ip)
+}
+
+func knownEvent(event string) bool {
+	for _, known := range Events {
+		if event == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign - подпись, которую подписчик проверяет у себя: hex(HMAC-SHA256(secret, "<timestamp>.<body>")).
+// Время в подписи не дает повторить старый запрос
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff - пауза после attempts неудачных попыток
+func Backoff(base time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	return delay
+}
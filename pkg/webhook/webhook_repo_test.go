@@ -0,0 +1,311 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reddit/pkg/post"
+	"reddit/pkg/user"
+)
+
+// allowAll - тестовый сервер слушает loopback
+func allowAll(_ net.IP) bool {
+	return false
+}
+
+func TestSubscriptionForm(t *testing.T) {
+	cases := []struct {
+		name  string
+		form  *SubscriptionForm
+		valid bool
+	}{
+		{"подписка на весь сайт", &SubscriptionForm{URL: "https://example.com/hook"}, true},
+		{"подписка на категорию и события", &SubscriptionForm{URL: "http://example.com/hook", Category: "music", Events: []string{post.EventPostAdded}}, true},
+		{"без адреса", &SubscriptionForm{}, false},
+		{"чужая схема", &SubscriptionForm{URL: "ftp://example.com/hook"}, false},
+		{"голоса не отдаются", &SubscriptionForm{URL: "https://example.com/hook", Events: []string{post.EventVote}}, false},
+		{"localhost", &SubscriptionForm{URL: "http://localhost:8080/hook"}, false},
+		{"loopback", &SubscriptionForm{URL: "http://127.0.0.1/hook"}, false},
+		{"внутренняя сеть", &SubscriptionForm{URL: "https://10.0.0.5/hook"}, false},
+		{"ipv6 loopback", &SubscriptionForm{URL: "http://[::1]:8080/hook"}, false},
+		{"метаданные облака", &SubscriptionForm{URL: "http://169.254.169.254/latest"}, false},
+	}
+	for _, testCase := range cases {
+		if errs := testCase.form.Validate(); (len(errs) == 0) != testCase.valid {
+			t.Errorf("%s: unexpected validation result %v", testCase.name, errs)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	expected := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute}
+	for i, delay := range expected {
+		if got := Backoff(DefaultBaseDelay, i+1); got != delay {
+			t.Errorf("attempt %d: expected %s, got %s", i+1, delay, got)
+		}
+	}
+	if got := Backoff(DefaultBaseDelay, 100); got != maxDelay {
+		t.Errorf("expected %s, got %s", maxDelay, got)
+	}
+}
+
+func TestHandleOutbox(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testSubscriptions := post.NewMockCollectionHelper(ctrl)
+	testDeliveries := post.NewMockCollectionHelper(ctrl)
+	testRepo := NewWebhookBusinessLogic(&WebhookDBRepo{Subscriptions: testSubscriptions, Deliveries: testDeliveries})
+
+	// голоса в вебхуки не идут
+	err := testRepo.Handle(context.Background(), post.NewDomainEvent(post.DomainVoteCast, "music", nil))
+	if err != nil {
+		t.Errorf("vote must be skipped: %v", err)
+		return
+	}
+
+	// payload берется из outbox как есть, id события одинаковый у всех повторов
+	event := post.NewDomainEvent(post.DomainPostCreated, "music", nil)
+	event.Payload = `{"title":"fef"}`
+	subscriptionID := primitive.NewObjectID()
+	cursor, err := mongo.NewCursorFromDocuments([]interface{}{&Subscription{ID: subscriptionID, URL: "https://example.com"}}, nil, nil)
+	if err != nil {
+		t.Fatalf("error in cursor")
+	}
+	filter := bson.M{"category": bson.M{"$in": []string{"", "music"}}, "events": post.EventPostAdded}
+	testSubscriptions.EXPECT().Find(gomock.Any(), filter, gomock.Any()).Return(cursor, nil)
+	var added *Delivery
+	deliveryFilter := bson.M{"eventId": event.ID.Hex(), "subscription": subscriptionID.Hex()}
+	testDeliveries.EXPECT().UpdateOne(gomock.Any(), deliveryFilter, gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, _, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+			if len(opts) != 1 || opts[0].Upsert == nil || !*opts[0].Upsert {
+				t.Errorf("delivery must be upserted")
+			}
+			added = update.(bson.M)["$setOnInsert"].(*Delivery)
+			return &mongo.UpdateResult{UpsertedCount: 1}, nil
+		})
+	err = testRepo.Handle(context.Background(), event)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if added.SubscriptionID != subscriptionID.Hex() || added.Status != StatusPending || added.Event != post.EventPostAdded {
+		t.Errorf("wrong delivery: %+v", added)
+		return
+	}
+	payload := &Payload{Data: &post.Post{}}
+	if err = json.Unmarshal([]byte(added.Payload), payload); err != nil {
+		t.Errorf("bad payload: %s", err)
+		return
+	}
+	if payload.ID != event.ID.Hex() || payload.Event != post.EventPostAdded || payload.Category != "music" || payload.Data.(*post.Post).Title != "fef" {
+		t.Errorf("wrong payload: %s", added.Payload)
+		return
+	}
+	select {
+	case <-testRepo.wake:
+	default:
+		t.Errorf("sender must be woken up")
+		return
+	}
+
+	// relay повторил событие: доставка уже есть или ее только что вставил параллельный повтор - дублей нет
+	for _, matched := range []*mongo.UpdateResult{{MatchedCount: 1}, nil} {
+		cursor, err = mongo.NewCursorFromDocuments([]interface{}{&Subscription{ID: subscriptionID, URL: "https://example.com"}}, nil, nil)
+		if err != nil {
+			t.Fatalf("error in cursor")
+		}
+		testSubscriptions.EXPECT().Find(gomock.Any(), filter, gomock.Any()).Return(cursor, nil)
+		var upsertErr error
+		if matched == nil {
+			upsertErr = mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: "duplicate key"}}}
+		}
+		testDeliveries.EXPECT().UpdateOne(gomock.Any(), deliveryFilter, gomock.Any(), gomock.Any()).Return(matched, upsertErr)
+		if err = testRepo.Handle(context.Background(), event); err != nil {
+			t.Errorf("unexpected error: %s", err)
+			return
+		}
+	}
+
+	// ошибка базы возвращается, чтобы relay повторил событие
+	testSubscriptions.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("db_error"))
+	if err = testRepo.Handle(context.Background(), event); err == nil {
+		t.Errorf("expected error, got nil")
+		return
+	}
+}
+
+func TestSendDue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	status := http.StatusInternalServerError
+	secret := "secret"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+		if r.Header.Get(SignatureHeader) != Sign(secret, timestamp, body) || r.Header.Get(EventHeader) != post.EventPostAdded {
+			t.Errorf("bad signature or headers: %v", r.Header)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("boom"))
+	}))
+	defer server.Close()
+
+	testSubscriptions := post.NewMockCollectionHelper(ctrl)
+	testDeliveries := post.NewMockCollectionHelper(ctrl)
+	testRepo := newWebhookBusinessLogic(&WebhookDBRepo{Subscriptions: testSubscriptions, Deliveries: testDeliveries}, allowAll)
+	subscription := &Subscription{ID: primitive.NewObjectID(), URL: server.URL, Secret: secret}
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	// одна попытка: выборка, подписки, захват доставки и запись итога
+	round := func(attempts int, subscriptions []interface{}) bson.M {
+		delivery := &Delivery{
+			ID:             primitive.NewObjectID(),
+			SubscriptionID: subscription.ID.Hex(),
+			Event:          post.EventPostAdded,
+			Payload:        `{"event":"post_added"}`,
+			Status:         StatusPending,
+			Attempts:       attempts,
+			NextAttempt:    now,
+		}
+		dueCursor, err := mongo.NewCursorFromDocuments([]interface{}{delivery}, nil, nil)
+		if err != nil {
+			t.Fatalf("error in cursor")
+		}
+		subscriptionsCursor, err := mongo.NewCursorFromDocuments(subscriptions, nil, nil)
+		if err != nil {
+			t.Fatalf("error in cursor")
+		}
+		testDeliveries.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any()).Return(dueCursor, nil)
+		testSubscriptions.EXPECT().Find(gomock.Any(), bson.M{}, gomock.Any()).Return(subscriptionsCursor, nil)
+		claimFilter := bson.M{"_id": delivery.ID, "status": StatusPending, "attempts": attempts}
+		testDeliveries.EXPECT().UpdateOne(gomock.Any(), claimFilter, gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+		var result bson.M
+		testDeliveries.EXPECT().UpdateOne(gomock.Any(), bson.M{"_id": delivery.ID}, gomock.Any()).DoAndReturn(
+			func(_ context.Context, _, update interface{}, _ ...interface{}) (*mongo.UpdateResult, error) {
+				result = update.(bson.M)["$set"].(bson.M)
+				return &mongo.UpdateResult{MatchedCount: 1}, nil
+			})
+		if _, err = testRepo.SendDue(context.Background(), now); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return result
+	}
+
+	// ошибка подписчика - повтор через BaseDelay
+	result := round(0, []interface{}{subscription})
+	if result["status"] != StatusPending || result["attempts"] != 1 || result["lastStatusCode"] != http.StatusInternalServerError {
+		t.Errorf("wrong result: %v", result)
+		return
+	}
+	if next := result["nextAttempt"].(time.Time); next.Before(now.Add(DefaultBaseDelay)) || next.After(now.Add(DefaultBaseDelay+time.Minute)) {
+		t.Errorf("wrong next attempt: %s", next)
+		return
+	}
+	if result["lastError"] != "unexpected status 500: boom" {
+		t.Errorf("wrong error: %v", result["lastError"])
+		return
+	}
+
+	// последняя попытка - в dead-letter
+	result = round(DefaultMaxAttempts-1, []interface{}{subscription})
+	if result["status"] != StatusDead || result["attempts"] != DefaultMaxAttempts {
+		t.Errorf("wrong result: %v", result)
+		return
+	}
+
+	// подписку удалили - сразу в dead-letter, запросов нет
+	result = round(0, []interface{}{})
+	if result["status"] != StatusDead || result["lastError"] != ErrNoSubscription.Error() {
+		t.Errorf("wrong result: %v", result)
+		return
+	}
+
+	// доставлено
+	status = http.StatusNoContent
+	result = round(2, []interface{}{subscription})
+	if result["status"] != StatusDelivered || result["lastError"] != "" {
+		t.Errorf("wrong result: %v", result)
+		return
+	}
+
+	// адрес подписки резолвится в loopback - настоящий клиент туда не ходит
+	testRepo.Client = NewWebhookBusinessLogic(nil).Client
+	result = round(0, []interface{}{subscription})
+	if result["status"] != StatusPending || result["lastError"] != ErrBlockedHost.Error() {
+		t.Errorf("wrong result: %v", result)
+		return
+	}
+}
+
+func TestRedeliver(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testDeliveries := post.NewMockCollectionHelper(ctrl)
+	testRepo := NewWebhookBusinessLogic(&WebhookDBRepo{Deliveries: testDeliveries})
+
+	if err := testRepo.Redeliver(context.Background(), "bad_id"); !errors.Is(err, ErrNoDelivery) {
+		t.Errorf("expected ErrNoDelivery, got %v", err)
+		return
+	}
+
+	objID := primitive.NewObjectID()
+	filter := bson.M{"_id": objID, "status": StatusDead}
+	testDeliveries.EXPECT().UpdateOne(gomock.Any(), filter, gomock.Any()).Return(&mongo.UpdateResult{}, nil)
+	if err := testRepo.Redeliver(context.Background(), objID.Hex()); !errors.Is(err, ErrNoDelivery) {
+		t.Errorf("expected ErrNoDelivery, got %v", err)
+		return
+	}
+
+	testDeliveries.EXPECT().UpdateOne(gomock.Any(), filter, gomock.Any()).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	if err := testRepo.Redeliver(context.Background(), objID.Hex()); err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testSubscriptions := post.NewMockCollectionHelper(ctrl)
+	testRepo := NewWebhookBusinessLogic(&WebhookDBRepo{Subscriptions: testSubscriptions})
+
+	testSubscriptions.EXPECT().InsertOne(gomock.Any(), gomock.Any()).Return(nil, nil)
+	subscription, err := testRepo.Subscribe(context.Background(), &user.User{ID: "user_id"}, &SubscriptionForm{URL: "https://example.com"})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+		return
+	}
+	if len(subscription.Secret) != 2*secretSize || len(subscription.Events) != len(Events) || subscription.ID.IsZero() {
+		t.Errorf("wrong subscription: %+v", subscription)
+		return
+	}
+
+	// в списке секретов нет
+	cursor, err := mongo.NewCursorFromDocuments([]interface{}{subscription}, nil, nil)
+	if err != nil {
+		t.Fatalf("error in cursor")
+	}
+	testSubscriptions.EXPECT().Find(gomock.Any(), bson.M{}, gomock.Any()).Return(cursor, nil)
+	subscriptions, err := testRepo.Subscriptions(context.Background())
+	if err != nil || len(subscriptions) != 1 || subscriptions[0].Secret != "" {
+		t.Errorf("unexpected result: %v, %v", subscriptions, err)
+		return
+	}
+}